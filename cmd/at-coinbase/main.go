@@ -4,6 +4,7 @@ import (
 	"context"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker/coinbase"
+	"github.com/sklinkert/at/internal/broker/shadow"
 	"github.com/sklinkert/at/internal/paperwallet"
 	"github.com/sklinkert/at/internal/strategy/rsiadx"
 	"github.com/sklinkert/at/internal/trader"
@@ -21,7 +22,7 @@ func mustConnectDB() *gorm.DB {
 	if err != nil {
 		log.WithError(err).Fatal("failed to connect database")
 	}
-	if err := db.AutoMigrate(&tick.Tick{}, &ohlc.OHLC{}, &trader.PerformanceRecord{}); err != nil {
+	if err := db.AutoMigrate(&tick.Tick{}, &ohlc.OHLC{}, &trader.PerformanceRecord{}, &trader.OrderRejection{}, &trader.TradingHalt{}, &trader.StrategySnapshot{}, &trader.JournalEntry{}); err != nil {
 		log.WithError(err).Fatal("db.AutoMigrate() failed")
 	}
 	return db
@@ -34,14 +35,18 @@ func main() {
 	strategyBackend := rsiadx.New(instrument, candleDuration)
 	wallet := paperwallet.New()
 	brokerBackend := coinbase.New(instrument, wallet)
+	shadowBroker := shadow.New(brokerBackend, wallet) // paper trading with live prices
 
 	db := mustConnectDB()
 
 	tr := trader.New(ctx, instrument, "", db,
-		trader.WithBroker(brokerBackend),
+		trader.WithBroker(shadowBroker),
 		trader.WithPersistCandleData(true),
+		trader.WithJournal(),
 		trader.WithStrategy(strategyBackend),
 		trader.WithFeedStoredCandles(strategyBackend),
+		trader.WithWarmUp(brokerBackend.WarmUpProvider()),
+		trader.WithFeedSupervision(time.Minute),
 	)
 	if err := tr.Start(); err != nil {
 		log.WithError(err).Fatal("failed to start trader")
@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"github.com/lfritz/env"
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/internal/broker/ig"
+	"github.com/sklinkert/at/internal/broker/shadow"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/internal/portfolio"
 	"github.com/sklinkert/at/internal/strategy"
 	heikinashi "github.com/sklinkert/at/internal/strategy/HeikinAshi"
 	"github.com/sklinkert/at/internal/strategy/doji"
@@ -21,6 +27,9 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -34,6 +43,7 @@ var conf struct {
 	broker                 string
 	gatherPerformanceData  bool
 	instrument             string
+	instruments            []string
 	debug                  bool
 	dbHost                 string
 	dbUser                 string
@@ -41,6 +51,7 @@ var conf struct {
 	dbName                 string
 	dbPort                 int
 	strategyName           string
+	strategyNames          []string
 	priceDBFile            string
 	yearFrom               int
 	yearTo                 int
@@ -51,6 +62,21 @@ var conf struct {
 	igPassword             string
 	igAccountID            string
 	currencyCode           string
+	risk                   trader.RiskLimits
+	halt                   trader.HaltConditions
+	resetHalt              bool
+	calendar               string
+	timezone               string
+	dayStart               string
+	candleAnchor           string
+	flatCandles            bool
+	calendarFiles          []string
+	checkpointInterval     string
+	feedStaleAfter         string
+	feedSessions           []string
+	shadowMode             string
+	shadowPriceTolerance   float64
+	shadowSummaryInterval  string
 }
 
 func mustConnectDB() *gorm.DB {
@@ -66,14 +92,15 @@ func mustConnectDB() *gorm.DB {
 	if err != nil {
 		log.WithError(err).Fatal("failed to connect database")
 	}
-	if err := db.AutoMigrate(&tick.Tick{}, &ohlc.OHLC{}, &trader.PerformanceRecord{}); err != nil {
+	if err := db.AutoMigrate(&tick.Tick{}, &ohlc.OHLC{}, &trader.PerformanceRecord{}, &trader.OrderRejection{}, &trader.TradingHalt{}, &trader.StrategySnapshot{}, &trader.JournalEntry{}); err != nil {
 		log.WithError(err).Fatal("db.AutoMigrate() failed")
 	}
 	return db
 }
 
 func main() {
-	var ctx = context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	var err error
 	var e = env.New()
@@ -85,7 +112,34 @@ func main() {
 	e.OptionalString("CURRENCY_CODE", &conf.currencyCode, "EUR", "Currency code")
 	e.OptionalString("BROKER", &conf.broker, "none", "Broker backend")
 	e.OptionalString("STRATEGY", &conf.strategyName, "meanreversion", "strategy to be executed")
+	e.OptionalList("INSTRUMENTS", &conf.instruments, ",", []string{}, "Instruments traded as a portfolio on one IG price feed, overrides INSTRUMENT")
+	e.OptionalList("STRATEGIES", &conf.strategyNames, ",", []string{}, "Strategies of the portfolio, each runs on every instrument, overrides STRATEGY")
 	e.OptionalString("CANDLE_DURATION", &conf.candleDuration, "60m", "Duration for OHLC candle")
+	e.OptionalInt("RISK_MAX_OPEN_POSITIONS", &conf.risk.MaxOpenPositions, 0, "Reject orders above this number of open positions, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_EXPOSURE", &conf.risk.MaxExposure, 0, "Max size of open positions per instrument and direction, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_DAILY_LOSS_PERCENT", &conf.risk.MaxDailyLossInPercent, 0, "Stop opening positions for the day after this loss in percent, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_ORDER_SIZE", &conf.risk.MaxOrderSize, 0, "Reject bigger orders, 0 disables the check")
+	e.Flag("RISK_PRICE_SANITY", &conf.risk.PriceSanity, "Reject orders after price jumps of more than 0.5% between two ticks")
+	e.OptionalString("RISK_TRADING_WINDOW", &conf.risk.TradingWindow, "", "Only open positions within this time of day, e.g. '08:00-20:00'")
+	e.OptionalList("RISK_TRADING_DAYS", &conf.risk.TradingDays, ",", []string{}, "Only open positions on these weekdays, e.g. 'Mon,Tue,Wed,Thu,Fri'")
+	e.OptionalString("RISK_TIMEZONE", &conf.risk.Timezone, "UTC", "Timezone of RISK_TRADING_WINDOW and RISK_TRADING_DAYS")
+	e.OptionalFloat("HALT_MAX_INTRADAY_LOSS_PIPS", &conf.halt.MaxIntradayLossInPips, 0, "Halt trading after this loss within a day, 0 disables the check")
+	e.OptionalFloat("HALT_MAX_DRAWDOWN_PIPS", &conf.halt.MaxDrawdownInPips, 0, "Halt trading after this drawdown from the equity peak, 0 disables the check")
+	e.OptionalInt("HALT_MAX_CONSECUTIVE_LOSSES", &conf.halt.MaxConsecutiveLosses, 0, "Halt trading after this number of consecutive losses, 0 disables the check")
+	e.Flag("HALT_FLATTEN", &conf.halt.FlattenPositions, "Close all positions when trading is halted")
+	e.Flag("RESET_HALT", &conf.resetHalt, "Reset a halt of the trader on start")
+	e.OptionalString("TIMEZONE", &conf.timezone, ohlc.DefaultTimezone, "Timezone of candles and reports")
+	e.OptionalString("DAY_START", &conf.dayStart, "00:00", "Time of day in TIMEZONE at which daily candles and returns begin, e.g. '17:00' for forex in America/New_York")
+	e.OptionalString("CANDLE_ANCHOR", &conf.candleAnchor, "", "Align candles to this RFC 3339 time instead of the beginning of the day")
+	e.Flag("FLAT_CANDLES", &conf.flatCandles, "Build candles for intervals without ticks from the previous close")
+	e.OptionalString("CALENDAR", &conf.calendar, calendar.NameForex, "Market hours: 'forex', 'nyse', 'always' or a JSON calendar config file")
+	e.OptionalList("CALENDAR_FILES", &conf.calendarFiles, ",", []string{}, "CSV or ICS files with holidays, early closes and blackouts")
+	e.OptionalString("CHECKPOINT_INTERVAL", &conf.checkpointInterval, "", "Save the strategy's state this often and restore it on restart, e.g. '5m'; empty disables checkpoints")
+	e.OptionalString("FEED_STALE_AFTER", &conf.feedStaleAfter, "", "Reconnect the price feed after this time without ticks while the market is open, e.g. '2m'; empty disables the supervision")
+	e.OptionalList("FEED_SESSIONS", &conf.feedSessions, ",", []string{}, "Other FEED_STALE_AFTER thresholds by time of day in TIMEZONE, e.g. '22:00-07:00=10m'")
+	e.OptionalString("SHADOW_MODE", &conf.shadowMode, "", "'paper' executes orders with a paperwallet at IG prices; 'parallel' sends REAL orders to IG as well and reports divergences of the fills")
+	e.OptionalFloat("SHADOW_PRICE_TOLERANCE", &conf.shadowPriceTolerance, 0, "Ignore differences of simulated and real fill prices up to this amount")
+	e.OptionalString("SHADOW_SUMMARY_INTERVAL", &conf.shadowSummaryInterval, "1h", "Log a summary of the shadow divergences this often and on shutdown")
 	e.OptionalString("IG_API_URL", &conf.igAPIURL, igmarkets.DemoAPIURL, "IG API URL")
 	e.OptionalString("IG_IDENTIFIER", &conf.igIdentifier, "", "IG Identifier")
 	e.OptionalString("IG_API_KEY", &conf.igAPIKey, "", "IG API key")
@@ -113,45 +167,228 @@ func main() {
 		log.WithError(err).Fatal("cannot parse candle duration")
 	}
 
-	var strategyBackend strategy.Strategy
-	switch conf.strategyName {
-	case strategy.NameDOJI:
-		strategyBackend = doji.New(conf.instrument)
-	case strategy.NameHeikinAshi:
-		strategyBackend = heikinashi.New(conf.instrument)
-	case strategy.NameScalper:
-		strategyBackend = scalper.New(conf.instrument)
-	case strategy.NameStochRSI:
-		strategyBackend = stochrsi.New(conf.instrument)
-	case strategy.NameLowCandle:
-		strategyBackend = lowcandle.New(conf.instrument, candleDuration)
-	case strategy.NameRSI:
-		strategyBackend = rsi.New(conf.instrument, candleDuration)
-	case strategy.NameRSIADX:
-		strategyBackend = rsiadx.New(conf.instrument, candleDuration)
-	default:
-		log.Fatalf("unsupported strategy %q", conf.strategyName)
+	if len(conf.instruments) == 0 {
+		conf.instruments = []string{conf.instrument}
+	}
+	if len(conf.strategyNames) == 0 {
+		conf.strategyNames = []string{conf.strategyName}
 	}
 
 	log.Info("Starting broker ", conf.broker)
 
-	brokerBackend, err := ig.New(conf.instrument, conf.igAPIURL, conf.igAPIKey, conf.igAccountID,
-		conf.igIdentifier, conf.igPassword)
+	tradingDay, err := ohlc.NewTradingDay(conf.timezone, conf.dayStart)
+	if err != nil {
+		log.WithError(err).Fatal("invalid timezone or start of day")
+	}
+
+	calendars, err := calendar.Load(conf.calendar)
+	if err != nil {
+		log.WithError(err).Fatal("cannot load calendar")
+	}
+	for _, marketCalendar := range calendars.All() {
+		for _, file := range conf.calendarFiles {
+			if err := marketCalendar.LoadFile(file); err != nil {
+				log.WithError(err).Fatal("cannot load calendar file")
+			}
+		}
+	}
+
+	// All traders share one IG session and price feed, subscribed during the hours of the first instrument
+	brokerBackend, err := ig.New(conf.instruments[0], conf.igAPIURL, conf.igAPIKey, conf.igAccountID,
+		conf.igIdentifier, conf.igPassword, ig.WithCalendar(calendars.For(conf.instruments[0])),
+		ig.WithInstruments(conf.instruments[1:]...))
 	if err != nil {
 		log.WithError(err).Fatal("ig.New() failed")
 	}
 
+	var orderBroker broker.Broker = brokerBackend
+	var shadowBroker *shadow.Shadow
+	switch conf.shadowMode {
+	case "":
+	case "paper", "parallel":
+		var shadowOptions = []shadow.Option{shadow.WithPriceTolerance(decimal.NewFromFloat(conf.shadowPriceTolerance))}
+		if conf.shadowMode == "parallel" {
+			log.Warn("SHADOW_MODE=parallel: orders are sent to IG as well")
+			shadowOptions = append(shadowOptions, shadow.WithExecution())
+		}
+		shadowBroker = shadow.New(brokerBackend, paperwallet.New(), shadowOptions...)
+		orderBroker = shadowBroker
+		interval, err := time.ParseDuration(conf.shadowSummaryInterval)
+		if err != nil {
+			log.WithError(err).Fatal("cannot parse shadow summary interval")
+		}
+		go logShadowSummary(ctx, shadowBroker, interval)
+	default:
+		log.Fatalf("unsupported shadow mode %q", conf.shadowMode)
+	}
+
+	riskRules, err := conf.risk.Rules()
+	if err != nil {
+		log.WithError(err).Fatal("invalid risk limits")
+	}
+
 	db := mustConnectDB()
 
-	tr := trader.New(ctx, conf.instrument, GitRev, db,
-		trader.WithBroker(brokerBackend),
+	var options = []trader.Option{
+		trader.WithBroker(orderBroker),
 		trader.WithPersistCandleData(true),
-		trader.WithStrategy(strategyBackend),
-		trader.WithFeedStoredCandles(strategyBackend),
+		trader.WithJournal(),
+		trader.WithWarmUp(brokerBackend.WarmUpProvider()),
 		trader.WithCurrencyCode(conf.currencyCode),
-	)
+		trader.WithRiskRules(riskRules...),
+		trader.WithHaltConditions(conf.halt),
+		trader.WithTradingDay(tradingDay),
+		trader.WithCandleTimer(time.Second),
+	}
+	if conf.candleAnchor != "" {
+		anchor, err := time.Parse(time.RFC3339, conf.candleAnchor)
+		if err != nil {
+			log.WithError(err).Fatal("invalid candle anchor")
+		}
+		options = append(options, trader.WithCandleAnchor(anchor))
+	}
+	if conf.flatCandles {
+		options = append(options, trader.WithFlatCandles())
+	}
+	if conf.checkpointInterval != "" {
+		interval, err := time.ParseDuration(conf.checkpointInterval)
+		if err != nil {
+			log.WithError(err).Fatal("cannot parse checkpoint interval")
+		}
+		options = append(options, trader.WithCheckpoints(interval))
+	}
+	if conf.feedStaleAfter != "" {
+		staleAfter, err := time.ParseDuration(conf.feedStaleAfter)
+		if err != nil {
+			log.WithError(err).Fatal("cannot parse feed stale threshold")
+		}
+		var sessions []trader.FeedSession
+		for _, s := range conf.feedSessions {
+			session, err := trader.ParseFeedSession(s)
+			if err != nil {
+				log.WithError(err).Fatal("cannot parse feed session")
+			}
+			sessions = append(sessions, session)
+		}
+		options = append(options, trader.WithFeedSupervision(staleAfter, sessions...))
+	}
+
+	var portfolioMode = len(conf.instruments)*len(conf.strategyNames) > 1
+	var traders []*trader.Trader
+	for _, strategyName := range conf.strategyNames {
+		for _, instrument := range conf.instruments {
+			strategyBackend := newStrategy(strategyName, instrument, candleDuration)
+			traderOptions := append(append([]trader.Option{}, options...),
+				trader.WithStrategy(strategyBackend),
+				trader.WithFeedStoredCandles(strategyBackend),
+				trader.WithCalendar(calendars.For(instrument)))
+			if portfolioMode {
+				traderOptions = append(traderOptions, trader.WithInstruments(instrument))
+			}
+			if len(conf.strategyNames) > 1 {
+				traderOptions = append(traderOptions, trader.WithOwner(strategyName+"/"+instrument))
+			}
+			tr := trader.New(ctx, instrument, GitRev, db, traderOptions...)
+			if conf.resetHalt {
+				if err := tr.ResetHalt(); err != nil {
+					log.WithError(err).Fatal("cannot reset trading halt")
+				}
+				log.Infof("Trading halt of %s has been reset", tr.ID())
+			}
+			traders = append(traders, tr)
+		}
+	}
+
+	if portfolioMode {
+		runPortfolio(ctx, orderBroker, traders, shadowBroker)
+		return
+	}
+
+	tr := traders[0]
+	go func() {
+		<-ctx.Done()
+		if err := tr.Checkpoint(); err != nil {
+			log.WithError(err).Error("cannot save checkpoint")
+		}
+		tr.Events().Close() // writes the queued journal entries
+		if shadowBroker != nil {
+			shadowBroker.Summary()
+		}
+		os.Exit(0)
+	}()
 	if err := tr.Start(); err != nil {
 		log.WithError(err).Fatal("failed to start trader")
 	}
 	tr.Summary()
+	if shadowBroker != nil {
+		shadowBroker.Summary()
+	}
+}
+
+// runPortfolio fans the price feed of the broker out to the traders. On shutdown the traders process
+// their last ticks, save a checkpoint and write the queued journal entries.
+func runPortfolio(ctx context.Context, orderBroker broker.Broker, traders []*trader.Trader, shadowBroker *shadow.Shadow) {
+	var options []portfolio.Option
+	for _, tr := range traders {
+		options = append(options, portfolio.WithTrader(tr))
+	}
+	p := portfolio.New(orderBroker, options...)
+
+	go func() {
+		<-ctx.Done()
+		if err := p.Stop(); err != nil {
+			log.WithError(err).Error("cannot stop portfolio")
+		}
+		p.Summary()
+		if shadowBroker != nil {
+			shadowBroker.Summary()
+		}
+		os.Exit(0)
+	}()
+	if err := p.Start(); err != nil {
+		log.WithError(err).Fatal("failed to start portfolio")
+	}
+	p.Summary()
+	if shadowBroker != nil {
+		shadowBroker.Summary()
+	}
+}
+
+func newStrategy(strategyName, instrument string, candleDuration time.Duration) strategy.Strategy {
+	switch strategyName {
+	case strategy.NameDOJI:
+		return doji.New(instrument)
+	case strategy.NameHeikinAshi:
+		return heikinashi.New(instrument)
+	case strategy.NameScalper:
+		return scalper.New(instrument)
+	case strategy.NameStochRSI:
+		return stochrsi.New(instrument)
+	case strategy.NameLowCandle:
+		return lowcandle.New(instrument, candleDuration)
+	case strategy.NameRSI:
+		return rsi.New(instrument, candleDuration)
+	case strategy.NameRSIADX:
+		return rsiadx.New(instrument, candleDuration)
+	default:
+		log.Fatalf("unsupported strategy %q", strategyName)
+	}
+
+	// Never reached
+	return nil
+}
+
+// logShadowSummary logs the divergences of the shadow broker every interval until ctx is done
+func logShadowSummary(ctx context.Context, shadowBroker *shadow.Shadow, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			shadowBroker.Summary()
+		}
+	}
 }
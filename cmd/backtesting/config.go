@@ -0,0 +1,424 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker/backtest"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/portfolio"
+	"github.com/sklinkert/at/internal/sizing"
+	"github.com/sklinkert/at/internal/trader"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	fillModelOHLC  = "ohlc"  // candles are replayed as open, high, low and close ticks
+	fillModelTicks = "ticks" // ticks with bid and ask
+)
+
+// runConfig contains everything required to reproduce a run. It's stored with the results.
+type runConfig struct {
+	RunID             string
+	ParentRunID       string // set for segments of a validation run
+	Segment           string
+	GitRev            string
+	Instruments       []string
+	Strategy          string
+	Strategies        []string          // several strategies run as portfolio, overrides Strategy
+	StrategyParams    map[string]string // trader key -> strategy.String()
+	CandleDuration    string
+	PeriodFrom        time.Time
+	PeriodTo          time.Time
+	PriceSource       string
+	PriceDBFile       string
+	PriceDBDuration   string // of the stored candles, 1m if empty
+	HistDataCSVFiles  []string
+	CSVFiles          []string
+	CSV               csvConfig
+	Benchmark         benchmarkConfig
+	InitialBalance    decimal.Decimal
+	TradingFeePercent decimal.Decimal
+	Slippage          decimal.Decimal
+	Spread            decimal.Decimal
+	FillModel         string
+	Seed              int64
+	Validation        validationConfig
+	Allocation        allocationConfig
+	Risk              trader.RiskLimits
+	Halt              trader.HaltConditions
+	Sizing            sizingConfig
+	Calendar          calendarConfig
+	Timezone          string // of candles, reports and charts, Europe/Berlin if empty
+	DayStart          string // time of day at which daily candles begin, e.g. "17:00"
+	CandleAnchor      string // RFC 3339 time candles are aligned to, the beginning of the day if empty
+	FlatCandles       bool   // candles for intervals without ticks
+}
+
+// calendarConfig selects the market hours of the instruments. An empty name trades at any time.
+type calendarConfig struct {
+	Name  string   // built-in calendar or JSON config file
+	Files []string // CSV or ICS files with holidays, early closes and blackouts for every calendar
+}
+
+// sizingConfig selects how order sizes are computed from the balance. An empty method keeps the
+// sizes of the strategy.
+type sizingConfig struct {
+	Method         string
+	RiskPercent    float64
+	Notional       float64
+	ATRPeriod      int
+	ATRMultiple    float64
+	KellyFraction  float64
+	KellyMinTrades int
+	LotStep        float64
+	MinSize        float64
+}
+
+// allocationConfig selects the capital allocation of portfolio runs
+type allocationConfig struct {
+	Method               string
+	Weights              map[string]float64 // trader key -> weight for fixed allocation
+	Lookback             string
+	EquityCurveTrades    int
+	EquityCurveReduction float64
+}
+
+// validationConfig splits the period into segments which are run on their own
+type validationConfig struct {
+	Method             string
+	OutOfSamplePercent float64
+	Folds              int
+	Purge              string
+}
+
+// benchmarkConfig selects the buy and hold benchmark. An empty instrument is the traded instrument.
+type benchmarkConfig struct {
+	Instrument  string
+	PriceDBFile string
+	CSVFiles    []string
+}
+
+type csvConfig struct {
+	Columns        string
+	Delimiter      string
+	Header         bool
+	TimeFormat     string
+	Timezone       string
+	Pricing        string
+	Ticks          bool
+	CandleDuration string
+}
+
+func newRunConfig() runConfig {
+	var instruments = conf.instruments
+	if len(instruments) == 0 {
+		instruments = []string{conf.instrument}
+	}
+
+	var seed = int64(conf.seed)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rc := runConfig{
+		RunID:             newRunID(),
+		GitRev:            GitRev,
+		Instruments:       instruments,
+		Strategy:          conf.strategyName,
+		Strategies:        conf.strategyNames,
+		StrategyParams:    map[string]string{},
+		CandleDuration:    conf.candleDuration,
+		PeriodFrom:        time.Date(conf.yearFrom, time.Month(conf.monthFrom), 1, 0, 0, 0, 0, time.UTC),
+		PeriodTo:          time.Date(conf.yearTo, time.Month(conf.monthTo), 31, 23, 23, 59, 0, time.UTC),
+		PriceSource:       conf.priceSource,
+		PriceDBFile:       conf.priceDBFile,
+		PriceDBDuration:   conf.priceDBDuration,
+		HistDataCSVFiles:  conf.importHistDataCSVFiles,
+		CSVFiles:          conf.csvFiles,
+		InitialBalance:    decimal.NewFromFloat(conf.initialBalance),
+		TradingFeePercent: decimal.NewFromFloat(conf.tradingFeePercent),
+		Slippage:          decimal.NewFromFloat(conf.slippage),
+		Spread:            decimal.NewFromFloat(conf.spread),
+		FillModel:         fillModelOHLC,
+		Benchmark: benchmarkConfig{
+			Instrument:  conf.benchmark,
+			PriceDBFile: conf.benchmarkPriceDBFile,
+			CSVFiles:    conf.benchmarkCSVFiles,
+		},
+		Seed: seed,
+		Validation: validationConfig{
+			Method:             conf.validation,
+			OutOfSamplePercent: conf.outOfSamplePercent,
+			Folds:              conf.folds,
+			Purge:              conf.purge,
+		},
+		Risk: conf.risk,
+		Halt: conf.halt,
+		Sizing: sizingConfig{
+			Method:         conf.sizing,
+			RiskPercent:    conf.sizingRiskPercent,
+			Notional:       conf.sizingNotional,
+			ATRPeriod:      conf.sizingATRPeriod,
+			ATRMultiple:    conf.sizingATRMultiple,
+			KellyFraction:  conf.sizingKellyFraction,
+			KellyMinTrades: conf.sizingKellyMinTrades,
+			LotStep:        conf.lotStep,
+			MinSize:        conf.minSize,
+		},
+		Allocation: allocationConfig{
+			Method:               conf.allocation,
+			Weights:              parseWeights(conf.allocationWeights),
+			Lookback:             conf.allocationLookback,
+			EquityCurveTrades:    conf.equityCurveTrades,
+			EquityCurveReduction: conf.equityCurveReduction,
+		},
+		Timezone:     conf.timezone,
+		DayStart:     conf.dayStart,
+		CandleAnchor: conf.candleAnchor,
+		FlatCandles:  conf.flatCandles,
+		Calendar: calendarConfig{
+			Name:  conf.calendar,
+			Files: conf.calendarFiles,
+		},
+		CSV: csvConfig{
+			Columns:        conf.csvColumns,
+			Delimiter:      conf.csvDelimiter,
+			Header:         conf.csvHeader,
+			TimeFormat:     conf.csvTimeFormat,
+			Timezone:       conf.csvTimezone,
+			Pricing:        conf.csvPricing,
+			Ticks:          conf.csvTicks,
+			CandleDuration: conf.csvCandleDuration,
+		},
+	}
+	if rc.PriceSource == "CSV" && rc.CSV.Ticks {
+		rc.FillModel = fillModelTicks
+	}
+
+	return rc
+}
+
+// parseWeights parses weights like "EURUSD=2,GBPUSD=1"
+func parseWeights(weights string) map[string]float64 {
+	var parsed = map[string]float64{}
+	if weights == "" {
+		return parsed
+	}
+	for _, mapping := range strings.Split(weights, ",") {
+		key, value, found := strings.Cut(mapping, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !found || err != nil {
+			log.Fatalf("invalid allocation weight %q", mapping)
+		}
+		parsed[strings.TrimSpace(key)] = weight
+	}
+	return parsed
+}
+
+// traderSpec describes one trader of a run
+type traderSpec struct {
+	Key        string // owner or instrument if only one strategy is used
+	Owner      string
+	Strategy   string
+	Instrument string
+}
+
+// traders returns a trader for each strategy and instrument. Traders get an owner if several
+// strategies share an instrument.
+func (rc runConfig) traders() []traderSpec {
+	var strategies = rc.Strategies
+	if len(strategies) == 0 {
+		strategies = []string{rc.Strategy}
+	}
+
+	var specs []traderSpec
+	for _, strategyName := range strategies {
+		for _, instrument := range rc.Instruments {
+			spec := traderSpec{Key: instrument, Strategy: strategyName, Instrument: instrument}
+			if len(strategies) > 1 {
+				spec.Owner = strategyName + "/" + instrument
+				spec.Key = spec.Owner
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// allocator returns the configured capital allocation or nil
+func (c allocationConfig) allocator() portfolio.Allocator {
+	switch c.Method {
+	case "":
+		return nil
+	case portfolio.AllocationFixed:
+		return portfolio.FixedWeights(c.Weights)
+	case portfolio.AllocationRiskParity:
+		lookback, err := time.ParseDuration(c.Lookback)
+		if err != nil {
+			log.WithError(err).Fatal("cannot parse allocation lookback")
+		}
+		return portfolio.RiskParity(lookback)
+	case portfolio.AllocationEquityCurve:
+		return portfolio.EquityCurve(c.EquityCurveTrades, c.EquityCurveReduction)
+	default:
+		log.Fatalf("unsupported allocation %q", c.Method)
+	}
+	return nil
+}
+
+// priceDBDuration returns the duration of the candles read from the price DB
+func (rc runConfig) priceDBDuration() (time.Duration, error) {
+	if rc.PriceDBDuration == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(rc.PriceDBDuration)
+}
+
+// tradingDay returns the timezone and beginning of days of the run
+func (rc runConfig) tradingDay() (ohlc.TradingDay, error) {
+	var timezone = rc.Timezone
+	if timezone == "" {
+		timezone = ohlc.DefaultTimezone
+	}
+	return ohlc.NewTradingDay(timezone, rc.DayStart)
+}
+
+// candleOptions returns the trader options for the alignment of candles and flat candles
+func (rc runConfig) candleOptions() ([]trader.Option, error) {
+	var options []trader.Option
+	if rc.CandleAnchor != "" {
+		anchor, err := time.Parse(time.RFC3339, rc.CandleAnchor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candle anchor %q: %w", rc.CandleAnchor, err)
+		}
+		options = append(options, trader.WithCandleAnchor(anchor))
+	}
+	if rc.FlatCandles {
+		options = append(options, trader.WithFlatCandles())
+	}
+	return options, nil
+}
+
+// calendars returns the configured calendars or nil
+func (c calendarConfig) calendars() (*calendar.Calendars, error) {
+	if c.Name == "" {
+		return nil, nil
+	}
+	calendars, err := calendar.Load(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, cal := range calendars.All() {
+		for _, file := range c.Files {
+			if err := cal.LoadFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return calendars, nil
+}
+
+// sizer returns the configured position sizing or nil
+func (c sizingConfig) sizer() *sizing.Sizer {
+	var method sizing.Method
+	switch c.Method {
+	case "":
+		return nil
+	case sizing.MethodFixedFractional:
+		method = sizing.FixedFractional(c.RiskPercent)
+	case sizing.MethodFixedNotional:
+		method = sizing.FixedNotional(c.Notional)
+	case sizing.MethodVolatility:
+		method = sizing.Volatility(c.RiskPercent, c.ATRMultiple)
+	case sizing.MethodKelly:
+		method = sizing.Kelly(c.KellyFraction, c.KellyMinTrades, c.RiskPercent)
+	default:
+		log.Fatalf("unsupported sizing %q", c.Method)
+	}
+	return sizing.New(method,
+		sizing.WithLotStep(c.LotStep),
+		sizing.WithMinSize(c.MinSize),
+		sizing.WithATRPeriod(c.ATRPeriod),
+	)
+}
+
+// recordKey identifies the trader of a performance record within its run
+func recordKey(record *trader.PerformanceRecord) string {
+	if record.Owner != "" {
+		return record.Owner
+	}
+	return record.Instrument
+}
+
+// newRunID returns a unique, sortable ID
+func newRunID() string {
+	var suffix = make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		log.WithError(err).Fatal("cannot generate run ID")
+	}
+	return time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+func (c csvConfig) format() backtest.CSVFormat {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		log.WithError(err).Fatalf("cannot load timezone %q", c.Timezone)
+	}
+	candleDuration, err := time.ParseDuration(c.CandleDuration)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse CSV candle duration")
+	}
+
+	var format = backtest.CSVFormat{
+		HasHeader:      c.Header,
+		TimeFormat:     c.TimeFormat,
+		Location:       loc,
+		Ticks:          c.Ticks,
+		CandleDuration: candleDuration,
+	}
+	if delimiter := []rune(c.Delimiter); len(delimiter) > 0 {
+		format.Delimiter = delimiter[0]
+	}
+
+	switch strings.ToUpper(c.Pricing) {
+	case "BID":
+		format.Pricing = backtest.CSVPricingBid
+	case "ASK":
+		format.Pricing = backtest.CSVPricingAsk
+	default:
+		format.Pricing = backtest.CSVPricingMid
+	}
+
+	var columns = map[string]*string{
+		"date":      &format.Columns.Date,
+		"time":      &format.Columns.Time,
+		"open":      &format.Columns.Open,
+		"high":      &format.Columns.High,
+		"low":       &format.Columns.Low,
+		"close":     &format.Columns.Close,
+		"ask_open":  &format.Columns.AskOpen,
+		"ask_high":  &format.Columns.AskHigh,
+		"ask_low":   &format.Columns.AskLow,
+		"ask_close": &format.Columns.AskClose,
+		"bid":       &format.Columns.Bid,
+		"ask":       &format.Columns.Ask,
+		"price":     &format.Columns.Price,
+		"volume":    &format.Columns.Volume,
+	}
+	for _, mapping := range strings.Split(c.Columns, ",") {
+		field, column, found := strings.Cut(mapping, "=")
+		target, exists := columns[strings.ToLower(strings.TrimSpace(field))]
+		if !found || !exists {
+			log.Fatalf("invalid CSV column mapping %q", mapping)
+		}
+		*target = strings.TrimSpace(column)
+	}
+
+	return format
+}
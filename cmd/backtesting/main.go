@@ -3,10 +3,7 @@ package main
 import (
 	"context"
 	"github.com/lfritz/env"
-	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
-	"github.com/sklinkert/at/internal/broker/backtest"
-	"github.com/sklinkert/at/internal/paperwallet"
 	"github.com/sklinkert/at/internal/strategy"
 	heikinashi "github.com/sklinkert/at/internal/strategy/HeikinAshi"
 	"github.com/sklinkert/at/internal/strategy/doji"
@@ -19,18 +16,22 @@ import (
 	"github.com/sklinkert/at/internal/strategy/sma10"
 	"github.com/sklinkert/at/internal/strategy/stochrsi"
 	"github.com/sklinkert/at/internal/trader"
-	chart "github.com/sklinkert/at/pkg/chart"
-	"github.com/sklinkert/at/pkg/chart/amcharts"
+	"github.com/sklinkert/at/pkg/ohlc"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"os"
 	"time"
 )
 
+// GitRev is injected by compiler by -X main.GitRev=$(VERSION)
+var GitRev string
+
 var conf struct {
 	importHistDataCSVFiles []string
 	broker                 string
 	gatherPerformanceData  bool
 	instrument             string
+	instruments            []string
 	persistData            bool
 	debug                  bool
 	dbHost                 string
@@ -39,8 +40,54 @@ var conf struct {
 	dbName                 string
 	dbPort                 int
 	strategyName           string
+	strategyNames          []string
+	allocation             string
+	allocationWeights      string
+	allocationLookback     string
+	equityCurveTrades      int
+	equityCurveReduction   float64
+	risk                   trader.RiskLimits
+	halt                   trader.HaltConditions
+	calendar               string
+	timezone               string
+	dayStart               string
+	calendarFiles          []string
+	sizing                 string
+	sizingRiskPercent      float64
+	sizingNotional         float64
+	sizingATRPeriod        int
+	sizingATRMultiple      float64
+	sizingKellyFraction    float64
+	sizingKellyMinTrades   int
+	lotStep                float64
+	minSize                float64
 	priceDBFile            string
+	priceDBDuration        string
 	priceSource            string
+	csvFiles               []string
+	csvColumns             string
+	csvDelimiter           string
+	csvHeader              bool
+	csvTimeFormat          string
+	csvTimezone            string
+	candleAnchor           string
+	flatCandles            bool
+	csvPricing             string
+	csvTicks               bool
+	csvCandleDuration      string
+	outputDir              string
+	benchmark              string
+	benchmarkPriceDBFile   string
+	benchmarkCSVFiles      []string
+	initialBalance         float64
+	tradingFeePercent      float64
+	slippage               float64
+	spread                 float64
+	seed                   int
+	validation             string
+	outOfSamplePercent     float64
+	folds                  int
+	purge                  string
 	yearFrom               int
 	yearTo                 int
 	monthFrom              int
@@ -55,7 +102,6 @@ var conf struct {
 
 func main() {
 	const BrokerBacktest = "backtest"
-	var graph chart.Chart
 	var ctx = context.Background()
 
 	var err error
@@ -64,10 +110,67 @@ func main() {
 	e.Flag("PERFORMANCE_DATA", &conf.gatherPerformanceData, "Gather performance data and print as CSV")
 	e.OptionalList("IMPORT_HISTDATA_CSV_FILES", &conf.importHistDataCSVFiles, ",", []string{}, "Import CSV files from histdata.com")
 	e.OptionalString("PRICE_SOURCE", &conf.priceSource, "LOCAL_DB", "Price source for backtesting. E.g. 'PATTERN_TRADING'")
+	e.OptionalList("CSV_FILES", &conf.csvFiles, ",", []string{}, "CSV quote files for PRICE_SOURCE=CSV, read in order; .gz files are decompressed")
+	e.OptionalString("CSV_COLUMNS", &conf.csvColumns, "time=0,open=1,high=2,low=3,close=4", "Column mapping e.g. 'time=0,bid=1,ask=2' or header names")
+	e.OptionalString("CSV_DELIMITER", &conf.csvDelimiter, ",", "CSV delimiter")
+	e.Flag("CSV_HEADER", &conf.csvHeader, "CSV files have a header line")
+	e.OptionalString("CSV_TIME_FORMAT", &conf.csvTimeFormat, time.RFC3339, "Go time layout, 'unix' or 'unixms'")
+	e.OptionalString("CSV_TIMEZONE", &conf.csvTimezone, "UTC", "Timezone of CSV timestamps")
+	e.OptionalString("CSV_PRICING", &conf.csvPricing, "MID", "MID, BID or ASK")
+	e.Flag("CSV_TICKS", &conf.csvTicks, "CSV lines are ticks instead of candles")
+	e.OptionalString("CSV_CANDLE_DURATION", &conf.csvCandleDuration, "1m", "Duration of the candles in the CSV files")
 	e.OptionalString("PRICE_DB_FILE", &conf.priceDBFile, "/data/EURUSD/db", "SQLite DB file for price data (OHLCs)")
+	e.OptionalString("PRICE_DB_DURATION", &conf.priceDBDuration, "1m", "Duration of the candles read from PRICE_DB_FILE, e.g. '1h' for candles stored by resample-candles")
 	e.OptionalString("INSTRUMENT", &conf.instrument, "CS.D.EURUSD.MINI.IP", "instrument to trade")
+	e.OptionalList("INSTRUMENTS", &conf.instruments, ",", []string{}, "Instruments for a portfolio backtest, overrides INSTRUMENT")
+	e.OptionalString("OUTPUT_DIR", &conf.outputDir, "./results", "Directory for the result bundle (trades, equity, metrics, chart, config)")
+	e.OptionalString("BENCHMARK", &conf.benchmark, "", "Instrument for the buy and hold benchmark, defaults to the traded instrument")
+	e.OptionalString("BENCHMARK_PRICE_DB_FILE", &conf.benchmarkPriceDBFile, "", "SQLite DB file of the benchmark, defaults to PRICE_DB_FILE")
+	e.OptionalList("BENCHMARK_CSV_FILES", &conf.benchmarkCSVFiles, ",", []string{}, "CSV quote files of the benchmark, defaults to CSV_FILES")
+	e.OptionalFloat("INITIAL_BALANCE", &conf.initialBalance, 1000, "Initial balance of the paperwallet")
+	e.OptionalFloat("TRADING_FEE_PERCENT", &conf.tradingFeePercent, 0.01, "Trading fee in percent")
+	e.OptionalFloat("SLIPPAGE", &conf.slippage, 0, "Absolute slippage added to fills")
+	e.OptionalFloat("SPREAD", &conf.spread, 0, "Additional bid/ask spread in cents")
+	e.OptionalInt("SEED", &conf.seed, 0, "Random seed, 0 picks a new one")
+	e.OptionalString("VALIDATION", &conf.validation, "", "Split the period into segments: 'holdout' or 'kfold'")
+	e.OptionalFloat("OUT_OF_SAMPLE_PERCENT", &conf.outOfSamplePercent, 30, "Share of the period at its end used as out-of-sample segment for VALIDATION=holdout")
+	e.OptionalInt("FOLDS", &conf.folds, 5, "Number of folds for VALIDATION=kfold")
+	e.OptionalString("PURGE", &conf.purge, "0s", "Gap between segments, e.g. the candle duration times the warm-up candles")
 	e.OptionalString("BROKER", &conf.broker, BrokerBacktest, "Broker backend")
 	e.OptionalString("STRATEGY", &conf.strategyName, "meanreversion", "strategy to be executed")
+	e.OptionalList("STRATEGIES", &conf.strategyNames, ",", []string{}, "Strategies for a portfolio backtest, each runs on every instrument, overrides STRATEGY")
+	e.OptionalString("ALLOCATION", &conf.allocation, "", "Capital allocation of portfolio backtests: 'fixed', 'riskparity' or 'equitycurve'")
+	e.OptionalString("ALLOCATION_WEIGHTS", &conf.allocationWeights, "", "Weights for ALLOCATION=fixed, e.g. 'EURUSD=2,GBPUSD=1' or 'sma10/EURUSD=1,...' with several strategies")
+	e.OptionalString("ALLOCATION_LOOKBACK", &conf.allocationLookback, "720h", "Lookback of the volatility for ALLOCATION=riskparity")
+	e.OptionalInt("EQUITY_CURVE_TRADES", &conf.equityCurveTrades, 10, "Trades of the equity curve's moving average for ALLOCATION=equitycurve")
+	e.OptionalFloat("EQUITY_CURVE_REDUCTION", &conf.equityCurveReduction, 0.5, "Weight factor while the equity curve is below its moving average")
+	e.OptionalInt("RISK_MAX_OPEN_POSITIONS", &conf.risk.MaxOpenPositions, 0, "Reject orders above this number of open positions, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_EXPOSURE", &conf.risk.MaxExposure, 0, "Max size of open positions per instrument and direction, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_DAILY_LOSS_PERCENT", &conf.risk.MaxDailyLossInPercent, 0, "Stop opening positions for the day after this loss in percent, 0 disables the check")
+	e.OptionalFloat("RISK_MAX_ORDER_SIZE", &conf.risk.MaxOrderSize, 0, "Reject bigger orders, 0 disables the check")
+	e.Flag("RISK_PRICE_SANITY", &conf.risk.PriceSanity, "Reject orders after price jumps of more than 0.5% between two ticks")
+	e.OptionalString("RISK_TRADING_WINDOW", &conf.risk.TradingWindow, "", "Only open positions within this time of day, e.g. '08:00-20:00'")
+	e.OptionalList("RISK_TRADING_DAYS", &conf.risk.TradingDays, ",", []string{}, "Only open positions on these weekdays, e.g. 'Mon,Tue,Wed,Thu,Fri'")
+	e.OptionalString("RISK_TIMEZONE", &conf.risk.Timezone, "UTC", "Timezone of RISK_TRADING_WINDOW and RISK_TRADING_DAYS")
+	e.OptionalFloat("HALT_MAX_INTRADAY_LOSS_PIPS", &conf.halt.MaxIntradayLossInPips, 0, "Halt trading after this loss within a day, 0 disables the check")
+	e.OptionalFloat("HALT_MAX_DRAWDOWN_PIPS", &conf.halt.MaxDrawdownInPips, 0, "Halt trading after this drawdown from the equity peak, 0 disables the check")
+	e.OptionalInt("HALT_MAX_CONSECUTIVE_LOSSES", &conf.halt.MaxConsecutiveLosses, 0, "Halt trading after this number of consecutive losses, 0 disables the check")
+	e.Flag("HALT_FLATTEN", &conf.halt.FlattenPositions, "Close all positions when trading is halted")
+	e.OptionalString("TIMEZONE", &conf.timezone, ohlc.DefaultTimezone, "Timezone of candles, reports and charts")
+	e.OptionalString("DAY_START", &conf.dayStart, "00:00", "Time of day in TIMEZONE at which daily candles and returns begin, e.g. '17:00' for forex in America/New_York")
+	e.OptionalString("CANDLE_ANCHOR", &conf.candleAnchor, "", "Align candles to this RFC 3339 time instead of the beginning of the day, e.g. '2021-01-04T09:30:00-05:00' for 90m candles from the NYSE opening")
+	e.Flag("FLAT_CANDLES", &conf.flatCandles, "Build candles for intervals without ticks from the previous close")
+	e.OptionalString("CALENDAR", &conf.calendar, "", "Market hours: 'forex', 'nyse', 'always' or a JSON calendar config file, empty trades at any time")
+	e.OptionalList("CALENDAR_FILES", &conf.calendarFiles, ",", []string{}, "CSV or ICS files with holidays, early closes and blackouts added to every calendar")
+	e.OptionalString("SIZING", &conf.sizing, "", "Compute order sizes from the balance: 'fixedfractional', 'fixednotional', 'volatility' or 'kelly'")
+	e.OptionalFloat("SIZING_RISK_PERCENT", &conf.sizingRiskPercent, 1, "Balance in percent risked per order for SIZING=fixedfractional, volatility and kelly until enough trades")
+	e.OptionalFloat("SIZING_NOTIONAL", &conf.sizingNotional, 1000, "Value per order for SIZING=fixednotional")
+	e.OptionalInt("SIZING_ATR_PERIOD", &conf.sizingATRPeriod, 14, "Candles of the ATR for SIZING=volatility")
+	e.OptionalFloat("SIZING_ATR_MULTIPLE", &conf.sizingATRMultiple, 2, "Price move in ATRs which loses SIZING_RISK_PERCENT for SIZING=volatility")
+	e.OptionalFloat("SIZING_KELLY_FRACTION", &conf.sizingKellyFraction, 0.5, "Fraction of the Kelly criterion for SIZING=kelly")
+	e.OptionalInt("SIZING_KELLY_MIN_TRADES", &conf.sizingKellyMinTrades, 20, "Closed positions required before SIZING=kelly uses the Kelly criterion")
+	e.OptionalFloat("LOT_STEP", &conf.lotStep, 0, "Order sizes are rounded down to multiples of it, 0 disables rounding")
+	e.OptionalFloat("MIN_SIZE", &conf.minSize, 0, "Orders below this size are skipped")
 	e.OptionalString("CANDLE_DURATION", &conf.candleDuration, "60m", "Duration for OHLC candle")
 	e.OptionalInt("YEAR_FROM", &conf.yearFrom, 1970, "Backtesting beginning year")
 	e.OptionalInt("YEAR_TO", &conf.yearTo, 2022, "Backtesting end year")
@@ -85,92 +188,52 @@ func main() {
 	if err != nil {
 		log.WithError(err).Fatal("failed to open database file")
 	}
-	candleDuration, err := time.ParseDuration(conf.candleDuration)
-	if err != nil {
-		log.WithError(err).Fatal("cannot parse candle duration")
+
+	log.Info("Starting broker ", conf.broker)
+
+	// backtesting rerun <id> reproduces a stored run and compares its metrics
+	if len(os.Args) > 1 && os.Args[1] == "rerun" {
+		if len(os.Args) != 3 {
+			log.Fatal("usage: backtesting rerun <id>")
+		}
+		rerun(ctx, db, os.Args[2])
+		return
 	}
 
-	var strategyBackend strategy.Strategy
-	switch conf.strategyName {
+	var rc = newRunConfig()
+	if rc.Validation.Method != "" {
+		validate(ctx, db, rc)
+		return
+	}
+	run(ctx, db, rc, true)
+}
+
+func newStrategy(strategyName, instrument string, candleDuration time.Duration) strategy.Strategy {
+	switch strategyName {
 	case strategy.NameDOJI:
-		strategyBackend = doji.New(conf.instrument)
+		return doji.New(instrument)
 	case strategy.NameHeikinAshi:
-		strategyBackend = heikinashi.New(conf.instrument)
+		return heikinashi.New(instrument)
 	case strategy.NameScalper:
-		strategyBackend = scalper.New(conf.instrument)
+		return scalper.New(instrument)
 	case strategy.NameStochRSI:
-		strategyBackend = stochrsi.New(conf.instrument)
+		return stochrsi.New(instrument)
 	case strategy.NameLowCandle:
-		strategyBackend = lowcandle.New(conf.instrument, candleDuration)
+		return lowcandle.New(instrument, candleDuration)
 	case strategy.NameHarami:
-		strategyBackend = harami.New(conf.instrument, candleDuration)
+		return harami.New(instrument, candleDuration)
 	case strategy.NameSMA10:
-		strategyBackend = sma10.New(conf.instrument, candleDuration)
+		return sma10.New(instrument, candleDuration)
 	case strategy.NameEngulfing:
-		strategyBackend = engulfing.New(conf.instrument, candleDuration)
+		return engulfing.New(instrument, candleDuration)
 	case strategy.NameRSI:
-		strategyBackend = rsi.New(conf.instrument, candleDuration)
+		return rsi.New(instrument, candleDuration)
 	case strategy.NameRSIADX:
-		strategyBackend = rsiadx.New(conf.instrument, candleDuration)
+		return rsiadx.New(instrument, candleDuration)
 	default:
-		log.Fatalf("unsupported strategy %q", conf.strategyName)
-	}
-
-	log.Info("Starting broker ", conf.broker)
-	var dataFeed backtest.Option
-	if len(conf.importHistDataCSVFiles) == 0 {
-		dataFeed = backtest.WithPriceDBFile(conf.priceDBFile, time.Minute)
-	} else {
-		dataFeed = backtest.WithTickDataFiles(conf.importHistDataCSVFiles)
+		log.Fatalf("unsupported strategy %q", strategyName)
 	}
 
-	periodFrom := time.Date(conf.yearFrom, time.Month(conf.monthFrom), 1, 0, 0, 0, 0, time.UTC)
-	periodTo := time.Date(conf.yearTo, time.Month(conf.monthTo), 31, 23, 23, 59, 0, time.UTC)
-
-	var priceDBOption backtest.Option
-	switch conf.priceSource {
-	case "COINBASE":
-		priceDBOption = backtest.WithQuotesSource(backtest.QuotesSourceCoinbase)
-	default:
-		priceDBOption = backtest.WithQuotesSource(backtest.QuotesSourceSqlite)
-	}
-
-	initialBalance := decimal.NewFromFloat(1000)
-	tradingFeePercent := decimal.NewFromFloat(0.01)
-	papperWallet := paperwallet.New(
-		paperwallet.WithInitialBalance(initialBalance),
-		paperwallet.WithTradingFeePercent(tradingFeePercent),
-		//paperwallet.WithSlippage(slippageAbsolute),
-	)
-
-	brokerBackend := backtest.New(conf.instrument, periodFrom, periodTo, papperWallet, dataFeed,
-		backtest.WithCandlePeriod(candleDuration),
-		priceDBOption,
-	)
-
-	//graph = plotly.NewChart()
-	graph = amcharts.NewChart(conf.instrument)
-
-	tr := trader.New(ctx, conf.instrument, "", db,
-		trader.WithBroker(brokerBackend),
-		trader.WithStrategy(strategyBackend),
-		trader.WithCandleSubscription(graph),
-		trader.WithPositionSubscription(graph),
-	)
-	if err := tr.Start(); err != nil {
-		log.WithError(err).Fatal("failed to start trader")
-	}
-
-	chartHTML, err := graph.RenderChartToHTML()
-	if err != nil {
-		log.WithError(err).Fatal("Unable to render chart as HTML")
-	}
-	if err := tr.SavePerformanceRecord(chartHTML); err != nil {
-		log.WithError(err).Error("unable to store performance record")
-	}
-	tr.Summary()
-
-	if err := graph.Start(); err != nil {
-		log.WithError(err).Error("failed to start amcharts server")
-	}
+	// Never reached
+	return nil
 }
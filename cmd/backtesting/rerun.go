@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/trader"
+	"gorm.io/gorm"
+)
+
+// rerun reproduces the stored run with the given ID and compares the metrics of both runs
+func rerun(ctx context.Context, db *gorm.DB, runID string) {
+	stored, err := trader.GetPerformanceRecordsByRunID(db, runID)
+	if err != nil {
+		log.WithError(err).Fatalf("cannot load run %q", runID)
+	}
+	if stored[0].BacktestingConfigJSON == "" {
+		log.Fatalf("run %q has no stored configuration", runID)
+	}
+
+	var rc runConfig
+	if err := json.Unmarshal([]byte(stored[0].BacktestingConfigJSON), &rc); err != nil {
+		log.WithError(err).Fatalf("cannot parse configuration of run %q", runID)
+	}
+
+	var storedParams = rc.StrategyParams
+	rc.RunID = newRunID()
+	rc.ParentRunID = "" // a rerun of a segment must not count as one of its parent's segments
+	rc.StrategyParams = map[string]string{}
+	if rc.GitRev != GitRev {
+		log.Warnf("Run %q was created with git rev %q, current rev is %q", runID, rc.GitRev, GitRev)
+	}
+	rc.GitRev = GitRev
+
+	var current = map[string]*trader.PerformanceRecord{}
+	for _, record := range run(ctx, db, rc, false) {
+		current[recordKey(record)] = record
+	}
+
+	var identical = true
+	for _, record := range stored {
+		var key = recordKey(&record)
+		if storedParams[key] != rc.StrategyParams[key] {
+			log.Warnf("%s: strategy parameters changed from %q to %q", key, storedParams[key], rc.StrategyParams[key])
+		}
+
+		currentRecord, exists := current[key]
+		if !exists {
+			log.Errorf("%s: no performance record in run %s", key, rc.RunID)
+			identical = false
+			continue
+		}
+		for _, diff := range trader.DiffPerformanceRecords(record, *currentRecord) {
+			log.Warnf("%s: %25s: %s -> %s", key, diff.Name, diff.Stored, diff.Current)
+			identical = false
+		}
+	}
+
+	if !identical {
+		log.Fatalf("Run %s differs from stored run %s", rc.RunID, runID)
+	}
+	log.Infof("Run %s reproduced stored run %s", rc.RunID, runID)
+}
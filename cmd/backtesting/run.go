@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/broker/backtest"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/internal/portfolio"
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/internal/trader"
+	"github.com/sklinkert/at/pkg/benchmark"
+	"github.com/sklinkert/at/pkg/chart"
+	"github.com/sklinkert/at/pkg/chart/amcharts"
+	"gorm.io/gorm"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// chartQueueSize is the number of events buffered for the chart
+const chartQueueSize = 1024
+
+type portfolioMetrics struct {
+	Traders   []*trader.PerformanceRecord
+	Portfolio *portfolio.Report
+}
+
+// run executes the backtest described by rc and returns the performance record of each trader
+func run(ctx context.Context, db *gorm.DB, rc runConfig, serveChart bool) []*trader.PerformanceRecord {
+	log.Infof("Starting run %s", rc.RunID)
+	rand.Seed(rc.Seed)
+
+	candleDuration, err := time.ParseDuration(rc.CandleDuration)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse candle duration")
+	}
+
+	tradingDay, err := rc.tradingDay()
+	if err != nil {
+		log.WithError(err).Fatal("invalid timezone or start of day")
+	}
+
+	priceDBDuration, err := rc.priceDBDuration()
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse price DB candle duration")
+	}
+
+	var dataFeed backtest.Option
+	if len(rc.HistDataCSVFiles) == 0 {
+		dataFeed = backtest.WithPriceDBFile(rc.PriceDBFile, priceDBDuration)
+	} else {
+		dataFeed = backtest.WithTickDataFiles(rc.HistDataCSVFiles)
+	}
+
+	var priceDBOption backtest.Option
+	switch rc.PriceSource {
+	case "COINBASE":
+		priceDBOption = backtest.WithQuotesSource(backtest.QuotesSourceCoinbase)
+	case "CSV":
+		priceDBOption = backtest.WithQuotesSource(backtest.QuotesSourceCSV)
+		dataFeed = backtest.WithTickDataFiles(rc.CSVFiles)
+	default:
+		priceDBOption = backtest.WithQuotesSource(backtest.QuotesSourceSqlite)
+	}
+
+	papperWallet := paperwallet.New(
+		paperwallet.WithInitialBalance(rc.InitialBalance),
+		paperwallet.WithTradingFeePercent(rc.TradingFeePercent),
+		paperwallet.WithSlippage(rc.Slippage),
+		paperwallet.WithSpread(rc.Spread),
+	)
+
+	var backtestOptions = []backtest.Option{
+		dataFeed,
+		backtest.WithCandlePeriod(candleDuration),
+		backtest.WithInstruments(rc.Instruments[1:]...),
+		backtest.WithCSVFormat(rc.CSV.format()),
+		backtest.WithOutputDir(filepath.Join(conf.outputDir, rc.Segment)),
+		backtest.WithLocation(tradingDay.Location),
+		priceDBOption,
+	}
+	if instrument := rc.Benchmark.Instrument; instrument != "" {
+		backtestOptions = append(backtestOptions, backtest.WithInstruments(instrument))
+		if rc.Benchmark.PriceDBFile != "" {
+			backtestOptions = append(backtestOptions, backtest.WithInstrumentPriceDBFile(instrument, rc.Benchmark.PriceDBFile))
+		}
+		if len(rc.Benchmark.CSVFiles) > 0 {
+			backtestOptions = append(backtestOptions, backtest.WithInstrumentCSVFiles(instrument, rc.Benchmark.CSVFiles...))
+		}
+	}
+	brokerBackend := backtest.New(rc.Instruments[0], rc.PeriodFrom, rc.PeriodTo, papperWallet, backtestOptions...)
+
+	var specs = rc.traders()
+	var strategies = map[string]strategy.Strategy{}
+	for _, spec := range specs {
+		strategies[spec.Key] = newStrategy(spec.Strategy, spec.Instrument, candleDuration)
+		rc.StrategyParams[spec.Key] = strategies[spec.Key].String()
+	}
+
+	configJSON, err := json.Marshal(rc)
+	if err != nil {
+		log.WithError(err).Fatal("cannot marshal run configuration")
+	}
+	if err := brokerBackend.WriteResultJSON(backtest.ResultFileConfig, rc); err != nil {
+		log.WithError(err).Error("unable to write run configuration")
+	}
+
+	riskRules, err := rc.Risk.Rules()
+	if err != nil {
+		log.WithError(err).Fatal("invalid risk limits")
+	}
+
+	candleOptions, err := rc.candleOptions()
+	if err != nil {
+		log.WithError(err).Fatal("invalid candle options")
+	}
+
+	calendars, err := rc.Calendar.calendars()
+	if err != nil {
+		log.WithError(err).Fatal("invalid calendar")
+	}
+
+	var balance = func() (float64, error) {
+		return papperWallet.GetBalance().InexactFloat64(), nil
+	}
+	var sizer = rc.Sizing.sizer()
+
+	var traderOptions = func(spec traderSpec) []trader.Option {
+		var options = []trader.Option{
+			trader.WithBroker(brokerBackend),
+			trader.WithStrategy(strategies[spec.Key]),
+			trader.WithOwner(spec.Owner),
+			trader.WithRiskRules(riskRules...),
+			trader.WithHaltConditions(rc.Halt),
+			trader.WithRunID(rc.RunID),
+			trader.WithBacktestingConfig(string(configJSON)),
+			trader.WithBenchmark(rc.Benchmark.Instrument),
+			trader.WithSegment(rc.ParentRunID, rc.Segment),
+			trader.WithTradingDay(tradingDay),
+		}
+		options = append(options, candleOptions...)
+		if sizer != nil {
+			options = append(options, trader.WithSizing(sizer, balance))
+		}
+		if calendars != nil {
+			options = append(options, trader.WithCalendar(calendars.For(spec.Instrument)))
+		}
+		return options
+	}
+
+	if len(specs) > 1 {
+		var traders []*trader.Trader
+		for _, spec := range specs {
+			options := append(traderOptions(spec), trader.WithInstruments(spec.Instrument))
+			traders = append(traders, trader.New(ctx, spec.Instrument, rc.GitRev, db, options...))
+		}
+		return runPortfolio(brokerBackend, traders, rc.Allocation.allocator(), tradingDay.Location)
+	}
+
+	var instrument = rc.Instruments[0]
+	//graph = plotly.NewChart()
+	graph := amcharts.NewChart(instrument, amcharts.WithLocation(tradingDay.Location))
+	tr := trader.New(ctx, instrument, rc.GitRev, db, traderOptions(specs[0])...)
+	graph.SetBenchmark(tr.Benchmark())
+	subscribeChart(tr.Events(), graph, tr.Strategy().GetCandleDuration())
+	if err := tr.Start(); err != nil {
+		log.WithError(err).Fatal("failed to start trader")
+	}
+
+	chartHTML, err := graph.RenderChartToHTML()
+	if err != nil {
+		log.WithError(err).Fatal("Unable to render chart as HTML")
+	}
+	if err := tr.SavePerformanceRecord(chartHTML); err != nil {
+		log.WithError(err).Error("unable to store performance record")
+	}
+	tr.Summary()
+
+	if err := brokerBackend.WriteResultFile(backtest.ResultFileChart, []byte(chartHTML)); err != nil {
+		log.WithError(err).Error("unable to write chart")
+	}
+	var records []*trader.PerformanceRecord
+	if record, err := tr.GetPerformanceRecord(""); err == nil {
+		records = append(records, record)
+		if err := brokerBackend.WriteResultJSON(backtest.ResultFileMetrics, record); err != nil {
+			log.WithError(err).Error("unable to write metrics")
+		}
+	}
+	log.Infof("Results of run %s written to %q", rc.RunID, brokerBackend.OutputDir())
+
+	if serveChart {
+		if err := graph.Start(); err != nil {
+			log.WithError(err).Error("failed to start amcharts server")
+		}
+	}
+
+	return records
+}
+
+// subscribeChart draws the candles of the strategy's timeframe and the closed positions
+func subscribeChart(bus *event.Bus, graph chart.Chart, candleDuration time.Duration) {
+	event.Subscribe(bus, "chart candles", func(e event.Candle) {
+		if e.Candle.Duration == candleDuration {
+			graph.OnCandle(e.Candle)
+		}
+	}, event.Async(chartQueueSize))
+	event.Subscribe(bus, "chart positions", func(e event.PositionClosed) {
+		graph.OnPosition(e.Position)
+	}, event.Async(chartQueueSize))
+}
+
+// runPortfolio runs the traders on a shared paperwallet. A nil allocator keeps the order sizes of
+// the strategies. Every trader gets its own chart, chart.html shows the equity curve of the portfolio.
+func runPortfolio(brokerBackend *backtest.Backtest, traders []*trader.Trader, allocator portfolio.Allocator, location *time.Location) []*trader.PerformanceRecord {
+	var options []portfolio.Option
+	if allocator != nil {
+		options = append(options, portfolio.WithAllocator(allocator))
+	}
+	for _, tr := range traders {
+		options = append(options, portfolio.WithTrader(tr))
+	}
+
+	p := portfolio.New(brokerBackend, options...)
+	var graphs = map[*trader.Trader]*amcharts.Chart{}
+	for _, tr := range p.Traders() {
+		graph := amcharts.NewChart(tr.Instrument, amcharts.WithLocation(location))
+		graph.SetBenchmark(tr.Benchmark())
+		subscribeChart(tr.Events(), graph, tr.Strategy().GetCandleDuration())
+		graphs[tr] = graph
+	}
+	if err := p.Start(); err != nil {
+		log.WithError(err).Fatal("failed to start portfolio")
+	}
+
+	var metrics portfolioMetrics
+	var positions []broker.Position
+	for _, tr := range p.Traders() {
+		key := portfolio.Key(tr)
+		chartHTML, err := graphs[tr].RenderChartToHTML()
+		if err != nil {
+			log.WithError(err).Errorf("unable to render chart of %q", key)
+		}
+		if err := brokerBackend.WriteResultFile(backtest.ResultFileTraderChart(key), []byte(chartHTML)); err != nil {
+			log.WithError(err).Errorf("unable to write chart of %q", key)
+		}
+		if err := tr.SavePerformanceRecord(chartHTML); err != nil {
+			log.WithError(err).Errorf("unable to store performance record for %q", key)
+		}
+		if record, err := tr.GetPerformanceRecord(""); err == nil {
+			metrics.Traders = append(metrics.Traders, record)
+			positions = append(positions, record.ClosedPositions...)
+		}
+	}
+	p.Summary()
+
+	if err := writeEquityChart(brokerBackend, positions, p.Traders()[0].Benchmark(), location); err != nil {
+		log.WithError(err).Error("unable to write chart")
+	}
+
+	if report, err := p.GetReport(); err == nil {
+		metrics.Portfolio = report
+	}
+	if err := brokerBackend.WriteResultJSON(backtest.ResultFileMetrics, metrics); err != nil {
+		log.WithError(err).Error("unable to write metrics")
+	}
+	log.Infof("Results written to %q", brokerBackend.OutputDir())
+
+	return metrics.Traders
+}
+
+// writeEquityChart writes the equity curve of the positions of all traders as chart.html
+func writeEquityChart(brokerBackend *backtest.Backtest, positions []broker.Position, b *benchmark.Benchmark, location *time.Location) error {
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].BuyTime.Before(positions[j].BuyTime)
+	})
+	graph := amcharts.NewChart("Portfolio", amcharts.WithLocation(location))
+	graph.SetBenchmark(b)
+	for _, position := range positions {
+		graph.OnPosition(position)
+	}
+
+	var buf = new(bytes.Buffer)
+	if err := graph.RenderEquityCurve(buf); err != nil {
+		return err
+	}
+	return brokerBackend.WriteResultFile(backtest.ResultFileChart, buf.Bytes())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/trader"
+	"github.com/sklinkert/at/internal/validation"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const validationResultFile = "validation.json"
+
+// reportedMetrics are printed per segment and summarized over all segments
+var reportedMetrics = []string{
+	"Trades",
+	"TotalPerformanceInPips",
+	"AVGPerformanceInPips",
+	"TradesWinRationInPercent",
+	"MaxLossInPercent",
+	"MaxAggregateDrawdownInPips",
+	"ExcessReturnInPercent",
+}
+
+type validationReport struct {
+	RunID      string
+	Method     string
+	Segments   []validation.Segment
+	Records    []*trader.PerformanceRecord
+	Statistics map[string][]trader.MetricStatistic // trader key -> statistics over all segments
+}
+
+// validate runs each segment of the configured period on its own. The segments are stored as
+// runs which are linked to rc.RunID.
+func validate(ctx context.Context, db *gorm.DB, rc runConfig) {
+	purge, err := time.ParseDuration(rc.Validation.Purge)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse purge duration")
+	}
+
+	segments, err := validation.Split(rc.Validation.Method, rc.PeriodFrom, rc.PeriodTo,
+		rc.Validation.OutOfSamplePercent, rc.Validation.Folds, purge)
+	if err != nil {
+		log.WithError(err).Fatal("cannot split period")
+	}
+
+	var report = validationReport{RunID: rc.RunID, Method: rc.Validation.Method, Segments: segments}
+	var byTrader = map[string][]trader.PerformanceRecord{}
+	for _, segment := range segments {
+		segmentConfig := rc
+		segmentConfig.RunID = rc.RunID + "-" + segment.Name
+		segmentConfig.ParentRunID = rc.RunID
+		segmentConfig.Segment = segment.Name
+		segmentConfig.PeriodFrom = segment.From
+		segmentConfig.PeriodTo = segment.To
+		segmentConfig.StrategyParams = map[string]string{}
+		segmentConfig.Validation = validationConfig{}
+
+		log.Infof("Running segment %s: %s -> %s", segment.Name,
+			segment.From.Format("02.01.2006 15:04"), segment.To.Format("02.01.2006 15:04"))
+		records := run(ctx, db, segmentConfig, false)
+		records = withEmptyRecords(records, segmentConfig)
+		for _, record := range records {
+			report.Records = append(report.Records, record)
+			byTrader[recordKey(record)] = append(byTrader[recordKey(record)], *record)
+		}
+	}
+
+	report.Statistics = map[string][]trader.MetricStatistic{}
+	for key, records := range byTrader {
+		report.Statistics[key] = trader.PerformanceStatistics(records)
+	}
+	printValidationReport(report)
+
+	if err := writeValidationReport(report); err != nil {
+		log.WithError(err).Error("unable to write validation report")
+	}
+	log.Infof("Segments of the %s validation are stored with parent run ID %s", rc.Validation.Method, rc.RunID)
+}
+
+// withEmptyRecords adds an empty record for every trader without positions in the segment so it
+// counts as a segment without performance.
+func withEmptyRecords(records []*trader.PerformanceRecord, rc runConfig) []*trader.PerformanceRecord {
+	var covered = map[string]bool{}
+	for _, record := range records {
+		covered[recordKey(record)] = true
+	}
+	for _, spec := range rc.traders() {
+		if covered[spec.Key] {
+			continue
+		}
+		log.Warnf("%s: segment %s has no positions", spec.Key, rc.Segment)
+		records = append(records, &trader.PerformanceRecord{
+			RunID:       rc.RunID,
+			ParentRunID: rc.ParentRunID,
+			Segment:     rc.Segment,
+			Instrument:  spec.Instrument,
+			Owner:       spec.Owner,
+		})
+	}
+	return records
+}
+
+func printValidationReport(report validationReport) {
+	var keys []string
+	for key := range report.Statistics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		log.Infof("%25s: %s (%s, %d segments)", "Validation", key, report.Method, len(report.Segments))
+		for _, record := range report.Records {
+			if recordKey(record) != key {
+				continue
+			}
+			log.Infof("%25s: %d trades %.2f pips (%.2f%% win)", record.Segment,
+				record.Trades, record.TotalPerformanceInPips, record.TradesWinRationInPercent)
+		}
+
+		var statistics = map[string]trader.MetricStatistic{}
+		for _, stat := range report.Statistics[key] {
+			statistics[stat.Name] = stat
+		}
+		for _, name := range reportedMetrics {
+			stat := statistics[name]
+			log.Infof("%25s: mean %.2f stddev %.2f min %.2f max %.2f", name, stat.Mean, stat.StdDev, stat.Min, stat.Max)
+		}
+	}
+}
+
+func writeValidationReport(report validationReport) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(conf.outputDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(conf.outputDir, validationResultFile), content, 0644)
+}
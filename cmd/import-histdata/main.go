@@ -65,7 +65,7 @@ func main() {
 	const candleDuration = time.Minute * 1
 	for currentTick := range c {
 		if candle != nil {
-			isOpen := candle.NewPrice(currentTick.Price(), currentTick.Datetime)
+			isOpen := candle.NewTick(currentTick)
 			if !isOpen {
 				if err := tx.Create(candle).Error; err != nil {
 					log.WithError(err).Fatal("Cannot store candle")
@@ -75,7 +75,7 @@ func main() {
 		}
 		if candle == nil {
 			candle = ohlc.New(instrument, currentTick.Datetime, candleDuration, true)
-			candle.NewPrice(currentTick.Price(), currentTick.Datetime)
+			candle.NewTick(currentTick)
 		}
 		//if err := tx.Create(&currentTick).Error; err != nil {
 		//	log.WithError(err).Warn("db.Create() failed: %v", currentTick)
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"github.com/lfritz/env"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"time"
+)
+
+const pageSize = 80000
+
+var conf struct {
+	priceDBFile    string
+	instrument     string
+	sourceDuration string
+	durations      []string
+	timezone       string
+	dayStart       string
+	candleAnchor   string
+}
+
+// Resamples the candles of a price DB, e.g. the 1m candles of import-histdata, to longer durations
+// and stores them in the same DB. Existing candles of these durations are replaced.
+func main() {
+	var e = env.New()
+	e.String("PRICE_DB_FILE", &conf.priceDBFile, "SQLite DB file with candles")
+	e.List("DURATIONS", &conf.durations, ",", "Durations of the resampled candles, e.g. '15m,1h,24h'")
+	e.OptionalString("INSTRUMENT", &conf.instrument, "", "Only resample candles of this instrument")
+	e.OptionalString("SOURCE_DURATION", &conf.sourceDuration, "1m", "Duration of the stored candles which are resampled")
+	e.OptionalString("TIMEZONE", &conf.timezone, ohlc.DefaultTimezone, "Timezone of the candles")
+	e.OptionalString("DAY_START", &conf.dayStart, "00:00", "Time of day in TIMEZONE at which daily candles begin")
+	e.OptionalString("CANDLE_ANCHOR", &conf.candleAnchor, "", "Align candles to this RFC 3339 time instead of the beginning of the day")
+	if err := e.Load(); err != nil {
+		log.WithError(err).Fatal("env loading failed")
+	}
+
+	sourceDuration, err := time.ParseDuration(conf.sourceDuration)
+	if err != nil {
+		log.WithError(err).Fatal("cannot parse source duration")
+	}
+	tradingDay, err := ohlc.NewTradingDay(conf.timezone, conf.dayStart)
+	if err != nil {
+		log.WithError(err).Fatal("invalid timezone or start of day")
+	}
+	var options []ohlc.AggregatorOption
+	if conf.candleAnchor != "" {
+		anchor, err := time.Parse(time.RFC3339, conf.candleAnchor)
+		if err != nil {
+			log.WithError(err).Fatal("invalid candle anchor")
+		}
+		options = append(options, ohlc.WithAnchor(anchor))
+	}
+
+	db, err := gorm.Open(sqlite.Open(conf.priceDBFile), &gorm.Config{})
+	if err != nil {
+		log.WithError(err).Fatalf("failed to connect database %q", conf.priceDBFile)
+	}
+	if err := db.AutoMigrate(&ohlc.OHLC{}); err != nil {
+		log.WithError(err).Fatal("db.AutoMigrate() failed")
+	}
+
+	var aggregators = map[string][]*ohlc.Aggregator{} // instrument -> aggregator of each duration
+	var durations []time.Duration
+	for _, d := range conf.durations {
+		duration, err := time.ParseDuration(d)
+		if err != nil {
+			log.WithError(err).Fatalf("cannot parse duration %q", d)
+		}
+		if duration <= sourceDuration {
+			log.Fatalf("duration %s must be longer than the source duration %s", duration, sourceDuration)
+		}
+		durations = append(durations, duration)
+		if err := scope(db).Where("duration = ?", duration).Delete(&ohlc.OHLC{}).Error; err != nil {
+			log.WithError(err).Fatalf("cannot delete existing %s candles", duration)
+		}
+	}
+
+	var resampled = map[time.Duration]int{}
+	var store = func(closed []*ohlc.OHLC) {
+		if len(closed) == 0 {
+			return
+		}
+		if err := db.CreateInBatches(closed, 1000).Error; err != nil {
+			log.WithError(err).Fatal("cannot store resampled candles")
+		}
+		for _, candle := range closed {
+			resampled[candle.Duration]++
+		}
+	}
+
+	var read int
+	var lastEnd = map[string]time.Time{} // instrument -> end of the last source candle
+	for offset := 0; ; offset += pageSize {
+		var candles []ohlc.OHLC
+		if err := scope(db).
+			Where("duration = ?", sourceDuration).
+			Order("start, instrument").
+			Offset(offset).
+			Limit(pageSize).
+			Find(&candles).Error; err != nil {
+			log.WithError(err).Fatal("db.Find(&candles) failed")
+		}
+		if len(candles) == 0 {
+			break
+		}
+
+		var closed []*ohlc.OHLC
+		for i := range candles {
+			var candle = &candles[i]
+			if _, exists := aggregators[candle.Instrument]; !exists {
+				for _, duration := range durations {
+					aggregators[candle.Instrument] = append(aggregators[candle.Instrument],
+						ohlc.NewAggregator(candle.Instrument, duration, tradingDay, options...))
+				}
+			}
+			for _, aggregator := range aggregators[candle.Instrument] {
+				closed = append(closed, aggregator.AddCandle(candle)...)
+			}
+			lastEnd[candle.Instrument] = candle.End
+		}
+		store(closed)
+
+		read += len(candles)
+		log.Infof("%d candles read until %s", read, candles[len(candles)-1].Start)
+	}
+
+	// Complete candles are stored, the last one only if the source candles cover it
+	for instrument, instrumentAggregators := range aggregators {
+		for _, aggregator := range instrumentAggregators {
+			store(aggregator.Advance(lastEnd[instrument]))
+		}
+	}
+
+	for _, duration := range durations {
+		log.Infof("%d candles of %s stored", resampled[duration], duration)
+	}
+}
+
+// scope restricts queries to the given instrument
+func scope(db *gorm.DB) *gorm.DB {
+	if conf.instrument == "" {
+		return db
+	}
+	return db.Where("instrument = ?", conf.instrument)
+}
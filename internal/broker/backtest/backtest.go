@@ -3,6 +3,7 @@ package backtest
 import (
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/pkg/ohlc"
 	"github.com/sklinkert/igmarkets"
 	"sync"
 	"time"
@@ -11,6 +12,7 @@ import (
 // Backtest contains all required data for running a backtesting.
 type Backtest struct {
 	instrument      string
+	instruments     []string // additional instruments for portfolio backtests
 	periodFrom      time.Time
 	periodTo        time.Time
 	quotesSource    QuotesSource
@@ -20,10 +22,17 @@ type Backtest struct {
 
 	// Price data sqlite file
 	priceDBFile           string
+	priceDBFiles          map[string]string // instrument -> sqlite file
 	priceDBCandleDuration time.Duration
 
 	// Read raw data from CSV files
 	tickDataFiles []string
+	csvFiles      map[string][]string // instrument -> CSV files
+	csvFormat     CSVFormat
+
+	// Directory for the result bundle
+	outputDir string
+	location  *time.Location // of times in result files
 	sync.RWMutex
 }
 
@@ -36,12 +45,50 @@ func WithPriceDBFile(dbFile string, candleDuration time.Duration) Option {
 	}
 }
 
+// WithInstrumentPriceDBFile sets a separate sqlite file for the given instrument. Instruments without
+// a separate file are read from the file set by WithPriceDBFile.
+func WithInstrumentPriceDBFile(instrument, dbFile string) Option {
+	return func(backtest *Backtest) {
+		backtest.priceDBFiles[instrument] = dbFile
+	}
+}
+
+// WithInstruments adds further instruments to the backtest. The price feeds of all instruments
+// are merged into one time-ordered stream and share the same paperwallet.
+func WithInstruments(instruments ...string) Option {
+	return func(backtest *Backtest) {
+		backtest.instruments = append(backtest.instruments, instruments...)
+	}
+}
+
 func WithTickDataFiles(files []string) Option {
 	return func(backtest *Backtest) {
 		backtest.tickDataFiles = files
 	}
 }
 
+// WithCSVFormat sets the layout of the CSV files read by QuotesSourceCSV
+func WithCSVFormat(format CSVFormat) Option {
+	return func(backtest *Backtest) {
+		backtest.csvFormat = format
+	}
+}
+
+// WithInstrumentCSVFiles sets separate CSV files for the given instrument. Files are read in the
+// given order. Instruments without separate files are read from the files set by WithTickDataFiles.
+func WithInstrumentCSVFiles(instrument string, files ...string) Option {
+	return func(backtest *Backtest) {
+		backtest.csvFiles[instrument] = files
+	}
+}
+
+// WithOutputDir sets the directory for the result bundle. It's created if missing.
+func WithOutputDir(dir string) Option {
+	return func(backtest *Backtest) {
+		backtest.outputDir = dir
+	}
+}
+
 func WithQuotesSource(quotesSource QuotesSource) Option {
 	return func(backtest *Backtest) {
 		backtest.quotesSource = quotesSource
@@ -60,13 +107,24 @@ func WithCandlePeriod(period time.Duration) Option {
 	}
 }
 
+// WithLocation sets the timezone of buy and sell times in the trades CSV, Europe/Berlin by default
+func WithLocation(location *time.Location) Option {
+	return func(backtest *Backtest) {
+		backtest.location = location
+	}
+}
+
 // New creates new backtesting instance
 func New(instrument string, periodFrom, periodTo time.Time, paperwallet *paperwallet.Paperwallet, options ...Option) *Backtest {
 	var b = &Backtest{
-		instrument:  instrument,
-		periodFrom:  periodFrom,
-		periodTo:    periodTo,
-		paperwallet: paperwallet,
+		instrument:   instrument,
+		periodFrom:   periodFrom,
+		periodTo:     periodTo,
+		paperwallet:  paperwallet,
+		priceDBFiles: map[string]string{},
+		csvFiles:     map[string][]string{},
+		outputDir:    defaultOutputDir,
+		location:     ohlc.DefaultTradingDay().Location,
 	}
 
 	for _, option := range options {
@@ -76,6 +134,27 @@ func New(instrument string, periodFrom, periodTo time.Time, paperwallet *paperwa
 	return b
 }
 
+// Instruments returns all instruments covered by the backtest
+func (b *Backtest) Instruments() []string {
+	var seen = map[string]bool{}
+	var instruments []string
+	for _, instrument := range append([]string{b.instrument}, b.instruments...) {
+		if seen[instrument] {
+			continue
+		}
+		seen[instrument] = true
+		instruments = append(instruments, instrument)
+	}
+	return instruments
+}
+
+func (b *Backtest) priceDBFileByInstrument(instrument string) string {
+	if dbFile, exists := b.priceDBFiles[instrument]; exists {
+		return dbFile
+	}
+	return b.priceDBFile
+}
+
 // Buy open new position with target and stop loss
 func (b *Backtest) Buy(order broker.Order) (string, error) {
 	b.Lock()
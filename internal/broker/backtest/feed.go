@@ -2,6 +2,7 @@ package backtest
 
 import (
 	"context"
+	"fmt"
 	"github.com/piquette/finance-go/chart"
 	"github.com/piquette/finance-go/datetime"
 	"github.com/shopspring/decimal"
@@ -21,9 +22,10 @@ const (
 	QuotesSourceYahooFinance
 	QuotesSourceIGMarkets
 	QuotesSourceCoinbase
+	QuotesSourceCSV
 )
 
-func (b *Backtest) retrieveCandlesFromIGMarkets(receiver chan ohlc.OHLC) {
+func (b *Backtest) retrieveCandlesFromIGMarkets(instrument string, receiver chan ohlc.OHLC) {
 	defer close(receiver)
 
 	var ctx = context.Background()
@@ -32,20 +34,20 @@ func (b *Backtest) retrieveCandlesFromIGMarkets(receiver chan ohlc.OHLC) {
 		log.WithError(err).Fatal("login failed")
 	}
 
-	priceResponse, err := b.brokerIGMarkets.GetPriceHistory(ctx, b.instrument, igmarkets.ResolutionHour, 100, b.periodFrom, b.periodTo)
+	priceResponse, err := b.brokerIGMarkets.GetPriceHistory(ctx, instrument, igmarkets.ResolutionHour, 100, b.periodFrom, b.periodTo)
 	if err != nil {
-		log.WithError(err).Fatalf("failed to fetch price history for %q from IG Markets", b.instrument)
+		log.WithError(err).Fatalf("failed to fetch price history for %q from IG Markets", instrument)
 	}
 	log.Infof("prices fetched: %d", len(priceResponse.Prices))
 
 	for _, price := range priceResponse.Prices {
-		open := bidAskToTick(b.instrument, price.SnapshotTimeUTCParsed, price.OpenPrice.Bid, price.OpenPrice.Ask)
-		high := bidAskToTick(b.instrument, price.SnapshotTimeUTCParsed, price.HighPrice.Bid, price.HighPrice.Ask)
-		low := bidAskToTick(b.instrument, price.SnapshotTimeUTCParsed, price.LowPrice.Bid, price.LowPrice.Ask)
-		close := bidAskToTick(b.instrument, price.SnapshotTimeUTCParsed, price.ClosePrice.Bid, price.ClosePrice.Ask)
+		open := bidAskToTick(instrument, price.SnapshotTimeUTCParsed, price.OpenPrice.Bid, price.OpenPrice.Ask)
+		high := bidAskToTick(instrument, price.SnapshotTimeUTCParsed, price.HighPrice.Bid, price.HighPrice.Ask)
+		low := bidAskToTick(instrument, price.SnapshotTimeUTCParsed, price.LowPrice.Bid, price.LowPrice.Ask)
+		close := bidAskToTick(instrument, price.SnapshotTimeUTCParsed, price.ClosePrice.Bid, price.ClosePrice.Ask)
 
 		var candle = ohlc.OHLC{
-			Instrument: b.instrument,
+			Instrument: instrument,
 			Open:       open.Price(),
 			High:       high.Price(),
 			Low:        low.Price(),
@@ -63,18 +65,18 @@ func bidAskToTick(instrument string, datetime time.Time, bid, ask float64) tick.
 	return tick.New(instrument, datetime, decimal.NewFromFloat(bid), decimal.NewFromFloat(ask))
 }
 
-func (b *Backtest) retrieveCandlesFromYahooFinance(receiver chan ohlc.OHLC) {
+func (b *Backtest) retrieveCandlesFromYahooFinance(instrument string, receiver chan ohlc.OHLC) {
 	defer close(receiver)
 
 	params := &chart.Params{
-		Symbol:   b.instrument,
+		Symbol:   instrument,
 		Interval: datetime.OneDay,
 		Start:    datetime.New(&b.periodFrom),
 		End:      datetime.New(&b.periodTo),
 	}
 
 	log.Infof("Fetching quotes from Yahoo Finance for %q with period %s - %s",
-		b.instrument, b.periodFrom, b.periodTo)
+		instrument, b.periodFrom, b.periodTo)
 	iter := chart.Get(params)
 
 	for iter.Next() {
@@ -82,7 +84,7 @@ func (b *Backtest) retrieveCandlesFromYahooFinance(receiver chan ohlc.OHLC) {
 		bar := iter.Bar()
 
 		candle := ohlc.OHLC{
-			Instrument: b.instrument,
+			Instrument: instrument,
 			Open:       bar.Open,
 			High:       bar.High,
 			Low:        bar.Low,
@@ -100,17 +102,29 @@ func (b *Backtest) retrieveCandlesFromYahooFinance(receiver chan ohlc.OHLC) {
 	}
 }
 
-func (b *Backtest) retrieveCandlesFromSQLite(receiver chan ohlc.OHLC) {
+func (b *Backtest) retrieveCandlesFromSQLite(instrument string, receiver chan ohlc.OHLC) {
 	defer close(receiver)
 
-	db, err := gorm.Open(sqlite.Open(b.priceDBFile), &gorm.Config{})
+	var priceDBFile = b.priceDBFileByInstrument(instrument)
+	db, err := gorm.Open(sqlite.Open(priceDBFile), &gorm.Config{})
 	if err != nil {
-		log.WithError(err).Fatalf("failed to connect database %q", b.priceDBFile)
+		log.WithError(err).Fatalf("failed to connect database %q", priceDBFile)
 	}
 
 	// Speed up read performance
 	db.Exec("PRAGMA locking_mode = EXCLUSIVE;")
 
+	var conditions = "duration = ? AND start BETWEEN ? AND ?"
+	var args = []interface{}{b.priceDBCandleDuration, b.periodFrom, b.periodTo}
+	filter, err := b.sqliteInstrumentFilter(db, instrument, priceDBFile)
+	if err != nil {
+		log.WithError(err).Fatalf("cannot read candles of %s from %q", instrument, priceDBFile)
+	}
+	if filter {
+		conditions += " AND instrument = ?"
+		args = append(args, instrument)
+	}
+
 	const pageSize = 80000
 	var offset int
 	for {
@@ -119,7 +133,7 @@ func (b *Backtest) retrieveCandlesFromSQLite(receiver chan ohlc.OHLC) {
 			Offset(offset).
 			Limit(pageSize).
 			Order("start").
-			Where("duration = ? AND start BETWEEN ? AND ?", b.priceDBCandleDuration, b.periodFrom, b.periodTo).
+			Where(conditions, args...).
 			Find(&candles).Error; err != nil {
 			log.WithError(err).Error("db.Find(&candles) failed")
 			return
@@ -129,35 +143,91 @@ func (b *Backtest) retrieveCandlesFromSQLite(receiver chan ohlc.OHLC) {
 			return
 		}
 		for _, candle := range candles {
+			// Stored instrument names may differ from the ones used for trading
+			candle.Instrument = instrument
 			receiver <- candle
 		}
 		offset += pageSize
 	}
 }
 
-func (b *Backtest) ListenToPriceFeed(traderChan chan tick.Tick) {
-	var c = make(chan ohlc.OHLC)
+// sqliteInstrumentFilter reports whether the candles of the DB must be filtered by the instrument. A
+// DB of a single instrument may store it under another name, e.g. EURUSD=X, unless the DB is shared
+// by several instruments of the backtest.
+func (b *Backtest) sqliteInstrumentFilter(db *gorm.DB, instrument, priceDBFile string) (bool, error) {
+	var stored []string
+	if err := db.Model(&ohlc.OHLC{}).Distinct("instrument").Pluck("instrument", &stored).Error; err != nil {
+		return false, err
+	}
+	for _, name := range stored {
+		if name == instrument {
+			return len(stored) > 1, nil
+		}
+	}
+
+	var sharedBy int
+	for _, i := range b.Instruments() {
+		if b.priceDBFileByInstrument(i) == priceDBFile {
+			sharedBy++
+		}
+	}
+	if len(stored) > 1 || sharedBy > 1 {
+		return false, fmt.Errorf("DB holds candles of %v but not of %s, use a separate DB file per instrument", stored, instrument)
+	}
+	return false, nil
+}
 
+func (b *Backtest) retrieveCandles(instrument string, receiver chan ohlc.OHLC) {
 	switch b.quotesSource {
 	case QuotesSourceSqlite:
-		go b.retrieveCandlesFromSQLite(c)
+		b.retrieveCandlesFromSQLite(instrument, receiver)
 	case QuotesSourceYahooFinance:
-		go b.retrieveCandlesFromYahooFinance(c)
+		b.retrieveCandlesFromYahooFinance(instrument, receiver)
 	case QuotesSourceIGMarkets:
-		go b.retrieveCandlesFromIGMarkets(c)
+		b.retrieveCandlesFromIGMarkets(instrument, receiver)
 	case QuotesSourceCoinbase:
-		go b.retrieveCandlesFromCoinbase(c)
+		b.retrieveCandlesFromCoinbase(instrument, receiver)
+	case QuotesSourceCSV:
+		b.retrieveCandlesFromCSV(instrument, receiver)
 	default:
 		log.Fatalf("Unknown quotes source: %d", b.quotesSource)
 	}
+}
+
+// retrieveTicks converts the candles of the given instrument into ticks
+func (b *Backtest) retrieveTicks(instrument string, receiver chan tick.Tick) {
+	if b.quotesSource == QuotesSourceCSV && b.csvFormat.Ticks {
+		b.retrieveTicksFromCSV(instrument, receiver)
+		return
+	}
+
+	defer close(receiver)
+
+	var c = make(chan ohlc.OHLC)
+	go b.retrieveCandles(instrument, c)
 
 	for candle := range c {
 		for _, currentTick := range candle.ToTicks() {
-			b.paperwallet.SetCurrenctPrice(currentTick)
-			traderChan <- currentTick
+			receiver <- currentTick
 		}
 	}
+}
+
+func (b *Backtest) ListenToPriceFeed(traderChan chan tick.Tick) {
+	var feeds []chan tick.Tick
+	for _, instrument := range b.Instruments() {
+		var feed = make(chan tick.Tick)
+		go b.retrieveTicks(instrument, feed)
+		feeds = append(feeds, feed)
+	}
+
+	for currentTick := range mergeTicks(feeds) {
+		b.paperwallet.SetCurrenctPrice(currentTick)
+		traderChan <- currentTick
+	}
 	b.paperwallet.CloseAllOpenPositions()
 	b.writeCSV()
+	b.writeEquityCSV()
 	b.paperwallet.PrintSummary()
+	close(traderChan)
 }
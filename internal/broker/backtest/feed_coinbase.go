@@ -26,7 +26,7 @@ func (b *Backtest) splitInstrument() (exchange, isin string) {
 	return parts[0], parts[1]
 }
 
-func (b *Backtest) retrieveCandlesFromCoinbase(receiver chan ohlc.OHLC) {
+func (b *Backtest) retrieveCandlesFromCoinbase(instrument string, receiver chan ohlc.OHLC) {
 	defer close(receiver)
 
 	client := coinbasepro.NewClient()
@@ -35,7 +35,7 @@ func (b *Backtest) retrieveCandlesFromCoinbase(receiver chan ohlc.OHLC) {
 		End:         b.periodTo,
 		Granularity: int(b.candlePeriod.Seconds()),
 	}
-	historicRates, err := client.GetHistoricRates(b.instrument, params)
+	historicRates, err := client.GetHistoricRates(instrument, params)
 	if err != nil {
 		log.WithError(err).Fatalf("cannot fetch historic rates from coinbase (params: %+v)", params)
 	}
@@ -45,13 +45,14 @@ func (b *Backtest) retrieveCandlesFromCoinbase(receiver chan ohlc.OHLC) {
 		highPrice := decimal.NewFromFloat(historicRate.High)
 		lowPrice := decimal.NewFromFloat(historicRate.Low)
 		closePrice := decimal.NewFromFloat(historicRate.Close)
-		candle := ohlc.New(b.instrument, historicRate.Time, b.candlePeriod, false)
+		candle := ohlc.New(instrument, historicRate.Time, b.candlePeriod, false)
 
 		candle.NewPrice(openPrice, historicRate.Time)
 		candle.NewPrice(highPrice, historicRate.Time)
 		candle.NewPrice(lowPrice, historicRate.Time)
 		candle.NewPrice(closePrice, historicRate.Time)
 		candle.ForceClose()
+		candle.Volume = historicRate.Volume
 
 		receiver <- *candle
 	}
@@ -0,0 +1,446 @@
+package backtest
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVPricing selects which side of the quotes is used for prices
+type CSVPricing int
+
+const (
+	// CSVPricingMid uses the mean of bid and ask or the price columns if no ask columns are mapped
+	CSVPricingMid CSVPricing = iota
+	CSVPricingBid
+	CSVPricingAsk
+)
+
+const (
+	// CSVTimeFormatUnix parses the time column as seconds since epoch
+	CSVTimeFormatUnix = "unix"
+	// CSVTimeFormatUnixMilli parses the time column as milliseconds since epoch
+	CSVTimeFormatUnixMilli = "unixms"
+)
+
+// CSVColumns maps fields to CSV columns. A column is either a zero-based index (e.g. "0") or
+// a header name if the file has a header. Empty columns are not used.
+type CSVColumns struct {
+	// Date is optional and prepended to Time with a space if the file has separate columns
+	Date string
+	Time string
+
+	// Candles; Open, High, Low and Close contain bid prices if ask columns are mapped
+	Open     string
+	High     string
+	Low      string
+	Close    string
+	AskOpen  string
+	AskHigh  string
+	AskLow   string
+	AskClose string
+
+	// Ticks; Price is used for files containing only one price per tick
+	Bid   string
+	Ask   string
+	Price string
+
+	// Volume of candles, optional
+	Volume string
+}
+
+// CSVFormat describes the layout of CSV quote files
+type CSVFormat struct {
+	Columns    CSVColumns
+	Delimiter  rune           // Defaults to ','
+	HasHeader  bool           // First line contains column names
+	TimeFormat string         // Go time layout, CSVTimeFormatUnix or CSVTimeFormatUnixMilli
+	Location   *time.Location // Timezone of the time column; defaults to UTC
+	Pricing    CSVPricing
+
+	// Ticks is true if every line is a tick instead of a candle
+	Ticks bool
+
+	// CandleDuration is the duration of a candle in the file. Defaults to the price DB candle duration.
+	CandleDuration time.Duration
+}
+
+// csvReader reads records of one CSV file with resolved column indexes
+type csvReader struct {
+	format  CSVFormat
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader, format CSVFormat) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if format.Delimiter != 0 {
+		reader.Comma = format.Delimiter
+	}
+	if format.Location == nil {
+		format.Location = time.UTC
+	}
+
+	var header []string
+	if format.HasHeader {
+		record, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read header: %w", err)
+		}
+		header = append(header, record...)
+	}
+
+	var columns = map[string]int{}
+	for field, column := range format.Columns.fields() {
+		if column == "" {
+			continue
+		}
+		index, err := columnIndex(column, header)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field, err)
+		}
+		columns[field] = index
+	}
+
+	if _, exists := columns["Time"]; !exists {
+		return nil, fmt.Errorf("no time column given")
+	}
+
+	return &csvReader{format: format, reader: reader, columns: columns}, nil
+}
+
+func (c CSVColumns) fields() map[string]string {
+	return map[string]string{
+		"Date":     c.Date,
+		"Time":     c.Time,
+		"Open":     c.Open,
+		"High":     c.High,
+		"Low":      c.Low,
+		"Close":    c.Close,
+		"AskOpen":  c.AskOpen,
+		"AskHigh":  c.AskHigh,
+		"AskLow":   c.AskLow,
+		"AskClose": c.AskClose,
+		"Bid":      c.Bid,
+		"Ask":      c.Ask,
+		"Price":    c.Price,
+		"Volume":   c.Volume,
+	}
+}
+
+func columnIndex(column string, header []string) (int, error) {
+	if index, err := strconv.Atoi(column); err == nil {
+		if index < 0 {
+			return 0, fmt.Errorf("negative index %d", index)
+		}
+		return index, nil
+	}
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown column %q", column)
+}
+
+func (c *csvReader) has(field string) bool {
+	_, exists := c.columns[field]
+	return exists
+}
+
+func (c *csvReader) value(record []string, field string) (string, error) {
+	index := c.columns[field]
+	if index >= len(record) {
+		return "", fmt.Errorf("missing column %s", field)
+	}
+	return strings.TrimSpace(record[index]), nil
+}
+
+func (c *csvReader) decimal(record []string, field string) (decimal.Decimal, error) {
+	value, err := c.value(record, field)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.NewFromString(value)
+}
+
+func (c *csvReader) time(record []string) (time.Time, error) {
+	value, err := c.value(record, "Time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if c.has("Date") {
+		date, err := c.value(record, "Date")
+		if err != nil {
+			return time.Time{}, err
+		}
+		value = date + " " + value
+	}
+
+	switch c.format.TimeFormat {
+	case CSVTimeFormatUnix, CSVTimeFormatUnixMilli:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if c.format.TimeFormat == CSVTimeFormatUnix {
+			return time.Unix(n, 0).In(c.format.Location), nil
+		}
+		return time.UnixMilli(n).In(c.format.Location), nil
+	case "":
+		return time.ParseInLocation(time.RFC3339, value, c.format.Location)
+	default:
+		return time.ParseInLocation(c.format.TimeFormat, value, c.format.Location)
+	}
+}
+
+// price returns the price of the given bid and ask column according to the pricing
+func (c *csvReader) price(record []string, bidField, askField string) (decimal.Decimal, error) {
+	bid, err := c.decimal(record, bidField)
+	if err != nil || !c.has(askField) {
+		return bid, err
+	}
+	ask, err := c.decimal(record, askField)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	switch c.format.Pricing {
+	case CSVPricingBid:
+		return bid, nil
+	case CSVPricingAsk:
+		return ask, nil
+	default:
+		return decimal.Avg(bid, ask), nil
+	}
+}
+
+func (c *csvReader) read() ([]string, error) {
+	return c.reader.Read()
+}
+
+func (c *csvReader) toCandle(instrument string, record []string, duration time.Duration) (ohlc.OHLC, error) {
+	start, err := c.time(record)
+	if err != nil {
+		return ohlc.OHLC{}, err
+	}
+
+	var prices = map[string]decimal.Decimal{}
+	for _, field := range []string{"Open", "High", "Low", "Close"} {
+		if !c.has(field) {
+			return ohlc.OHLC{}, fmt.Errorf("no %s column given", field)
+		}
+		price, err := c.price(record, field, "Ask"+field)
+		if err != nil {
+			return ohlc.OHLC{}, fmt.Errorf("cannot parse %s: %w", field, err)
+		}
+		prices[field] = price
+	}
+
+	volume, err := c.volume(record)
+	if err != nil {
+		return ohlc.OHLC{}, err
+	}
+
+	return ohlc.OHLC{
+		Instrument: instrument,
+		Open:       prices["Open"],
+		High:       prices["High"],
+		HighTime:   start,
+		Low:        prices["Low"],
+		LowTime:    start,
+		Close:      prices["Close"],
+		Start:      start,
+		End:        start.Add(duration),
+		Duration:   duration,
+		Volume:     volume,
+	}, nil
+}
+
+func (c *csvReader) toTick(instrument string, record []string) (tick.Tick, error) {
+	t, err := c.priceTick(instrument, record)
+	if err != nil {
+		return tick.Tick{}, err
+	}
+	if t.Volume, err = c.volume(record); err != nil {
+		return tick.Tick{}, err
+	}
+	return t, nil
+}
+
+func (c *csvReader) priceTick(instrument string, record []string) (tick.Tick, error) {
+	datetime, err := c.time(record)
+	if err != nil {
+		return tick.Tick{}, err
+	}
+
+	if c.has("Price") {
+		price, err := c.decimal(record, "Price")
+		if err != nil {
+			return tick.Tick{}, fmt.Errorf("cannot parse price: %w", err)
+		}
+		return tick.New(instrument, datetime, price, price), nil
+	}
+
+	if !c.has("Bid") || !c.has("Ask") {
+		return tick.Tick{}, fmt.Errorf("no price or bid and ask columns given")
+	}
+	bid, err := c.decimal(record, "Bid")
+	if err != nil {
+		return tick.Tick{}, fmt.Errorf("cannot parse bid: %w", err)
+	}
+	ask, err := c.decimal(record, "Ask")
+	if err != nil {
+		return tick.Tick{}, fmt.Errorf("cannot parse ask: %w", err)
+	}
+
+	switch c.format.Pricing {
+	case CSVPricingBid:
+		ask = bid
+	case CSVPricingAsk:
+		bid = ask
+	}
+	return tick.New(instrument, datetime, bid, ask), nil
+}
+
+// volume returns the optional volume of the record, zero without volume column
+func (c *csvReader) volume(record []string) (float64, error) {
+	if !c.has("Volume") {
+		return 0, nil
+	}
+	value, err := c.decimal(record, "Volume")
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse Volume: %w", err)
+	}
+	return value.InexactFloat64(), nil
+}
+
+// openCSVFile opens the given file and decompresses it if it ends with .gz
+func openCSVFile(file string) (io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(file, ".gz") {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	if err := g.Reader.Close(); err != nil {
+		_ = g.file.Close()
+		return err
+	}
+	return g.file.Close()
+}
+
+func (b *Backtest) csvFilesByInstrument(instrument string) []string {
+	if files, exists := b.csvFiles[instrument]; exists {
+		return files
+	}
+	return b.tickDataFiles
+}
+
+func (b *Backtest) inPeriod(t time.Time) bool {
+	if !b.periodFrom.IsZero() && t.Before(b.periodFrom) {
+		return false
+	}
+	if !b.periodTo.IsZero() && t.After(b.periodTo) {
+		return false
+	}
+	return true
+}
+
+// readCSVFiles reads all CSV files of the instrument in the given order and calls handle for each record
+func (b *Backtest) readCSVFiles(instrument string, handle func(file string, reader *csvReader, record []string)) {
+	for _, file := range b.csvFilesByInstrument(instrument) {
+		clog := log.WithFields(log.Fields{
+			"FILE":       file,
+			"INSTRUMENT": instrument,
+		})
+		clog.Info("Reading quotes from CSV file")
+
+		f, err := openCSVFile(file)
+		if err != nil {
+			clog.WithError(err).Fatal("unable to open CSV file")
+		}
+
+		reader, err := newCSVReader(f, b.csvFormat)
+		if err != nil {
+			clog.WithError(err).Fatal("unable to read CSV file")
+		}
+
+		for {
+			record, err := reader.read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				clog.WithError(err).Fatal("reading CSV file failed")
+			}
+			handle(file, reader, record)
+		}
+
+		if err := f.Close(); err != nil {
+			clog.WithError(err).Warn("cannot close file handle")
+		}
+	}
+}
+
+func (b *Backtest) retrieveCandlesFromCSV(instrument string, receiver chan ohlc.OHLC) {
+	defer close(receiver)
+
+	var duration = b.csvFormat.CandleDuration
+	if duration == 0 {
+		duration = b.priceDBCandleDuration
+	}
+
+	b.readCSVFiles(instrument, func(file string, reader *csvReader, record []string) {
+		candle, err := reader.toCandle(instrument, record, duration)
+		if err != nil {
+			log.WithError(err).Warnf("Ignoring malformed line in %q: %v", file, record)
+			return
+		}
+		if b.inPeriod(candle.Start) {
+			receiver <- candle
+		}
+	})
+}
+
+func (b *Backtest) retrieveTicksFromCSV(instrument string, receiver chan tick.Tick) {
+	defer close(receiver)
+
+	b.readCSVFiles(instrument, func(file string, reader *csvReader, record []string) {
+		currentTick, err := reader.toTick(instrument, record)
+		if err != nil {
+			log.WithError(err).Warnf("Ignoring malformed line in %q: %v", file, record)
+			return
+		}
+		if b.inPeriod(currentTick.Datetime) {
+			receiver <- currentTick
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package backtest
+
+import (
+	"compress/gzip"
+	"github.com/AMekss/assert"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, file, content string) {
+	if filepath.Ext(file) != ".gz" {
+		assert.NoError(t.Fatalf, os.WriteFile(file, []byte(content), 0600))
+		return
+	}
+
+	f, err := os.Create(file)
+	assert.NoError(t.Fatalf, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(content))
+	assert.NoError(t.Fatalf, err)
+	assert.NoError(t.Fatalf, gz.Close())
+	assert.NoError(t.Fatalf, f.Close())
+}
+
+func TestBacktest_retrieveCandlesFromCSV(t *testing.T) {
+	var dir = t.TempDir()
+	var first = filepath.Join(dir, "1.csv")
+	var second = filepath.Join(dir, "2.csv.gz")
+	writeFile(t, first, "date;time;bid_open;bid_high;bid_low;bid_close;ask_open;ask_high;ask_low;ask_close;volume\n"+
+		"2021.01.04;10:00;1.0;1.4;0.8;1.2;1.2;1.6;1.0;1.4;120\n"+
+		"2021.01.04;11:00;broken;1;1;1;1;1;1;1;1\n")
+	writeFile(t, second, "date;time;bid_open;bid_high;bid_low;bid_close;ask_open;ask_high;ask_low;ask_close;volume\n"+
+		"2021.01.04;12:00;2.0;2.0;2.0;2.0;2.2;2.2;2.2;2.2;80\n"+
+		"2021.02.01;12:00;3.0;3.0;3.0;3.0;3.0;3.0;3.0;3.0;1\n")
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t.Fatalf, err)
+
+	b := New("EURUSD", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC),
+		paperwallet.New(),
+		WithQuotesSource(QuotesSourceCSV),
+		WithTickDataFiles([]string{first, second}),
+		WithCSVFormat(CSVFormat{
+			Columns: CSVColumns{
+				Date: "date", Time: "time",
+				Open: "bid_open", High: "bid_high", Low: "bid_low", Close: "bid_close",
+				AskOpen: "ask_open", AskHigh: "ask_high", AskLow: "ask_low", AskClose: "ask_close",
+				Volume: "volume",
+			},
+			Delimiter:      ';',
+			HasHeader:      true,
+			TimeFormat:     "2006.01.02 15:04",
+			Location:       loc,
+			CandleDuration: time.Hour,
+		}),
+	)
+
+	var c = make(chan ohlc.OHLC)
+	go b.retrieveCandles("EURUSD", c)
+
+	var candles []ohlc.OHLC
+	for candle := range c {
+		candles = append(candles, candle)
+	}
+
+	assert.EqualInt(t.Fatalf, 2, len(candles))
+	assert.EqualStrings(t, "EURUSD", candles[0].Instrument)
+	assert.EqualStrings(t, "1.1", candles[0].Open.String())
+	assert.EqualStrings(t, "1.5", candles[0].High.String())
+	assert.EqualStrings(t, "0.9", candles[0].Low.String())
+	assert.EqualStrings(t, "1.3", candles[0].Close.String())
+	assert.EqualTime(t, time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC), candles[0].Start)
+	assert.EqualTime(t, time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC), candles[0].End)
+	assert.EqualStrings(t, "2.1", candles[1].Close.String())
+	assert.EqualFloat64(t, 120, candles[0].Volume)
+}
+
+func TestBacktest_retrieveTicksFromCSV(t *testing.T) {
+	var file = filepath.Join(t.TempDir(), "ticks.csv")
+	writeFile(t, file, "1609459200000,1.1,1.2,5\n1609459201000,1.3,1.4,2.5\n")
+
+	b := New("EURUSD", time.Time{}, time.Time{}, paperwallet.New(),
+		WithQuotesSource(QuotesSourceCSV),
+		WithInstrumentCSVFiles("EURUSD", file),
+		WithCSVFormat(CSVFormat{
+			Columns:    CSVColumns{Time: "0", Bid: "1", Ask: "2", Volume: "3"},
+			TimeFormat: CSVTimeFormatUnixMilli,
+			Pricing:    CSVPricingBid,
+			Ticks:      true,
+		}),
+	)
+
+	var c = make(chan tick.Tick)
+	go b.retrieveTicks("EURUSD", c)
+
+	var ticks []tick.Tick
+	for currentTick := range c {
+		ticks = append(ticks, currentTick)
+	}
+
+	assert.EqualInt(t.Fatalf, 2, len(ticks))
+	assert.EqualTime(t, time.Date(2021, 1, 1, 0, 0, 1, 0, time.UTC), ticks[1].Datetime)
+	assert.EqualStrings(t, "1.3", ticks[1].Bid.String())
+	assert.EqualStrings(t, "1.3", ticks[1].Ask.String())
+	assert.EqualFloat64(t, 2.5, ticks[1].Volume)
+}
+
+func Test_columnIndex(t *testing.T) {
+	var header = []string{"Time", " Bid "}
+
+	index, err := columnIndex("bid", header)
+	assert.NoError(t, err)
+	assert.EqualInt(t, 1, index)
+
+	index, err = columnIndex("3", header)
+	assert.NoError(t, err)
+	assert.EqualInt(t, 3, index)
+
+	_, err = columnIndex("ask", header)
+	assert.True(t, err != nil)
+}
@@ -0,0 +1,62 @@
+package backtest
+
+import (
+	"github.com/sklinkert/at/pkg/tick"
+	"time"
+)
+
+type feedHead struct {
+	feed        chan tick.Tick
+	currentTick tick.Tick
+	sortKey     time.Time
+	exhausted   bool
+}
+
+func (h *feedHead) next() {
+	currentTick, ok := <-h.feed
+	if !ok {
+		h.exhausted = true
+		return
+	}
+	h.currentTick = currentTick
+	// Ticks without timestamp keep the position of their predecessor
+	if !currentTick.Datetime.IsZero() {
+		h.sortKey = currentTick.Datetime
+	}
+}
+
+// mergeTicks merges several time-ordered tick feeds into one time-ordered feed.
+// Ticks with the same timestamp are emitted in the order of the given feeds.
+func mergeTicks(feeds []chan tick.Tick) chan tick.Tick {
+	var merged = make(chan tick.Tick)
+
+	go func() {
+		defer close(merged)
+
+		var heads []*feedHead
+		for _, feed := range feeds {
+			head := &feedHead{feed: feed}
+			head.next()
+			heads = append(heads, head)
+		}
+
+		for {
+			var earliest *feedHead
+			for _, head := range heads {
+				if head.exhausted {
+					continue
+				}
+				if earliest == nil || head.sortKey.Before(earliest.sortKey) {
+					earliest = head
+				}
+			}
+			if earliest == nil {
+				return
+			}
+			merged <- earliest.currentTick
+			earliest.next()
+		}
+	}()
+
+	return merged
+}
@@ -0,0 +1,44 @@
+package backtest
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"testing"
+	"time"
+)
+
+func sendTicks(instrument string, start time.Time, minutes []int) chan tick.Tick {
+	var feed = make(chan tick.Tick)
+	go func() {
+		defer close(feed)
+		for _, minute := range minutes {
+			price := decimal.NewFromFloat(float64(minute))
+			feed <- tick.New(instrument, start.Add(time.Duration(minute)*time.Minute), price, price)
+		}
+	}()
+	return feed
+}
+
+func Test_mergeTicks(t *testing.T) {
+	var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var feeds = []chan tick.Tick{
+		sendTicks("A", start, []int{0, 2, 4, 5}),
+		sendTicks("B", start, []int{1, 2, 3}),
+		sendTicks("C", start, []int{}),
+	}
+
+	var merged []tick.Tick
+	for currentTick := range mergeTicks(feeds) {
+		merged = append(merged, currentTick)
+	}
+
+	assert.EqualInt(t.Fatalf, 7, len(merged))
+	var wantInstruments = []string{"A", "B", "A", "B", "B", "A", "A"}
+	for i := range merged {
+		assert.EqualStrings(t, wantInstruments[i], merged[i].Instrument)
+		if i > 0 {
+			assert.False(t, merged[i].Datetime.Before(merged[i-1].Datetime))
+		}
+	}
+}
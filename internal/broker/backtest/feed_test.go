@@ -0,0 +1,50 @@
+package backtest
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBacktest_retrieveCandlesFromSQLite(t *testing.T) {
+	var dbFile = filepath.Join(t.TempDir(), "prices.db")
+	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+	assert.NoError(t.Fatalf, db.AutoMigrate(&ohlc.OHLC{}))
+	var start = time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+	for i, instrument := range []string{"EURUSD", "GBPUSD", "EURUSD"} {
+		candle := ohlc.New(instrument, start.Add(time.Minute*time.Duration(i)), time.Minute, false)
+		candle.NewPrice(decimal.NewFromInt(int64(i+1)), candle.Start)
+		candle.ForceClose()
+		assert.NoError(t.Fatalf, db.Create(candle).Error)
+	}
+
+	b := New("EURUSD", start.AddDate(0, 0, -1), start.AddDate(0, 0, 1), paperwallet.New(),
+		WithQuotesSource(QuotesSourceSqlite),
+		WithPriceDBFile(dbFile, time.Minute),
+		WithInstruments("GBPUSD"))
+
+	for instrument, want := range map[string][]string{"EURUSD": {"1", "3"}, "GBPUSD": {"2"}} {
+		var c = make(chan ohlc.OHLC)
+		go b.retrieveCandles(instrument, c)
+		var closes []string
+		for candle := range c {
+			assert.EqualStrings(t, instrument, candle.Instrument)
+			closes = append(closes, candle.Close.String())
+		}
+		assert.EqualInt(t.Fatalf, len(want), len(closes))
+		for i := range want {
+			assert.EqualStrings(t, want[i], closes[i])
+		}
+	}
+
+	filter, err := b.sqliteInstrumentFilter(db, "USDJPY", dbFile)
+	assert.False(t, filter)
+	assert.ErrorIncludesMessage(t, "separate DB file per instrument", err)
+}
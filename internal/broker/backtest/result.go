@@ -0,0 +1,94 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files of the result bundle written into the output directory
+const (
+	ResultFileTrades  = "trades.csv"
+	ResultFileEquity  = "equity.csv"
+	ResultFileMetrics = "metrics.json"
+	ResultFileChart   = "chart.html"
+	ResultFileConfig  = "config.json"
+)
+
+const defaultOutputDir = "./results"
+
+// ResultFileTraderChart returns the name of the chart of one trader of a portfolio, e.g. chart_sma10_EURUSD.html
+func ResultFileTraderChart(key string) string {
+	return "chart_" + strings.ReplaceAll(key, "/", "_") + ".html"
+}
+
+// EquityPoint is the balance after a position has been closed
+type EquityPoint struct {
+	Time        time.Time
+	Instrument  string
+	Performance decimal.Decimal
+	Equity      decimal.Decimal
+}
+
+// OutputDir returns the directory of the result bundle
+func (b *Backtest) OutputDir() string {
+	return b.outputDir
+}
+
+// WriteResultFile writes content into a file of the result bundle
+func (b *Backtest) WriteResultFile(name string, content []byte) error {
+	file, err := b.createResultFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteResultJSON writes v as indented JSON into a file of the result bundle
+func (b *Backtest) WriteResultJSON(name string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s: %w", name, err)
+	}
+	return b.WriteResultFile(name, content)
+}
+
+// createResultFile creates the output directory if missing and the file inside of it
+func (b *Backtest) createResultFile(name string) (*os.File, error) {
+	if err := os.MkdirAll(b.outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create output directory %q: %w", b.outputDir, err)
+	}
+	return os.Create(filepath.Join(b.outputDir, name))
+}
+
+func equityCurve(initialBalance decimal.Decimal, closedPositions []broker.Position) []EquityPoint {
+	var positions = make([]broker.Position, len(closedPositions))
+	copy(positions, closedPositions)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].SellTime.Before(positions[j].SellTime)
+	})
+
+	var equity = initialBalance
+	var points []EquityPoint
+	for _, position := range positions {
+		perf := decimal.NewFromFloat(position.PerformanceAbsolute(decimal.Zero, decimal.Zero))
+		equity = equity.Add(perf)
+		points = append(points, EquityPoint{
+			Time:        position.SellTime,
+			Instrument:  position.Instrument,
+			Performance: perf,
+			Equity:      equity,
+		})
+	}
+	return points
+}
@@ -15,7 +15,7 @@ func (b *Backtest) writeCSV() {
 	b.RLock()
 	defer b.RUnlock()
 
-	file, err := os.Create("./results/backtesting_result.csv")
+	file, err := b.createResultFile(ResultFileTrades)
 	if err != nil {
 		log.WithError(err).Error("creating CSV file failed")
 		return
@@ -32,6 +32,8 @@ func (b *Backtest) writeCSV() {
 
 	header := []string{
 		"#",
+		"Instrument",
+		"Tag",
 		"Weekday",
 		"BuyTime",
 		"SellTime",
@@ -57,20 +59,15 @@ func (b *Backtest) writeCSV() {
 	}
 
 	closedPositions, _ := b.GetClosedPositions()
-	csvPrintPosition(writer, closedPositions)
+	csvPrintPosition(writer, closedPositions, b.location)
 
 	openPositions, _ := b.GetOpenPositions()
-	csvPrintPosition(writer, openPositions)
+	csvPrintPosition(writer, openPositions, b.location)
 }
 
-func csvPrintPosition(writer *csv.Writer, positions []broker.Position) {
+func csvPrintPosition(writer *csv.Writer, positions []broker.Position, location *time.Location) {
 	var totalPerfPips decimal.Decimal
 
-	locBerlin, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		log.WithError(err).Fatal("cannot load time zone")
-	}
-
 	for i, position := range positions {
 		targetInPips := helper.Cent2Pips(position.TargetPrice.Sub(position.BuyPrice)).Round(2)
 		stopLossInPips := helper.Cent2Pips(position.BuyPrice.Sub(position.StopLossPrice)).Round(2)
@@ -85,9 +82,11 @@ func csvPrintPosition(writer *csv.Writer, positions []broker.Position) {
 
 		record := []string{
 			fmt.Sprintf("%d", i+1),
-			position.BuyTime.In(locBerlin).Weekday().String(),
-			position.BuyTime.In(locBerlin).Format("2006-01-02 15:04:05"),
-			position.SellTime.In(locBerlin).Format("2006-01-02 15:04:05"),
+			position.Instrument,
+			position.Tag,
+			position.BuyTime.In(location).Weekday().String(),
+			position.BuyTime.In(location).Format("2006-01-02 15:04:05"),
+			position.SellTime.In(location).Format("2006-01-02 15:04:05"),
 			position.BuyDirection.String(),
 			fmt.Sprintf("%.1f", position.Size),
 			position.BuyPrice.Round(5).String(),
@@ -110,3 +109,41 @@ func csvPrintPosition(writer *csv.Writer, positions []broker.Position) {
 		}
 	}
 }
+
+// writeEquityCSV writes the balance after each closed position
+func (b *Backtest) writeEquityCSV() {
+	b.RLock()
+	defer b.RUnlock()
+
+	file, err := b.createResultFile(ResultFileEquity)
+	if err != nil {
+		log.WithError(err).Error("creating equity CSV file failed")
+		return
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			log.WithError(err).Warn("file.Close() failed")
+		}
+	}(file)
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write([]string{"Time", "Instrument", "Performance", "Equity"}); err != nil {
+		log.WithError(err).Fatal("cannot write to file")
+	}
+
+	closedPositions, _ := b.GetClosedPositions()
+	for _, point := range equityCurve(b.paperwallet.GetInitialBalance(), closedPositions) {
+		record := []string{
+			point.Time.UTC().Format(time.RFC3339),
+			point.Instrument,
+			point.Performance.String(),
+			point.Equity.String(),
+		}
+		if err := writer.Write(record); err != nil {
+			log.WithError(err).Fatal("cannot write to file")
+		}
+	}
+}
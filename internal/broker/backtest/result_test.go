@@ -0,0 +1,48 @@
+package backtest
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_equityCurve(t *testing.T) {
+	var now = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	var positions = []broker.Position{
+		{Instrument: "B", BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(2), SellPrice: decimal.NewFromFloat(1), SellTime: now.Add(time.Hour), Size: 1},
+		{Instrument: "A", BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(1), SellPrice: decimal.NewFromFloat(3), SellTime: now, Size: 1},
+	}
+
+	points := equityCurve(decimal.NewFromFloat(100), positions)
+	assert.EqualInt(t.Fatalf, 2, len(points))
+	assert.EqualStrings(t, "A", points[0].Instrument)
+	assert.EqualStrings(t, "102", points[0].Equity.String())
+	assert.EqualStrings(t, "101", points[1].Equity.String())
+}
+
+func TestBacktest_WriteResultFile(t *testing.T) {
+	var dir = filepath.Join(t.TempDir(), "run", "1")
+	b := New("EURUSD", time.Time{}, time.Time{}, paperwallet.New(), WithOutputDir(dir))
+
+	assert.NoError(t.Fatalf, b.WriteResultJSON(ResultFileConfig, map[string]string{"Strategy": "rsi"}))
+	content, err := os.ReadFile(filepath.Join(dir, ResultFileConfig))
+	assert.NoError(t.Fatalf, err)
+	assert.IncludesString(t, `"Strategy": "rsi"`, string(content))
+
+	b.writeCSV()
+	b.writeEquityCSV()
+	for _, name := range []string{ResultFileTrades, ResultFileEquity} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+	}
+}
+
+func TestResultFileTraderChart(t *testing.T) {
+	assert.EqualStrings(t, "chart_EURUSD.html", ResultFileTraderChart("EURUSD"))
+	assert.EqualStrings(t, "chart_sma10_EURUSD.html", ResultFileTraderChart("sma10/EURUSD"))
+}
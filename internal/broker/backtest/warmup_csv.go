@@ -0,0 +1,140 @@
+package backtest
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type csvProvider struct {
+	dir    string
+	format CSVFormat
+	day    ohlc.TradingDay
+}
+
+// NewCSVProvider provides warm-up candles from the CSV files in dir whose names begin with the
+// instrument, e.g. EURUSD_2021.csv.gz, read in the order of their names. Ticks and shorter candles
+// are aggregated to the requested duration.
+func NewCSVProvider(dir string, format CSVFormat, day ohlc.TradingDay) warmup.Provider {
+	return csvProvider{dir: dir, format: format, day: day}
+}
+
+func (p csvProvider) Name() string {
+	return "csv"
+}
+
+func (p csvProvider) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	var sourceDuration = p.format.CandleDuration
+	if sourceDuration == 0 {
+		sourceDuration = time.Minute
+	}
+	if !p.format.Ticks && sourceDuration > duration {
+		return nil, fmt.Errorf("cannot build %s candles from %s candles", duration, sourceDuration)
+	}
+
+	files, err := p.files(instrument)
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []ohlc.OHLC
+	var keep = func(closed []*ohlc.OHLC) {
+		for _, candle := range closed {
+			candles = append(candles, *candle)
+		}
+		if len(candles) > limit {
+			candles = candles[len(candles)-limit:]
+		}
+	}
+
+	var aggregator = ohlc.NewAggregator(instrument, duration, p.day)
+	var lastEnd time.Time
+	for _, file := range files {
+		err := readCSVFile(file, p.format, func(reader *csvReader, record []string) {
+			if p.format.Ticks {
+				currentTick, err := reader.toTick(instrument, record)
+				if err != nil {
+					log.WithError(err).Warnf("Ignoring malformed line in %q: %v", file, record)
+					return
+				}
+				if !currentTick.Datetime.After(to) {
+					keep(aggregator.AddTick(currentTick))
+					lastEnd = currentTick.Datetime
+				}
+				return
+			}
+
+			candle, err := reader.toCandle(instrument, record, sourceDuration)
+			if err != nil {
+				log.WithError(err).Warnf("Ignoring malformed line in %q: %v", file, record)
+				return
+			}
+			if !candle.End.After(to) {
+				keep(aggregator.AddCandle(&candle))
+				lastEnd = candle.End
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	keep(aggregator.Advance(lastEnd))
+
+	return candles, nil
+}
+
+// files returns the CSV files of the instrument sorted by name
+func (p csvProvider) files(instrument string) ([]string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		var name = entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(strings.ToUpper(name), strings.ToUpper(instrument)) {
+			continue
+		}
+		if strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".csv.gz") {
+			files = append(files, filepath.Join(p.dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readCSVFile calls handle for each record of the file
+func readCSVFile(file string, format CSVFormat, handle func(reader *csvReader, record []string)) error {
+	f, err := openCSVFile(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.WithError(err).Warnf("cannot close %q", file)
+		}
+	}()
+
+	reader, err := newCSVReader(f, format)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for {
+		record, err := reader.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		handle(reader, record)
+	}
+}
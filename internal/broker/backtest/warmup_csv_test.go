@@ -0,0 +1,42 @@
+package backtest
+
+import (
+	"fmt"
+	"github.com/AMekss/assert"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVProvider_Candles(t *testing.T) {
+	var dir = t.TempDir()
+	var lines = []string{"time,open,high,low,close,volume"}
+	for minute := 0; minute < 12; minute++ {
+		var price = 1 + float64(minute)/10
+		lines = append(lines, fmt.Sprintf("2021-01-04 10:%02d,%.1f,%.1f,%.1f,%.1f,10", minute, price, price, price, price))
+	}
+	writeFile(t, filepath.Join(dir, "EURUSD_1.csv"), strings.Join(lines[:6], "\n"))
+	writeFile(t, filepath.Join(dir, "EURUSD_2.csv.gz"), strings.Join(append(lines[:1], lines[6:]...), "\n"))
+	writeFile(t, filepath.Join(dir, "GBPUSD_1.csv"), strings.Join(lines, "\n"))
+
+	var format = CSVFormat{
+		Columns:        CSVColumns{Time: "time", Open: "open", High: "high", Low: "low", Close: "close", Volume: "volume"},
+		HasHeader:      true,
+		TimeFormat:     "2006-01-02 15:04",
+		CandleDuration: time.Minute,
+	}
+	var provider = NewCSVProvider(dir, format, ohlc.TradingDay{Location: time.UTC})
+
+	candles, err := provider.Candles("EURUSD", time.Minute*5, time.Date(2021, 1, 4, 10, 10, 0, 0, time.UTC), 1)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(candles))
+	assert.EqualTime(t, time.Date(2021, 1, 4, 10, 5, 0, 0, time.UTC), candles[0].Start)
+	assert.EqualStrings(t, "1.5", candles[0].Open.String())
+	assert.EqualStrings(t, "1.9", candles[0].Close.String())
+	assert.EqualFloat64(t, 50, candles[0].Volume)
+
+	_, err = provider.Candles("EURUSD", time.Second*30, time.Date(2021, 1, 4, 10, 10, 0, 0, time.UTC), 1)
+	assert.ErrorIncludesMessage(t, "cannot build 30s candles from 1m0s candles", err)
+}
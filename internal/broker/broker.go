@@ -34,5 +34,19 @@ type Broker interface {
 	GetOpenOrders() ([]Order, error)
 	GetOpenPositionsByInstrument(instrument string) ([]Position, error)
 	GetClosedPositions() ([]Position, error)
-	ListenToPriceFeed(chan tick.Tick)
+	ListenToPriceFeed(chan tick.Tick) // closes the channel when the feed ends
+}
+
+// Reconnector is implemented by brokers whose price feed can be reconnected, e.g. when the trader detects a
+// stale feed
+type Reconnector interface {
+	// Reconnect drops the connection of the price feed. ListenToPriceFeed connects again and keeps sending
+	// ticks to the same channel. Must not block.
+	Reconnect()
+}
+
+// OwnerRegistry is implemented by brokers which only store a key of the owner with orders, e.g. IG in the deal
+// reference. Traders register their owner so the broker can resolve the keys of positions opened before a restart.
+type OwnerRegistry interface {
+	RegisterOwner(owner string)
 }
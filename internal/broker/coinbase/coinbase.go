@@ -1,15 +1,19 @@
 package coinbase
 
 import (
+	ws "github.com/gorilla/websocket"
 	"github.com/preichenberger/go-coinbasepro/v2"
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/internal/paperwallet"
+	"sync"
 )
 
 type Coinbase struct {
 	paperwallet *paperwallet.Paperwallet
 	cbClient    *coinbasepro.Client
 	instruments []string
+	wsConn      *ws.Conn // connection of the price feed
+	sync.Mutex
 }
 
 func New(instrument string, paperwallet *paperwallet.Paperwallet) *Coinbase {
@@ -0,0 +1,60 @@
+package coinbase
+
+import (
+	"github.com/preichenberger/go-coinbasepro/v2"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"sort"
+	"time"
+)
+
+// maxHistoricRates is the maximum number of candles of one historic rates request
+const maxHistoricRates = 300
+
+type historicRates struct {
+	cb *Coinbase
+}
+
+// WarmUpProvider provides warm-up candles from the historic rates of Coinbase. Coinbase supports
+// candles of 1m, 5m, 15m, 1h, 6h and 1d and returns up to 300 candles.
+func (cb *Coinbase) WarmUpProvider() warmup.Provider {
+	return historicRates{cb: cb}
+}
+
+func (h historicRates) Name() string {
+	return "coinbase"
+}
+
+func (h historicRates) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	if limit > maxHistoricRates {
+		limit = maxHistoricRates
+	}
+	rates, err := h.cb.cbClient.GetHistoricRates(instrument, coinbasepro.GetHistoricRatesParams{
+		Start:       to.Add(-duration * time.Duration(limit)),
+		End:         to,
+		Granularity: int(duration.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candles ohlc.OHLCList
+	for _, rate := range rates {
+		candles = append(candles, ohlc.OHLC{
+			Instrument: instrument,
+			Open:       decimal.NewFromFloat(rate.Open),
+			High:       decimal.NewFromFloat(rate.High),
+			HighTime:   rate.Time,
+			Low:        decimal.NewFromFloat(rate.Low),
+			LowTime:    rate.Time,
+			Close:      decimal.NewFromFloat(rate.Close),
+			Start:      rate.Time,
+			End:        rate.Time.Add(duration),
+			Duration:   duration,
+			Volume:     rate.Volume,
+		})
+	}
+	sort.Sort(candles)
+	return candles, nil
+}
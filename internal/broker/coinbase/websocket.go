@@ -71,12 +71,16 @@ func (cb *Coinbase) ListenToPriceFeed(tickChan chan tick.Tick) {
 
 		log.Infof("Connected to websocket for instruments %+v", cb.instruments)
 		retryDelay = defaultRetryDelay
+		cb.Lock()
+		cb.wsConn = wsConn
+		cb.Unlock()
 
 		const messageType = "ticker"
 		for true {
 			message := coinbasepro.Message{}
 			if err := wsConn.ReadJSON(&message); err != nil {
-				println(err.Error())
+				log.WithError(err).Warn("Reading from websocket failed, reconnecting")
+				_ = wsConn.Close()
 				break
 			}
 			if message.Type != messageType {
@@ -95,6 +99,9 @@ func (cb *Coinbase) ListenToPriceFeed(tickChan chan tick.Tick) {
 			}
 
 			tickData := tick.New(message.ProductID, message.Time.Time(), bid, ask)
+			if size, err := decimal.NewFromString(message.LastSize); err == nil {
+				tickData.Volume = size.InexactFloat64()
+			}
 			if err := tickData.Validate(); err != nil {
 				log.WithError(err).Warnf("Invalid tick: %s", tickData.String())
 				continue
@@ -104,3 +111,13 @@ func (cb *Coinbase) ListenToPriceFeed(tickChan chan tick.Tick) {
 		}
 	}
 }
+
+// Reconnect closes the websocket, ListenToPriceFeed connects again
+func (cb *Coinbase) Reconnect() {
+	cb.Lock()
+	defer cb.Unlock()
+	if cb.wsConn != nil {
+		_ = cb.wsConn.Close()
+		cb.wsConn = nil
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
 	"github.com/sklinkert/at/pkg/tick"
 	"github.com/sklinkert/igmarkets"
+	"hash/fnv"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,37 +20,70 @@ import (
 const openPositionsRefreshInterval = time.Second * 5
 const closedPositionsRefreshInterval = time.Second * 5
 const maxTokenRefreshFailures = 5
+const dealReferencePrefix = "at-"
+const ownedDealReferencePrefix = "at_" // followed by the owner key
+const ownerKeyLength = 7
+
+// deal keeps the order data IG doesn't store
+type deal struct {
+	tag   string
+	owner string
+}
 
 type Broker struct {
 	igHandle                   *igmarkets.IGMarkets
 	watchlistID                string
 	instrument                 string
-	loc                        *time.Location
+	instruments                []string // epics of the price feed
 	cachedOpenPositions        []broker.Position
 	cachedClosedPositions      []broker.Position
 	openPositionsLastChecked   time.Time
 	closedPositionsLastChecked time.Time
 	tokenRefreshFailures       int
+	deals                      map[string]deal   // tag and owner of orders by deal ID and deal reference
+	owners                     map[string]string // owner key -> owner
+	calendar                   *calendar.Calendar
+	reconnect                  chan struct{} // requests a new subscription of the price feed
 	sync.RWMutex
 }
 
+type Option func(*Broker)
+
+// WithCalendar sets the market hours of the instrument, the forex calendar by default. The price
+// feed is only subscribed and the token only refreshed while the market is open.
+func WithCalendar(cal *calendar.Calendar) Option {
+	return func(b *Broker) {
+		b.calendar = cal
+	}
+}
+
+// WithInstruments subscribes the prices of further instruments, e.g. for a portfolio of traders
+func WithInstruments(instruments ...string) Option {
+	return func(b *Broker) {
+		b.instruments = append(b.instruments, instruments...)
+	}
+}
+
 // New creates new broker instance and does the API login
-func New(instrument string, apiURL, apiKey, accountID, identifier, password string) (*Broker, error) {
+func New(instrument string, apiURL, apiKey, accountID, identifier, password string, options ...Option) (*Broker, error) {
 	var igHandle = igmarkets.New(apiURL, apiKey, accountID, identifier, password)
 	if err := igHandle.Login(context.Background()); err != nil {
 		return nil, err
 	}
 
-	locBerlin, err := time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		log.WithError(err).Fatal("cannot load time zone")
-	}
-
 	b := &Broker{
 		igHandle:    igHandle,
 		watchlistID: "_at",
 		instrument:  instrument,
-		loc:         locBerlin,
+		instruments: []string{instrument},
+		deals:       map[string]deal{},
+		owners:      map[string]string{},
+		calendar:    calendar.Forex(),
+		reconnect:   make(chan struct{}, 1),
+	}
+
+	for _, option := range options {
+		option(b)
 	}
 
 	const watchlistName = "_at"
@@ -85,8 +121,7 @@ func (b *Broker) refreshIGToken() {
 	for {
 		time.Sleep(time.Second * 50)
 
-		now := time.Now().In(b.loc)
-		if !areMarketsOpen(now) {
+		if !b.calendar.IsOpen(time.Now()) {
 			continue
 		}
 		log.Debug("Refreshing IG token")
@@ -154,7 +189,8 @@ func (b *Broker) BuyMarket(order broker.Order) (string, broker.Position, error)
 		LimitLevel:   targetStr,
 		StopLevel:    order.StopLossPrice.String(),
 		//GuaranteedStop: true,
-		ForceOpen: true,
+		ForceOpen:     true,
+		DealReference: toDealReference(order.Tag, order.Owner, time.Now()),
 	}
 
 	//if !order.TrailingStopDistanceInPips.IsZero() {
@@ -200,6 +236,10 @@ func (b *Broker) BuyMarket(order broker.Order) (string, broker.Position, error)
 	}
 
 	b.openPositionsLastChecked = time.Time{} // invalidate cache
+	if order.Owner != "" {
+		b.owners[ownerKey(order.Owner)] = order.Owner
+	}
+	b.rememberDeal(deal{tag: order.Tag, owner: order.Owner}, confirmation.AffectedDeals[0].DealID, confirmation.DealReference)
 
 	return dealRef.DealReference, broker.Position{
 		Reference:     toInternalReference(confirmation.AffectedDeals[0].DealID, confirmation.DealReference),
@@ -210,9 +250,99 @@ func (b *Broker) BuyMarket(order broker.Order) (string, broker.Position, error)
 		TargetPrice:   decimal.NewFromFloat(confirmation.LimitLevel),
 		StopLossPrice: decimal.NewFromFloat(confirmation.StopLevel),
 		Size:          order.Size,
+		Tag:           order.Tag,
+		Owner:         order.Owner,
 	}, nil
 }
 
+// toDealReference builds a deal reference which carries the order tag and the key of the owner.
+// IG accepts up to 30 characters of [A-Za-z0-9_-], other characters of the tag are replaced by '_'
+// and long tags are truncated. Returns an empty string for orders without tag and owner so IG
+// generates the reference.
+func toDealReference(tag, owner string, now time.Time) string {
+	if tag == "" && owner == "" {
+		return ""
+	}
+	var prefix = dealReferencePrefix
+	if owner != "" {
+		prefix = ownedDealReferencePrefix + ownerKey(owner)
+	}
+	var suffix = strconv.FormatInt(now.UnixMilli(), 36)
+	var sanitized = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, tag)
+	if maxLen := 30 - len(prefix) - len(suffix) - 1; len(sanitized) > maxLen {
+		sanitized = sanitized[:maxLen]
+	}
+	return prefix + sanitized + "-" + suffix
+}
+
+// parseDealReference returns the tag and the owner key of a deal reference created by toDealReference
+func parseDealReference(dealReference string) (tag, key string) {
+	switch {
+	case strings.HasPrefix(dealReference, dealReferencePrefix):
+		tag = strings.TrimPrefix(dealReference, dealReferencePrefix)
+	case strings.HasPrefix(dealReference, ownedDealReferencePrefix) &&
+		len(dealReference) > len(ownedDealReferencePrefix)+ownerKeyLength:
+		tag = dealReference[len(ownedDealReferencePrefix)+ownerKeyLength:]
+		key = dealReference[len(ownedDealReferencePrefix) : len(ownedDealReferencePrefix)+ownerKeyLength]
+	default:
+		return "", ""
+	}
+	if i := strings.LastIndex(tag, "-"); i >= 0 {
+		return tag[:i], key
+	}
+	return "", key
+}
+
+// ownerKey is a short hash of the owner which fits into deal references
+func ownerKey(owner string) string {
+	var h = fnv.New32a()
+	_, _ = h.Write([]byte(owner))
+	var key = strconv.FormatUint(uint64(h.Sum32()), 36)
+	return strings.Repeat("0", ownerKeyLength-len(key)) + key
+}
+
+// RegisterOwner makes the owner of positions opened before a restart known. Deal references only
+// carry the key of the owner.
+func (b *Broker) RegisterOwner(owner string) {
+	b.Lock()
+	defer b.Unlock()
+	b.owners[ownerKey(owner)] = owner
+}
+
+// dealOf returns tag and owner of the deal from its reference or from the deals seen before.
+// Caller must hold the read lock.
+func (b *Broker) dealOf(dealID, dealReference string) deal {
+	var d = b.deals[dealID]
+	if d == (deal{}) {
+		d = b.deals[dealReference]
+	}
+	if tag, key := parseDealReference(dealReference); tag != "" || key != "" {
+		d.tag = tag
+		if owner, known := b.owners[key]; known {
+			d.owner = owner
+		} else if key != "" {
+			log.Warnf("Unknown owner key %q of deal %s", key, dealReference)
+		}
+	}
+	return d
+}
+
+// rememberDeal keeps tag and owner of a deal, e.g. to find them for the closed position by deal ID.
+// Caller must hold the write lock.
+func (b *Broker) rememberDeal(d deal, references ...string) {
+	if d.tag == "" && d.owner == "" {
+		return
+	}
+	for _, reference := range references {
+		b.deals[reference] = d
+	}
+}
+
 func toInternalReference(dealID, dealReference string) string {
 	return fmt.Sprintf("%s:%s", dealID, dealReference)
 }
@@ -345,6 +475,7 @@ func (b *Broker) GetOpenPositions() ([]broker.Position, error) {
 			return positions, fmt.Errorf("cannot parse CreatedDateUTC: %+v", position)
 		}
 
+		var d = b.dealOf(position.DealID, position.DealReference)
 		positions = append(positions, broker.Position{
 			Reference:     toInternalReference(position.DealID, position.DealReference),
 			Instrument:    positionData.MarketData.Epic,
@@ -353,11 +484,17 @@ func (b *Broker) GetOpenPositions() ([]broker.Position, error) {
 			BuyDirection:  direction,
 			TargetPrice:   decimal.NewFromFloat(position.LimitLevel),
 			StopLossPrice: decimal.NewFromFloat(position.StopLevel),
+			Tag:           d.tag,
+			Owner:         d.owner,
 		})
 	}
 
 	b.RUnlock()
 	b.Lock()
+	for _, position := range positions {
+		dealID, dealReference := fromInternalReference(position)
+		b.rememberDeal(deal{tag: position.Tag, owner: position.Owner}, dealID, dealReference)
+	}
 	b.cachedOpenPositions = positions
 	b.openPositionsLastChecked = time.Now()
 	b.Unlock()
@@ -394,6 +531,8 @@ func (b *Broker) GetClosedPositions() ([]broker.Position, error) {
 		buyPrice, _ := decimal.NewFromString(transaction.OpenLevel)
 		sellPrice, _ := decimal.NewFromString(transaction.CloseLevel)
 
+		d := b.dealOf(transaction.Reference, transaction.Reference)
+
 		positions = append(positions, broker.Position{
 			Reference:  transaction.Reference,
 			Instrument: transaction.InstrumentName,
@@ -401,6 +540,8 @@ func (b *Broker) GetClosedPositions() ([]broker.Position, error) {
 			BuyTime:    buyTime,
 			SellPrice:  sellPrice,
 			SellTime:   sellTime,
+			Tag:        d.tag,
+			Owner:      d.owner,
 		})
 	}
 
@@ -420,22 +561,31 @@ func (b *Broker) ListenToPriceFeed(tickChan chan tick.Tick) {
 	for {
 		time.Sleep(time.Second * 5)
 
-		if !areMarketsOpen(time.Now()) {
+		if !b.calendar.IsOpen(time.Now()) {
 			continue
 		}
 
-		var lightStreamReceiver = make(chan igmarkets.LightStreamerTick)
+		select {
+		case <-b.reconnect: // requested before this subscription
+		default:
+		}
+
 		b.Lock()
-		err := b.igHandle.OpenLightStreamerSubscription(context.Background(), []string{b.instrument}, lightStreamReceiver)
+		session, err := b.igHandle.LoginVersion2(context.Background())
 		b.Unlock()
 		if err != nil {
-			log.WithError(err).Error("OpenLightStreamerSubscription() failed")
+			log.WithError(err).Error("LoginVersion2() failed")
+			continue
+		}
+		stream, err := subscribe(b.igHandle.APIURL, b.igHandle.APIKey, session, b.instruments)
+		if err != nil {
+			log.WithError(err).Error("Cannot subscribe to price feed")
 			continue
 		}
 
 		//var timeOfLastPriceUpdate time.Time
-		for market := range lightStreamReceiver {
-			if market.Epic != b.instrument {
+		for market := range b.receive(stream) {
+			if !b.subscribed(market.Epic) {
 				continue
 			}
 			log.Debugf("Tick: %+v", market)
@@ -475,18 +625,46 @@ func (b *Broker) ListenToPriceFeed(tickChan chan tick.Tick) {
 	}
 }
 
-func areMarketsOpen(now time.Time) bool {
-	now = now.UTC()
-	if now.Weekday() == time.Friday && now.Hour() >= 22 {
-		return false
-	}
-	if now.Weekday() == time.Saturday {
-		return false
+// subscribed checks if the epic is one of the instruments of the price feed
+func (b *Broker) subscribed(epic string) bool {
+	for _, instrument := range b.instruments {
+		if instrument == epic {
+			return true
+		}
 	}
-	if now.Weekday() == time.Sunday && now.Hour() < 22 {
-		return false
+	return false
+}
+
+// Reconnect ends the subscription of the price feed, ListenToPriceFeed subscribes again
+func (b *Broker) Reconnect() {
+	select {
+	case b.reconnect <- struct{}{}:
+	default:
 	}
-	return true
+}
+
+// receive forwards the ticks of the subscription until it ends or a reconnect is requested. The
+// subscription is closed afterwards.
+func (b *Broker) receive(stream *lightstreamer) chan igmarkets.LightStreamerTick {
+	var ticks = make(chan igmarkets.LightStreamerTick)
+	go func() {
+		defer close(ticks)
+		defer stream.Close()
+		for {
+			select {
+			case <-b.reconnect:
+				log.Warn("Reconnecting to price feed")
+				return
+			case market, ok := <-stream.ticks:
+				if !ok {
+					log.Warn("Price feed subscription ended, reconnecting")
+					return
+				}
+				ticks <- market
+			}
+		}
+	}()
+	return ticks
 }
 
 func (b *Broker) CancelOrder(orderID string) error {
@@ -0,0 +1,48 @@
+package ig
+
+import (
+	"github.com/AMekss/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDealReferenceTag(t *testing.T) {
+	var now = time.Date(2022, 1, 3, 10, 0, 0, 0, time.UTC)
+
+	assert.EqualStrings(t, "", toDealReference("", "", now))
+	tag, key := parseDealReference("ZK8BT9VE8TQTYPZ")
+	assert.EqualStrings(t, "", tag+key)
+
+	ref := toDealReference("engulfing-long", "", now)
+	assert.True(t, len(ref) <= 30)
+	tag, _ = parseDealReference(ref)
+	assert.EqualStrings(t, "engulfing-long", tag)
+
+	ref = toDealReference("rsi/long", "", now)
+	tag, _ = parseDealReference(ref)
+	assert.EqualStrings(t, "rsi_long", tag)
+
+	ref = toDealReference(strings.Repeat("x", 40), "", now)
+	assert.True(t, len(ref) <= 30)
+	tag, _ = parseDealReference(ref)
+	assert.True(t, strings.HasPrefix(tag, "xxx"))
+}
+
+func TestBroker_dealOf(t *testing.T) {
+	var now = time.Date(2022, 1, 3, 10, 0, 0, 0, time.UTC)
+	var owner = "sma10/CS.D.EURUSD.MINI.IP"
+	var ref = toDealReference("long-1", owner, now)
+	assert.True(t, len(ref) <= 30)
+
+	// Restarted broker which knows the owner from its trader
+	var b = &Broker{deals: map[string]deal{}, owners: map[string]string{}}
+	b.RegisterOwner(owner)
+	var d = b.dealOf("DIAAAA", ref)
+	assert.EqualStrings(t, owner, d.owner)
+	assert.EqualStrings(t, "long-1", d.tag)
+
+	d = b.dealOf("DIAAAA", toDealReference("", "rsi/GBPUSD", now))
+	assert.EqualStrings(t, "", d.owner)
+	assert.EqualStrings(t, "", d.tag)
+}
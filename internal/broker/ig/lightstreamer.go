@@ -0,0 +1,236 @@
+package ig
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/igmarkets"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lightstreamerRequestTimeout = time.Second * 30
+
+// lightstreamer is a price subscription of IG's streaming API. Unlike
+// igmarkets.OpenLightStreamerSubscription() it can be closed, which ends the stream and destroys the
+// Lightstreamer and the IG session.
+type lightstreamer struct {
+	ticks     chan igmarkets.LightStreamerTick // closed when the stream ends
+	apiURL    string
+	apiKey    string
+	session   *igmarkets.SessionVersion2
+	sessionID string
+	client    *http.Client
+	location  *time.Location
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// subscribe creates a Lightstreamer session for the IG session and streams the prices of the epics
+func subscribe(apiURL, apiKey string, session *igmarkets.SessionVersion2, epics []string) (*lightstreamer, error) {
+	location, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var l = &lightstreamer{
+		ticks:    make(chan igmarkets.LightStreamerTick),
+		apiURL:   apiURL,
+		apiKey:   apiKey,
+		session:  session,
+		client:   &http.Client{Transport: &http.Transport{MaxIdleConns: 1, IdleConnTimeout: time.Second * 30}},
+		location: location,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	if err := l.open(ctx, epics); err != nil {
+		cancel()
+		l.logout()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *lightstreamer) open(ctx context.Context, epics []string) error {
+	response, err := l.post(ctx, "create_session.txt", url.Values{
+		"LS_op2":      {"create"},
+		"LS_cid":      {"mgQkwtwdysogQz2BJ4Ji kOj2Bg"},
+		"LS_user":     {l.session.CurrentAccountId},
+		"LS_password": {"CST-" + l.session.CSTToken + "|XST-" + l.session.XSTToken},
+		"LS_polling":  {"true"}, "LS_polling_millis": {"0"}, "LS_idle_millis": {"0"},
+	})
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(response, "\r\n") {
+		if strings.HasPrefix(line, "SessionId:") {
+			l.sessionID = strings.TrimPrefix(line, "SessionId:")
+		}
+	}
+	if l.sessionID == "" {
+		return fmt.Errorf("no Lightstreamer session ID in %q", response)
+	}
+
+	var items []string
+	for _, epic := range epics {
+		items = append(items, "MARKET:"+epic)
+	}
+	if _, err := l.post(ctx, "control.txt", url.Values{
+		"LS_session": {l.sessionID},
+		"LS_op":      {"add"},
+		"LS_Table":   {"1"},
+		"LS_id":      {strings.Join(items, " ")},
+		"LS_schema":  {"UPDATE_TIME BID OFFER MARKET_STATE"},
+		"LS_mode":    {"MERGE"},
+	}); err != nil {
+		l.destroy()
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, l.session.LightstreamerEndpoint+"/lightstreamer/bind_session.txt",
+		strings.NewReader(url.Values{"LS_session": {l.sessionID}, "LS_polling": {"false"}}.Encode()))
+	if err != nil {
+		l.destroy()
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	stream, err := l.client.Do(request)
+	if err != nil {
+		l.destroy()
+		return fmt.Errorf("binding Lightstreamer session failed: %w", err)
+	}
+
+	go l.read(ctx, stream.Body, epics)
+	return nil
+}
+
+// read sends the price updates of the stream to ticks until the stream ends or is closed
+func (l *lightstreamer) read(ctx context.Context, stream io.ReadCloser, epics []string) {
+	defer close(l.done)
+	defer close(l.ticks)
+	defer stream.Close()
+
+	var lastTicks = map[string]igmarkets.LightStreamerTick{} // epic -> tick
+	var scanner = bufio.NewScanner(stream)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if line == "LOOP" || strings.HasPrefix(line, "END") {
+			log.Infof("Lightstreamer session ended: %s", line)
+			return
+		}
+		t, ok := l.parse(line, epics, lastTicks)
+		if !ok {
+			continue
+		}
+		lastTicks[t.Epic] = t
+		select {
+		case l.ticks <- t:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		log.WithError(err).Warn("Reading Lightstreamer stream failed")
+	}
+}
+
+// parse parses a price update like "1,1|10:15:03|1.2345|1.2347|TRADEABLE". Empty fields are unchanged
+// since the previous update of the epic.
+func (l *lightstreamer) parse(line string, epics []string, lastTicks map[string]igmarkets.LightStreamerTick) (igmarkets.LightStreamerTick, bool) {
+	var fields = strings.Split(line, "|")
+	if len(fields) != 5 || !strings.HasPrefix(fields[0], "1,") {
+		return igmarkets.LightStreamerTick{}, false
+	}
+	item, err := strconv.Atoi(strings.TrimPrefix(fields[0], "1,"))
+	if err != nil || item < 1 || item > len(epics) {
+		return igmarkets.LightStreamerTick{}, false
+	}
+
+	var t = lastTicks[epics[item-1]]
+	t.Epic = epics[item-1]
+	if fields[1] != "" {
+		now := time.Now().In(l.location)
+		clock, err := time.ParseInLocation("15:04:05", fields[1], l.location)
+		if err != nil {
+			log.WithError(err).Warnf("Cannot parse time of price update %q", line)
+			return igmarkets.LightStreamerTick{}, false
+		}
+		t.Time = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, l.location)
+	}
+	if bid, err := strconv.ParseFloat(fields[2], 64); err == nil {
+		t.Bid = bid
+	}
+	if ask, err := strconv.ParseFloat(fields[3], 64); err == nil {
+		t.Ask = ask
+	}
+	return t, true
+}
+
+// Close ends the stream and destroys the Lightstreamer and the IG session. ticks is closed afterwards.
+func (l *lightstreamer) Close() {
+	l.cancel()
+	<-l.done
+	l.destroy()
+	l.logout()
+}
+
+func (l *lightstreamer) destroy() {
+	ctx, cancel := context.WithTimeout(context.Background(), lightstreamerRequestTimeout)
+	defer cancel()
+	if _, err := l.post(ctx, "control.txt", url.Values{"LS_session": {l.sessionID}, "LS_op": {"destroy"}}); err != nil {
+		log.WithError(err).Warn("Cannot destroy Lightstreamer session")
+	}
+}
+
+// logout ends the IG session created for the subscription
+func (l *lightstreamer) logout() {
+	ctx, cancel := context.WithTimeout(context.Background(), lightstreamerRequestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, l.apiURL+"/gateway/deal/session", nil)
+	if err != nil {
+		log.WithError(err).Warn("Cannot log out of IG session")
+		return
+	}
+	request.Header.Set("X-IG-API-KEY", l.apiKey)
+	request.Header.Set("CST", l.session.CSTToken)
+	request.Header.Set("X-SECURITY-TOKEN", l.session.XSTToken)
+	request.Header.Set("Version", "1")
+	response, err := l.client.Do(request)
+	if err != nil {
+		log.WithError(err).Warn("Cannot log out of IG session")
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		log.Warnf("Cannot log out of IG session: %s", response.Status)
+	}
+}
+
+// post sends a request to the Lightstreamer server and returns its response, which starts with OK
+func (l *lightstreamer) post(ctx context.Context, path string, values url.Values) (string, error) {
+	var endpoint = l.session.LightstreamerEndpoint + "/lightstreamer/" + path
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := l.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("calling Lightstreamer endpoint %s failed: %w", endpoint, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response of Lightstreamer endpoint %s failed: %w", endpoint, err)
+	}
+	if !strings.HasPrefix(string(body), "OK") {
+		return "", fmt.Errorf("unexpected response of Lightstreamer endpoint %s: %q", endpoint, body)
+	}
+	return string(body), nil
+}
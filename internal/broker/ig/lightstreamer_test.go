@@ -0,0 +1,61 @@
+package ig
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/sklinkert/igmarkets"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestLightstreamer_Close(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	var loggedOut bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lightstreamer/create_session.txt":
+			_, _ = w.Write([]byte("OK\r\nSessionId:S1\r\nControlAddress:localhost\r\n\r\n"))
+		case "/lightstreamer/control.txt":
+			_ = r.ParseForm()
+			mu.Lock()
+			ops = append(ops, r.Form.Get("LS_session")+" "+r.Form.Get("LS_op"))
+			mu.Unlock()
+			_, _ = w.Write([]byte("OK\r\n"))
+		case "/lightstreamer/bind_session.txt":
+			_, _ = w.Write([]byte("OK\r\nSessionId:S1\r\n\r\n1,1|10:15:03|1.1|1.2|TRADEABLE\r\n1,1||1.3||TRADEABLE\r\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/gateway/deal/session":
+			mu.Lock()
+			loggedOut = r.Method == http.MethodDelete && r.Header.Get("CST") == "cst"
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	var session = &igmarkets.SessionVersion2{LightstreamerEndpoint: server.URL, CSTToken: "cst", XSTToken: "xst"}
+	stream, err := subscribe(server.URL, "key", session, []string{"CS.D.EURUSD.MINI.IP"})
+	assert.NoError(t.Fatalf, err)
+
+	first := <-stream.ticks
+	assert.EqualStrings(t, "CS.D.EURUSD.MINI.IP", first.Epic)
+	assert.EqualFloat64(t, 1.1, first.Bid)
+	second := <-stream.ticks
+	assert.EqualFloat64(t, 1.3, second.Bid)
+	assert.EqualFloat64(t, 1.2, second.Ask)
+	assert.EqualTime(t, first.Time, second.Time)
+
+	stream.Close()
+	_, open := <-stream.ticks
+	assert.False(t, open)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.EqualInt(t.Fatalf, 2, len(ops))
+	assert.EqualStrings(t, "S1 add", ops[0])
+	assert.EqualStrings(t, "S1 destroy", ops[1])
+	assert.True(t, loggedOut)
+}
@@ -0,0 +1,78 @@
+package ig
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/igmarkets"
+	"time"
+)
+
+var resolutions = map[time.Duration]string{
+	time.Second:      igmarkets.ResolutionSecond,
+	time.Minute:      igmarkets.ResolutionMinute,
+	time.Minute * 2:  "MINUTE_2",
+	time.Minute * 3:  "MINUTE_3",
+	time.Minute * 5:  "MINUTE_5",
+	time.Minute * 10: "MINUTE_10",
+	time.Minute * 15: "MINUTE_15",
+	time.Minute * 30: "MINUTE_30",
+	time.Hour:        igmarkets.ResolutionHour,
+	time.Hour * 2:    igmarkets.ResolutionTwoHour,
+	time.Hour * 3:    igmarkets.ResolutionThreeHour,
+	time.Hour * 4:    igmarkets.ResolutionFourHour,
+	time.Hour * 24:   igmarkets.ResolutionDay,
+}
+
+type priceHistory struct {
+	b *Broker
+}
+
+// WarmUpProvider provides warm-up candles from the price history of IG. IG limits the number of
+// historical data points per week.
+func (b *Broker) WarmUpProvider() warmup.Provider {
+	return priceHistory{b: b}
+}
+
+func (p priceHistory) Name() string {
+	return "ig"
+}
+
+func (p priceHistory) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	resolution, supported := resolutions[duration]
+	if !supported {
+		return nil, fmt.Errorf("no price history of %s candles", duration)
+	}
+
+	p.b.RLock()
+	prices, err := p.b.igHandle.GetPriceHistory(context.Background(), instrument, resolution, limit, time.Time{}, to.UTC())
+	p.b.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var candles []ohlc.OHLC
+	for _, price := range prices.Prices {
+		var start = price.SnapshotTimeUTCParsed
+		candles = append(candles, ohlc.OHLC{
+			Instrument: instrument,
+			Open:       midPrice(price.OpenPrice),
+			High:       midPrice(price.HighPrice),
+			HighTime:   start,
+			Low:        midPrice(price.LowPrice),
+			LowTime:    start,
+			Close:      midPrice(price.ClosePrice),
+			Start:      start,
+			End:        start.Add(duration),
+			Duration:   duration,
+			Volume:     float64(price.LastTradedVolume),
+		})
+	}
+	return candles, nil
+}
+
+func midPrice(price igmarkets.Price) decimal.Decimal {
+	return decimal.NewFromFloat(price.Bid).Add(decimal.NewFromFloat(price.Ask)).Div(decimal.NewFromInt(2))
+}
@@ -18,6 +18,8 @@ type Order struct {
 	StopLossPrice decimal.Decimal // optional
 	Limit         decimal.Decimal // required when Type=OrderTypeLimit
 	CandleStart   time.Time
+	Tag           string // optional, passed on to the position to group results, e.g. by sub-strategy
+	Owner         string // optional, ID of the trader which placed the order when several traders share a broker
 }
 
 // NewMarketOrder creates a new order from given parameters
@@ -78,6 +80,6 @@ func type2String(t OrderType) string {
 }
 
 func (order *Order) String() string {
-	return fmt.Sprintf("{OrderID=%q Type=%s BuyDirection=%q Size=%f Target=%s Limit=%s StopLoss=%s}",
-		order.ID, type2String(order.Type), order.Direction, order.Size, order.TargetPrice, order.Limit, order.StopLossPrice)
+	return fmt.Sprintf("{OrderID=%q Type=%s BuyDirection=%q Size=%f Target=%s Limit=%s StopLoss=%s Tag=%q}",
+		order.ID, type2String(order.Type), order.Direction, order.Size, order.TargetPrice, order.Limit, order.StopLossPrice, order.Tag)
 }
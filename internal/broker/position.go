@@ -22,6 +22,8 @@ type Position struct {
 	OHLCAgeOnBuy        time.Duration
 	CandleBuyTime       time.Time
 	CandleSellTime      time.Time
+	Tag                 string // copied from the order
+	Owner               string // copied from the order
 
 	// Backtesting
 	MaxSurge                  float64 // Pips
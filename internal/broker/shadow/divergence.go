@@ -0,0 +1,164 @@
+package shadow
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"time"
+)
+
+// DivergenceKind tells how simulated and real execution differ
+type DivergenceKind string
+
+const (
+	DivergenceRejected   DivergenceKind = "rejected"    // only one side accepted an order or close
+	DivergenceEntryPrice DivergenceKind = "entry price" // fill prices of the opened positions differ
+	DivergenceExitPrice  DivergenceKind = "exit price"  // fill prices of the closed positions differ
+	DivergenceExitTime   DivergenceKind = "exit time"   // the positions were closed at different times
+	DivergenceOneSided   DivergenceKind = "one sided"   // only one of the positions was closed
+	DivergenceUntracked  DivergenceKind = "untracked"   // real position without simulated one
+)
+
+// Divergence is a difference between the paperwallet's simulation and the live broker's execution
+type Divergence struct {
+	Time               time.Time // time of the last tick when the divergence was detected
+	Kind               DivergenceKind
+	Instrument         string
+	SimulatedReference string
+	RealReference      string
+	Simulated          decimal.Decimal // simulated price
+	Real               decimal.Decimal // real price
+	Message            string
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Instrument, d.Kind, d.Message)
+}
+
+// Divergences returns the divergences found so far
+func (s *Shadow) Divergences() []Divergence {
+	s.Lock()
+	defer s.Unlock()
+	return append([]Divergence(nil), s.divergences...)
+}
+
+// Summary logs how many positions were opened by both brokers and the divergences by kind, e.g.
+// periodically and on shutdown
+func (s *Shadow) Summary() {
+	s.Lock()
+	var counts = map[DivergenceKind]int{}
+	for _, d := range s.divergences {
+		counts[d.Kind]++
+	}
+	var paired, open, total = s.paired, len(s.pairs), len(s.divergences)
+	s.Unlock()
+
+	log.Infof("%25s: %d (%d open)", "Shadow paired positions", paired, open)
+	log.Infof("%25s: %d", "Shadow divergences", total)
+	for _, kind := range []DivergenceKind{DivergenceRejected, DivergenceEntryPrice, DivergenceExitPrice,
+		DivergenceExitTime, DivergenceOneSided, DivergenceUntracked} {
+		if counts[kind] > 0 {
+			log.Infof("%25s: %d", kind, counts[kind])
+		}
+	}
+}
+
+// diverge records and logs the divergence. Caller must hold the lock.
+func (s *Shadow) diverge(d Divergence) {
+	d.Time = s.lastTick
+	s.divergences = append(s.divergences, d)
+	log.WithFields(log.Fields{
+		"Kind":               d.Kind,
+		"Instrument":         d.Instrument,
+		"SimulatedReference": d.SimulatedReference,
+		"RealReference":      d.RealReference,
+	}).Warnf("Shadow divergence: %s", d.Message)
+}
+
+// comparePrices reports a divergence if the prices differ by more than the price tolerance
+func (s *Shadow) comparePrices(kind DivergenceKind, simulated, real broker.Position, simulatedPrice, realPrice decimal.Decimal) {
+	var difference = realPrice.Sub(simulatedPrice)
+	if difference.Abs().LessThanOrEqual(s.priceTolerance) {
+		return
+	}
+	s.diverge(Divergence{
+		Kind:               kind,
+		Instrument:         simulated.Instrument,
+		SimulatedReference: simulated.Reference,
+		RealReference:      real.Reference,
+		Simulated:          simulatedPrice,
+		Real:               realPrice,
+		Message:            fmt.Sprintf("real %s is %s, simulated %s (difference %s)", kind, realPrice, simulatedPrice, difference),
+	})
+}
+
+// reconcile compares the exits of the paired positions. Pairs are removed when both positions are
+// closed. Caller must hold the lock.
+func (s *Shadow) reconcile(simulatedClosed []broker.Position) {
+	if len(s.pairs) == 0 {
+		return
+	}
+	realClosed, err := s.live.GetClosedPositions()
+	if err != nil {
+		log.WithError(err).Error("Cannot get closed positions of the live broker")
+		return
+	}
+	var simulatedByRef, realByRef = byReference(simulatedClosed), byReference(realClosed)
+
+	var open []*pair
+	for _, p := range s.pairs {
+		if position, closed := simulatedByRef[p.simulated.Reference]; closed {
+			p.simulated, p.simulatedClosed = position, true
+		}
+		if position, closed := realByRef[p.real.Reference]; closed {
+			p.real, p.realClosed = position, true
+		}
+
+		switch {
+		case p.simulatedClosed && p.realClosed:
+			s.comparePrices(DivergenceExitPrice, p.simulated, p.real, p.simulated.SellPrice, p.real.SellPrice)
+			if gap := p.real.SellTime.Sub(p.simulated.SellTime); gap > s.timeTolerance || -gap > s.timeTolerance {
+				s.diverge(Divergence{
+					Kind:               DivergenceExitTime,
+					Instrument:         p.simulated.Instrument,
+					SimulatedReference: p.simulated.Reference,
+					RealReference:      p.real.Reference,
+					Message:            fmt.Sprintf("real exit at %s, simulated at %s", p.real.SellTime, p.simulated.SellTime),
+				})
+			}
+			continue
+		case p.simulatedClosed || p.realClosed:
+			s.checkOneSided(p)
+		}
+		open = append(open, p)
+	}
+	s.pairs = open
+}
+
+// checkOneSided reports a pair whose other position is still open after the time tolerance
+func (s *Shadow) checkOneSided(p *pair) {
+	var closedBy, sellTime = "paperwallet", p.simulated.SellTime
+	if p.realClosed {
+		closedBy, sellTime = "live broker", p.real.SellTime
+	}
+	if p.oneSidedReported || s.lastTick.Sub(sellTime) <= s.timeTolerance {
+		return
+	}
+	p.oneSidedReported = true
+	s.diverge(Divergence{
+		Kind:               DivergenceOneSided,
+		Instrument:         p.simulated.Instrument,
+		SimulatedReference: p.simulated.Reference,
+		RealReference:      p.real.Reference,
+		Message:            fmt.Sprintf("position closed by the %s only at %s", closedBy, sellTime),
+	})
+}
+
+func byReference(positions []broker.Position) map[string]broker.Position {
+	var m = make(map[string]broker.Position, len(positions))
+	for _, position := range positions {
+		m[position.Reference] = position
+	}
+	return m
+}
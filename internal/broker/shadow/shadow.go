@@ -0,0 +1,227 @@
+package shadow
+
+import (
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/pkg/tick"
+	"sync"
+	"time"
+)
+
+// Shadow is a broker which takes the prices of a live broker's feed and executes orders with a
+// paperwallet, e.g. to paper trade with IG or Coinbase prices. With WithExecution() market orders
+// are sent to the live broker as well and the simulated fills are compared with the real ones.
+// The trader always sees the simulated orders and positions.
+type Shadow struct {
+	live           broker.Broker
+	paperwallet    *paperwallet.Paperwallet
+	execute        bool
+	priceTolerance decimal.Decimal
+	timeTolerance  time.Duration
+	pairs          []*pair
+	paired         int // positions opened by both brokers
+	divergences    []Divergence
+	lastTick       time.Time
+	sync.Mutex
+}
+
+// pair is a simulated position and the real position opened by the same order
+type pair struct {
+	simulated, real  broker.Position
+	simulatedClosed  bool
+	realClosed       bool
+	oneSidedReported bool
+}
+
+type Option func(*Shadow)
+
+// WithExecution sends market orders and closes to the live broker in parallel to the paperwallet.
+// Limit orders are simulated only.
+func WithExecution() Option {
+	return func(s *Shadow) {
+		s.execute = true
+	}
+}
+
+// WithPriceTolerance ignores differences of fill prices up to the tolerance
+func WithPriceTolerance(tolerance decimal.Decimal) Option {
+	return func(s *Shadow) {
+		s.priceTolerance = tolerance
+	}
+}
+
+// WithTimeTolerance sets how long one position of a pair may stay open after the other one was closed
+// before the divergence is reported. Defaults to one minute.
+func WithTimeTolerance(tolerance time.Duration) Option {
+	return func(s *Shadow) {
+		s.timeTolerance = tolerance
+	}
+}
+
+func New(live broker.Broker, wallet *paperwallet.Paperwallet, options ...Option) *Shadow {
+	s := &Shadow{
+		live:          live,
+		paperwallet:   wallet,
+		timeTolerance: time.Minute,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// ListenToPriceFeed passes the live broker's ticks to the paperwallet and to tickChan
+func (s *Shadow) ListenToPriceFeed(tickChan chan tick.Tick) {
+	var feed = make(chan tick.Tick)
+	go s.live.ListenToPriceFeed(feed)
+
+	for currentTick := range feed {
+		s.paperwallet.SetCurrenctPrice(currentTick)
+		s.Lock()
+		s.lastTick = currentTick.Datetime
+		s.Unlock()
+		tickChan <- currentTick
+	}
+	close(tickChan)
+}
+
+// RegisterOwner registers the owner with the live broker if it needs it
+func (s *Shadow) RegisterOwner(owner string) {
+	if registry, ok := s.live.(broker.OwnerRegistry); ok {
+		registry.RegisterOwner(owner)
+	}
+}
+
+// Reconnect reconnects the price feed of the live broker if it supports it
+func (s *Shadow) Reconnect() {
+	if reconnector, ok := s.live.(broker.Reconnector); ok {
+		reconnector.Reconnect()
+	}
+}
+
+func (s *Shadow) Buy(order broker.Order) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.execute || order.Type != broker.OrderTypeMarket {
+		return s.paperwallet.Buy(order)
+	}
+
+	simulatedBefore, _ := s.paperwallet.GetOpenPositions()
+	orderID, err := s.paperwallet.Buy(order)
+	if err != nil {
+		// A real position without simulated twin would never be closed
+		return orderID, err
+	}
+	simulatedAfter, _ := s.paperwallet.GetOpenPositions()
+
+	realBefore, err := s.live.GetOpenPositions()
+	if err != nil {
+		log.WithError(err).Error("Cannot get open positions of the live broker, order is simulated only")
+		return orderID, nil
+	}
+	if _, err := s.live.Buy(order); err != nil {
+		s.diverge(Divergence{Kind: DivergenceRejected, Instrument: order.Instrument,
+			Message: "order rejected by the live broker only: " + err.Error()})
+		return orderID, nil
+	}
+	realAfter, err := s.live.GetOpenPositions()
+	if err != nil {
+		log.WithError(err).Error("Cannot get open positions of the live broker")
+	}
+
+	simulated, simulatedFound := newPosition(simulatedBefore, simulatedAfter)
+	real, realFound := newPosition(realBefore, realAfter)
+	switch {
+	case !realFound:
+		s.diverge(Divergence{Kind: DivergenceUntracked, Instrument: order.Instrument,
+			Message: "cannot find the real position opened by the order, it has to be closed manually"})
+	case !simulatedFound:
+		var message = "real position closed, the simulated one was not found"
+		if err := s.live.Sell(real); err != nil {
+			message = "real position without simulated one cannot be closed, it has to be closed manually: " + err.Error()
+		}
+		s.diverge(Divergence{Kind: DivergenceUntracked, Instrument: order.Instrument, RealReference: real.Reference,
+			Message: message})
+	default:
+		s.pairs = append(s.pairs, &pair{simulated: simulated, real: real})
+		s.paired++
+		s.comparePrices(DivergenceEntryPrice, simulated, real, simulated.BuyPrice, real.BuyPrice)
+	}
+	return orderID, nil
+}
+
+func (s *Shadow) Sell(position broker.Position) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if err := s.paperwallet.Sell(position); err != nil {
+		return err
+	}
+	if !s.execute {
+		return nil
+	}
+	for _, p := range s.pairs {
+		if p.simulated.Reference != position.Reference {
+			continue
+		}
+		if err := s.live.Sell(p.real); err != nil {
+			s.diverge(Divergence{Kind: DivergenceRejected, Instrument: position.Instrument,
+				SimulatedReference: p.simulated.Reference, RealReference: p.real.Reference,
+				Message: "close rejected by the live broker only: " + err.Error()})
+		}
+	}
+	return nil
+}
+
+func (s *Shadow) CancelOrder(orderID string) error {
+	return s.paperwallet.CancelOrder(orderID)
+}
+
+func (s *Shadow) GetOpenOrders() ([]broker.Order, error) {
+	return s.paperwallet.GetOpenOrders(), nil
+}
+
+func (s *Shadow) GetOpenPosition(positionRef string) (broker.Position, error) {
+	return s.paperwallet.GetOpenPosition(positionRef)
+}
+
+func (s *Shadow) GetOpenPositions() ([]broker.Position, error) {
+	return s.paperwallet.GetOpenPositions()
+}
+
+func (s *Shadow) GetOpenPositionsByInstrument(instrument string) ([]broker.Position, error) {
+	return s.paperwallet.GetOpenPositionsByInstrument(instrument)
+}
+
+// GetClosedPositions returns the simulated closed positions and compares their exits with the real ones
+func (s *Shadow) GetClosedPositions() ([]broker.Position, error) {
+	closed, err := s.paperwallet.GetClosedPositions()
+	if err != nil {
+		return nil, err
+	}
+	if s.execute {
+		s.Lock()
+		s.reconcile(closed)
+		s.Unlock()
+	}
+	return closed, nil
+}
+
+// newPosition returns the position which is open after but not before an order
+func newPosition(before, after []broker.Position) (broker.Position, bool) {
+	var known = map[string]bool{}
+	for _, position := range before {
+		known[position.Reference] = true
+	}
+	for _, position := range after {
+		if !known[position.Reference] {
+			return position, true
+		}
+	}
+	return broker.Position{}, false
+}
@@ -0,0 +1,170 @@
+package shadow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/paperwallet"
+	"github.com/sklinkert/at/pkg/tick"
+	"strings"
+	"testing"
+	"time"
+)
+
+// liveBroker fills orders at the last ask plus slippage and closes positions at the last bid
+type liveBroker struct {
+	ticks    []tick.Tick
+	last     tick.Tick
+	slippage decimal.Decimal
+	reject   error
+	open     []broker.Position
+	closed   []broker.Position
+	buys     int
+}
+
+func (b *liveBroker) Buy(order broker.Order) (string, error) {
+	b.buys++
+	if b.reject != nil {
+		return "", b.reject
+	}
+	var reference = fmt.Sprintf("real-%d", b.buys)
+	b.open = append(b.open, broker.Position{Reference: reference, Instrument: order.Instrument,
+		BuyPrice: b.last.Ask.Add(b.slippage), BuyTime: b.last.Datetime, BuyDirection: order.Direction, Size: order.Size})
+	return reference, nil
+}
+
+func (b *liveBroker) Sell(position broker.Position) error {
+	for i, p := range b.open {
+		if p.Reference == position.Reference {
+			p.SellPrice, p.SellTime = b.last.Bid, b.last.Datetime
+			b.closed = append(b.closed, p)
+			b.open = append(b.open[:i], b.open[i+1:]...)
+			return nil
+		}
+	}
+	return broker.ErrPositionNotFound
+}
+
+func (b *liveBroker) CancelOrder(string) error               { return nil }
+func (b *liveBroker) GetOpenOrders() ([]broker.Order, error) { return nil, nil }
+func (b *liveBroker) GetOpenPosition(string) (broker.Position, error) {
+	return broker.Position{}, broker.ErrPositionNotFound
+}
+func (b *liveBroker) GetOpenPositions() ([]broker.Position, error) {
+	return append([]broker.Position(nil), b.open...), nil
+}
+func (b *liveBroker) GetOpenPositionsByInstrument(string) ([]broker.Position, error) {
+	return b.GetOpenPositions()
+}
+func (b *liveBroker) GetClosedPositions() ([]broker.Position, error) { return b.closed, nil }
+
+func (b *liveBroker) ListenToPriceFeed(tickChan chan tick.Tick) {
+	for _, t := range b.ticks {
+		tickChan <- t
+	}
+	close(tickChan)
+}
+
+var start = time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+
+// setPrice passes a tick to the shadow broker like its price feed
+func setPrice(s *Shadow, live *liveBroker, minutes int, bid, ask float64) {
+	var t = tick.New("EURUSD", start.Add(time.Minute*time.Duration(minutes)), decimal.NewFromFloat(bid), decimal.NewFromFloat(ask))
+	live.last = t
+	s.paperwallet.SetCurrenctPrice(t)
+	s.lastTick = t.Datetime
+}
+
+func marketOrder() broker.Order {
+	return broker.NewMarketOrder(broker.BuyDirectionLong, 1, "EURUSD", decimal.NewFromFloat(2), decimal.NewFromFloat(0.5))
+}
+
+func TestShadow_ListenToPriceFeed(t *testing.T) {
+	var live = &liveBroker{ticks: []tick.Tick{
+		tick.New("EURUSD", start, decimal.NewFromFloat(1.0), decimal.NewFromFloat(1.1)),
+		tick.New("EURUSD", start.Add(time.Second), decimal.NewFromFloat(1.2), decimal.NewFromFloat(1.3)),
+	}}
+	var s = New(live, paperwallet.New())
+
+	var ticks = make(chan tick.Tick)
+	go s.ListenToPriceFeed(ticks)
+	var received int
+	for range ticks {
+		received++
+	}
+	assert.EqualInt(t, 2, received)
+
+	// Orders are filled by the paperwallet at the live prices and not sent to the live broker
+	_, err := s.Buy(marketOrder())
+	assert.NoError(t.Fatalf, err)
+	positions, err := s.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(positions))
+	assert.EqualStrings(t, "1.3", positions[0].BuyPrice.String())
+	assert.EqualInt(t, 0, live.buys)
+}
+
+func TestShadow_divergences(t *testing.T) {
+	var live = &liveBroker{slippage: decimal.NewFromFloat(0.02)}
+	var s = New(live, paperwallet.New(), WithExecution(), WithPriceTolerance(decimal.NewFromFloat(0.01)))
+
+	// Entry with slippage, exit by the trader at the same price
+	setPrice(s, live, 0, 1.0, 1.1)
+	_, err := s.Buy(marketOrder())
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t, 1, live.buys)
+	setPrice(s, live, 5, 1.2, 1.3)
+	positions, err := s.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.NoError(t.Fatalf, s.Sell(positions[0]))
+	_, err = s.GetClosedPositions()
+	assert.NoError(t.Fatalf, err)
+
+	var divergences = s.Divergences()
+	assert.EqualInt(t.Fatalf, 1, len(divergences))
+	assert.True(t, divergences[0].Kind == DivergenceEntryPrice)
+	assert.EqualStrings(t, "1.12", divergences[0].Real.String())
+	assert.EqualStrings(t, "1.1", divergences[0].Simulated.String())
+	assert.EqualInt(t, 0, len(s.pairs))
+
+	// The live broker closes the position, the simulated one stays open
+	live.slippage = decimal.Zero
+	_, err = s.Buy(marketOrder())
+	assert.NoError(t.Fatalf, err)
+	assert.NoError(t.Fatalf, live.Sell(live.open[0]))
+	_, _ = s.GetClosedPositions()
+	assert.EqualInt(t, 1, len(s.Divergences()))
+	setPrice(s, live, 7, 1.2, 1.3)
+	_, _ = s.GetClosedPositions()
+	_, _ = s.GetClosedPositions()
+	divergences = s.Divergences()
+	assert.EqualInt(t.Fatalf, 2, len(divergences))
+	assert.True(t, divergences[1].Kind == DivergenceOneSided)
+
+	// Orders rejected by the live broker only
+	live.reject = errors.New("market closed")
+	_, err = s.Buy(marketOrder())
+	assert.NoError(t.Fatalf, err)
+	divergences = s.Divergences()
+	assert.EqualInt(t.Fatalf, 3, len(divergences))
+	assert.True(t, divergences[2].Kind == DivergenceRejected)
+	assert.True(t, strings.HasSuffix(divergences[2].Message, "market closed"))
+	assert.EqualInt(t, 2, s.paired)
+	s.Summary()
+}
+
+func TestShadow_simulatedRejection(t *testing.T) {
+	var live = &liveBroker{}
+	var s = New(live, paperwallet.New(), WithExecution())
+	setPrice(s, live, 0, 1.0, 1.1)
+
+	// The paperwallet rejects the stop loss above the price, the live broker would accept the order
+	var order = broker.NewMarketOrder(broker.BuyDirectionLong, 1, "EURUSD", decimal.NewFromFloat(2), decimal.NewFromFloat(1.5))
+	_, err := s.Buy(order)
+	assert.ErrorIncludesMessage(t, "below stop loss", err)
+	assert.EqualInt(t, 0, live.buys)
+	assert.EqualInt(t, 0, len(live.open))
+	assert.EqualInt(t, 0, len(s.Divergences()))
+}
@@ -0,0 +1,319 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	NameForex  = "forex"
+	NameNYSE   = "nyse"
+	NameAlways = "always"
+)
+
+const dateLayout = "2006-01-02"
+
+// maxSessionDays limits the search for contiguous sessions and the next open, e.g. over long holidays
+const maxSessionDays = 14
+
+// Session is a trading session on a weekday given as time of day in the calendar's location. A
+// session closing at 24:00 continues with a session opening at 00:00 on the next day.
+type Session struct {
+	Weekday time.Weekday
+	Open    time.Duration
+	Close   time.Duration
+}
+
+// Blackout is a period without trading, e.g. a central bank meeting
+type Blackout struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+type dailyBlackout struct {
+	name     string
+	location *time.Location
+	from, to time.Duration
+}
+
+// Calendar knows when an exchange or instrument can be traded
+type Calendar struct {
+	name           string
+	location       *time.Location
+	sessions       map[time.Weekday][]Session
+	holidays       map[string]string        // date -> name
+	earlyCloses    map[string]time.Duration // date -> close
+	blackouts      []Blackout
+	dailyBlackouts []dailyBlackout
+}
+
+type Option func(*Calendar)
+
+// WithSessions opens the market between open and close on the given weekdays
+func WithSessions(open, close time.Duration, weekdays ...time.Weekday) Option {
+	return func(c *Calendar) {
+		for _, weekday := range weekdays {
+			c.addSession(Session{Weekday: weekday, Open: open, Close: close})
+		}
+	}
+}
+
+// WithHoliday closes the market on the date
+func WithHoliday(date time.Time, name string) Option {
+	return func(c *Calendar) {
+		c.addHoliday(date, name)
+	}
+}
+
+// WithEarlyClose closes all sessions of the date at the given time of day
+func WithEarlyClose(date time.Time, close time.Duration) Option {
+	return func(c *Calendar) {
+		c.addEarlyClose(date, close)
+	}
+}
+
+// WithBlackout forbids trading between start and end
+func WithBlackout(name string, start, end time.Time) Option {
+	return func(c *Calendar) {
+		c.addBlackout(Blackout{Name: name, Start: start, End: end})
+	}
+}
+
+// WithDailyBlackout forbids trading between from and to on every day, given as time of day in the
+// location, e.g. around the opening of another exchange
+func WithDailyBlackout(name string, location *time.Location, from, to time.Duration) Option {
+	return func(c *Calendar) {
+		c.dailyBlackouts = append(c.dailyBlackouts, dailyBlackout{name: name, location: location, from: from, to: to})
+	}
+}
+
+// WithUSOpenAndClose forbids trading around the opening and the closing of the US stock markets
+// (NYSE and NASDAQ, 09:30 - 16:00), from 09:00 to 10:00 and from 16:00 to 17:00 in the location
+func WithUSOpenAndClose(location *time.Location) Option {
+	return func(c *Calendar) {
+		WithDailyBlackout("US market open", location, time.Hour*9, time.Hour*10)(c)
+		WithDailyBlackout("US market close", location, time.Hour*16, time.Hour*17)(c)
+	}
+}
+
+func New(name string, location *time.Location, options ...Option) *Calendar {
+	c := &Calendar{
+		name:        name,
+		location:    location,
+		sessions:    map[time.Weekday][]Session{},
+		holidays:    map[string]string{},
+		earlyCloses: map[string]time.Duration{},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Weekdays are Monday to Friday
+var Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+// Forex is open from Sunday 22:00 UTC until Friday 22:00 UTC
+func Forex() *Calendar {
+	return New(NameForex, time.UTC,
+		WithSessions(time.Hour*22, time.Hour*24, time.Sunday),
+		WithSessions(0, time.Hour*24, time.Monday, time.Tuesday, time.Wednesday, time.Thursday),
+		WithSessions(0, time.Hour*22, time.Friday))
+}
+
+// NYSE is open from 09:30 until 16:00 New York time on weekdays. Holidays have to be loaded.
+func NYSE() *Calendar {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.FixedZone("EST", -5*60*60)
+	}
+	return New(NameNYSE, loc, WithSessions(time.Hour*9+time.Minute*30, time.Hour*16, Weekdays...))
+}
+
+// Always is open all the time, e.g. for crypto currencies
+func Always() *Calendar {
+	return New(NameAlways, time.UTC, WithSessions(0, time.Hour*24, time.Sunday, time.Monday, time.Tuesday,
+		time.Wednesday, time.Thursday, time.Friday, time.Saturday))
+}
+
+// Named returns the built-in calendar with the given name
+func Named(name string) (*Calendar, error) {
+	switch name {
+	case NameForex:
+		return Forex(), nil
+	case NameNYSE:
+		return NYSE(), nil
+	case NameAlways:
+		return Always(), nil
+	}
+	return nil, fmt.Errorf("unknown calendar %q", name)
+}
+
+func (c *Calendar) Name() string {
+	return c.name
+}
+
+func (c *Calendar) Location() *time.Location {
+	return c.location
+}
+
+func (c *Calendar) addSession(session Session) {
+	c.sessions[session.Weekday] = append(c.sessions[session.Weekday], session)
+	sort.Slice(c.sessions[session.Weekday], func(i, j int) bool {
+		return c.sessions[session.Weekday][i].Open < c.sessions[session.Weekday][j].Open
+	})
+}
+
+func (c *Calendar) addHoliday(date time.Time, name string) {
+	c.holidays[date.Format(dateLayout)] = name
+}
+
+func (c *Calendar) addEarlyClose(date time.Time, close time.Duration) {
+	c.earlyCloses[date.Format(dateLayout)] = close
+}
+
+func (c *Calendar) addBlackout(blackout Blackout) {
+	c.blackouts = append(c.blackouts, blackout)
+}
+
+type window struct {
+	start, end time.Time
+}
+
+func (w window) contains(t time.Time) bool {
+	return !t.Before(w.start) && t.Before(w.end)
+}
+
+// windows returns the open periods of the day which contains t
+func (c *Calendar) windows(t time.Time) []window {
+	var y, m, d = t.In(c.location).Date()
+	var day = time.Date(y, m, d, 0, 0, 0, 0, c.location)
+	var date = day.Format(dateLayout)
+	if _, isHoliday := c.holidays[date]; isHoliday {
+		return nil
+	}
+	earlyClose, hasEarlyClose := c.earlyCloses[date]
+
+	var windows []window
+	for _, session := range c.sessions[day.Weekday()] {
+		var closeAt = session.Close
+		if hasEarlyClose && earlyClose < closeAt {
+			closeAt = earlyClose
+		}
+		if closeAt <= session.Open {
+			continue
+		}
+		windows = append(windows, window{start: clock(day, session.Open), end: clock(day, closeAt)})
+	}
+	return windows
+}
+
+// clock returns the time of day on the day, 24:00 is midnight of the next day
+func clock(day time.Time, timeOfDay time.Duration) time.Time {
+	var y, m, d = day.Date()
+	if timeOfDay >= time.Hour*24 {
+		return time.Date(y, m, d+1, 0, 0, 0, 0, day.Location())
+	}
+	return time.Date(y, m, d, int(timeOfDay.Hours()), int(timeOfDay.Minutes())%60, int(timeOfDay.Seconds())%60, 0,
+		day.Location())
+}
+
+func (c *Calendar) window(t time.Time) (window, bool) {
+	for _, w := range c.windows(t) {
+		if w.contains(t) {
+			return w, true
+		}
+	}
+	return window{}, false
+}
+
+// IsOpen tells if the market is open at t. Blackouts don't close the market.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	_, ok := c.window(t)
+	return ok
+}
+
+// Session returns start and end of the session which contains t. Sessions which follow each other
+// without a break, e.g. over midnight, are one session. ok is false if the market is closed at t.
+func (c *Calendar) Session(t time.Time) (start, end time.Time, ok bool) {
+	w, ok := c.window(t)
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	start, end = w.start, w.end
+
+	for i := 0; i < maxSessionDays*2; i++ {
+		previous, found := c.window(start.Add(-time.Nanosecond))
+		if !found || !previous.end.Equal(start) {
+			break
+		}
+		start = previous.start
+	}
+	for i := 0; i < maxSessionDays*2; i++ {
+		next, found := c.window(end)
+		if !found || !next.start.Equal(end) {
+			break
+		}
+		end = next.end
+	}
+	return start, end, true
+}
+
+// SameSession tells if a and b are in the same session
+func (c *Calendar) SameSession(a, b time.Time) bool {
+	startA, _, okA := c.Session(a)
+	startB, _, okB := c.Session(b)
+	return okA && okB && startA.Equal(startB)
+}
+
+// NextOpen returns t if the market is open or the start of the next session
+func (c *Calendar) NextOpen(t time.Time) (time.Time, bool) {
+	if c.IsOpen(t) {
+		return t, true
+	}
+	for day := 0; day <= maxSessionDays; day++ {
+		var y, m, d = t.In(c.location).Date()
+		for _, w := range c.windows(time.Date(y, m, d+day, 12, 0, 0, 0, c.location)) {
+			if w.start.After(t) {
+				return w.start, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Blackout returns the name of the blackout active at t
+func (c *Calendar) Blackout(t time.Time) (string, bool) {
+	for _, blackout := range c.blackouts {
+		if !t.Before(blackout.Start) && t.Before(blackout.End) {
+			return blackout.Name, true
+		}
+	}
+	for _, blackout := range c.dailyBlackouts {
+		var local = t.In(blackout.location)
+		var y, m, d = local.Date()
+		var day = time.Date(y, m, d, 0, 0, 0, 0, blackout.location)
+		if (window{start: clock(day, blackout.from), end: clock(day, blackout.to)}).contains(local) {
+			return blackout.name, true
+		}
+	}
+	return "", false
+}
+
+// Check returns an error explaining why t can't be traded, nil if it can
+func (c *Calendar) Check(t time.Time) error {
+	if name, ok := c.holidays[t.In(c.location).Format(dateLayout)]; ok {
+		return fmt.Errorf("%s is closed for %s", c.name, name)
+	}
+	if !c.IsOpen(t) {
+		return fmt.Errorf("%s is closed at %s", c.name, t.In(c.location).Format("Mon 15:04 MST"))
+	}
+	if name, ok := c.Blackout(t); ok {
+		return fmt.Errorf("blackout %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package calendar
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+	"time"
+)
+
+// Monday, 4th of January 2021
+var monday = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+
+func TestForex(t *testing.T) {
+	var c = Forex()
+	var friday = monday.AddDate(0, 0, 4)
+	var sunday = monday.AddDate(0, 0, -1)
+
+	assert.True(t, c.IsOpen(monday))
+	assert.True(t, c.IsOpen(friday.Add(time.Hour*21+time.Minute*59)))
+	assert.True(t, !c.IsOpen(friday.Add(time.Hour*22)))
+	assert.True(t, !c.IsOpen(friday.AddDate(0, 0, 1).Add(time.Hour*12)))
+	assert.True(t, !c.IsOpen(sunday.Add(time.Hour*21)))
+	assert.True(t, c.IsOpen(sunday.Add(time.Hour*22)))
+}
+
+func TestCalendar_Session(t *testing.T) {
+	var c = Forex()
+	start, end, ok := c.Session(monday.AddDate(0, 0, 2))
+	assert.True(t.Fatalf, ok)
+	assert.True(t, start.Equal(monday.Add(-time.Hour*2)))
+	assert.True(t, end.Equal(monday.AddDate(0, 0, 4).Add(time.Hour*22)))
+
+	_, _, ok = c.Session(monday.AddDate(0, 0, 5))
+	assert.True(t, !ok)
+
+	c = New("xetra", time.UTC,
+		WithSessions(time.Hour*9, time.Hour*17+time.Minute*30, Weekdays...),
+		WithEarlyClose(monday, time.Hour*14))
+	_, end, ok = c.Session(monday.Add(time.Hour * 10))
+	assert.True(t.Fatalf, ok)
+	assert.True(t, end.Equal(monday.Add(time.Hour*14)))
+	assert.True(t, !c.SameSession(monday.Add(time.Hour*10), monday.AddDate(0, 0, 1).Add(time.Hour*10)))
+	assert.True(t, c.SameSession(monday.Add(time.Hour*10), monday.Add(time.Hour*13)))
+}
+
+func TestCalendar_NextOpen(t *testing.T) {
+	var c = New("xetra", time.UTC, WithSessions(time.Hour*9, time.Hour*17, Weekdays...),
+		WithHoliday(monday, "New Year"))
+
+	next, ok := c.NextOpen(monday.Add(time.Hour * 10))
+	assert.True(t.Fatalf, ok)
+	assert.True(t, next.Equal(monday.AddDate(0, 0, 1).Add(time.Hour*9)))
+
+	next, ok = c.NextOpen(monday.AddDate(0, 0, 1).Add(time.Hour * 10))
+	assert.True(t.Fatalf, ok)
+	assert.True(t, next.Equal(monday.AddDate(0, 0, 1).Add(time.Hour*10)))
+
+	_, ok = New("never", time.UTC).NextOpen(monday)
+	assert.True(t, !ok)
+}
+
+func TestCalendar_Check(t *testing.T) {
+	var est = time.FixedZone("EST", -5*60*60)
+	var c = New("test", time.UTC, WithSessions(0, time.Hour*24, Weekdays...),
+		WithHoliday(monday, "New Year"),
+		WithBlackout("ECB", monday.AddDate(0, 0, 1).Add(time.Hour*12), monday.AddDate(0, 0, 1).Add(time.Hour*13)),
+		WithUSOpenAndClose(est))
+
+	assert.ErrorIncludesMessage(t, "test is closed for New Year", c.Check(monday.Add(time.Hour)))
+	assert.ErrorIncludesMessage(t, "test is closed at Sat 12:00 UTC", c.Check(monday.AddDate(0, 0, 5).Add(time.Hour*12)))
+	assert.ErrorIncludesMessage(t, "blackout ECB", c.Check(monday.AddDate(0, 0, 1).Add(time.Hour*12)))
+	assert.NoError(t, c.Check(monday.AddDate(0, 0, 1).Add(time.Hour*13)))
+	// 09:30 EST
+	assert.ErrorIncludesMessage(t, "blackout US market open", c.Check(monday.AddDate(0, 0, 1).Add(time.Hour*14+time.Minute*30)))
+	// 16:59 EST
+	assert.ErrorIncludesMessage(t, "blackout US market close", c.Check(monday.AddDate(0, 0, 1).Add(time.Hour*21+time.Minute*59)))
+	assert.NoError(t, c.Check(monday.AddDate(0, 0, 1).Add(time.Hour*22)))
+}
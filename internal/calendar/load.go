@@ -0,0 +1,413 @@
+package calendar
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Calendars selects the calendar of an instrument
+type Calendars struct {
+	fallback     *Calendar
+	byInstrument map[string]*Calendar
+}
+
+// For returns the calendar of the instrument or the default calendar
+func (c *Calendars) For(instrument string) *Calendar {
+	if calendar, ok := c.byInstrument[instrument]; ok {
+		return calendar
+	}
+	return c.fallback
+}
+
+// All returns every calendar once
+func (c *Calendars) All() []*Calendar {
+	var all = []*Calendar{c.fallback}
+	var seen = map[*Calendar]bool{c.fallback: true}
+	for _, calendar := range c.byInstrument {
+		if !seen[calendar] {
+			seen[calendar] = true
+			all = append(all, calendar)
+		}
+	}
+	return all
+}
+
+// Config is the JSON configuration of calendars
+type Config struct {
+	Default   string           `json:"default"` // name of the calendar for instruments without calendar
+	Calendars []CalendarConfig `json:"calendars"`
+}
+
+type CalendarConfig struct {
+	Name        string   `json:"name"`
+	Base        string   `json:"base"` // built-in calendar whose sessions are copied, e.g. "forex"
+	Timezone    string   `json:"timezone"`
+	Instruments []string `json:"instruments"`
+	Sessions    []struct {
+		Days  []string `json:"days"`
+		Open  string   `json:"open"`
+		Close string   `json:"close"`
+	} `json:"sessions"`
+	Holidays []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"holidays"`
+	EarlyCloses []struct {
+		Date  string `json:"date"`
+		Close string `json:"close"`
+	} `json:"early_closes"`
+	Blackouts []struct {
+		Name  string    `json:"name"`
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"blackouts"`
+	DailyBlackouts []struct {
+		Name     string `json:"name"`
+		Timezone string `json:"timezone"`
+		From     string `json:"from"`
+		To       string `json:"to"`
+	} `json:"daily_blackouts"`
+	Files []string `json:"files"` // CSV or ICS files with holidays, early closes and blackouts
+}
+
+// Load returns the built-in calendar with the given name or the calendars of a JSON config file
+// for every instrument
+func Load(nameOrPath string) (*Calendars, error) {
+	if calendar, err := Named(nameOrPath); err == nil {
+		return &Calendars{fallback: calendar}, nil
+	}
+
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid calendar config %s: %w", nameOrPath, err)
+	}
+	return config.calendars(filepath.Dir(nameOrPath))
+}
+
+func (config Config) calendars(dir string) (*Calendars, error) {
+	var calendars = &Calendars{byInstrument: map[string]*Calendar{}}
+	var byName = map[string]*Calendar{}
+	for _, calendarConfig := range config.Calendars {
+		calendar, err := calendarConfig.calendar(dir)
+		if err != nil {
+			return nil, fmt.Errorf("calendar %s: %w", calendarConfig.Name, err)
+		}
+		byName[calendar.Name()] = calendar
+		for _, instrument := range calendarConfig.Instruments {
+			calendars.byInstrument[instrument] = calendar
+		}
+	}
+
+	var fallback = config.Default
+	if fallback == "" {
+		fallback = NameForex
+	}
+	if calendar, ok := byName[fallback]; ok {
+		calendars.fallback = calendar
+		return calendars, nil
+	}
+	calendar, err := Named(fallback)
+	if err != nil {
+		return nil, err
+	}
+	calendars.fallback = calendar
+	return calendars, nil
+}
+
+func (config CalendarConfig) calendar(dir string) (*Calendar, error) {
+	var location = time.UTC
+	var sessions map[time.Weekday][]Session
+	if config.Base != "" {
+		base, err := Named(config.Base)
+		if err != nil {
+			return nil, err
+		}
+		location, sessions = base.location, base.sessions
+	}
+	if config.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(config.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	var calendar = New(config.Name, location)
+	for _, daySessions := range sessions {
+		for _, session := range daySessions {
+			calendar.addSession(session)
+		}
+	}
+	for _, session := range config.Sessions {
+		open, err := ParseClockTime(session.Open)
+		if err != nil {
+			return nil, err
+		}
+		closeAt, err := ParseClockTime(session.Close)
+		if err != nil {
+			return nil, err
+		}
+		for _, day := range session.Days {
+			weekday, err := ParseWeekday(day)
+			if err != nil {
+				return nil, err
+			}
+			calendar.addSession(Session{Weekday: weekday, Open: open, Close: closeAt})
+		}
+	}
+	for _, holiday := range config.Holidays {
+		date, err := time.ParseInLocation(dateLayout, holiday.Date, location)
+		if err != nil {
+			return nil, err
+		}
+		calendar.addHoliday(date, holiday.Name)
+	}
+	for _, earlyClose := range config.EarlyCloses {
+		date, err := time.ParseInLocation(dateLayout, earlyClose.Date, location)
+		if err != nil {
+			return nil, err
+		}
+		closeAt, err := ParseClockTime(earlyClose.Close)
+		if err != nil {
+			return nil, err
+		}
+		calendar.addEarlyClose(date, closeAt)
+	}
+	for _, blackout := range config.Blackouts {
+		calendar.addBlackout(Blackout{Name: blackout.Name, Start: blackout.Start, End: blackout.End})
+	}
+	for _, blackout := range config.DailyBlackouts {
+		blackoutLocation, err := time.LoadLocation(blackout.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		from, err := ParseClockTime(blackout.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := ParseClockTime(blackout.To)
+		if err != nil {
+			return nil, err
+		}
+		WithDailyBlackout(blackout.Name, blackoutLocation, from, to)(calendar)
+	}
+	for _, file := range config.Files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if err := calendar.LoadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return calendar, nil
+}
+
+// LoadFile adds the holidays, early closes and blackouts of a CSV or ICS file
+func (c *Calendar) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".ics") {
+		err = c.loadICS(f)
+	} else {
+		err = c.loadCSV(f)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// loadCSV reads lines of date,type,name[,from[,to]] with type holiday, early_close or blackout.
+// Times are in the calendar's location. Lines starting with # are skipped.
+func (c *Calendar) loadCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+		if err := c.addRecord(record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+func (c *Calendar) addRecord(record []string) error {
+	if len(record) < 3 {
+		return fmt.Errorf("want date,type,name[,from[,to]], got %q", strings.Join(record, ","))
+	}
+	date, err := time.ParseInLocation(dateLayout, record[0], c.location)
+	if err != nil {
+		return err
+	}
+	var times []time.Duration
+	for _, field := range record[3:] {
+		timeOfDay, err := ParseClockTime(field)
+		if err != nil {
+			return err
+		}
+		times = append(times, timeOfDay)
+	}
+
+	switch strings.ToLower(record[1]) {
+	case "holiday":
+		c.addHoliday(date, record[2])
+	case "early_close":
+		if len(times) != 1 {
+			return fmt.Errorf("early close %s requires a close time", record[0])
+		}
+		c.addEarlyClose(date, times[0])
+	case "blackout":
+		if len(times) != 2 {
+			return fmt.Errorf("blackout %s requires from and to", record[0])
+		}
+		c.addBlackout(Blackout{Name: record[2], Start: clock(date, times[0]), End: clock(date, times[1])})
+	default:
+		return fmt.Errorf("unknown type %q", record[1])
+	}
+	return nil
+}
+
+// loadICS adds all-day events as holidays and events with a time as blackouts
+func (c *Calendar) loadICS(r io.Reader) error {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// folded lines continue with a space or tab
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var event map[string]string
+	for _, line := range lines {
+		switch line {
+		case "BEGIN:VEVENT":
+			event = map[string]string{}
+			continue
+		case "END:VEVENT":
+			if err := c.addEvent(event); err != nil {
+				return err
+			}
+			event = nil
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		if key, value, found := strings.Cut(line, ":"); found {
+			event[key] = value
+			if name, _, hasParams := strings.Cut(key, ";"); hasParams {
+				event[name] = value
+				event[name+"_PARAMS"] = key
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Calendar) addEvent(event map[string]string) error {
+	var summary = event["SUMMARY"]
+	start, allDay, err := c.parseICSTime(event, "DTSTART")
+	if err != nil {
+		return fmt.Errorf("event %q: %w", summary, err)
+	}
+	if _, ok := event["DTEND"]; !ok {
+		if !allDay {
+			return fmt.Errorf("event %q has no end", summary)
+		}
+		c.addHoliday(start, summary)
+		return nil
+	}
+	end, _, err := c.parseICSTime(event, "DTEND")
+	if err != nil {
+		return fmt.Errorf("event %q: %w", summary, err)
+	}
+
+	if !allDay {
+		c.addBlackout(Blackout{Name: summary, Start: start, End: end})
+		return nil
+	}
+	// the end date of all-day events is exclusive
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		c.addHoliday(day, summary)
+	}
+	return nil
+}
+
+func (c *Calendar) parseICSTime(event map[string]string, key string) (t time.Time, allDay bool, err error) {
+	var value = event[key]
+	var params = event[key+"_PARAMS"]
+	if strings.Contains(params, "VALUE=DATE") && !strings.Contains(params, "VALUE=DATE-TIME") || len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, c.location)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	var location = c.location
+	if _, tzid, found := strings.Cut(params, "TZID="); found {
+		tzid, _, _ = strings.Cut(tzid, ";")
+		if location, err = time.LoadLocation(tzid); err != nil {
+			return t, false, err
+		}
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, location)
+	return t, false, err
+}
+
+// ParseClockTime parses a time of day like "08:30" as duration since midnight. "24:00" is midnight
+// of the next day.
+func ParseClockTime(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return time.Hour * 24, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWeekday parses abbreviated weekdays like "Mon"
+func ParseWeekday(s string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(strings.TrimSpace(s), weekday.String()[:3]) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
@@ -0,0 +1,103 @@
+package calendar
+
+import (
+	"github.com/AMekss/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	var path = filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCalendar_LoadFile(t *testing.T) {
+	var dir = t.TempDir()
+	var c = New("test", time.UTC, WithSessions(time.Hour*8, time.Hour*20, Weekdays...))
+
+	assert.NoError(t.Fatalf, c.LoadFile(writeFile(t, dir, "days.csv", `date,type,name,from,to
+# comment
+2021-01-04,holiday,New Year
+2021-01-05,early_close,Short day,12:00
+2021-01-06,blackout,ECB,13:45,15:00
+`)))
+	assert.True(t, !c.IsOpen(monday.Add(time.Hour*10)))
+	assert.True(t, !c.IsOpen(monday.AddDate(0, 0, 1).Add(time.Hour*12)))
+	assert.ErrorIncludesMessage(t, "blackout ECB", c.Check(monday.AddDate(0, 0, 2).Add(time.Hour*14)))
+
+	assert.NoError(t.Fatalf, c.LoadFile(writeFile(t, dir, "events.ics", `BEGIN:VCALENDAR
+BEGIN:VEVENT
+SUMMARY:Fed
+DTSTART:20210107T180000Z
+DTEND:20210107T190000Z
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Company
+  holiday
+DTSTART;VALUE=DATE:20210111
+DTEND;VALUE=DATE:20210113
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Berlin
+DTSTART;TZID=Europe/Berlin:20210114T100000
+DTEND;TZID=Europe/Berlin:20210114T110000
+END:VEVENT
+END:VCALENDAR
+`)))
+	assert.ErrorIncludesMessage(t, "blackout Fed", c.Check(monday.AddDate(0, 0, 3).Add(time.Hour*18)))
+	assert.ErrorIncludesMessage(t, "closed for Company holiday", c.Check(monday.AddDate(0, 0, 8).Add(time.Hour*10)))
+	assert.True(t, !c.IsOpen(monday.AddDate(0, 0, 7).Add(time.Hour*10)))
+	assert.True(t, c.IsOpen(monday.AddDate(0, 0, 9).Add(time.Hour*10)))
+	assert.NoError(t, c.Check(monday.AddDate(0, 0, 10).Add(time.Hour*10)))
+	assert.ErrorIncludesMessage(t, "blackout Berlin", c.Check(monday.AddDate(0, 0, 10).Add(time.Hour*9)))
+
+	assert.ErrorIncludesMessage(t, "unknown type", c.LoadFile(writeFile(t, dir, "bad.csv", "2021-01-04,closed,x\n")))
+}
+
+func TestLoad(t *testing.T) {
+	calendars, err := Load(NameNYSE)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualStrings(t, NameNYSE, calendars.For("AAPL").Name())
+
+	var dir = t.TempDir()
+	writeFile(t, dir, "xetra.csv", "2021-01-04,holiday,Bank holiday\n")
+	calendars, err = Load(writeFile(t, dir, "calendars.json", `{
+  "calendars": [
+    {
+      "name": "xetra",
+      "timezone": "Europe/Berlin",
+      "instruments": ["DAX"],
+      "sessions": [{"days": ["Mon", "Tue", "Wed", "Thu", "Fri"], "open": "09:00", "close": "17:30"}],
+      "early_closes": [{"date": "2021-01-05", "close": "14:00"}],
+      "files": ["xetra.csv"]
+    },
+    {
+      "name": "fx",
+      "base": "forex",
+      "instruments": ["EURUSD"],
+      "daily_blackouts": [{"name": "rollover", "timezone": "UTC", "from": "21:55", "to": "22:05"}]
+    }
+  ]
+}`))
+	assert.NoError(t.Fatalf, err)
+	assert.EqualStrings(t, NameForex, calendars.For("GBPUSD").Name())
+	assert.EqualInt(t, 3, len(calendars.All()))
+
+	var xetra = calendars.For("DAX")
+	assert.EqualStrings(t, "xetra", xetra.Name())
+	assert.True(t, !xetra.IsOpen(monday.Add(time.Hour*10)))
+	assert.True(t, xetra.IsOpen(monday.AddDate(0, 0, 1).Add(time.Hour*12)))
+	assert.True(t, !xetra.IsOpen(monday.AddDate(0, 0, 1).Add(time.Hour*13)))
+
+	var fx = calendars.For("EURUSD")
+	assert.True(t, fx.IsOpen(monday.Add(time.Hour*22)))
+	assert.ErrorIncludesMessage(t, "blackout rollover", fx.Check(monday.Add(time.Hour*22)))
+
+	_, err = Load("unknown")
+	assert.True(t, err != nil)
+}
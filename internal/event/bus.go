@@ -0,0 +1,146 @@
+package event
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
+)
+
+// Bus delivers events to subscribers. Synchronous subscribers are called by the publisher, so
+// they must be fast. Asynchronous subscribers have their own goroutine and a bounded queue.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*Subscription
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// Subscription of a handler to the events of one type
+type Subscription struct {
+	name         string
+	accepts      func(Event) bool
+	handler      func(Event)
+	queue        chan Event // nil: synchronous
+	dropWhenFull bool
+	dropped      uint64
+}
+
+type SubscribeOption func(*Subscription)
+
+// Async delivers the events in a goroutine of the subscriber. The publisher blocks while size
+// events are queued unless DropWhenFull is given.
+func Async(size int) SubscribeOption {
+	return func(s *Subscription) {
+		s.queue = make(chan Event, size)
+	}
+}
+
+// DropWhenFull drops events instead of blocking the publisher when the queue of an asynchronous
+// subscriber is full, e.g. for metrics or notifications which must not slow down trading
+func DropWhenFull() SubscribeOption {
+	return func(s *Subscription) {
+		s.dropWhenFull = true
+	}
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls handler for every event of type T, e.g. event.Candle. Subscribe to event.Event
+// to receive all events.
+func Subscribe[T Event](bus *Bus, name string, handler func(T), options ...SubscribeOption) *Subscription {
+	s := &Subscription{
+		name: name,
+		accepts: func(e Event) bool {
+			_, ok := e.(T)
+			return ok
+		},
+		handler: func(e Event) {
+			handler(e.(T))
+		},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	bus.add(s)
+	return s
+}
+
+func (b *Bus) add(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		log.Warnf("Event bus is closed, ignoring subscription %q", s.name)
+		return
+	}
+	b.subscribers = append(b.subscribers, s)
+	if s.queue != nil {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for e := range s.queue {
+				s.handler(e)
+			}
+		}()
+	}
+}
+
+// Publish passes the event to all subscribers of its type. Events published after Close are
+// ignored.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+	for _, s := range b.subscribers {
+		if !s.accepts(e) {
+			continue
+		}
+		if s.queue == nil {
+			s.handler(e)
+			continue
+		}
+		if !s.dropWhenFull {
+			s.queue <- e
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			if atomic.AddUint64(&s.dropped, 1) == 1 {
+				log.Warnf("Queue of event subscriber %q is full, dropping events", s.name)
+			}
+		}
+	}
+}
+
+// Close stops publishing and waits until the asynchronous subscribers handled their queued events
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for _, s := range b.subscribers {
+		if s.queue != nil {
+			close(s.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
@@ -0,0 +1,44 @@
+package event
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+	"time"
+)
+
+func TestBus(t *testing.T) {
+	var bus = NewBus()
+	var candles, all int
+	Subscribe(bus, "candles", func(Candle) { candles++ })
+	Subscribe(bus, "all", func(Event) { all++ }, Async(1))
+
+	var now = time.Now()
+	bus.Publish(Tick{Header: Header{Time: now}})
+	bus.Publish(Candle{Header: Header{Time: now}})
+	bus.Publish(Candle{Header: Header{Time: now}})
+	bus.Close()
+	bus.Publish(Candle{Header: Header{Time: now}})
+
+	assert.EqualInt(t, 2, candles)
+	assert.EqualInt(t, 3, all)
+}
+
+func TestBus_dropWhenFull(t *testing.T) {
+	var bus = NewBus()
+	var block = make(chan struct{})
+	var handled int
+	var s = Subscribe(bus, "slow", func(Tick) {
+		<-block
+		handled++
+	}, Async(1), DropWhenFull())
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(Tick{})
+	}
+	close(block)
+	bus.Close()
+
+	// One event in the handler and one in the queue
+	assert.True(t, s.Dropped() >= 3)
+	assert.EqualInt(t, 5, handled+int(s.Dropped()))
+}
@@ -0,0 +1,93 @@
+package event
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"time"
+)
+
+// Event is published on the bus. Subscribers choose the events they receive by type.
+type Event interface {
+	// Occurred returns the time of the tick which caused the event
+	Occurred() time.Time
+}
+
+// Header is shared by all events
+type Header struct {
+	Time       time.Time
+	Instrument string
+	Owner      string // owner of the trader which published the event, set when traders share a bus
+
+	// Candle processed by the trader when the event occurred, zero outside of candle processing
+	CandleStart    time.Time
+	CandleDuration time.Duration
+}
+
+func (h Header) Occurred() time.Time {
+	return h.Time
+}
+
+// Tick is a tick processed by the trader
+type Tick struct {
+	Header
+	Tick tick.Tick
+}
+
+// Candle is a closed candle of one of the strategy's timeframes
+type Candle struct {
+	Header
+	Candle ohlc.OHLC
+}
+
+// Order is an order accepted by the broker
+type Order struct {
+	Header
+	Order broker.Order
+}
+
+// Fill is the execution of an order (Entry) or the close of a position
+type Fill struct {
+	Header
+	Reference string // position
+	Direction broker.BuyDirection
+	Price     decimal.Decimal
+	Size      float64
+	Entry     bool
+}
+
+// PositionOpened is published when the trader sees a new position of the broker
+type PositionOpened struct {
+	Header
+	Position broker.Position
+}
+
+// PositionClosed is published when the trader sees a position closed by the broker
+type PositionClosed struct {
+	Header
+	Position broker.Position
+}
+
+// Rejection is an order rejected by a risk rule
+type Rejection struct {
+	Header
+	Order  broker.Order
+	Rule   string
+	Reason string
+}
+
+// Error is an error of the trader which didn't stop it, e.g. a failed broker request
+type Error struct {
+	Header
+	Message string
+	Err     error
+}
+
+// FeedHealth is published when the price feed of an instrument becomes stale and when it recovers
+type FeedHealth struct {
+	Header
+	Healthy    bool
+	LastTick   time.Time // last tick before the outage
+	Backfilled int       // candles loaded from the broker's history after the recovery
+}
@@ -31,6 +31,9 @@ func (pw *Paperwallet) CancelOrder(orderID string) error {
 
 func (pw *Paperwallet) checkOpenOrders() {
 	for orderID, order := range pw.openOrders {
+		if !pw.pricedBy(order.Instrument, pw.currentTick) {
+			continue
+		}
 		if order.Direction == broker.BuyDirectionLong {
 			if pw.currentTick.Ask.LessThanOrEqual(order.Limit) {
 				pw.openPosition(orderID, order)
@@ -1,6 +1,7 @@
 package paperwallet
 
 import (
+	"fmt"
 	"github.com/shopspring/decimal"
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/pkg/tick"
@@ -21,6 +22,7 @@ type Paperwallet struct {
 	spreadInCents     decimal.Decimal
 	slippageAbsolute  decimal.Decimal
 	currentTick       tick.Tick
+	currentTicks      map[string]tick.Tick // instrument -> most recent tick
 	sync.RWMutex
 }
 
@@ -64,6 +66,7 @@ func New(options ...Option) *Paperwallet {
 		openPositions:   map[string]broker.Position{},
 		closedPositions: map[string]broker.Position{},
 		openOrders:      map[string]broker.Order{},
+		currentTicks:    map[string]tick.Tick{},
 	}
 
 	for _, option := range options {
@@ -78,5 +81,33 @@ func (pw *Paperwallet) GetInitialBalance() decimal.Decimal {
 }
 
 func (pw *Paperwallet) GetBalance() decimal.Decimal {
+	pw.Lock()
+	defer pw.Unlock()
 	return pw.balance
 }
+
+// tickByInstrument returns the most recent tick for the given instrument or a zero tick if no price has
+// been seen for the instrument yet.
+func (pw *Paperwallet) tickByInstrument(instrument string) tick.Tick {
+	if currentTick, exists := pw.currentTicks[instrument]; exists {
+		return currentTick
+	}
+	if pw.currentTick.Instrument == instrument {
+		return pw.currentTick
+	}
+	return tick.Tick{}
+}
+
+// priced returns an error if no price has been seen for the instrument yet. Orders are rejected until
+// the instrument has its own tick instead of being filled at the price of another instrument.
+func (pw *Paperwallet) priced(instrument string) error {
+	if _, exists := pw.currentTicks[instrument]; exists || pw.currentTick.Instrument == instrument {
+		return nil
+	}
+	return fmt.Errorf("no price of %s yet", instrument)
+}
+
+// pricedBy checks if the given tick is the current price source for the instrument.
+func (pw *Paperwallet) pricedBy(instrument string, currentTick tick.Tick) bool {
+	return instrument == currentTick.Instrument
+}
@@ -254,10 +254,10 @@ func Test_getBuyPriceByDirection(t *testing.T) {
 	b := New()
 	b.currentTick = tick.New("test", time.Now(), bid, ask)
 
-	price := b.getBuyPriceByDirection(broker.BuyDirectionLong)
+	price := b.getBuyPriceByDirection(broker.BuyDirectionLong, "test")
 	assertDecimal(t, ask, price)
 
-	price = b.getBuyPriceByDirection(broker.BuyDirectionShort)
+	price = b.getBuyPriceByDirection(broker.BuyDirectionShort, "test")
 	assertDecimal(t, bid, price)
 }
 
@@ -267,17 +267,17 @@ func Test_getSellPriceByDirection(t *testing.T) {
 	b := New()
 	b.currentTick = tick.New("test", time.Now(), bid, ask)
 
-	price := b.getSellPriceByDirection(broker.BuyDirectionLong, true)
+	price := b.getSellPriceByDirection(broker.BuyDirectionLong, "test", true)
 	assertDecimal(t, bid, price)
 
-	price = b.getSellPriceByDirection(broker.BuyDirectionShort, true)
+	price = b.getSellPriceByDirection(broker.BuyDirectionShort, "test", true)
 	assertDecimal(t, ask, price)
 
 	// without slippage
-	price = b.getSellPriceByDirection(broker.BuyDirectionLong, false)
+	price = b.getSellPriceByDirection(broker.BuyDirectionLong, "test", false)
 	assertDecimal(t, bid, price)
 
-	price = b.getSellPriceByDirection(broker.BuyDirectionShort, false)
+	price = b.getSellPriceByDirection(broker.BuyDirectionShort, "test", false)
 	assertDecimal(t, ask, price)
 }
 
@@ -397,26 +397,90 @@ func Test_getAbsoluteTradingFee(t *testing.T) {
 		Ask: price,
 	}
 	wantLong := tradingFee.Add(price)
-	assertDecimal(t, wantLong, b.getBuyPriceByDirection(broker.BuyDirectionLong))
+	assertDecimal(t, wantLong, b.getBuyPriceByDirection(broker.BuyDirectionLong, ""))
 
 	// Buy: Short
 	b.currentTick = tick.Tick{
 		Bid: price,
 	}
 	wantShort := price.Sub(tradingFee)
-	assertDecimal(t, wantShort, b.getBuyPriceByDirection(broker.BuyDirectionShort))
+	assertDecimal(t, wantShort, b.getBuyPriceByDirection(broker.BuyDirectionShort, ""))
 
 	// Sell: Long
 	b.currentTick = tick.Tick{
 		Bid: price,
 	}
 	wantLong = price.Sub(tradingFee)
-	assertDecimal(t, wantLong, b.getSellPriceByDirection(broker.BuyDirectionLong, true))
+	assertDecimal(t, wantLong, b.getSellPriceByDirection(broker.BuyDirectionLong, "", true))
 
 	// Sell: Short
 	b.currentTick = tick.Tick{
 		Ask: price,
 	}
 	wantShort = price.Add(tradingFee)
-	assertDecimal(t, wantShort, b.getSellPriceByDirection(broker.BuyDirectionShort, true))
+	assertDecimal(t, wantShort, b.getSellPriceByDirection(broker.BuyDirectionShort, "", true))
+}
+
+func TestMultipleInstruments(t *testing.T) {
+	b := New()
+	now := time.Now()
+	b.SetCurrenctPrice(tick.New("A", now, decimal.NewFromFloat(1.0), decimal.NewFromFloat(1.0)))
+	b.SetCurrenctPrice(tick.New("B", now, decimal.NewFromFloat(100.0), decimal.NewFromFloat(100.0)))
+
+	orderA := broker.NewMarketOrder(broker.BuyDirectionLong, 1.00, "A", decimal.NewFromFloat(2.0), decimal.NewFromFloat(0.5))
+	_, err := b.Buy(orderA)
+	assert.NoError(t.Fatalf, err)
+
+	positions, err := b.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(positions))
+	assertDecimal(t, decimal.NewFromFloat(1.0), positions[0].BuyPrice)
+
+	// Price of B must not trigger target of A
+	b.SetCurrenctPrice(tick.New("B", now.Add(time.Minute), decimal.NewFromFloat(101.0), decimal.NewFromFloat(101.0)))
+	positions, err = b.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(positions))
+
+	// Sell uses the price of A even though B was the most recent tick
+	err = b.Sell(positions[0])
+	assert.NoError(t.Fatalf, err)
+	closedPositions, err := b.GetClosedPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(closedPositions))
+	assertDecimal(t, decimal.NewFromFloat(1.0), closedPositions[0].SellPrice)
+	assert.True(t, now == closedPositions[0].SellTime)
+
+	// Orders of C are rejected or pending until C has its own price
+	orderC := broker.NewMarketOrder(broker.BuyDirectionLong, 1.00, "C", decimal.Zero, decimal.Zero)
+	_, err = b.Buy(orderC)
+	assert.ErrorIncludesMessage(t, "no price of C", err)
+	limitC := broker.NewLimitOrder(broker.BuyDirectionLong, 1.00, "C", decimal.Zero, decimal.Zero, decimal.NewFromFloat(200))
+	b.openOrders["limit"] = limitC
+	b.SetCurrenctPrice(tick.New("B", now.Add(time.Minute*2), decimal.NewFromFloat(101.0), decimal.NewFromFloat(101.0)))
+	assert.EqualInt(t, 1, len(b.openOrders))
+	positions, err = b.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t, 0, len(positions))
+}
+
+func TestOrderTagIsPassedToPosition(t *testing.T) {
+	b := New()
+	b.SetCurrenctPrice(tick.New("", time.Now(), decimal.NewFromFloat(1.0), decimal.NewFromFloat(1.0)))
+
+	order := broker.NewMarketOrder(broker.BuyDirectionLong, 1.00, "", decimal.Zero, decimal.Zero)
+	order.Tag = "long"
+	_, err := b.Buy(order)
+	assert.NoError(t.Fatalf, err)
+
+	positions, err := b.GetOpenPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(positions))
+	assert.EqualStrings(t, "long", positions[0].Tag)
+
+	assert.NoError(t.Fatalf, b.Sell(positions[0]))
+	closedPositions, err := b.GetClosedPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(closedPositions))
+	assert.EqualStrings(t, "long", closedPositions[0].Tag)
 }
@@ -18,6 +18,9 @@ func (pw *Paperwallet) Buy(order broker.Order) (orderID string, err error) {
 	if err := order.Valid(); err != nil {
 		log.WithError(err).Fatalf("Order is not valid: %+v", order)
 	}
+	if err := pw.priced(order.Instrument); err != nil {
+		return "", err
+	}
 	if err := pw.buyCheckTargetAndStopLoss(order); err != nil {
 		return "", err
 	}
@@ -42,12 +45,14 @@ func (pw *Paperwallet) openPosition(orderID string, order broker.Order) broker.P
 	position := broker.Position{
 		Reference:     positionRef,
 		Instrument:    order.Instrument,
-		BuyPrice:      pw.getBuyPriceByDirection(order.Direction),
-		BuyTime:       pw.currentTick.Datetime,
+		BuyPrice:      pw.getBuyPriceByDirection(order.Direction, order.Instrument),
+		BuyTime:       pw.tickByInstrument(order.Instrument).Datetime,
 		BuyDirection:  order.Direction,
 		TargetPrice:   order.TargetPrice,
 		StopLossPrice: order.StopLossPrice,
 		Size:          order.Size,
+		Tag:           order.Tag,
+		Owner:         order.Owner,
 	}
 	pw.openPositions[position.Reference] = position
 	delete(pw.openOrders, orderID)
@@ -61,22 +66,24 @@ func (pw *Paperwallet) openPosition(orderID string, order broker.Order) broker.P
 	return position
 }
 
-func (pw *Paperwallet) getSellPriceByDirection(direction broker.BuyDirection, slippage bool) decimal.Decimal {
+func (pw *Paperwallet) getSellPriceByDirection(direction broker.BuyDirection, instrument string, slippage bool) decimal.Decimal {
+	var currentTick = pw.tickByInstrument(instrument)
+
 	switch direction {
 	case broker.BuyDirectionLong:
 		if slippage {
-			tradingFee := pw.getAbsoluteTradingFee(pw.currentTick.Bid)
+			tradingFee := pw.getAbsoluteTradingFee(currentTick.Bid)
 			pw.totalTradingFee = pw.totalTradingFee.Add(tradingFee)
-			return pw.currentTick.Bid.Sub(pw.slippageAbsolute).Sub(tradingFee)
+			return currentTick.Bid.Sub(pw.slippageAbsolute).Sub(tradingFee)
 		}
-		return pw.currentTick.Bid
+		return currentTick.Bid
 	case broker.BuyDirectionShort:
 		if slippage {
-			tradingFee := pw.getAbsoluteTradingFee(pw.currentTick.Ask)
+			tradingFee := pw.getAbsoluteTradingFee(currentTick.Ask)
 			pw.totalTradingFee = pw.totalTradingFee.Add(tradingFee)
-			return pw.currentTick.Ask.Add(pw.slippageAbsolute).Add(tradingFee)
+			return currentTick.Ask.Add(pw.slippageAbsolute).Add(tradingFee)
 		}
-		return pw.currentTick.Ask
+		return currentTick.Ask
 	default:
 		log.Fatal("unsupported direction", direction)
 	}
@@ -98,11 +105,11 @@ func (pw *Paperwallet) sell(position broker.Position, optionalSellPrice decimal.
 	}
 
 	if optionalSellPrice.IsZero() {
-		position.SellPrice = pw.getSellPriceByDirection(position.BuyDirection, slippage)
+		position.SellPrice = pw.getSellPriceByDirection(position.BuyDirection, position.Instrument, slippage)
 	} else {
 		position.SellPrice = optionalSellPrice
 	}
-	position.SellTime = pw.currentTick.Datetime
+	position.SellTime = pw.tickByInstrument(position.Instrument).Datetime
 
 	_, exists = pw.closedPositions[position.Reference]
 	if exists {
@@ -141,20 +148,22 @@ func (pw *Paperwallet) Sell(position broker.Position) error {
 }
 
 func (pw *Paperwallet) buyCheckTargetAndStopLoss(order broker.Order) error {
+	var currentTick = pw.tickByInstrument(order.Instrument)
+
 	switch order.Direction {
 	case broker.BuyDirectionLong:
 		//if order.TargetPrice.LessThan(pw.currentTick.Ask) {
 		//	return fmt.Errorf("target is below current price: %s < %s", order.TargetPrice, pw.currentTick.Ask)
 		//}
-		if pw.currentTick.Ask.LessThan(order.StopLossPrice) {
-			return fmt.Errorf("current price is below stop loss: %s < %s", pw.currentTick.Ask, order.StopLossPrice)
+		if currentTick.Ask.LessThan(order.StopLossPrice) {
+			return fmt.Errorf("current price is below stop loss: %s < %s", currentTick.Ask, order.StopLossPrice)
 		}
 	case broker.BuyDirectionShort:
 		//if order.TargetPrice.GreaterThan(pw.currentTick.Bid) {
 		//	return fmt.Errorf("target is above current price: %s > %s", order.TargetPrice, pw.currentTick.Bid)
 		//}
-		if pw.currentTick.Bid.GreaterThan(order.StopLossPrice) {
-			return fmt.Errorf("current price is above stop loss: %s > %s", pw.currentTick.Bid, order.StopLossPrice)
+		if currentTick.Bid.GreaterThan(order.StopLossPrice) {
+			return fmt.Errorf("current price is above stop loss: %s > %s", currentTick.Bid, order.StopLossPrice)
 		}
 	default:
 		log.Fatalf("unsupported order direction %s", order.Direction)
@@ -162,16 +171,18 @@ func (pw *Paperwallet) buyCheckTargetAndStopLoss(order broker.Order) error {
 	return nil
 }
 
-func (pw *Paperwallet) getBuyPriceByDirection(direction broker.BuyDirection) decimal.Decimal {
+func (pw *Paperwallet) getBuyPriceByDirection(direction broker.BuyDirection, instrument string) decimal.Decimal {
+	var currentTick = pw.tickByInstrument(instrument)
+
 	switch direction {
 	case broker.BuyDirectionLong:
-		var tradingFee = pw.getAbsoluteTradingFee(pw.currentTick.Ask)
+		var tradingFee = pw.getAbsoluteTradingFee(currentTick.Ask)
 		pw.totalTradingFee = pw.totalTradingFee.Add(tradingFee)
-		return pw.currentTick.Ask.Add(pw.slippageAbsolute).Add(tradingFee)
+		return currentTick.Ask.Add(pw.slippageAbsolute).Add(tradingFee)
 	case broker.BuyDirectionShort:
-		var tradingFee = pw.getAbsoluteTradingFee(pw.currentTick.Bid)
+		var tradingFee = pw.getAbsoluteTradingFee(currentTick.Bid)
 		pw.totalTradingFee = pw.totalTradingFee.Add(tradingFee)
-		return pw.currentTick.Bid.Sub(pw.slippageAbsolute).Sub(tradingFee)
+		return currentTick.Bid.Sub(pw.slippageAbsolute).Sub(tradingFee)
 	default:
 		log.Fatal("unsupported direction", direction)
 	}
@@ -214,9 +225,12 @@ func (pw *Paperwallet) checkOpenPositionsStopLoss(position broker.Position) (pos
 	return false
 }
 
+// SetCurrenctPrice updates the price of the tick's instrument and triggers pending orders,
+// targets and stop losses of that instrument.
 func (pw *Paperwallet) SetCurrenctPrice(currentTick tick.Tick) {
 	pw.Lock()
 	pw.currentTick = currentTick
+	pw.currentTicks[currentTick.Instrument] = currentTick
 	pw.checkOpenOrders()
 	pw.checkOpenPositions()
 	pw.Unlock()
@@ -224,6 +238,10 @@ func (pw *Paperwallet) SetCurrenctPrice(currentTick tick.Tick) {
 
 func (pw *Paperwallet) checkOpenPositions() {
 	for ref, position := range pw.openPositions {
+		if !pw.pricedBy(position.Instrument, pw.currentTick) {
+			continue
+		}
+
 		var perfPips = position.PerformanceAbsolute(pw.currentTick.Bid, pw.currentTick.Ask) * 10000
 		if perfPips > position.MaxSurge {
 			position.MaxSurge = perfPips
@@ -9,11 +9,12 @@ import (
 func (pw *Paperwallet) printOpenPositionSummary(position *broker.Position) {
 	sizeDec := decimal.NewFromFloat(position.Size)
 	entryAmount := position.BuyPrice.Mul(sizeDec)
+	currentTick := pw.tickByInstrument(position.Instrument)
 	currentPrice := decimal.Decimal{}
 	if position.BuyDirection == broker.BuyDirectionLong {
-		currentPrice = pw.currentTick.Bid
+		currentPrice = currentTick.Bid
 	} else {
-		currentPrice = pw.currentTick.Ask
+		currentPrice = currentTick.Ask
 	}
 	nowAmount := currentPrice.Mul(sizeDec)
 	profit := nowAmount.Sub(entryAmount)
@@ -0,0 +1,183 @@
+package portfolio
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	AllocationFixed       = "fixed"
+	AllocationRiskParity  = "riskparity"
+	AllocationEquityCurve = "equitycurve"
+)
+
+// Allocator decides which share of the capital each trader gets. The portfolio multiplies the
+// order sizes of a trader by its weight times the number of traders, so equal weights keep the
+// sizes of the strategies.
+type Allocator interface {
+	// Weights returns the weight of each trader. closedPositions contains the closed positions of
+	// each trader sorted by sell time. Weights sum up to at most 1.
+	Weights(closedPositions map[string][]broker.Position, now time.Time) (map[string]float64, error)
+}
+
+type fixedWeights struct {
+	weights map[string]float64
+}
+
+// FixedWeights allocates the given weights. They are normalized to the traders of the portfolio,
+// traders without weight get nothing.
+func FixedWeights(weights map[string]float64) Allocator {
+	return &fixedWeights{weights: weights}
+}
+
+func (f *fixedWeights) Weights(closedPositions map[string][]broker.Position, _ time.Time) (map[string]float64, error) {
+	var weights = map[string]float64{}
+	for key := range closedPositions {
+		weights[key] = math.Max(f.weights[key], 0)
+	}
+	return normalize(weights)
+}
+
+type riskParity struct {
+	lookback time.Duration
+}
+
+// RiskParity weights traders by the inverse volatility of their daily performance within the
+// lookback period. Traders without volatility get the average weight of the others, all traders
+// get equal weights if there is no volatility at all.
+func RiskParity(lookback time.Duration) Allocator {
+	return &riskParity{lookback: lookback}
+}
+
+func (r *riskParity) Weights(closedPositions map[string][]broker.Position, now time.Time) (map[string]float64, error) {
+	var inverseVolatility = map[string]float64{}
+	var sum float64
+	var withVolatility int
+	for key, positions := range closedPositions {
+		volatility := dailyVolatility(positions, now.Add(-r.lookback), now)
+		if volatility > 0 {
+			inverseVolatility[key] = 1 / volatility
+			sum += inverseVolatility[key]
+			withVolatility++
+		}
+	}
+
+	var weights = map[string]float64{}
+	for key := range closedPositions {
+		switch {
+		case withVolatility == 0:
+			weights[key] = 1
+		case inverseVolatility[key] == 0:
+			weights[key] = sum / float64(withVolatility)
+		default:
+			weights[key] = inverseVolatility[key]
+		}
+	}
+	return normalize(weights)
+}
+
+type equityCurve struct {
+	trades    int
+	reduction float64
+}
+
+// EquityCurve gives every trader the same weight but reduces it by the given factor while the
+// trader's equity curve is below its moving average over the last trades. The reduced capital is
+// not passed on to other traders.
+func EquityCurve(trades int, reduction float64) Allocator {
+	return &equityCurve{trades: trades, reduction: reduction}
+}
+
+func (e *equityCurve) Weights(closedPositions map[string][]broker.Position, _ time.Time) (map[string]float64, error) {
+	if len(closedPositions) == 0 {
+		return nil, errors.New("no traders")
+	}
+
+	var weights = map[string]float64{}
+	for key, positions := range closedPositions {
+		weights[key] = 1 / float64(len(closedPositions))
+		if belowMovingAverage(positions, e.trades) {
+			weights[key] *= e.reduction
+		}
+	}
+	return weights, nil
+}
+
+// belowMovingAverage checks if the equity after the last position is below the average equity
+// after each of the last trades positions
+func belowMovingAverage(positions []broker.Position, trades int) bool {
+	if trades < 1 || len(positions) < trades {
+		return false
+	}
+
+	var equity float64
+	var curve []float64
+	for _, position := range positions {
+		equity += position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+		curve = append(curve, equity)
+	}
+
+	var sum float64
+	for _, e := range curve[len(curve)-trades:] {
+		sum += e
+	}
+	return equity < sum/float64(trades)
+}
+
+// dailyVolatility returns the standard deviation of the daily performance in percent. Days
+// without positions count as zero performance.
+func dailyVolatility(positions []broker.Position, from, to time.Time) float64 {
+	var days = map[string]float64{}
+	for day := from.UTC().Truncate(time.Hour * 24); !day.After(to); day = day.Add(time.Hour * 24) {
+		days[day.Format("2006-01-02")] = 0
+	}
+	for _, position := range positions {
+		if position.SellTime.Before(from) || position.SellTime.After(to) {
+			continue
+		}
+		days[position.SellTime.UTC().Format("2006-01-02")] += position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+	}
+	if len(days) < 2 {
+		return 0
+	}
+
+	var mean float64
+	for _, perf := range days {
+		mean += perf
+	}
+	mean /= float64(len(days))
+
+	var variance float64
+	for _, perf := range days {
+		variance += (perf - mean) * (perf - mean)
+	}
+	return math.Sqrt(variance / float64(len(days)-1))
+}
+
+func normalize(weights map[string]float64) (map[string]float64, error) {
+	var sum float64
+	for _, weight := range weights {
+		sum += weight
+	}
+	if sum <= 0 {
+		return nil, errors.New("weights must not be zero")
+	}
+	for key := range weights {
+		weights[key] /= sum
+	}
+	return weights, nil
+}
+
+// sortedKeys returns the keys of the weights in alphabetical order
+func sortedKeys(weights map[string]float64) []string {
+	var keys []string
+	for key := range weights {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
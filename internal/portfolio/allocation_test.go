@@ -0,0 +1,58 @@
+package portfolio
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/sklinkert/at/internal/broker"
+	"testing"
+	"time"
+)
+
+func TestFixedWeights(t *testing.T) {
+	var closedPositions = map[string][]broker.Position{"A": nil, "B": nil, "C": nil}
+	weights, err := FixedWeights(map[string]float64{"A": 3, "B": 1, "D": 4}).Weights(closedPositions, time.Now())
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 0.75, weights["A"])
+	assert.EqualFloat64(t, 0.25, weights["B"])
+	assert.EqualFloat64(t, 0, weights["C"])
+	assert.EqualInt(t, 3, len(weights))
+
+	_, err = FixedWeights(map[string]float64{}).Weights(closedPositions, time.Now())
+	assert.ErrorIncludesMessage(t, "zero", err)
+}
+
+func TestRiskParity(t *testing.T) {
+	var day = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	var closedPositions = map[string][]broker.Position{
+		// A is twice as volatile as B
+		"A": {newPosition("A", day, 1, 3), newPosition("A", day.AddDate(0, 0, 1), 3, 1)},
+		"B": {newPosition("B", day, 2, 3), newPosition("B", day.AddDate(0, 0, 1), 3, 2)},
+		"C": nil,
+	}
+
+	weights, err := RiskParity(time.Hour*24*2).Weights(closedPositions, day.AddDate(0, 0, 1))
+	assert.NoError(t.Fatalf, err)
+	assert.True(t, weights["B"] > weights["A"])
+	assert.True(t, weights["C"] > weights["A"] && weights["C"] < weights["B"])
+	assert.EqualFloat64(t, 1, weights["A"]+weights["B"]+weights["C"])
+
+	weights, err = RiskParity(time.Hour).Weights(map[string][]broker.Position{"A": nil, "B": nil}, day)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 0.5, weights["A"])
+	assert.EqualFloat64(t, 0.5, weights["B"])
+}
+
+func TestEquityCurve(t *testing.T) {
+	var day = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	var closedPositions = map[string][]broker.Position{
+		"A": {newPosition("A", day, 1, 2), newPosition("A", day.AddDate(0, 0, 1), 1, 2)},
+		"B": {newPosition("B", day, 1, 2), newPosition("B", day.AddDate(0, 0, 1), 2, 1)},
+	}
+
+	weights, err := EquityCurve(2, 0.5).Weights(closedPositions, day)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 0.5, weights["A"])
+	assert.EqualFloat64(t, 0.25, weights["B"])
+
+	_, err = EquityCurve(2, 0.5).Weights(map[string][]broker.Position{}, day)
+	assert.ErrorIncludesMessage(t, "no traders", err)
+}
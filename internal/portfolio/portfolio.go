@@ -0,0 +1,230 @@
+package portfolio
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/trader"
+	"github.com/sklinkert/at/pkg/tick"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Portfolio runs several traders on top of one shared broker. The broker's price feed is fanned
+// out to every trader responsible for the tick's instrument. Traders should be restricted to
+// their instruments with trader.WithInstruments(). Traders sharing an instrument need an owner
+// set by trader.WithOwner().
+type Portfolio struct {
+	broker        broker.Broker
+	traders       []*trader.Trader
+	allocator     Allocator
+	weights       map[string]float64
+	lastRebalance time.Time
+	running       bool
+	stop          chan struct{} // ends the fan-out of the feed
+	fannedOut     chan struct{} // closed when the tick channels of the traders are closed
+	stopping      sync.Once
+	sync.Mutex
+}
+
+type Option func(*Portfolio)
+
+// WithTrader adds a trader to the portfolio. The trader has to use the portfolio's broker.
+func WithTrader(tr *trader.Trader) Option {
+	return func(portfolio *Portfolio) {
+		portfolio.traders = append(portfolio.traders, tr)
+	}
+}
+
+// WithAllocator scales the order sizes of the traders by their capital allocation. Weights are
+// updated once a day.
+func WithAllocator(allocator Allocator) Option {
+	return func(portfolio *Portfolio) {
+		portfolio.allocator = allocator
+	}
+}
+
+func New(brokerBackend broker.Broker, options ...Option) *Portfolio {
+	p := &Portfolio{
+		broker: brokerBackend,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+// Traders returns all traders of the portfolio
+func (p *Portfolio) Traders() []*trader.Trader {
+	return p.traders
+}
+
+// Key identifies a trader within the portfolio: its owner or its instrument if it has no owner
+func Key(tr *trader.Trader) string {
+	if tr.Owner() != "" {
+		return tr.Owner()
+	}
+	return tr.Instrument
+}
+
+// Weights returns the current capital allocation of each trader
+func (p *Portfolio) Weights() map[string]float64 {
+	p.Lock()
+	defer p.Unlock()
+
+	var weights = map[string]float64{}
+	for key, weight := range p.weights {
+		weights[key] = weight
+	}
+	return weights
+}
+
+// rebalance updates the capital allocation and the order size factor of each trader
+func (p *Portfolio) rebalance(now time.Time) {
+	p.Lock()
+	p.lastRebalance = now
+	p.Unlock()
+
+	var closedPositions = map[string][]broker.Position{}
+	for _, tr := range p.traders {
+		positions, err := tr.GetClosedPositions()
+		if err != nil {
+			log.WithError(err).Errorf("Cannot get closed positions of %q", Key(tr))
+			return
+		}
+		sort.Sort(sortedBySellTime(positions))
+		closedPositions[Key(tr)] = positions
+	}
+
+	weights, err := p.allocator.Weights(closedPositions, now)
+	if err != nil {
+		log.WithError(err).Error("Cannot allocate capital")
+		return
+	}
+
+	for _, tr := range p.traders {
+		tr.SetSizeFactor(weights[Key(tr)] * float64(len(p.traders)))
+	}
+
+	p.Lock()
+	p.weights = weights
+	p.Unlock()
+
+	for _, key := range sortedKeys(weights) {
+		log.Debugf("Allocation %s: %.2f%%", key, weights[key]*100)
+	}
+}
+
+// rebalanceDue checks if the allocation hasn't been updated on the tick's day
+func (p *Portfolio) rebalanceDue(now time.Time) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.allocator == nil {
+		return false
+	}
+	y1, m1, d1 := p.lastRebalance.Date()
+	y2, m2, d2 := now.Date()
+	return y1 != y2 || m1 != m2 || d1 != d2
+}
+
+// Start starts all traders and blocks until the broker's price feed ended and the traders are stopped
+func (p *Portfolio) Start() error {
+	p.Lock()
+	if p.running {
+		p.Unlock()
+		return errors.New("already running")
+	}
+	if len(p.traders) == 0 {
+		p.Unlock()
+		return errors.New("no traders")
+	}
+	p.running = true
+	p.stop = make(chan struct{})
+	p.fannedOut = make(chan struct{})
+	p.Unlock()
+
+	for _, tr := range p.traders {
+		if err := tr.StartWithExternalFeed(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Starting portfolio with %d traders", len(p.traders))
+
+	var feed = make(chan tick.Tick)
+	go p.fanOut(feed)
+	p.broker.ListenToPriceFeed(feed)
+
+	p.Lock()
+	p.running = false
+	p.Unlock()
+	p.stopTraders()
+
+	return nil
+}
+
+// fanOut passes the ticks of the feed to the traders until the feed ends or the portfolio is
+// stopped and closes the tick channels of the traders afterwards
+func (p *Portfolio) fanOut(feed chan tick.Tick) {
+	defer close(p.fannedOut)
+	defer func() {
+		for _, tr := range p.traders {
+			close(tr.TickChan)
+		}
+	}()
+
+	for {
+		select {
+		case <-p.stop:
+			// The broker may keep sending until its feed ends
+			go func() {
+				for range feed {
+				}
+			}()
+			return
+		case currentTick, ok := <-feed:
+			if !ok {
+				return
+			}
+			if p.rebalanceDue(currentTick.Datetime) {
+				p.rebalance(currentTick.Datetime)
+			}
+			for _, tr := range p.traders {
+				if tr.HandlesInstrument(currentTick.Instrument) {
+					tr.TickChan <- currentTick
+				}
+			}
+		}
+	}
+}
+
+// Stop ends the fan-out of the price feed and stops all traders once they processed their ticks
+func (p *Portfolio) Stop() error {
+	p.Lock()
+	if !p.running {
+		p.Unlock()
+		return errors.New("already stopped")
+	}
+	p.running = false
+	close(p.stop)
+	p.Unlock()
+
+	p.stopTraders()
+	return nil
+}
+
+// stopTraders stops the traders after the fan-out ended. Concurrent calls wait for the first one.
+func (p *Portfolio) stopTraders() {
+	p.stopping.Do(func() {
+		<-p.fannedOut
+		for _, tr := range p.traders {
+			if err := tr.Stop(); err != nil {
+				log.WithError(err).Warn("Cannot stop trader")
+			}
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package portfolio
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/internal/strategy/sma10"
+	"github.com/sklinkert/at/internal/trader"
+	"github.com/sklinkert/at/pkg/tick"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// feedBroker has no positions and sends the given number of minute ticks of A and B
+type feedBroker struct {
+	ticks int
+	sent  chan struct{} // receives a value after each tick
+}
+
+func (feedBroker) Buy(broker.Order) (string, error)       { return "", nil }
+func (feedBroker) CancelOrder(string) error               { return nil }
+func (feedBroker) Sell(broker.Position) error             { return nil }
+func (feedBroker) GetOpenOrders() ([]broker.Order, error) { return nil, nil }
+func (feedBroker) GetOpenPosition(string) (broker.Position, error) {
+	return broker.Position{}, broker.ErrPositionNotFound
+}
+func (feedBroker) GetOpenPositions() ([]broker.Position, error)                   { return nil, nil }
+func (feedBroker) GetOpenPositionsByInstrument(string) ([]broker.Position, error) { return nil, nil }
+func (feedBroker) GetClosedPositions() ([]broker.Position, error)                 { return nil, nil }
+
+func (b feedBroker) ListenToPriceFeed(c chan tick.Tick) {
+	var start = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < b.ticks; i++ {
+		price := decimal.NewFromFloat(1.0 + float64(i%100)/1000)
+		for _, instrument := range []string{"A", "B"} {
+			c <- tick.New(instrument, start.Add(time.Minute*time.Duration(i)), price, price)
+		}
+		if b.sent != nil {
+			b.sent <- struct{}{}
+		}
+	}
+	close(c)
+	if b.sent != nil {
+		close(b.sent)
+	}
+}
+
+func newTestPortfolio(b feedBroker, received *int64) *Portfolio {
+	var options []Option
+	for _, instrument := range []string{"A", "B"} {
+		tr := trader.New(context.Background(), instrument, "", nil, trader.WithBroker(b),
+			trader.WithStrategy(sma10.New(instrument, time.Minute*15)), trader.WithInstruments(instrument))
+		event.Subscribe(tr.Events(), "test", func(event.Tick) {
+			atomic.AddInt64(received, 1)
+		}, event.Async(1))
+		options = append(options, WithTrader(tr))
+	}
+	return New(b, options...)
+}
+
+func TestPortfolio_Start(t *testing.T) {
+	var received int64
+	var p = newTestPortfolio(feedBroker{ticks: 500}, &received)
+
+	// Start returns after every trader processed all ticks of the feed
+	assert.NoError(t.Fatalf, p.Start())
+	assert.EqualInt(t, 1000, int(atomic.LoadInt64(&received)))
+	assert.ErrorIncludesMessage(t, "already stopped", p.Stop())
+}
+
+func TestPortfolio_Stop(t *testing.T) {
+	var received int64
+	var b = feedBroker{ticks: 500, sent: make(chan struct{})}
+	var p = newTestPortfolio(b, &received)
+
+	var started = make(chan error)
+	go func() { started <- p.Start() }()
+	for i := 0; i < 10; i++ {
+		<-b.sent
+	}
+
+	// The feed keeps sending while the portfolio stops
+	go func() {
+		for range b.sent {
+		}
+	}()
+	assert.NoError(t.Fatalf, p.Stop())
+	var stopped = atomic.LoadInt64(&received)
+	assert.True(t, stopped >= 20 && stopped < 1000)
+	assert.NoError(t, <-started)
+	assert.EqualInt(t, int(stopped), int(atomic.LoadInt64(&received)))
+}
@@ -0,0 +1,338 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"math"
+	"sort"
+)
+
+// InstrumentResult is the performance of one instrument within the portfolio
+type InstrumentResult struct {
+	Instrument       string
+	Trades           int
+	TradesWin        int
+	TotalPerformance float64
+	MaxDrawdown      float64
+}
+
+// StrategyResult is the performance of one trader within the portfolio identified by its owner
+type StrategyResult struct {
+	Owner            string
+	Weight           float64 // current capital allocation, zero without allocator
+	Trades           int
+	TradesWin        int
+	TotalPerformance float64
+	MaxDrawdown      float64
+}
+
+// OpenPositionsResult aggregates the open positions of an instrument over all traders
+type OpenPositionsResult struct {
+	Instrument string
+	Positions  int
+	NetSize    float64 // long minus short
+}
+
+// Report is the performance of the whole portfolio
+type Report struct {
+	Instruments      []InstrumentResult
+	Strategies       []StrategyResult
+	OpenPositions    []OpenPositionsResult
+	Trades           int
+	TradesWin        int
+	TotalPerformance float64
+	MaxDrawdown      float64
+
+	// SumOfInstrumentDrawdowns is the worst case when all instruments draw down at the same time
+	SumOfInstrumentDrawdowns float64
+
+	// DrawdownDiversification is MaxDrawdown / SumOfInstrumentDrawdowns. Values near 1 mean
+	// drawdowns are correlated, lower values mean the instruments diversify each other.
+	DrawdownDiversification float64
+
+	// MaxConcurrentDrawdowns is the highest number of instruments being in a drawdown at the same time
+	MaxConcurrentDrawdowns int
+
+	// Correlations contains the correlation of the daily performance for each pair of instruments
+	Correlations map[string]float64
+}
+
+type sortedBySellTime []broker.Position
+
+func (p sortedBySellTime) Len() int {
+	return len(p)
+}
+
+func (p sortedBySellTime) Less(i, j int) bool {
+	return p[i].SellTime.Before(p[j].SellTime)
+}
+
+func (p sortedBySellTime) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// GetReport calculates the performance of each instrument and the whole portfolio
+func (p *Portfolio) GetReport() (*Report, error) {
+	closedPositions, err := p.broker.GetClosedPositions()
+	if err != nil {
+		return nil, err
+	}
+	if len(closedPositions) == 0 {
+		return nil, errors.New("no positions")
+	}
+
+	openPositions, err := p.broker.GetOpenPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport(closedPositions)
+	report.OpenPositions = aggregateOpenPositions(openPositions)
+	var weights = p.Weights()
+	for i := range report.Strategies {
+		report.Strategies[i].Weight = weights[report.Strategies[i].Owner]
+	}
+	return report, nil
+}
+
+// aggregateOpenPositions sums up the open positions of each instrument
+func aggregateOpenPositions(openPositions []broker.Position) []OpenPositionsResult {
+	var byInstrument = map[string]*OpenPositionsResult{}
+	var instruments []string
+	for _, position := range openPositions {
+		result, exists := byInstrument[position.Instrument]
+		if !exists {
+			result = &OpenPositionsResult{Instrument: position.Instrument}
+			byInstrument[position.Instrument] = result
+			instruments = append(instruments, position.Instrument)
+		}
+		result.Positions++
+		if position.BuyDirection == broker.BuyDirectionShort {
+			result.NetSize -= position.Size
+		} else {
+			result.NetSize += position.Size
+		}
+	}
+	sort.Strings(instruments)
+
+	var results []OpenPositionsResult
+	for _, instrument := range instruments {
+		results = append(results, *byInstrument[instrument])
+	}
+	return results
+}
+
+func newReport(closedPositions []broker.Position) *Report {
+	var positions = make(sortedBySellTime, len(closedPositions))
+	copy(positions, closedPositions)
+	sort.Sort(positions)
+
+	var report = &Report{
+		Correlations: map[string]float64{},
+	}
+
+	var byInstrument = map[string][]broker.Position{}
+	var instruments []string
+	for _, position := range positions {
+		if _, exists := byInstrument[position.Instrument]; !exists {
+			instruments = append(instruments, position.Instrument)
+		}
+		byInstrument[position.Instrument] = append(byInstrument[position.Instrument], position)
+	}
+	sort.Strings(instruments)
+
+	for _, instrument := range instruments {
+		result := InstrumentResult{
+			Instrument:       instrument,
+			Trades:           len(byInstrument[instrument]),
+			TradesWin:        tradesWin(byInstrument[instrument]),
+			TotalPerformance: totalPerformance(byInstrument[instrument]),
+			MaxDrawdown:      maxDrawdown(byInstrument[instrument]),
+		}
+		report.Instruments = append(report.Instruments, result)
+		report.SumOfInstrumentDrawdowns += result.MaxDrawdown
+	}
+
+	var byOwner = map[string][]broker.Position{}
+	var owners []string
+	for _, position := range positions {
+		if position.Owner == "" {
+			continue
+		}
+		if _, exists := byOwner[position.Owner]; !exists {
+			owners = append(owners, position.Owner)
+		}
+		byOwner[position.Owner] = append(byOwner[position.Owner], position)
+	}
+	sort.Strings(owners)
+
+	for _, owner := range owners {
+		report.Strategies = append(report.Strategies, StrategyResult{
+			Owner:            owner,
+			Trades:           len(byOwner[owner]),
+			TradesWin:        tradesWin(byOwner[owner]),
+			TotalPerformance: totalPerformance(byOwner[owner]),
+			MaxDrawdown:      maxDrawdown(byOwner[owner]),
+		})
+	}
+
+	report.Trades = len(positions)
+	report.TradesWin = tradesWin(positions)
+	report.TotalPerformance = totalPerformance(positions)
+	report.MaxDrawdown = maxDrawdown(positions)
+	report.MaxConcurrentDrawdowns = maxConcurrentDrawdowns(positions)
+	if report.SumOfInstrumentDrawdowns > 0 {
+		report.DrawdownDiversification = report.MaxDrawdown / report.SumOfInstrumentDrawdowns
+	}
+
+	var dailyPerformance = map[string]map[string]float64{}
+	for _, instrument := range instruments {
+		dailyPerformance[instrument] = dailyPerformanceByDay(byInstrument[instrument])
+	}
+	for i := range instruments {
+		for j := i + 1; j < len(instruments); j++ {
+			key := fmt.Sprintf("%s/%s", instruments[i], instruments[j])
+			report.Correlations[key] = correlation(dailyPerformance[instruments[i]], dailyPerformance[instruments[j]])
+		}
+	}
+
+	return report
+}
+
+func positionPerformance(position broker.Position) float64 {
+	return position.PerformanceAbsolute(decimal.Zero, decimal.Zero)
+}
+
+func tradesWin(positions []broker.Position) (trades int) {
+	for _, position := range positions {
+		if positionPerformance(position) >= 0 {
+			trades++
+		}
+	}
+	return
+}
+
+func totalPerformance(positions []broker.Position) (total float64) {
+	for _, position := range positions {
+		total += positionPerformance(position)
+	}
+	return
+}
+
+// maxDrawdown returns the biggest decline from a peak of the equity curve. Positions have to be
+// sorted by sell time.
+func maxDrawdown(positions []broker.Position) float64 {
+	var equity, peak, drawdown float64
+	for _, position := range positions {
+		equity += positionPerformance(position)
+		peak = math.Max(peak, equity)
+		drawdown = math.Max(drawdown, peak-equity)
+	}
+	return drawdown
+}
+
+// maxConcurrentDrawdowns counts how many instruments are below their equity peak at the same time.
+// Positions have to be sorted by sell time.
+func maxConcurrentDrawdowns(positions []broker.Position) (maxConcurrent int) {
+	var equity = map[string]float64{}
+	var peak = map[string]float64{}
+	for _, position := range positions {
+		equity[position.Instrument] += positionPerformance(position)
+		peak[position.Instrument] = math.Max(peak[position.Instrument], equity[position.Instrument])
+
+		var concurrent int
+		for instrument := range equity {
+			if equity[instrument] < peak[instrument] {
+				concurrent++
+			}
+		}
+		if concurrent > maxConcurrent {
+			maxConcurrent = concurrent
+		}
+	}
+	return
+}
+
+func dailyPerformanceByDay(positions []broker.Position) map[string]float64 {
+	var perf = map[string]float64{}
+	for _, position := range positions {
+		perf[position.SellTime.Format("2006-01-02")] += positionPerformance(position)
+	}
+	return perf
+}
+
+// correlation returns the Pearson correlation of two daily performance series. Days without
+// trades count as zero performance.
+func correlation(a, b map[string]float64) float64 {
+	var days = map[string]bool{}
+	for day := range a {
+		days[day] = true
+	}
+	for day := range b {
+		days[day] = true
+	}
+	n := float64(len(days))
+	if n < 2 {
+		return 0
+	}
+
+	var sumA, sumB float64
+	for day := range days {
+		sumA += a[day]
+		sumB += b[day]
+	}
+	meanA, meanB := sumA/n, sumB/n
+
+	var cov, varA, varB float64
+	for day := range days {
+		cov += (a[day] - meanA) * (b[day] - meanB)
+		varA += (a[day] - meanA) * (a[day] - meanA)
+		varB += (b[day] - meanB) * (b[day] - meanB)
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// Summary prints the performance of each trader and the whole portfolio
+func (p *Portfolio) Summary() {
+	for _, tr := range p.traders {
+		tr.Summary()
+	}
+
+	report, err := p.GetReport()
+	if err != nil {
+		log.WithError(err).Error("Cannot get portfolio report")
+		return
+	}
+
+	for _, result := range report.Instruments {
+		log.Infof("%25s: %d trades (%d win) performance %.4f max drawdown %.4f", result.Instrument,
+			result.Trades, result.TradesWin, result.TotalPerformance, result.MaxDrawdown)
+	}
+	for _, result := range report.Strategies {
+		log.Infof("%25s: %d trades (%d win) performance %.4f max drawdown %.4f allocation %.2f%%", result.Owner,
+			result.Trades, result.TradesWin, result.TotalPerformance, result.MaxDrawdown, result.Weight*100)
+	}
+	for _, result := range report.OpenPositions {
+		log.Infof("%25s: %d open positions, net size %.2f", result.Instrument, result.Positions, result.NetSize)
+	}
+	log.Infof("%25s: %d (%d win)", "Portfolio positions", report.Trades, report.TradesWin)
+	log.Infof("%25s: %.4f", "Portfolio performance", report.TotalPerformance)
+	log.Infof("%25s: %.4f (sum of instruments %.4f)", "Portfolio max drawdown", report.MaxDrawdown, report.SumOfInstrumentDrawdowns)
+	log.Infof("%25s: %.2f", "Drawdown diversification", report.DrawdownDiversification)
+	log.Infof("%25s: %d", "Max concurrent drawdowns", report.MaxConcurrentDrawdowns)
+
+	var pairs []string
+	for pair := range report.Correlations {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		log.Infof("%25s: %.2f", "Correlation "+pair, report.Correlations[pair])
+	}
+}
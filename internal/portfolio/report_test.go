@@ -0,0 +1,98 @@
+package portfolio
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"testing"
+	"time"
+)
+
+func newPosition(instrument string, sellTime time.Time, buyPrice, sellPrice float64) broker.Position {
+	return broker.Position{
+		Instrument:   instrument,
+		BuyDirection: broker.BuyDirectionLong,
+		BuyPrice:     decimal.NewFromFloat(buyPrice),
+		SellPrice:    decimal.NewFromFloat(sellPrice),
+		BuyTime:      sellTime.Add(-time.Hour),
+		SellTime:     sellTime,
+		Size:         1,
+	}
+}
+
+func Test_newReport(t *testing.T) {
+	var day = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	var positions = []broker.Position{
+		newPosition("A", day, 1, 3),                  // A: +2
+		newPosition("B", day.Add(time.Minute), 1, 2), // B: +1
+		newPosition("A", day.AddDate(0, 0, 1), 3, 1), // A: -2
+		newPosition("B", day.AddDate(0, 0, 2), 2, 1), // B: -1
+		newPosition("A", day.AddDate(0, 0, 3), 1, 2), // A: +1
+		newPosition("B", day.AddDate(0, 0, 3), 1, 1), // B: 0
+	}
+
+	report := newReport(positions)
+	assert.EqualInt(t, 6, report.Trades)
+	assert.EqualInt(t, 4, report.TradesWin)
+	assert.EqualFloat64(t, 1, report.TotalPerformance)
+	assert.EqualInt(t.Fatalf, 2, len(report.Instruments))
+
+	assert.EqualStrings(t, "A", report.Instruments[0].Instrument)
+	assert.EqualFloat64(t, 1, report.Instruments[0].TotalPerformance)
+	assert.EqualFloat64(t, 2, report.Instruments[0].MaxDrawdown)
+	assert.EqualFloat64(t, 1, report.Instruments[1].MaxDrawdown)
+
+	// Portfolio: +2 +1 -2 -1 +1 +0 => peak 3, lowest 0
+	assert.EqualFloat64(t, 3, report.MaxDrawdown)
+	assert.EqualFloat64(t, 3, report.SumOfInstrumentDrawdowns)
+	assert.EqualFloat64(t, 1, report.DrawdownDiversification)
+	assert.EqualInt(t, 2, report.MaxConcurrentDrawdowns)
+	assert.True(t, report.Correlations["A/B"] > 0)
+}
+
+func Test_newReportStrategies(t *testing.T) {
+	var day = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	var positions = []broker.Position{
+		newPosition("A", day, 1, 3),
+		newPosition("A", day.AddDate(0, 0, 1), 3, 1),
+		newPosition("A", day.AddDate(0, 0, 2), 1, 2),
+	}
+	positions[0].Owner = "sma/A"
+	positions[1].Owner = "rsi/A"
+	positions[2].Owner = "sma/A"
+
+	report := newReport(positions)
+	assert.EqualInt(t.Fatalf, 2, len(report.Strategies))
+	assert.EqualStrings(t, "rsi/A", report.Strategies[0].Owner)
+	assert.EqualInt(t, 1, report.Strategies[0].Trades)
+	assert.EqualFloat64(t, -2, report.Strategies[0].TotalPerformance)
+	assert.EqualStrings(t, "sma/A", report.Strategies[1].Owner)
+	assert.EqualInt(t, 2, report.Strategies[1].TradesWin)
+	assert.EqualFloat64(t, 3, report.Strategies[1].TotalPerformance)
+}
+
+func Test_aggregateOpenPositions(t *testing.T) {
+	var now = time.Now()
+	var positions = []broker.Position{
+		newPosition("B", now, 1, 1),
+		newPosition("A", now, 1, 1),
+		newPosition("A", now, 1, 1),
+	}
+	positions[2].BuyDirection = broker.BuyDirectionShort
+	positions[2].Size = 3
+
+	results := aggregateOpenPositions(positions)
+	assert.EqualInt(t.Fatalf, 2, len(results))
+	assert.EqualStrings(t, "A", results[0].Instrument)
+	assert.EqualInt(t, 2, results[0].Positions)
+	assert.EqualFloat64(t, -2, results[0].NetSize)
+	assert.EqualFloat64(t, 1, results[1].NetSize)
+}
+
+func Test_correlation(t *testing.T) {
+	a := map[string]float64{"1": 1, "2": 2, "3": 3}
+	b := map[string]float64{"1": -1, "2": -2, "3": -3}
+	assert.EqualFloat64(t, -1, correlation(a, b))
+	assert.EqualFloat64(t, 1, correlation(a, a))
+	assert.EqualFloat64(t, 0, correlation(a, map[string]float64{}))
+}
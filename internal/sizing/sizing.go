@@ -0,0 +1,218 @@
+package sizing
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"math"
+)
+
+const (
+	MethodFixedFractional = "fixedfractional"
+	MethodFixedNotional   = "fixednotional"
+	MethodVolatility      = "volatility"
+	MethodKelly           = "kelly"
+)
+
+var (
+	ErrNoStopLoss   = errors.New("order has no stop loss")
+	ErrNoVolatility = errors.New("volatility is unknown")
+	ErrNoEdge       = errors.New("historical trades have no edge")
+	ErrBelowMinSize = errors.New("size is below the instrument's minimum size")
+)
+
+// Input describes the account and the order to size
+type Input struct {
+	Equity          float64
+	Price           float64 // entry price
+	StopLoss        float64 // stop loss price, zero if the order has none
+	ATR             float64 // average true range of the instrument, zero if unknown
+	ClosedPositions []broker.Position
+}
+
+// Method computes an order size before rounding to the instrument's lots
+type Method interface {
+	Size(in Input) (float64, error)
+}
+
+type fixedFractional struct {
+	riskPercent float64
+}
+
+// FixedFractional risks riskPercent of the equity between entry and stop loss
+func FixedFractional(riskPercent float64) Method {
+	return &fixedFractional{riskPercent: riskPercent}
+}
+
+func (f *fixedFractional) Size(in Input) (float64, error) {
+	return riskSize(in, f.riskPercent)
+}
+
+type fixedNotional struct {
+	notional float64
+}
+
+// FixedNotional buys the same value on every order
+func FixedNotional(notional float64) Method {
+	return &fixedNotional{notional: notional}
+}
+
+func (f *fixedNotional) Size(in Input) (float64, error) {
+	if in.Price <= 0 {
+		return 0, fmt.Errorf("invalid price %f", in.Price)
+	}
+	return f.notional / in.Price, nil
+}
+
+type volatility struct {
+	riskPercent float64
+	atrMultiple float64
+}
+
+// Volatility risks riskPercent of the equity on a price move of atrMultiple times the ATR, so
+// positions get smaller when the market gets more volatile
+func Volatility(riskPercent, atrMultiple float64) Method {
+	return &volatility{riskPercent: riskPercent, atrMultiple: atrMultiple}
+}
+
+func (v *volatility) Size(in Input) (float64, error) {
+	if in.ATR <= 0 {
+		return 0, ErrNoVolatility
+	}
+	return in.Equity * v.riskPercent / 100 / (in.ATR * v.atrMultiple), nil
+}
+
+type kelly struct {
+	fraction           float64
+	minTrades          int
+	defaultRiskPercent float64
+}
+
+// Kelly risks the given fraction of the Kelly criterion between entry and stop loss. The criterion
+// is derived from win rate and payoff ratio of the closed positions. defaultRiskPercent is risked
+// until there are minTrades closed positions.
+func Kelly(fraction float64, minTrades int, defaultRiskPercent float64) Method {
+	return &kelly{fraction: fraction, minTrades: minTrades, defaultRiskPercent: defaultRiskPercent}
+}
+
+func (k *kelly) Size(in Input) (float64, error) {
+	if len(in.ClosedPositions) < k.minTrades || len(in.ClosedPositions) == 0 {
+		return riskSize(in, k.defaultRiskPercent)
+	}
+
+	criterion := kellyCriterion(in.ClosedPositions)
+	if criterion <= 0 {
+		return 0, ErrNoEdge
+	}
+	return riskSize(in, criterion*k.fraction*100)
+}
+
+// kellyCriterion returns the share of the equity to risk: win rate - loss rate / payoff ratio
+func kellyCriterion(positions []broker.Position) float64 {
+	var wins, losses int
+	var sumWin, sumLoss float64
+	for _, position := range positions {
+		perf := position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+		switch {
+		case perf > 0:
+			wins++
+			sumWin += perf
+		case perf < 0:
+			losses++
+			sumLoss -= perf
+		}
+	}
+	if wins == 0 {
+		return 0
+	}
+	if losses == 0 {
+		return 1
+	}
+
+	var winRate = float64(wins) / float64(wins+losses)
+	var payoffRatio = (sumWin / float64(wins)) / (sumLoss / float64(losses))
+	return winRate - (1-winRate)/payoffRatio
+}
+
+// riskSize returns the size which loses riskPercent of the equity when the stop loss is hit
+func riskSize(in Input, riskPercent float64) (float64, error) {
+	if in.StopLoss <= 0 {
+		return 0, ErrNoStopLoss
+	}
+	distance := math.Abs(in.Price - in.StopLoss)
+	if distance == 0 {
+		return 0, fmt.Errorf("stop loss %f equals price", in.StopLoss)
+	}
+	return in.Equity * riskPercent / 100 / distance, nil
+}
+
+const defaultATRPeriod = 14
+
+// Sizer computes order sizes with a sizing method and rounds them to the instrument's lots
+type Sizer struct {
+	method    Method
+	lotStep   float64
+	minSize   float64
+	atrPeriod int
+}
+
+type Option func(*Sizer)
+
+// WithLotStep rounds sizes down to multiples of step
+func WithLotStep(step float64) Option {
+	return func(sizer *Sizer) {
+		sizer.lotStep = step
+	}
+}
+
+// WithMinSize rejects sizes below min
+func WithMinSize(min float64) Option {
+	return func(sizer *Sizer) {
+		sizer.minSize = min
+	}
+}
+
+// WithATRPeriod sets the number of candles of the ATR passed by the trader, default 14
+func WithATRPeriod(period int) Option {
+	return func(sizer *Sizer) {
+		sizer.atrPeriod = period
+	}
+}
+
+func New(method Method, options ...Option) *Sizer {
+	s := &Sizer{method: method, atrPeriod: defaultATRPeriod}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// ATRPeriod returns the number of candles of the ATR in Input
+func (s *Sizer) ATRPeriod() int {
+	return s.atrPeriod
+}
+
+// Size returns the order size for the input rounded to the instrument's lots
+func (s *Sizer) Size(in Input) (float64, error) {
+	size, err := s.method.Size(in)
+	if err != nil {
+		return 0, err
+	}
+	return s.Round(size)
+}
+
+// Round rounds the size down to the lot step. Returns ErrBelowMinSize if the rounded size is
+// below the minimum size or zero.
+func (s *Sizer) Round(size float64) (float64, error) {
+	if s.lotStep > 0 {
+		step := decimal.NewFromFloat(s.lotStep)
+		size = decimal.NewFromFloat(size).Div(step).Floor().Mul(step).InexactFloat64()
+	}
+	if size <= 0 || size < s.minSize {
+		return 0, ErrBelowMinSize
+	}
+	return size, nil
+}
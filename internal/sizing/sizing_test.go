@@ -0,0 +1,91 @@
+package sizing
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"testing"
+)
+
+func newPosition(buyPrice, sellPrice float64) broker.Position {
+	return broker.Position{
+		BuyDirection: broker.BuyDirectionLong,
+		BuyPrice:     decimal.NewFromFloat(buyPrice),
+		SellPrice:    decimal.NewFromFloat(sellPrice),
+		Size:         1,
+	}
+}
+
+func TestFixedFractional(t *testing.T) {
+	size, err := FixedFractional(1).Size(Input{Equity: 10000, Price: 100, StopLoss: 98})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 50, size)
+
+	size, err = FixedFractional(1).Size(Input{Equity: 10000, Price: 100, StopLoss: 104})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 25, size)
+
+	_, err = FixedFractional(1).Size(Input{Equity: 10000, Price: 100})
+	assert.True(t, err == ErrNoStopLoss)
+}
+
+func TestFixedNotional(t *testing.T) {
+	size, err := FixedNotional(1000).Size(Input{Price: 40})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 25, size)
+
+	_, err = FixedNotional(1000).Size(Input{})
+	assert.ErrorIncludesMessage(t, "invalid price", err)
+}
+
+func TestVolatility(t *testing.T) {
+	size, err := Volatility(1, 2).Size(Input{Equity: 10000, Price: 100, ATR: 0.5})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 100, size)
+
+	_, err = Volatility(1, 2).Size(Input{Equity: 10000, Price: 100})
+	assert.True(t, err == ErrNoVolatility)
+}
+
+func TestKelly(t *testing.T) {
+	var in = Input{Equity: 10000, Price: 100, StopLoss: 99}
+
+	// Not enough trades yet
+	size, err := Kelly(0.5, 4, 1).Size(in)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 100, size)
+
+	// 3 wins of +2%, 1 loss of -1% => 0.75 - 0.25 / 2 = 0.625
+	in.ClosedPositions = []broker.Position{newPosition(100, 102), newPosition(100, 102), newPosition(100, 99), newPosition(100, 102)}
+	size, err = Kelly(0.5, 4, 1).Size(in)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 3125, size)
+
+	// 1 win of +1%, 3 losses of -1% => 0.25 - 0.75 / 1 < 0
+	in.ClosedPositions = []broker.Position{newPosition(100, 101), newPosition(100, 99), newPosition(100, 99), newPosition(100, 99)}
+	_, err = Kelly(0.5, 4, 1).Size(in)
+	assert.True(t, err == ErrNoEdge)
+}
+
+func TestSizer_Round(t *testing.T) {
+	var sizer = New(FixedNotional(1000), WithLotStep(0.1), WithMinSize(0.5))
+
+	size, err := sizer.Round(0.38)
+	assert.True(t, err == ErrBelowMinSize)
+	assert.EqualFloat64(t, 0, size)
+
+	size, err = sizer.Round(0.79)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 0.7, size)
+
+	_, err = sizer.Size(Input{Price: 3000})
+	assert.True(t, err == ErrBelowMinSize)
+
+	size, err = sizer.Size(Input{Price: 300})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 3.3, size)
+
+	size, err = New(FixedNotional(1000)).Size(Input{Price: 3000})
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 1000.0/3000, size)
+}
@@ -1,6 +1,7 @@
 package heikinashi
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/shopspring/decimal"
@@ -30,6 +31,19 @@ type HeikinAshi struct {
 	currentTick            tick.Tick
 }
 
+// haCandlesToCheck is the number of the most recent Heikin-Ashi candles checkCandleAmount() looks at with offset 2
+const haCandlesToCheck = 6
+
+type snapshot struct {
+	ClosedHACandles        []ohlc.OHLC          `json:"closed_ha_candles"`
+	IgnoreInitialDirection bool                 `json:"ignore_initial_direction"`
+	InitialDirection       *broker.BuyDirection `json:"initial_direction"`
+	CurrentDirection       *broker.BuyDirection `json:"current_direction"`
+	CandlesReceived        bool                 `json:"candles_received"`
+	Volatility             json.RawMessage      `json:"volatility"`
+	SMA                    json.RawMessage      `json:"sma"`
+}
+
 func New(instrument string) *HeikinAshi {
 	clog := log.WithFields(log.Fields{"INSTRUMENT": instrument})
 
@@ -321,6 +335,62 @@ func (ha *HeikinAshi) calcStopLossPrice(direction broker.BuyDirection, tick tick
 	}
 }
 
+// Snapshot returns the indicators, directions and the most recent Heikin-Ashi candles
+func (ha *HeikinAshi) Snapshot() ([]byte, error) {
+	var s = snapshot{
+		IgnoreInitialDirection: ha.ignoreInitialDirection,
+		InitialDirection:       ha.initialDirection,
+		CurrentDirection:       ha.currentDirection,
+		CandlesReceived:        ha.candlesReceived,
+	}
+	var candles = ha.closedHACandles
+	if len(candles) > haCandlesToCheck {
+		candles = candles[len(candles)-haCandlesToCheck:]
+	}
+	for _, candle := range candles {
+		s.ClosedHACandles = append(s.ClosedHACandles, *candle)
+	}
+
+	var err error
+	if s.Volatility, err = ha.volaTracker.Snapshot(); err != nil {
+		return nil, err
+	}
+	smaSnapshotter, ok := ha.sma.(indicator.Snapshotter)
+	if !ok {
+		return nil, errors.New("SMA cannot be snapshotted")
+	}
+	if s.SMA, err = smaSnapshotter.Snapshot(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func (ha *HeikinAshi) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	var restored = New(ha.instrument)
+	if err := restored.volaTracker.Restore(s.Volatility); err != nil {
+		return fmt.Errorf("cannot restore volatility: %w", err)
+	}
+	if err := restored.sma.(indicator.Snapshotter).Restore(s.SMA); err != nil {
+		return fmt.Errorf("cannot restore SMA: %w", err)
+	}
+
+	ha.volaTracker, ha.sma = restored.volaTracker, restored.sma
+	ha.closedHACandles = nil
+	for i := range s.ClosedHACandles {
+		ha.closedHACandles = append(ha.closedHACandles, &s.ClosedHACandles[i])
+	}
+	ha.ignoreInitialDirection = s.IgnoreInitialDirection
+	ha.initialDirection = s.InitialDirection
+	ha.currentDirection = s.CurrentDirection
+	ha.candlesReceived = s.CandlesReceived
+	return nil
+}
+
 func (ha *HeikinAshi) Name() string {
 	return strategy.NameHeikinAshi
 }
@@ -60,3 +60,37 @@ func TestHeikinAshi_checkCandleAmount(t *testing.T) {
 	err = ha.checkCandleAmount(broker.BuyDirectionLong, 2)
 	assert.NoError(t, err)
 }
+
+func TestHeikinAshi_Snapshot(t *testing.T) {
+	var candles []*ohlc.OHLC
+	for i, price := range []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 9, 8} {
+		o := ohlc.New("test", time.Now().Add(time.Minute*time.Duration(i)), time.Minute, false)
+		o.NewPrice(decimal.NewFromFloat(price), o.Start)
+		o.NewPrice(decimal.NewFromFloat(price+0.5), o.Start)
+		o.ForceClose()
+		candles = append(candles, o)
+	}
+	var ha = New("test")
+	for i := range candles {
+		ha.OnCandle(candles[:i+1])
+	}
+
+	snapshot, err := ha.Snapshot()
+	assert.NoError(t.Fatalf, err)
+	var restored = New("test")
+	assert.NoError(t.Fatalf, restored.Restore(snapshot))
+
+	assert.EqualInt(t, haCandlesToCheck, len(restored.closedHACandles))
+	assert.True(t, restored.candlesReceived)
+	assert.True(t, *restored.currentDirection == *ha.currentDirection)
+	assert.True(t, restored.ignoreInitialDirection == ha.ignoreInitialDirection)
+	assert.IsNil(t, restored.initialDirection)
+	assert.NoError(t, restored.checkCandleAmount(broker.BuyDirectionLong, 2))
+	want, err := ha.volaTracker.MedianVolatilityInPercentage()
+	assert.NoError(t.Fatalf, err)
+	got, err := restored.volaTracker.MedianVolatilityInPercentage()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, want, got)
+
+	assert.ErrorIncludesMessage(t, "invalid character", restored.Restore([]byte("broken")))
+}
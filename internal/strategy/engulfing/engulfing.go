@@ -4,6 +4,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
 	"github.com/sklinkert/at/internal/strategy"
 	"github.com/sklinkert/at/pkg/helper"
 	"github.com/sklinkert/at/pkg/indicator/sma"
@@ -23,7 +24,7 @@ type Engulfing struct {
 	//previousLows  ring.Ring
 	//previousHighs ring.Ring
 	ohlcPeriod    time.Duration
-	locEST        *time.Location
+	calendar      *calendar.Calendar
 	openPositions []broker.Position
 	openOrders    []broker.Order
 }
@@ -34,22 +35,23 @@ const (
 	smaCandles           = 200
 	strategyLongEnabled  = true
 	strategyShortEnabled = true
+	tagLong              = "long"
+	tagShort             = "short"
 )
 
 func New(instrument string, candleDuration time.Duration) *Engulfing {
 	clog := log.WithFields(log.Fields{"INSTRUMENT": instrument, "CANDLE": candleDuration})
 
-	locEST, err := time.LoadLocation("EST")
-	if err != nil {
-		clog.WithError(err).Fatal("time zone EST missing")
-	}
+	// Weekends are in local time
+	var tradingCalendar = calendar.New(strategy.NameEngulfing, time.Local,
+		calendar.WithSessions(0, time.Hour*24, calendar.Weekdays...))
 
 	return &Engulfing{
 		clog:       clog,
 		instrument: instrument,
 		sma:        sma.New(smaCandles),
 		ohlcPeriod: candleDuration,
-		locEST:     locEST,
+		calendar:   tradingCalendar,
 	}
 }
 
@@ -77,23 +79,6 @@ func (d *Engulfing) GetCandleDuration() time.Duration {
 	return d.ohlcPeriod
 }
 
-func (d *Engulfing) noTradingPeriod(now time.Time) bool {
-	now = now.Local()
-
-	// Weekend
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-		return true
-	}
-
-	// Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing
-	//var estTime = now.In(d.locEST)
-	//if estTime.Hour() == 9 || estTime.Hour() == 16 { // 09:30 - 16:00 EST
-	//	return true
-	//}
-
-	return false
-}
-
 func (d *Engulfing) OnTick(_ tick.Tick) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
 	return
 }
@@ -218,8 +203,8 @@ func (d *Engulfing) strategyShort(closedCandles []*ohlc.OHLC) (toOpen []broker.O
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
@@ -229,7 +214,7 @@ func (d *Engulfing) strategyShort(closedCandles []*ohlc.OHLC) (toOpen []broker.O
 	}
 
 	if d.isBullishEngulfingCandle(closedCandles) {
-		toOpenNew := d.prepareOrder(closedCandle, broker.BuyDirectionShort, 1.00)
+		toOpenNew := d.prepareOrder(closedCandle, broker.BuyDirectionShort, 1.00, tagShort)
 		return []broker.Order{toOpenNew}, []broker.Position{}
 	}
 
@@ -267,8 +252,8 @@ func (d *Engulfing) strategyLong(closedCandles []*ohlc.OHLC) (toOpen []broker.Or
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
@@ -278,14 +263,14 @@ func (d *Engulfing) strategyLong(closedCandles []*ohlc.OHLC) (toOpen []broker.Or
 	}
 
 	if d.isBearishEngulfingCandle(closedCandles) {
-		toOpenNew := d.prepareOrder(closedCandle, broker.BuyDirectionLong, 1.00)
+		toOpenNew := d.prepareOrder(closedCandle, broker.BuyDirectionLong, 1.00, tagLong)
 		return []broker.Order{toOpenNew}, []broker.Position{}
 	}
 
 	return
 }
 
-func (d *Engulfing) prepareOrder(closedCandle *ohlc.OHLC, direction broker.BuyDirection, size float64) broker.Order {
+func (d *Engulfing) prepareOrder(closedCandle *ohlc.OHLC, direction broker.BuyDirection, size float64, tag string) broker.Order {
 	var (
 		targetPrice   = helper.CalcTargetPriceByPercentage(closedCandle.Close, helper.FloatToDecimal(targetInPercent), direction)
 		stopLossPrice = helper.CalcStopLossPriceByPercentage(closedCandle.Close, helper.FloatToDecimal(stopLossInPercent), direction)
@@ -297,9 +282,12 @@ func (d *Engulfing) prepareOrder(closedCandle *ohlc.OHLC, direction broker.BuyDi
 		"Close":     closedCandle.Close,
 		"Target":    targetInPercent,
 		"StopLoss":  stopLossPrice,
+		"Tag":       tag,
 	}).Debug("Prepare new order")
 
-	return broker.NewMarketOrder(direction, size, d.instrument, targetPrice, stopLossPrice)
+	order := broker.NewMarketOrder(direction, size, d.instrument, targetPrice, stopLossPrice)
+	order.Tag = tag
+	return order
 }
 
 func (d *Engulfing) Name() string {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
 	"github.com/sklinkert/at/internal/strategy"
 	"github.com/sklinkert/at/pkg/helper"
 	"github.com/sklinkert/at/pkg/indicator/sma"
@@ -24,7 +25,7 @@ type LowCandle struct {
 	previousLows  *circularbuffer.CircularBuffer
 	previousHighs *circularbuffer.CircularBuffer
 	ohlcPeriod    time.Duration
-	locEST        *time.Location
+	calendar      *calendar.Calendar
 	openPositions []broker.Position
 	openOrders    []broker.Order
 }
@@ -45,6 +46,10 @@ func New(instrument string, candleDuration time.Duration) *LowCandle {
 		clog.WithError(err).Fatal("time zone EST missing")
 	}
 
+	// Weekends are in local time. Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing.
+	var tradingCalendar = calendar.New(strategy.NameLowCandle, time.Local,
+		calendar.WithSessions(0, time.Hour*24, calendar.Weekdays...), calendar.WithUSOpenAndClose(locEST))
+
 	return &LowCandle{
 		clog:          clog,
 		instrument:    instrument,
@@ -52,7 +57,7 @@ func New(instrument string, candleDuration time.Duration) *LowCandle {
 		previousLows:  circularbuffer.New(7, 7),
 		previousHighs: circularbuffer.New(7, 7),
 		ohlcPeriod:    candleDuration,
-		locEST:        locEST,
+		calendar:      tradingCalendar,
 	}
 }
 
@@ -84,28 +89,6 @@ func (d *LowCandle) GetCandleDuration() time.Duration {
 	return d.ohlcPeriod
 }
 
-func (d *LowCandle) noTradingPeriod(now time.Time) bool {
-	now = now.Local()
-
-	// Weekend
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-		return true
-	}
-
-	// Avoid trading during night hours to improve backtesting matching.
-	if now.Hour() > 20 && now.Hour() < 4 {
-		return false
-	}
-
-	// Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing
-	var estTime = now.In(d.locEST)
-	if estTime.Hour() == 9 || estTime.Hour() == 16 { // 09:30 - 16:00 EST
-		return true
-	}
-
-	return false
-}
-
 func (d *LowCandle) OnTick(_ tick.Tick) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
 	return
 }
@@ -156,8 +139,8 @@ func (d *LowCandle) strategyLong(closedCandles []*ohlc.OHLC) (toOpen []broker.Or
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
@@ -211,8 +194,8 @@ func (d *LowCandle) strategyShort(closedCandles []*ohlc.OHLC) (toOpen []broker.O
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
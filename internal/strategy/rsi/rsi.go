@@ -1,6 +1,8 @@
 package rsi
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
@@ -33,6 +35,11 @@ const (
 	maxAgeOpenPosition = time.Hour * 2
 )
 
+type snapshot struct {
+	RSI json.RawMessage `json:"rsi"`
+	SMA json.RawMessage `json:"sma"`
+}
+
 func New(instrument string, candleDuration time.Duration) *RSI {
 	clog := log.WithFields(log.Fields{"INSTRUMENT": instrument})
 
@@ -66,6 +73,35 @@ func (d *RSI) GetWarmUpCandleAmount() uint {
 	return rsiSize * 10
 }
 
+// Snapshot returns the state of the RSI and the SMA
+func (d *RSI) Snapshot() ([]byte, error) {
+	var s snapshot
+	var err error
+	if s.RSI, err = d.rsi.Snapshot(); err != nil {
+		return nil, err
+	}
+	if s.SMA, err = d.sma.Snapshot(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func (d *RSI) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	var rsi, smaLong = indicatorrsi.New(rsiSize), sma.New(smaCandles)
+	if err := rsi.Restore(s.RSI); err != nil {
+		return fmt.Errorf("cannot restore RSI %d: %w", rsiSize, err)
+	}
+	if err := smaLong.Restore(s.SMA); err != nil {
+		return fmt.Errorf("cannot restore SMA %d: %w", smaCandles, err)
+	}
+	d.rsi, d.sma = rsi, smaLong
+	return nil
+}
+
 func (d *RSI) OnPosition(openPositions []broker.Position, _ []broker.Position) {
 	d.openPositions = openPositions
 }
@@ -1,6 +1,8 @@
 package rsiadx
 
 import (
+	"encoding/json"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/internal/strategy"
@@ -34,6 +36,12 @@ const (
 	maxAgeOpenPosition  = time.Hour * 2
 )
 
+type snapshot struct {
+	RSI json.RawMessage `json:"rsi"`
+	ADX json.RawMessage `json:"adx"`
+	EO  json.RawMessage `json:"eo"`
+}
+
 func New(instrument string, candleDuration time.Duration) *RSIADX {
 	clog := log.WithFields(log.Fields{"INSTRUMENT": instrument})
 
@@ -47,6 +55,41 @@ func New(instrument string, candleDuration time.Duration) *RSIADX {
 	}
 }
 
+// Snapshot returns the state of the RSI, the ADX and the environment overlay
+func (d *RSIADX) Snapshot() ([]byte, error) {
+	var s snapshot
+	var err error
+	if s.RSI, err = d.rsi.Snapshot(); err != nil {
+		return nil, err
+	}
+	if s.ADX, err = d.adx.Snapshot(); err != nil {
+		return nil, err
+	}
+	if s.EO, err = d.eo.Snapshot(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func (d *RSIADX) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	var rsi, adx, overlay = indicatorrsi.New(rsiCandles), indicatoradx.New(adxCandles), eo.New()
+	if err := rsi.Restore(s.RSI); err != nil {
+		return fmt.Errorf("cannot restore RSI %d: %w", rsiCandles, err)
+	}
+	if err := adx.Restore(s.ADX); err != nil {
+		return fmt.Errorf("cannot restore ADX %d: %w", adxCandles, err)
+	}
+	if err := overlay.Restore(s.EO); err != nil {
+		return fmt.Errorf("cannot restore environment overlay: %w", err)
+	}
+	d.rsi, d.adx, d.eo = rsi, adx, overlay
+	return nil
+}
+
 func (d *RSIADX) OnPosition(openPositions []broker.Position, _ []broker.Position) {
 	d.openPositions = openPositions
 }
@@ -0,0 +1,45 @@
+package rsiadx
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+func TestRSIADX_Snapshot(t *testing.T) {
+	var start = time.Now()
+	var rsiadx = New("test", time.Minute)
+	for i := 0; i < 60; i++ {
+		o := ohlc.New("test", start.Add(time.Minute*time.Duration(i)), time.Minute, false)
+		o.NewPrice(decimal.NewFromFloat(float64(100+i%7)), o.Start)
+		o.NewPrice(decimal.NewFromFloat(float64(101+i%5)), o.Start)
+		o.ForceClose()
+		rsiadx.OnWarmUpCandle(o)
+	}
+
+	snapshot, err := rsiadx.Snapshot()
+	assert.NoError(t.Fatalf, err)
+	var restored = New("test", time.Minute)
+	assert.NoError(t.Fatalf, restored.Restore(snapshot))
+
+	wantRSI, err := rsiadx.getRSI()
+	assert.NoError(t.Fatalf, err)
+	gotRSI, err := restored.getRSI()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, wantRSI, gotRSI)
+
+	wantADX, err := rsiadx.getADX()
+	assert.NoError(t.Fatalf, err)
+	gotADX, err := restored.getADX()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, wantADX, gotADX)
+
+	wantUpper, wantLower := rsiadx.eo.RSI()
+	gotUpper, gotLower := restored.eo.RSI()
+	assert.EqualFloat64(t, wantUpper, gotUpper)
+	assert.EqualFloat64(t, wantLower, gotLower)
+
+	assert.ErrorIncludesMessage(t, "invalid character", restored.Restore([]byte("broken")))
+}
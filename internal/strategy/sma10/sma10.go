@@ -1,9 +1,11 @@
 package sma10
 
 import (
+	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
 	"github.com/sklinkert/at/internal/strategy"
 	"github.com/sklinkert/at/pkg/helper"
 	"github.com/sklinkert/at/pkg/indicator/sma"
@@ -22,7 +24,7 @@ type SMA struct {
 	sma           *sma.SMA
 	sma10         *sma.SMA
 	ohlcPeriod    time.Duration
-	locEST        *time.Location
+	calendar      *calendar.Calendar
 	openPositions []broker.Position
 	openOrders    []broker.Order
 }
@@ -35,6 +37,11 @@ const (
 	strategyShortEnabled = true
 )
 
+type snapshot struct {
+	SMA   json.RawMessage `json:"sma"`
+	SMA10 json.RawMessage `json:"sma10"`
+}
+
 func New(instrument string, candleDuration time.Duration) *SMA {
 	clog := log.WithFields(log.Fields{"INSTRUMENT": instrument, "CANDLE": candleDuration})
 
@@ -43,13 +50,17 @@ func New(instrument string, candleDuration time.Duration) *SMA {
 		clog.WithError(err).Fatal("time zone EST missing")
 	}
 
+	// Weekends are in local time. Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing.
+	var tradingCalendar = calendar.New(strategy.NameSMA10, time.Local,
+		calendar.WithSessions(0, time.Hour*24, calendar.Weekdays...), calendar.WithUSOpenAndClose(locEST))
+
 	return &SMA{
 		clog:       clog,
 		instrument: instrument,
 		sma:        sma.New(smaCandles),
 		sma10:      sma.New(10),
 		ohlcPeriod: candleDuration,
-		locEST:     locEST,
+		calendar:   tradingCalendar,
 	}
 }
 
@@ -74,25 +85,37 @@ func (d *SMA) feedIndicator(closedCandle *ohlc.OHLC) {
 	d.sma10.Insert(closedCandle)
 }
 
-func (d *SMA) GetCandleDuration() time.Duration {
-	return d.ohlcPeriod
+// Snapshot returns the state of both SMAs
+func (d *SMA) Snapshot() ([]byte, error) {
+	var s snapshot
+	var err error
+	if s.SMA, err = d.sma.Snapshot(); err != nil {
+		return nil, err
+	}
+	if s.SMA10, err = d.sma10.Snapshot(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
 }
 
-func (d *SMA) noTradingPeriod(now time.Time) bool {
-	now = now.Local()
-
-	// Weekend
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-		return true
+func (d *SMA) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
 	}
-
-	// Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing
-	var estTime = now.In(d.locEST)
-	if estTime.Hour() == 9 || estTime.Hour() == 16 { // 09:30 - 16:00 EST
-		return true
+	var smaLong, sma10 = sma.New(smaCandles), sma.New(10)
+	if err := smaLong.Restore(s.SMA); err != nil {
+		return fmt.Errorf("cannot restore SMA %d: %w", smaCandles, err)
+	}
+	if err := sma10.Restore(s.SMA10); err != nil {
+		return fmt.Errorf("cannot restore SMA 10: %w", err)
 	}
+	d.sma, d.sma10 = smaLong, sma10
+	return nil
+}
 
-	return false
+func (d *SMA) GetCandleDuration() time.Duration {
+	return d.ohlcPeriod
 }
 
 func (d *SMA) OnTick(_ tick.Tick) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
@@ -146,8 +169,8 @@ func (d *SMA) strategyLong(closedCandles []*ohlc.OHLC) (toOpen []broker.Order, t
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
@@ -194,8 +217,8 @@ func (d *SMA) strategyShort(closedCandles []*ohlc.OHLC) (toOpen []broker.Order,
 		return
 	}
 
-	if d.noTradingPeriod(closedCandle.End) {
-		d.clog.Info("No trading period")
+	if err := d.calendar.Check(closedCandle.End); err != nil {
+		d.clog.Infof("No trading period: %v", err)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package stochrsi
 
 import (
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/internal/strategy"
@@ -25,6 +26,9 @@ const (
 	lowerThreshold    = 10
 	targetInPercent   = 4.0
 	stopLossInPercent = 0.5
+	fastK             = 5
+	fastD             = 2
+	rsiSize           = 14
 )
 
 func New(instrument string) *RSI {
@@ -33,10 +37,24 @@ func New(instrument string) *RSI {
 	return &RSI{
 		clog:       clog,
 		instrument: instrument,
-		rsi:        stochrsi.New(5, 2, 14),
+		rsi:        stochrsi.New(fastK, fastD, rsiSize),
 	}
 }
 
+// Snapshot returns the state of the stochastic RSI
+func (d *RSI) Snapshot() ([]byte, error) {
+	return d.rsi.Snapshot()
+}
+
+func (d *RSI) Restore(data []byte) error {
+	var rsi = stochrsi.New(fastK, fastD, rsiSize)
+	if err := rsi.Restore(data); err != nil {
+		return fmt.Errorf("cannot restore StochRSI: %w", err)
+	}
+	d.rsi = rsi
+	return nil
+}
+
 func (d *RSI) OnPosition(openPositions []broker.Position, _ []broker.Position) {
 	d.openPositions = openPositions
 }
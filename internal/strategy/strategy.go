@@ -25,7 +25,7 @@ type Strategy interface {
 	Name() string
 
 	// OnCandle is processing a list of closed candles. Will be called right after a new candle has been closed.
-	// closedCandles contains the 100 most recent candles.
+	// closedCandles contains the 100 most recent candles unless the strategy implements History.
 	OnCandle(closedCandles []*ohlc.OHLC) (toOpen, toClose []broker.Order, toClosePositions []broker.Position)
 
 	// OnTick is processing a new tick. Will be called right after a new tick has been received.
@@ -50,3 +50,67 @@ type Strategy interface {
 	// String explains the strategy settings, e.g. stop loss, target, etc.
 	String() string
 }
+
+// MultiTimeframe is implemented by strategies which need candles of several durations, e.g. 5m for entries and
+// 1h and 1d for the trend. The trader calls OnTimeframeCandle instead of OnCandle for them.
+type MultiTimeframe interface {
+	// GetCandleDurations returns the durations of all timeframes. GetCandleDuration() is the primary timeframe
+	// which is drawn in charts.
+	GetCandleDurations() []time.Duration
+
+	// OnTimeframeCandle is processing the closed candles of a timeframe. Will be called right after a new candle
+	// of the timeframe has been closed. closedCandles contains the 100 most recent candles of the timeframe
+	// unless the strategy implements History.
+	// Candles of longer timeframes are processed first if several close at the same time.
+	OnTimeframeCandle(timeframe time.Duration, closedCandles []*ohlc.OHLC) (toOpen, toClose []broker.Order, toClosePositions []broker.Position)
+
+	// OnWarmUpTimeframeCandle sends a closed candle of the timeframe for warming up indicators. Candles come from
+	// database or, until the warm-up amount has been reached, from the price feed.
+	OnWarmUpTimeframeCandle(timeframe time.Duration, closedCandle *ohlc.OHLC)
+
+	// GetTimeframeWarmUpCandleAmount tells the trader how many warmup candles of the timeframe are required.
+	GetTimeframeWarmUpCandleAmount(timeframe time.Duration) uint
+}
+
+// RejectionHandler is implemented by strategies which want to know about orders rejected by the trader's risk
+// rules, e.g. to reset their state.
+type RejectionHandler interface {
+	// OnOrderRejected is called right after the order has been rejected with the reason of the rejection.
+	OnOrderRejected(order broker.Order, reason string)
+}
+
+// Bars is implemented by strategies which process bars built from ticks instead of time candles, e.g. Renko
+// bricks or volume bars. The bars of a timeframe replace its candles in OnCandle, OnTimeframeCandle and the
+// warm-up; the timeframe's duration only identifies them. Bars aren't warmed up from stored candles.
+type Bars interface {
+	// GetBarType returns the bar type of the timeframe, the zero ohlc.BarType for candles of its duration.
+	GetBarType(timeframe time.Duration) ohlc.BarType
+}
+
+// History is implemented by strategies which need another number of closed candles than 100 in OnCandle and
+// OnTimeframeCandle, e.g. 200 for a 200 period SMA. Warm-up candles from the database count as history.
+type History interface {
+	// GetCandleHistory returns the number of the most recent closed candles of the timeframe passed to the
+	// strategy. Zero keeps the default.
+	GetCandleHistory(timeframe time.Duration) uint
+}
+
+// Snapshotter is implemented by strategies whose state can be saved and restored, e.g. indicator buffers and
+// previous candles. Traders with checkpoints restore the state on a restart instead of warming up again.
+type Snapshotter interface {
+	// Snapshot returns the state of the strategy.
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the state of a new strategy with a snapshot. Will be called before any candle is received.
+	// The state is unchanged if the snapshot can't be restored.
+	Restore(snapshot []byte) error
+}
+
+// FeedHealthHandler is implemented by strategies which want to know when the price feed of an instrument becomes
+// stale and when it recovers, e.g. to stop opening positions while prices are missing.
+type FeedHealthHandler interface {
+	// OnFeedHealth is called when the feed of the instrument becomes stale (healthy is false) and when it recovers.
+	// lastTick is the time of the last tick before the outage. Candles missed during the outage are sent as
+	// warm-up candles before the recovery is reported.
+	OnFeedHealth(instrument string, healthy bool, lastTick time.Time)
+}
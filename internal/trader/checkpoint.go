@@ -0,0 +1,225 @@
+package trader
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"gorm.io/gorm"
+	"time"
+)
+
+// StrategySnapshot is the saved state of a trader's strategy. Each trader keeps its latest snapshot.
+type StrategySnapshot struct {
+	gorm.Model
+	TraderKey string `gorm:"index"`
+	Strategy  string
+	LastTick  time.Time // time of the last tick processed before the snapshot
+	Data      []byte
+	Candles   []byte // open candles and candle history of the trader, see candlesSnapshot
+}
+
+// candlesSnapshot is the state of the trader's candles saved with the strategy, so a restarted trader
+// continues the open candles and passes the same history to the strategy
+type candlesSnapshot struct {
+	Builders []builderSnapshot
+	History  []historySnapshot
+}
+
+type builderSnapshot struct {
+	Instrument string
+	Duration   time.Duration
+	Data       []byte
+}
+
+type historySnapshot struct {
+	Instrument string
+	Duration   time.Duration
+	Candles    []*ohlc.CandleState
+}
+
+// WithCheckpoints saves the state of the strategy, the open candles and the candle history in the DB
+// every interval and on Stop() and restores them in New(), so a restarted trader resumes with the same
+// indicators and state instead of warming up again. An interval of zero only saves on Stop() and Checkpoint(). The strategy must implement
+// strategy.Snapshotter.
+func WithCheckpoints(interval time.Duration) Option {
+	return func(trader *Trader) {
+		trader.checkpoints = true
+		trader.checkpointInterval = interval
+	}
+}
+
+// Checkpoint saves the state of the strategy, e.g. on shutdown. Does nothing without WithCheckpoints().
+func (tr *Trader) Checkpoint() error {
+	tr.Lock()
+	defer tr.Unlock()
+	if !tr.checkpoints {
+		return nil
+	}
+	return tr.checkpoint()
+}
+
+func (tr *Trader) checkpoint() error {
+	snapshotter, ok := tr.strategy.(strategy.Snapshotter)
+	if !ok {
+		return errors.New("strategy cannot be snapshotted")
+	}
+	data, err := snapshotter.Snapshot()
+	if err != nil {
+		return err
+	}
+	candles, err := tr.snapshotCandles()
+	if err != nil {
+		return err
+	}
+
+	var lastTick time.Time
+	if t := tr.lastReceivedTick[tr.Instrument]; t != nil {
+		lastTick = t.Datetime
+	}
+	var snapshot StrategySnapshot
+	return tr.gormDB.
+		Where(StrategySnapshot{TraderKey: tr.key()}).
+		Assign(StrategySnapshot{Strategy: tr.strategy.Name(), LastTick: lastTick, Data: data, Candles: candles}).
+		FirstOrCreate(&snapshot).Error
+}
+
+// restoreCheckpoint restores the state of the strategy from its latest snapshot. Returns false if
+// there is no snapshot.
+func (tr *Trader) restoreCheckpoint() (bool, error) {
+	snapshotter, ok := tr.strategy.(strategy.Snapshotter)
+	if !ok {
+		return false, errors.New("strategy cannot be snapshotted")
+	}
+
+	var snapshots []StrategySnapshot
+	if err := tr.gormDB.Where("trader_key = ? AND strategy = ?", tr.key(), tr.strategy.Name()).
+		Order("updated_at DESC").Limit(1).Find(&snapshots).Error; err != nil {
+		return false, err
+	}
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+	if err := snapshotter.Restore(snapshots[0].Data); err != nil {
+		return false, err
+	}
+	tr.clog.Infof("Strategy restored from the snapshot of %s (last tick %s)", snapshots[0].UpdatedAt, snapshots[0].LastTick)
+	tr.restoredUntil = snapshots[0].LastTick
+	if tr.restoredUntil.IsZero() {
+		// No tick was processed before the snapshot
+		tr.restoredUntil = snapshots[0].UpdatedAt
+	}
+	if len(snapshots[0].Candles) > 0 {
+		if err := tr.restoreCandles(snapshots[0].Candles); err != nil {
+			tr.clog.WithError(err).Warn("Cannot restore candles from checkpoint, loading them from the warm-up sources")
+		}
+	}
+
+	// The restored strategy is warmed up already
+	if multi, ok := tr.strategy.(strategy.MultiTimeframe); ok {
+		var instruments = map[string]bool{tr.Instrument: true}
+		for instrument := range tr.instruments {
+			instruments[instrument] = true
+		}
+		for instrument := range instruments {
+			for _, timeframe := range timeframes(tr.strategy) {
+				tr.warmUpCandles[candleKey{instrument: instrument, duration: timeframe}] = multi.GetTimeframeWarmUpCandleAmount(timeframe)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (tr *Trader) snapshotCandles() ([]byte, error) {
+	var snapshot candlesSnapshot
+	for instrument, builders := range tr.aggregators {
+		for _, builder := range builders {
+			data, err := builder.Snapshot()
+			if err != nil {
+				return nil, err
+			}
+			snapshot.Builders = append(snapshot.Builders, builderSnapshot{Instrument: instrument, Duration: builder.Duration(), Data: data})
+		}
+	}
+	for key, history := range tr.closedCandles {
+		var candles []*ohlc.CandleState
+		for _, candle := range history.candles() {
+			candles = append(candles, ohlc.StateOf(candle))
+		}
+		snapshot.History = append(snapshot.History, historySnapshot{Instrument: key.instrument, Duration: key.duration, Candles: candles})
+	}
+	return json.Marshal(snapshot)
+}
+
+// restoreCandles restores the candle history and continues the open candles of the snapshot. Timeframes
+// the strategy doesn't use anymore are ignored.
+func (tr *Trader) restoreCandles(data []byte) error {
+	var snapshot candlesSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	var builders = map[candleKey][]byte{}
+	for _, builder := range snapshot.Builders {
+		builders[candleKey{instrument: builder.Instrument, duration: builder.Duration}] = builder.Data
+	}
+	var aggregators = map[string][]ohlc.Builder{}
+	for key := range builders {
+		if _, exists := aggregators[key.instrument]; exists {
+			continue
+		}
+		for _, duration := range tr.candleDurations() {
+			builder := tr.newBuilder(key.instrument, duration)
+			if data, exists := builders[candleKey{instrument: key.instrument, duration: duration}]; exists {
+				if err := builder.Restore(data); err != nil {
+					return err
+				}
+			}
+			aggregators[key.instrument] = append(aggregators[key.instrument], builder)
+		}
+	}
+
+	for _, history := range snapshot.History {
+		if !containsDuration(tr.candleDurations(), history.Duration) {
+			continue
+		}
+		for _, candle := range history.Candles {
+			tr.addClosedCandle(history.Instrument, candle.Candle())
+		}
+	}
+	tr.aggregators = aggregators
+	return nil
+}
+
+// resetBuilder drops the open candle of the instrument's timeframe, e.g. a restored candle which ended
+// while the trader was down
+func (tr *Trader) resetBuilder(instrument string, duration time.Duration) {
+	for i, builder := range tr.aggregators[instrument] {
+		if builder.Duration() == duration {
+			tr.aggregators[instrument][i] = tr.newBuilder(instrument, duration)
+		}
+	}
+}
+
+// checkpointPeriodically saves the state of the strategy every checkpoint interval
+func (tr *Trader) checkpointPeriodically() {
+	ticker := time.NewTicker(tr.checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tr.ctx.Done():
+			return
+		case <-ticker.C:
+			tr.Lock()
+			if !tr.running {
+				tr.Unlock()
+				return
+			}
+			if err := tr.checkpoint(); err != nil {
+				tr.clog.WithError(err).Error("Cannot save checkpoint")
+			}
+			tr.Unlock()
+		}
+	}
+}
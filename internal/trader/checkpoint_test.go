@@ -0,0 +1,119 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// snapshotStrategy is a multi timeframe strategy whose state is a string
+type snapshotStrategy struct {
+	*timeframeStrategy
+	state string
+}
+
+func (s *snapshotStrategy) Snapshot() ([]byte, error) {
+	return []byte(s.state), nil
+}
+
+func (s *snapshotStrategy) Restore(snapshot []byte) error {
+	s.state = string(snapshot)
+	return nil
+}
+
+func TestTrader_checkpoint(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "checkpoint.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+
+	var s = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(2), state: "first"}
+	var tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(s), WithCheckpoints(0))
+	assert.True(t, !tr.restored)
+	assert.NoError(t.Fatalf, tr.Checkpoint())
+	s.state = "second"
+	assert.NoError(t.Fatalf, tr.Checkpoint())
+
+	var snapshots int64
+	assert.NoError(t.Fatalf, db.Model(&StrategySnapshot{}).Count(&snapshots).Error)
+	assert.EqualInt(t, 1, int(snapshots))
+
+	// The restarted trader restores the state and doesn't warm up the strategy again
+	var restarted = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(2)}
+	tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(restarted), WithCheckpoints(0))
+	assert.True(t, tr.restored)
+	assert.EqualStrings(t, "second", restarted.state)
+
+	feedMinuteTicks(tr, time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), 180)
+	assert.EqualInt(t, 0, restarted.warmUpCandles[time.Hour])
+	assert.EqualInt(t, 3, len(restarted.candles[time.Hour]))
+
+	// Strategies without snapshots are warmed up as usual
+	tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(newTimeframeStrategy(2)), WithCheckpoints(0))
+	assert.True(t, !tr.checkpoints && !tr.restored)
+}
+
+func TestTrader_checkpointCatchUp(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "checkpoint.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var s = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(2), state: "first"}
+	var tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(s), WithCheckpoints(0))
+	feedMinuteTicks(tr, from, 119)
+	assert.NoError(t.Fatalf, tr.Checkpoint())
+
+	// Restarted at 05:00, the strategy saw ticks until 01:59 and misses the candles of 01:00 until 04:00
+	var restarted = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(2)}
+	tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(restarted), WithCheckpoints(0))
+	assert.True(t.Fatalf, tr.restored)
+	tr.warmUpProviders = append(tr.warmUpProviders, candleProvider{from: from})
+	tr.warmUp(from.Add(time.Hour * 5))
+	assert.EqualInt(t, 4, restarted.warmUpCandles[time.Hour])
+	assert.EqualInt(t, 5, len(tr.history(tr.Instrument, time.Hour)))
+
+	// The restored candle of 01:00 ended while the trader was down and isn't closed again
+	feedMinuteTicks(tr, from.Add(time.Hour*5), 0)
+	assert.EqualInt(t, 5, len(tr.history(tr.Instrument, time.Hour)))
+}
+
+func TestTrader_checkpointCandles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "checkpoint.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var s = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(0)}
+	var tr = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(s), WithCheckpoints(0))
+	feedMinuteTicks(tr, from, 89)
+	assert.NoError(t.Fatalf, tr.Checkpoint())
+
+	// The restarted trader continues the open candles and has the same history
+	var restarted = &snapshotStrategy{timeframeStrategy: newTimeframeStrategy(0)}
+	var restartedTrader = New(context.Background(), "EURUSD", "", db, WithBroker(noopBroker{}), WithStrategy(restarted), WithCheckpoints(0))
+	assert.EqualInt(t, 17, len(restartedTrader.history("EURUSD", time.Minute*5)))
+	assert.EqualInt(t, 1, len(restartedTrader.history("EURUSD", time.Hour)))
+
+	for _, trader := range []*Trader{tr, restartedTrader} {
+		for i := 90; i <= 180; i++ {
+			price := decimal.NewFromFloat(1.0 + float64(i)/1000)
+			trader.processTick("EURUSD", tick.New("EURUSD", from.Add(time.Minute*time.Duration(i)), price, price))
+		}
+	}
+	for _, timeframe := range []time.Duration{time.Minute * 5, time.Hour} {
+		var want, got = tr.history("EURUSD", timeframe), restartedTrader.history("EURUSD", timeframe)
+		assert.EqualInt(t.Fatalf, len(want), len(got))
+		for i := range want {
+			assert.EqualStrings(t, want[i].Close.String(), got[i].Close.String())
+			assert.EqualStrings(t, want[i].Open.String(), got[i].Open.String())
+			assert.EqualTime(t, want[i].Start, got[i].Start)
+			assert.EqualTime(t, want[i].End, got[i].End)
+		}
+	}
+	assert.EqualInt(t, len(s.candles[time.Hour]), len(restarted.candles[time.Hour])+1)
+	assert.EqualInt(t, 3, restarted.candles[time.Hour][1])
+	assert.EqualInt(t, 0, restarted.warmUpCandles[time.Hour])
+}
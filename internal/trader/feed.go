@@ -0,0 +1,300 @@
+package trader
+
+import (
+	"fmt"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"sort"
+	"strings"
+	"time"
+)
+
+// feedCheckInterval is the interval between checks of the price feeds
+const feedCheckInterval = time.Second * 5
+
+// FeedSession sets another stale threshold between From and To, given as time of day in the
+// trading day's location, e.g. a longer one for the quiet Asian session. From after To spans midnight.
+type FeedSession struct {
+	From       time.Duration
+	To         time.Duration
+	StaleAfter time.Duration
+}
+
+// ParseFeedSession parses a session like "22:00-07:00=10m"
+func ParseFeedSession(s string) (FeedSession, error) {
+	window, staleAfter, found := strings.Cut(s, "=")
+	if !found {
+		return FeedSession{}, fmt.Errorf("invalid feed session %q", s)
+	}
+	start, end, found := strings.Cut(window, "-")
+	if !found {
+		return FeedSession{}, fmt.Errorf("invalid feed session %q", s)
+	}
+
+	var session FeedSession
+	var err error
+	if session.From, err = calendar.ParseClockTime(start); err != nil {
+		return FeedSession{}, err
+	}
+	if session.To, err = calendar.ParseClockTime(end); err != nil {
+		return FeedSession{}, err
+	}
+	if session.StaleAfter, err = time.ParseDuration(strings.TrimSpace(staleAfter)); err != nil {
+		return FeedSession{}, err
+	}
+	return session, nil
+}
+
+// FeedStatus is the health of the price feed of an instrument
+type FeedStatus struct {
+	Instrument string
+	Healthy    bool
+	LastTick   time.Time
+	StaleSince time.Time // zero while healthy
+	Outages    int
+	Reconnects int
+	Backfilled int // candles loaded after the last outage
+}
+
+type feedSupervisor struct {
+	staleAfter    time.Duration
+	sessions      []FeedSession
+	started       time.Time // ticks aren't expected before
+	lastReconnect time.Time
+	status        map[string]*FeedStatus
+}
+
+// WithFeedSupervision treats the price feed of an instrument as stale when no tick arrived for
+// staleAfter while the market is open. The broker's feed is reconnected if it implements
+// broker.Reconnector, and the candles missed during the outage are loaded from the warm-up providers
+// once ticks arrive again. Only for live trading.
+func WithFeedSupervision(staleAfter time.Duration, sessions ...FeedSession) Option {
+	return func(trader *Trader) {
+		trader.feed = &feedSupervisor{
+			staleAfter: staleAfter,
+			sessions:   sessions,
+			status:     map[string]*FeedStatus{},
+		}
+	}
+}
+
+// FeedHealth returns the health of the price feed of each instrument, nil without WithFeedSupervision()
+func (tr *Trader) FeedHealth() []FeedStatus {
+	tr.Lock()
+	defer tr.Unlock()
+
+	if tr.feed == nil {
+		return nil
+	}
+	var statuses []FeedStatus
+	for _, instrument := range tr.supervisedInstruments() {
+		statuses = append(statuses, *tr.feed.statusOf(instrument))
+	}
+	return statuses
+}
+
+func (f *feedSupervisor) statusOf(instrument string) *FeedStatus {
+	status, exists := f.status[instrument]
+	if !exists {
+		status = &FeedStatus{Instrument: instrument, Healthy: true}
+		f.status[instrument] = status
+	}
+	return status
+}
+
+// threshold returns the stale threshold of the session at t
+func (f *feedSupervisor) threshold(t time.Time) time.Duration {
+	var timeOfDay = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	for _, session := range f.sessions {
+		var inSession = timeOfDay >= session.From && timeOfDay < session.To
+		if session.From > session.To {
+			inSession = timeOfDay >= session.From || timeOfDay < session.To
+		}
+		if inSession {
+			return session.StaleAfter
+		}
+	}
+	return f.staleAfter
+}
+
+// supervisedInstruments returns the instruments whose ticks are expected, sorted by name
+func (tr *Trader) supervisedInstruments() []string {
+	if len(tr.instruments) == 0 {
+		return []string{tr.Instrument}
+	}
+	var instruments []string
+	for instrument := range tr.instruments {
+		instruments = append(instruments, instrument)
+	}
+	sort.Strings(instruments)
+	return instruments
+}
+
+// superviseFeed checks the price feeds until the trader stops
+func (tr *Trader) superviseFeed() {
+	ticker := time.NewTicker(feedCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tr.ctx.Done():
+			return
+		case now := <-ticker.C:
+			tr.Lock()
+			if !tr.running {
+				tr.Unlock()
+				return
+			}
+			tr.checkFeed(now)
+			tr.Unlock()
+		}
+	}
+}
+
+// checkFeed marks the feeds without ticks for longer than the threshold of the session as stale and
+// reconnects the broker's feed, again after each threshold while a feed stays stale. Time while the
+// market is closed doesn't count.
+func (tr *Trader) checkFeed(now time.Time) {
+	now = now.In(tr.tradingDay.Location)
+	if tr.feed.started.IsZero() {
+		tr.feed.started = now
+	}
+	if tr.calendar != nil && !tr.calendar.IsOpen(now) {
+		return
+	}
+	var threshold = tr.feed.threshold(now)
+
+	var stale []*FeedStatus
+	for _, instrument := range tr.supervisedInstruments() {
+		var status = tr.feed.statusOf(instrument)
+		var since = tr.feed.started
+		if status.LastTick.After(since) {
+			since = status.LastTick
+		}
+		if tr.calendar != nil {
+			if start, _, ok := tr.calendar.Session(now); ok && start.After(since) {
+				since = start
+			}
+		}
+		if now.Sub(since) < threshold {
+			continue
+		}
+
+		if status.Healthy {
+			status.Healthy = false
+			status.StaleSince = now
+			status.Outages++
+			tr.clog.Warnf("Price feed of %s is stale, no tick since %s", instrument, since)
+			tr.publishFeedHealth(instrument, now, *status)
+		}
+		stale = append(stale, status)
+	}
+
+	if len(stale) == 0 || now.Sub(tr.feed.lastReconnect) < threshold {
+		return
+	}
+	tr.feed.lastReconnect = now
+	reconnector, ok := tr.broker.(broker.Reconnector)
+	if !ok {
+		tr.clog.Warn("Broker cannot reconnect its price feed")
+		return
+	}
+	for _, status := range stale {
+		status.Reconnects++
+	}
+	reconnector.Reconnect()
+}
+
+// feedTick records the tick of the instrument. The first tick after an outage backfills the missed
+// candles before it's processed.
+func (tr *Trader) feedTick(instrument string, currentTick tick.Tick) {
+	var status = tr.feed.statusOf(instrument)
+	if !status.Healthy {
+		status.Backfilled = tr.backfill(instrument, currentTick.Datetime)
+		status.Healthy = true
+		status.StaleSince = time.Time{}
+		tr.clog.Infof("Price feed of %s recovered after %s, %d candles backfilled", instrument,
+			currentTick.Datetime.Sub(status.LastTick), status.Backfilled)
+		tr.publishFeedHealth(instrument, currentTick.Datetime, *status)
+	}
+	status.LastTick = currentTick.Datetime
+}
+
+// backfill loads the candles which ended between the last closed candle and now from the warm-up
+// providers. They are added to the history and sent to the strategy as warm-up candles, the
+// strategy doesn't trade on prices of the past. Returns the number of loaded candles.
+func (tr *Trader) backfill(instrument string, now time.Time) int {
+	if len(tr.warmUpProviders) == 0 {
+		tr.clog.Warnf("No warm-up providers, candles of %s missed during the outage can't be backfilled", instrument)
+		return 0
+	}
+
+	var lastTick = tr.lastReceivedTick[instrument]
+	var candles []*ohlc.OHLC
+	for i, aggregator := range tr.aggregators[instrument] {
+		var duration = aggregator.Duration()
+		if tr.isBar(duration) {
+			continue
+		}
+		var from time.Time
+		if history := tr.history(instrument, duration); len(history) > 0 {
+			from = history[len(history)-1].End
+		}
+		var limit = tr.historySize(duration)
+		if !from.IsZero() && int(now.Sub(from)/duration)+1 < limit {
+			limit = int(now.Sub(from)/duration) + 1
+		}
+
+		loaded, report := warmup.Load(tr.warmUpProviders, instrument, duration, now, tr.tradingDay, limit)
+		for _, err := range report.Errors {
+			tr.clog.WithError(err).Warn("Backfill provider failed")
+		}
+		var lastEnd time.Time
+		for j := range loaded {
+			if loaded[j].Start.Before(from) {
+				continue
+			}
+			candles = append(candles, &loaded[j])
+			lastEnd = loaded[j].End
+		}
+		if lastTick != nil && lastEnd.After(lastTick.Datetime) {
+			// The open candle only has the ticks before the outage
+			tr.aggregators[instrument][i] = tr.newBuilder(instrument, duration)
+		}
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		if candles[i].End.Equal(candles[j].End) {
+			return candles[i].Duration > candles[j].Duration
+		}
+		return candles[i].End.Before(candles[j].End)
+	})
+	multi, isMulti := tr.strategy.(strategy.MultiTimeframe)
+	for _, candle := range candles {
+		tr.closeCandle(instrument, candle)
+		tr.events.Publish(event.Candle{Header: tr.eventHeader(instrument, candle.End), Candle: *candle})
+		if !tr.isTimeframe(candle.Duration) {
+			continue
+		}
+		if isMulti {
+			multi.OnWarmUpTimeframeCandle(candle.Duration, candle)
+		} else {
+			tr.strategy.OnWarmUpCandle(candle)
+		}
+	}
+	return len(candles)
+}
+
+func (tr *Trader) publishFeedHealth(instrument string, now time.Time, status FeedStatus) {
+	if handler, ok := tr.strategy.(strategy.FeedHealthHandler); ok {
+		handler.OnFeedHealth(instrument, status.Healthy, status.LastTick)
+	}
+	tr.events.Publish(event.FeedHealth{Header: tr.eventHeader(instrument, now), Healthy: status.Healthy,
+		LastTick: status.LastTick, Backfilled: status.Backfilled})
+}
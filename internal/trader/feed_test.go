@@ -0,0 +1,124 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/pkg/tick"
+	"testing"
+	"time"
+)
+
+type reconnectBroker struct {
+	noopBroker
+	reconnects *int
+}
+
+func (b reconnectBroker) Reconnect() {
+	*b.reconnects++
+}
+
+// feedHealthStrategy records the reported health of the feed
+type feedHealthStrategy struct {
+	*timeframeStrategy
+	health []bool
+}
+
+func (s *feedHealthStrategy) OnFeedHealth(_ string, healthy bool, _ time.Time) {
+	s.health = append(s.health, healthy)
+}
+
+func TestTrader_checkFeed(t *testing.T) {
+	var reconnects int
+	var s = &feedHealthStrategy{timeframeStrategy: newTimeframeStrategy(0)}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(reconnectBroker{reconnects: &reconnects}),
+		WithStrategy(s), WithFeedSupervision(time.Minute))
+	var events []event.FeedHealth
+	event.Subscribe(tr.Events(), "test", func(e event.FeedHealth) {
+		events = append(events, e)
+	})
+
+	var now = time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	var price = decimal.NewFromFloat(1.1)
+	tr.checkFeed(now)
+	tr.processTick(tr.Instrument, tick.New(tr.Instrument, now.Add(time.Second*10), price, price))
+
+	tr.checkFeed(now.Add(time.Minute))
+	assert.True(t, tr.FeedHealth()[0].Healthy)
+
+	tr.checkFeed(now.Add(time.Second * 75))
+	tr.checkFeed(now.Add(time.Second * 90))
+	assert.False(t, tr.FeedHealth()[0].Healthy)
+	assert.EqualInt(t, 1, reconnects)
+
+	// Reconnected again after the threshold
+	tr.checkFeed(now.Add(time.Second * 135))
+	assert.EqualInt(t, 2, reconnects)
+	assert.EqualInt(t, 2, tr.FeedHealth()[0].Reconnects)
+
+	tr.processTick(tr.Instrument, tick.New(tr.Instrument, now.Add(time.Minute*3), price, price))
+	var status = tr.FeedHealth()[0]
+	assert.True(t, status.Healthy)
+	assert.EqualInt(t, 1, status.Outages)
+
+	assert.EqualInt(t.Fatalf, 2, len(s.health))
+	assert.True(t, !s.health[0] && s.health[1])
+	assert.EqualInt(t.Fatalf, 2, len(events))
+	assert.EqualTime(t, now.Add(time.Second*10), events[1].LastTick)
+}
+
+func TestFeedSupervisor_threshold(t *testing.T) {
+	var f = &feedSupervisor{staleAfter: time.Minute, sessions: []FeedSession{
+		{From: time.Hour * 22, To: time.Hour * 7, StaleAfter: time.Minute * 10},
+		{From: time.Hour * 12, To: time.Hour * 13, StaleAfter: time.Minute * 5},
+	}}
+	var day = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+
+	assert.True(t, f.threshold(day.Add(time.Hour*23)) == time.Minute*10)
+	assert.True(t, f.threshold(day.Add(time.Hour*3)) == time.Minute*10)
+	assert.True(t, f.threshold(day.Add(time.Hour*7)) == time.Minute)
+	assert.True(t, f.threshold(day.Add(time.Hour*12+time.Minute*30)) == time.Minute*5)
+	assert.True(t, f.threshold(day.Add(time.Hour*13)) == time.Minute)
+}
+
+func TestTrader_backfill(t *testing.T) {
+	var s = newTimeframeStrategy(0)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s),
+		WithFeedSupervision(time.Minute))
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	tr.warmUpProviders = append(tr.warmUpProviders, candleProvider{from: from})
+	tr.checkFeed(from)
+	feedMinuteTicks(tr, from, 20)
+	assert.EqualInt(t.Fatalf, 4, len(s.candles[time.Minute*5]))
+
+	tr.checkFeed(from.Add(time.Minute * 22))
+	assert.False(t, tr.FeedHealth()[0].Healthy)
+
+	// The feed recovers at 01:07, the candles from 00:20 until 01:05 are loaded
+	var price = decimal.NewFromFloat(1.1)
+	tr.processTick(tr.Instrument, tick.New(tr.Instrument, from.Add(time.Minute*67), price, price))
+	assert.EqualInt(t, 10, tr.FeedHealth()[0].Backfilled)
+	assert.EqualInt(t, 9, s.warmUpCandles[time.Minute*5])
+	assert.EqualInt(t, 1, s.warmUpCandles[time.Hour])
+	assert.EqualInt(t, 13, len(tr.history(tr.Instrument, time.Minute*5)))
+	assert.EqualInt(t, 4, len(s.candles[time.Minute*5]))
+
+	// The next candle is built from ticks after the outage and passed with the backfilled history
+	tr.processTick(tr.Instrument, tick.New(tr.Instrument, from.Add(time.Minute*70), price, price))
+	assert.EqualInt(t.Fatalf, 5, len(s.candles[time.Minute*5]))
+	assert.EqualInt(t, 14, s.candles[time.Minute*5][4])
+	var last = tr.history(tr.Instrument, time.Minute*5)[13]
+	assert.EqualTime(t, from.Add(time.Minute*65), last.Start)
+	assert.True(t, last.Close.Equal(price))
+}
+
+func TestParseFeedSession(t *testing.T) {
+	session, err := ParseFeedSession("22:00-07:00=10m")
+	assert.NoError(t.Fatalf, err)
+	assert.True(t, session.From == time.Hour*22 && session.To == time.Hour*7 && session.StaleAfter == time.Minute*10)
+
+	_, err = ParseFeedSession("22:00-07:00")
+	assert.ErrorIncludesMessage(t, "invalid feed session", err)
+}
@@ -0,0 +1,214 @@
+package trader
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/tick"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+const RuleHalted = "halted"
+
+// HaltConditions stop the trader from opening new positions. Zero values disable a condition.
+type HaltConditions struct {
+	MaxIntradayLossInPips float64 // loss since the first tick of the trading day
+	MaxDrawdownInPips     float64 // loss from the highest equity
+	MaxConsecutiveLosses  int
+	FlattenPositions      bool // close all open positions and cancel open orders when halted
+}
+
+// TradingHalt is a halt of a trader. It's active until it's reset and survives restarts.
+type TradingHalt struct {
+	gorm.Model
+	TraderKey string `gorm:"index"`
+	Reason    string
+	Time      time.Time  // time of the tick which triggered the halt
+	ResetAt   *time.Time // nil while the halt is active
+}
+
+// equityTracker follows the performance of closed and open positions in pips
+type equityTracker struct {
+	equity         float64
+	peak           float64
+	maxDrawdown    float64
+	day            string
+	dayStartEquity float64
+	rebase         bool // the next equity is the new peak and start of the day
+}
+
+// update sets the current equity on the trading day, e.g. "2021-01-05", and returns the drawdown from peak
+// and the loss of the day
+func (e *equityTracker) update(equity float64, day string) (drawdown, intradayLoss float64) {
+	if day != e.day || e.rebase {
+		e.day = day
+		e.dayStartEquity = equity
+	}
+	if e.rebase {
+		e.peak = equity
+		e.rebase = false
+	}
+	if equity > e.peak {
+		e.peak = equity
+	}
+	e.equity = equity
+
+	drawdown = e.peak - equity
+	if drawdown > e.maxDrawdown {
+		e.maxDrawdown = drawdown
+	}
+	return drawdown, e.dayStartEquity - equity
+}
+
+// key identifies the trader's halts and checkpoints. Backtests are identified by their run ID so they never
+// inherit halts of other runs.
+func (tr *Trader) key() string {
+	if tr.runID != "" {
+		return tr.ID()
+	}
+	return fmt.Sprintf("%s/%s/%s", tr.strategy.Name(), tr.Instrument, tr.owner)
+}
+
+// loadHalt restores an active halt from the database
+func (tr *Trader) loadHalt() error {
+	var halts []TradingHalt
+	if err := tr.gormDB.Where("trader_key = ? AND reset_at IS NULL", tr.key()).Find(&halts).Error; err != nil {
+		return err
+	}
+	if len(halts) > 0 {
+		tr.halt = &halts[0]
+		tr.clog.Warnf("Trading is halted since %s: %s", tr.halt.Time, tr.halt.Reason)
+		return nil
+	}
+
+	var lastReset TradingHalt
+	err := tr.gormDB.Where("trader_key = ? AND reset_at IS NOT NULL", tr.key()).Order("reset_at DESC").Limit(1).Find(&lastReset).Error
+	if err != nil {
+		return err
+	}
+	if lastReset.ResetAt != nil {
+		tr.haltResetAt = *lastReset.ResetAt
+		tr.equityTracker.rebase = true
+	}
+	return nil
+}
+
+// Halted returns the reason of the active halt or an empty string
+func (tr *Trader) Halted() string {
+	tr.Lock()
+	defer tr.Unlock()
+
+	if tr.halt == nil {
+		return ""
+	}
+	return tr.halt.Reason
+}
+
+// ResetHalt allows the trader to open positions again. Drawdown and intraday loss are measured from the
+// equity at the reset and only losses closed after the reset count.
+func (tr *Trader) ResetHalt() error {
+	tr.Lock()
+	defer tr.Unlock()
+
+	tr.halt = nil
+	tr.haltResetAt = time.Now()
+	if lastTick := tr.lastReceivedTick[tr.Instrument]; lastTick != nil {
+		tr.haltResetAt = lastTick.Datetime
+	}
+	tr.equityTracker.rebase = true
+	if tr.gormDB == nil {
+		return nil
+	}
+	return ResetHalt(tr.gormDB, tr.key(), tr.haltResetAt)
+}
+
+// ResetHalt resets the active halt of the trader with the given key, e.g. "rsi/EURUSD/", at the given time
+func ResetHalt(db *gorm.DB, traderKey string, at time.Time) error {
+	return db.Model(&TradingHalt{}).Where("trader_key = ? AND reset_at IS NULL", traderKey).
+		Update("reset_at", at).Error
+}
+
+// updateEquity tracks the equity of closed and open positions and halts trading if a halt
+// condition is met
+func (tr *Trader) updateEquity(currentTick tick.Tick, openPositions, closedPositions []broker.Position) {
+	var equity float64
+	for _, position := range closedPositions {
+		equity += position.PerformanceAbsolute(decimal.Zero, decimal.Zero)
+	}
+	for _, position := range openPositions {
+		var positionTick = tr.lastReceivedTick[position.Instrument]
+		if position.Instrument == currentTick.Instrument {
+			positionTick = &currentTick
+		}
+		if positionTick == nil {
+			continue // valued at zero until its instrument has a price
+		}
+		equity += position.PerformanceAbsolute(positionTick.Bid, positionTick.Ask)
+	}
+	equity *= pipsFactor
+
+	drawdown, intradayLoss := tr.equityTracker.update(equity, tr.tradingDay.Key(currentTick.Datetime))
+	tr.MaxAggregatedDrawdownInPips = decimal.NewFromFloat(tr.equityTracker.maxDrawdown)
+
+	if tr.halt != nil {
+		return
+	}
+
+	var reason string
+	var conditions = tr.haltConditions
+	var losses = consecutiveLosses(closedPositions, tr.haltResetAt)
+	switch {
+	case conditions.MaxIntradayLossInPips > 0 && intradayLoss >= conditions.MaxIntradayLossInPips:
+		reason = fmt.Sprintf("intraday loss of %.2f pips reached %.2f pips", intradayLoss, conditions.MaxIntradayLossInPips)
+	case conditions.MaxDrawdownInPips > 0 && drawdown >= conditions.MaxDrawdownInPips:
+		reason = fmt.Sprintf("drawdown of %.2f pips reached %.2f pips", drawdown, conditions.MaxDrawdownInPips)
+	case conditions.MaxConsecutiveLosses > 0 && losses >= conditions.MaxConsecutiveLosses:
+		reason = fmt.Sprintf("%d consecutive losses", losses)
+	default:
+		return
+	}
+	tr.haltTrading(reason, currentTick.Datetime, openPositions)
+}
+
+// haltTrading stops opening positions and optionally flattens all positions
+func (tr *Trader) haltTrading(reason string, now time.Time, openPositions []broker.Position) {
+	tr.halt = &TradingHalt{TraderKey: tr.key(), Reason: reason, Time: now}
+	tr.clog.Errorf("Halting trading: %s", reason)
+
+	if tr.gormDB != nil {
+		if err := tr.gormDB.Create(tr.halt).Error; err != nil {
+			tr.clog.WithError(err).Error("Cannot persist trading halt")
+		}
+	}
+
+	if !tr.haltConditions.FlattenPositions {
+		return
+	}
+	openOrders, err := tr.getOpenOrders()
+	if err != nil {
+		tr.reportError(tr.Instrument, now, err, "Cannot get open orders")
+	}
+	tr.processClosableOrders(now, openOrders)
+	tr.processClosablePositions(now, openPositions)
+}
+
+// consecutiveLosses returns the number of losses since the last winning position, counting only
+// positions closed after since
+func consecutiveLosses(closedPositions []broker.Position, since time.Time) int {
+	var positions = make([]broker.Position, len(closedPositions))
+	copy(positions, closedPositions)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].SellTime.Before(positions[j].SellTime)
+	})
+
+	var losses int
+	for i := len(positions) - 1; i >= 0; i-- {
+		if !positions[i].SellTime.After(since) || positions[i].PerformanceAbsolute(decimal.Zero, decimal.Zero) >= 0 {
+			break
+		}
+		losses++
+	}
+	return losses
+}
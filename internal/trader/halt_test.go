@@ -0,0 +1,159 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newClosedPosition(sellTime time.Time, buyPrice, sellPrice float64) broker.Position {
+	return broker.Position{
+		Instrument:   "EURUSD",
+		BuyDirection: broker.BuyDirectionLong,
+		BuyPrice:     decimal.NewFromFloat(buyPrice),
+		SellPrice:    decimal.NewFromFloat(sellPrice),
+		SellTime:     sellTime,
+		Size:         1,
+	}
+}
+
+func Test_equityTracker(t *testing.T) {
+	var e equityTracker
+	tradingDay, err := ohlc.NewTradingDay("America/New_York", "17:00")
+	assert.NoError(t.Fatalf, err)
+	var day = time.Date(2021, 1, 4, 9, 0, 0, 0, tradingDay.Location)
+
+	drawdown, intradayLoss := e.update(10, tradingDay.Key(day))
+	assert.EqualFloat64(t, 0, drawdown)
+	assert.EqualFloat64(t, 0, intradayLoss)
+
+	drawdown, intradayLoss = e.update(30, tradingDay.Key(day.Add(time.Hour)))
+	assert.EqualFloat64(t, 0, drawdown)
+	assert.EqualFloat64(t, -20, intradayLoss)
+
+	// 17:00 begins the next trading day on the same date
+	drawdown, intradayLoss = e.update(5, tradingDay.Key(day.Add(time.Hour*8)))
+	assert.EqualFloat64(t, 25, drawdown)
+	assert.EqualFloat64(t, 0, intradayLoss)
+
+	drawdown, intradayLoss = e.update(15, tradingDay.Key(day.AddDate(0, 0, 1)))
+	assert.EqualFloat64(t, 15, drawdown)
+	assert.EqualFloat64(t, -10, intradayLoss)
+	assert.EqualFloat64(t, 25, e.maxDrawdown)
+}
+
+func Test_consecutiveLosses(t *testing.T) {
+	var now = time.Now()
+	var positions = []broker.Position{
+		newClosedPosition(now.Add(time.Minute*3), 1.2, 1.1),
+		newClosedPosition(now, 1.1, 1.2),
+		newClosedPosition(now.Add(time.Minute*2), 1.2, 1.1),
+		newClosedPosition(now.Add(time.Minute), 1.1, 1.2),
+	}
+	assert.EqualInt(t, 2, consecutiveLosses(positions, time.Time{}))
+	assert.EqualInt(t, 0, consecutiveLosses(positions[1:2], time.Time{}))
+	assert.EqualInt(t, 0, consecutiveLosses(nil, time.Time{}))
+	assert.EqualInt(t, 1, consecutiveLosses(positions, now.Add(time.Minute*2)))
+}
+
+func TestTrader_halt(t *testing.T) {
+	var b = &orderBroker{}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b), WithStrategy(newTimeframeStrategy(0)),
+		WithHaltConditions(HaltConditions{MaxDrawdownInPips: 50, FlattenPositions: true}))
+
+	var now = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	var openPositions = []broker.Position{{Instrument: "EURUSD", BuyDirection: broker.BuyDirectionLong,
+		BuyPrice: decimal.NewFromFloat(1.2000), Size: 1}}
+
+	// Open position gains 100 pips, then falls back to +60 pips
+	tr.updateEquity(newRiskTick(now, 1.2100), openPositions, nil)
+	tr.updateEquity(newRiskTick(now.Add(time.Hour), 1.2060), openPositions, nil)
+	assert.EqualStrings(t, "", tr.Halted())
+	assert.EqualFloat64(t, 40, tr.MaxAggregatedDrawdownInPips.Round(6).InexactFloat64())
+
+	tr.updateEquity(newRiskTick(now.Add(time.Hour*2), 1.2040), openPositions, nil)
+	assert.EqualStrings(t, "drawdown of 60.00 pips reached 50.00 pips", tr.Halted())
+	assert.EqualInt(t, 1, len(b.sold))
+
+	tr.processOrders("EURUSD", newRiskTick(now.Add(time.Hour*3), 1.2040), nil, nil, []broker.Order{{Instrument: "EURUSD", Size: 1}})
+	assert.EqualInt(t, 0, len(b.orders))
+	assert.EqualInt(t, 1, tr.rejectedOrders[RuleHalted])
+
+	tr.lastReceivedTick["EURUSD"] = &tick.Tick{Datetime: now.Add(time.Hour * 3)}
+	assert.NoError(t, tr.ResetHalt())
+	tr.processOrders("EURUSD", newRiskTick(now.Add(time.Hour*4), 1.2040), nil, nil, []broker.Order{{Instrument: "EURUSD", Size: 1}})
+	assert.EqualInt(t, 1, len(b.orders))
+
+	// The drawdown is measured from the equity at the reset, not from the peak before the halt
+	tr.updateEquity(newRiskTick(now.Add(time.Hour*4), 1.2040), openPositions, nil)
+	assert.EqualStrings(t, "", tr.Halted())
+	tr.updateEquity(newRiskTick(now.Add(time.Hour*5), 1.1990), openPositions, nil)
+	assert.EqualStrings(t, "drawdown of 50.00 pips reached 50.00 pips", tr.Halted())
+	assert.EqualFloat64(t, 60, tr.MaxAggregatedDrawdownInPips.Round(6).InexactFloat64())
+}
+
+func TestTrader_haltConsecutiveLosses(t *testing.T) {
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(&orderBroker{}), WithStrategy(newTimeframeStrategy(0)),
+		WithHaltConditions(HaltConditions{MaxConsecutiveLosses: 2}))
+
+	var now = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	var closedPositions = []broker.Position{
+		newClosedPosition(now, 1.2, 1.1),
+		newClosedPosition(now.Add(time.Minute), 1.2, 1.1),
+	}
+	tr.updateEquity(newRiskTick(now.Add(time.Minute), 1.1), nil, closedPositions)
+	assert.EqualStrings(t, "2 consecutive losses", tr.Halted())
+
+	// Losses before the reset don't count again
+	tr.lastReceivedTick["EURUSD"] = &tick.Tick{Datetime: now.Add(time.Minute * 2)}
+	assert.NoError(t, tr.ResetHalt())
+	closedPositions = append(closedPositions, newClosedPosition(now.Add(time.Minute*3), 1.2, 1.1))
+	tr.updateEquity(newRiskTick(now.Add(time.Minute*3), 1.1), nil, closedPositions)
+	assert.EqualStrings(t, "", tr.Halted())
+	closedPositions = append(closedPositions, newClosedPosition(now.Add(time.Minute*4), 1.2, 1.1))
+	tr.updateEquity(newRiskTick(now.Add(time.Minute*4), 1.1), nil, closedPositions)
+	assert.EqualStrings(t, "2 consecutive losses", tr.Halted())
+}
+
+func TestTrader_loadHalt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "halt.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+	var now = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	var tr = New(context.Background(), "EURUSD", "", db, WithBroker(&orderBroker{}), WithStrategy(newTimeframeStrategy(0)))
+	tr.haltTrading("test", now, nil)
+
+	// The halt survives a restart, its reset as well
+	tr = New(context.Background(), "EURUSD", "", db, WithBroker(&orderBroker{}), WithStrategy(newTimeframeStrategy(0)))
+	assert.EqualStrings(t, "test", tr.Halted())
+	tr.lastReceivedTick["EURUSD"] = &tick.Tick{Datetime: now.Add(time.Hour)}
+	assert.NoError(t.Fatalf, tr.ResetHalt())
+
+	tr = New(context.Background(), "EURUSD", "", db, WithBroker(&orderBroker{}), WithStrategy(newTimeframeStrategy(0)))
+	assert.EqualStrings(t, "", tr.Halted())
+	assert.EqualTime(t, now.Add(time.Hour), tr.haltResetAt.UTC())
+	assert.True(t, tr.equityTracker.rebase)
+}
+
+func TestTrader_equityOfSeveralInstruments(t *testing.T) {
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(&orderBroker{}), WithStrategy(newTimeframeStrategy(0)))
+	var now = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	var openPositions = []broker.Position{
+		{Instrument: "EURUSD", BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(1.2000), Size: 1},
+		{Instrument: "GBPUSD", BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(1.3000), Size: 1},
+	}
+
+	// GBPUSD is valued with its own price, not with the one of EURUSD
+	var gbpusd = tick.New("GBPUSD", now, decimal.NewFromFloat(1.3020), decimal.NewFromFloat(1.3020))
+	tr.lastReceivedTick["GBPUSD"] = &gbpusd
+	tr.updateEquity(newRiskTick(now, 1.2010), openPositions, nil)
+	assert.EqualFloat64(t, 30, math.Round(tr.equityTracker.equity*1e6)/1e6)
+}
@@ -1,10 +1,8 @@
 package trader
 
 import (
-	"fmt"
 	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
-	"github.com/sklinkert/at/pkg/helper"
 	"sort"
 )
 
@@ -49,23 +47,31 @@ func distanceInPercentage(price1, price2 decimal.Decimal) decimal.Decimal {
 //	return distanceInPercentage(decimal.NewFromFloat(sma), price), nil
 //}
 
-func (tr *Trader) printPositionPerformanceByNotes() {
-	closedPositions, _ := tr.GetClosedPositions()
-
-	var perfPositionsByNote = map[string]float64{}
-	for _, position := range closedPositions {
-		perfInPips := helper.Cent2Pips(decimal.NewFromFloat(position.PerformanceAbsolute(decimal.Zero, decimal.Zero)))
-		perfInPipsFloat, _ := perfInPips.Float64()
-		key := fmt.Sprintf("%d-%s", position.BuyTime.Year(), position.Reference)
-		perfPositionsByNote[key] += perfInPipsFloat
+// printTagPerformances prints the performance of each tag. Nothing is printed if no position is tagged.
+func printTagPerformances(performances []TagPerformance) {
+	if len(performances) == 1 && performances[0].Tag == "" {
+		return
 	}
-	var sortedKeys []string
-	for key := range perfPositionsByNote {
-		sortedKeys = append(sortedKeys, key)
+	for _, perf := range performances {
+		var tag = perf.Tag
+		if tag == "" {
+			tag = "(untagged)"
+		}
+		log.Infof("%25s: %s %d trades (%d win, %d loss) %.2f%% %.2f pips (AVG %.2f pips)", "Tag", tag,
+			perf.Trades, perf.TradesWin, perf.TradesLoss, perf.TotalPerformanceInPercent,
+			perf.TotalPerformanceInPips, perf.AVGPerformanceInPips)
 	}
-	sort.Strings(sortedKeys)
-	for _, note := range sortedKeys {
-		totalProfit := perfPositionsByNote[note]
-		log.Infof("%25s: %s %.2f pips", "Total profit for positions with note", note, totalProfit)
+}
+
+// printRejectedOrders prints the number of orders rejected by each risk rule
+func printRejectedOrders(rejectedOrders map[string]int) {
+	var rules []string
+	for rule := range rejectedOrders {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	for _, rule := range rules {
+		log.Infof("%25s: %s %d orders", "Rejected", rule, rejectedOrders[rule])
 	}
 }
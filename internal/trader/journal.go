@@ -0,0 +1,131 @@
+package trader
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/event"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	JournalOrder          = "order"           // order accepted by the broker
+	JournalEntryFill      = "entry_fill"      // execution of an order
+	JournalExitFill       = "exit_fill"       // close of a position
+	JournalPositionOpened = "position_opened" // new position of the broker
+	JournalPositionClosed = "position_closed" // position closed by the broker
+)
+
+// journalQueueSize is the number of events the journal buffers before trading waits for the DB
+const journalQueueSize = 1000
+
+// JournalEntry is an order, fill or position change of a trader, persisted as it happens
+type JournalEntry struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	TraderKey      string `gorm:"uniqueIndex:idx_journal_entry"`
+	RunID          string `gorm:"index"`
+	Strategy       string
+	GitRev         string
+	Kind           string `gorm:"uniqueIndex:idx_journal_entry"`
+	Instrument     string
+	Owner          string
+	Tag            string
+	OrderID        string
+	Reference      string `gorm:"uniqueIndex:idx_journal_entry"` // position, ID of orders
+	Direction      broker.BuyDirection
+	Size           float64
+	Price          decimal.Decimal // fill price, limit of orders
+	TargetPrice    decimal.Decimal
+	StopLossPrice  decimal.Decimal
+	CandleStart    time.Time     // candle which triggered the entry
+	CandleDuration time.Duration // zero if no candle was processed, e.g. fills seen on Stop()
+	Time           time.Time     // time of the tick or the fill
+}
+
+// WithJournal persists every order, fill and opened or closed position in the journal_entries
+// table, e.g. for reporting and reconciliation of live trading. The entries are written by a
+// subscriber of the trader's events, so trading doesn't wait for the DB unless its queue is full.
+func WithJournal() Option {
+	return func(trader *Trader) {
+		trader.journal = true
+	}
+}
+
+// Journal returns the journal entries of the trader since the given time, oldest first. Entries of
+// recent events may not be written yet.
+func (tr *Trader) Journal(since time.Time) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	err := tr.gormDB.Where("trader_key = ? AND time >= ?", tr.key(), since).Order("time, id").Find(&entries).Error
+	return entries, err
+}
+
+// subscribeJournal writes the orders, fills and position changes of the trader to the journal.
+// Positions of the broker are seen again after a restart, the unique index keeps them from being
+// journaled twice.
+func (tr *Trader) subscribeJournal() {
+	var template = JournalEntry{
+		TraderKey: tr.key(),
+		RunID:     tr.runID,
+		Strategy:  tr.strategy.Name(),
+		GitRev:    tr.gitRev,
+	}
+	var newEntry = func(kind string, header event.Header) JournalEntry {
+		var entry = template
+		entry.Kind = kind
+		entry.Instrument = header.Instrument
+		entry.Owner = header.Owner
+		entry.CandleStart = header.CandleStart
+		entry.CandleDuration = header.CandleDuration
+		entry.Time = header.Time
+		return entry
+	}
+
+	// One subscriber keeps the entries in the order of the events
+	event.Subscribe(tr.events, "journal", func(e event.Event) {
+		switch e := e.(type) {
+		case event.Order:
+			var entry = newEntry(JournalOrder, e.Header)
+			entry.Tag = e.Order.Tag
+			entry.OrderID = e.Order.ID
+			entry.Reference = e.Order.ID
+			entry.Direction = e.Order.Direction
+			entry.Size = e.Order.Size
+			entry.Price = e.Order.Limit
+			entry.TargetPrice = e.Order.TargetPrice
+			entry.StopLossPrice = e.Order.StopLossPrice
+			tr.writeJournal(entry)
+		case event.Fill:
+			var entry = newEntry(JournalExitFill, e.Header)
+			if e.Entry {
+				entry.Kind = JournalEntryFill
+			}
+			entry.Reference = e.Reference
+			entry.Direction = e.Direction
+			entry.Size = e.Size
+			entry.Price = e.Price
+			tr.writeJournal(entry)
+		case event.PositionOpened:
+			tr.writeJournal(positionEntry(newEntry(JournalPositionOpened, e.Header), e.Position, e.Position.BuyPrice))
+		case event.PositionClosed:
+			tr.writeJournal(positionEntry(newEntry(JournalPositionClosed, e.Header), e.Position, e.Position.SellPrice))
+		}
+	}, event.Async(journalQueueSize))
+}
+
+func positionEntry(entry JournalEntry, position broker.Position, price decimal.Decimal) JournalEntry {
+	entry.Tag = position.Tag
+	entry.Reference = position.Reference
+	entry.Direction = position.BuyDirection
+	entry.Size = position.Size
+	entry.Price = price
+	entry.TargetPrice = position.TargetPrice
+	entry.StopLossPrice = position.StopLossPrice
+	return entry
+}
+
+func (tr *Trader) writeJournal(entry JournalEntry) {
+	if err := tr.gormDB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		tr.clog.WithError(err).Errorf("Cannot persist journal entry %s of %s", entry.Kind, entry.Instrument)
+	}
+}
@@ -0,0 +1,63 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrader_journal(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "journal.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+
+	var now = time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+	var b = &orderBroker{closedPositions: []broker.Position{
+		{Reference: "1", Instrument: "EURUSD", Tag: "breakout", BuyTime: now, SellTime: now.Add(time.Minute),
+			BuyPrice: decimal.NewFromFloat(1.1), SellPrice: decimal.NewFromFloat(1.2)},
+	}}
+	var tr = New(context.Background(), "EURUSD", "abc", db, WithBroker(b), WithStrategy(newTimeframeStrategy(0)),
+		WithJournal())
+
+	tr.candle = ohlc.New("EURUSD", now.Add(-time.Minute*5), time.Minute*5, false)
+	var price = decimal.NewFromFloat(1.1)
+	tr.processOrders("EURUSD", tick.New("EURUSD", now, price, price), nil, nil,
+		[]broker.Order{{Instrument: "EURUSD", Size: 1, Tag: "breakout"}})
+	tr.candle = nil
+	tr.detectClosedPositions(b.closedPositions)
+	tr.Events().Close() // waits for the journal
+
+	entries, err := tr.Journal(now)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 5, len(entries))
+	assert.EqualStrings(t, JournalOrder, entries[0].Kind)
+	assert.EqualStrings(t, "1", entries[0].Reference)
+	assert.EqualStrings(t, "breakout", entries[0].Tag)
+	assert.EqualStrings(t, "timeframes", entries[0].Strategy)
+	assert.EqualStrings(t, "abc", entries[0].GitRev)
+	assert.EqualTime(t, now.Add(-time.Minute*5), entries[0].CandleStart)
+	assert.True(t, entries[0].CandleDuration == time.Minute*5)
+	assert.EqualStrings(t, JournalEntryFill, entries[1].Kind)
+	assert.EqualStrings(t, JournalPositionOpened, entries[2].Kind)
+	assert.EqualStrings(t, JournalExitFill, entries[3].Kind)
+	assert.EqualStrings(t, JournalPositionClosed, entries[4].Kind)
+	assert.EqualStrings(t, "1", entries[4].Reference)
+	assert.True(t, entries[4].Price.Equal(decimal.NewFromFloat(1.2)))
+	assert.True(t, entries[4].CandleDuration == 0)
+
+	// The restarted trader sees the closed position again
+	tr = New(context.Background(), "EURUSD", "abc", db, WithBroker(b), WithStrategy(newTimeframeStrategy(0)),
+		WithJournal())
+	tr.detectClosedPositions(b.closedPositions)
+	tr.Events().Close()
+	entries, err = tr.Journal(now)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t, 5, len(entries))
+}
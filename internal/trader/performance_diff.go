@@ -0,0 +1,129 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// MetricDiff is a metric that differs between two performance records
+type MetricDiff struct {
+	Name    string
+	Stored  string
+	Current string
+}
+
+// ignoredMetrics differ between runs by nature or aren't stored with the record
+var ignoredMetrics = map[string]bool{
+	"ID":                    true,
+	"CreatedAt":             true,
+	"UpdatedAt":             true,
+	"DeletedAt":             true,
+	"BacktestingID":         true, // contains the run ID
+	"RunID":                 true,
+	"ParentRunID":           true,
+	"GitRev":                true, // rerun warns about another git rev
+	"Duration":              true, // wall clock time of the run
+	"ChartHTML":             true,
+	"BacktestingConfigJSON": true, // contains the run ID
+	"ClosedPositions":       true, // not stored
+}
+
+type metric struct {
+	name    string
+	text    string  // equal values have the same text
+	value   float64 // set for numeric metrics, durations are in seconds
+	numeric bool
+}
+
+// recordMetrics returns all metrics of a performance record in field order, including the fields of
+// embedded structs
+func recordMetrics(value reflect.Value) []metric {
+	var metrics []metric
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			metrics = append(metrics, recordMetrics(value.Field(i))...)
+			continue
+		}
+		if ignoredMetrics[field.Name] {
+			continue
+		}
+		metrics = append(metrics, newMetric(field.Name, value.Field(i)))
+	}
+	return metrics
+}
+
+// numericMetrics returns all numeric metrics of a performance record in field order
+func numericMetrics(record PerformanceRecord) []metric {
+	var metrics []metric
+	for _, m := range recordMetrics(reflect.ValueOf(record)) {
+		if m.numeric {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+func newMetric(name string, value reflect.Value) metric {
+	var m = metric{name: name}
+	switch v := value.Interface().(type) {
+	case time.Time:
+		m.text = v.UTC().Format(time.RFC3339Nano)
+		return m
+	case time.Duration:
+		m.text, m.value, m.numeric = v.String(), v.Seconds(), true
+		return m
+	}
+
+	switch value.Kind() {
+	case reflect.Float64:
+		m.value, m.numeric = value.Float(), true
+		m.text = strconv.FormatFloat(m.value, 'f', -1, 64)
+	case reflect.Int, reflect.Int64:
+		m.value, m.numeric = float64(value.Int()), true
+		m.text = strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint:
+		m.value, m.numeric = float64(value.Uint()), true
+		m.text = strconv.FormatUint(value.Uint(), 10)
+	case reflect.String:
+		m.text = value.String()
+	case reflect.Map, reflect.Slice:
+		if value.Len() > 0 { // nil and empty are the same once stored
+			m.text = jsonText(value)
+		}
+	default:
+		m.text = jsonText(value)
+	}
+	return m
+}
+
+func jsonText(value reflect.Value) string {
+	content, err := json.Marshal(value.Interface())
+	if err != nil {
+		return fmt.Sprintf("%v", value.Interface())
+	}
+	return string(content)
+}
+
+// DiffPerformanceRecords compares all metrics of two performance records. IDs, timestamps and other
+// fields which differ between runs by nature are ignored.
+func DiffPerformanceRecords(stored, current PerformanceRecord) []MetricDiff {
+	var diffs []MetricDiff
+	var storedMetrics = recordMetrics(reflect.ValueOf(stored))
+	var currentMetrics = recordMetrics(reflect.ValueOf(current))
+
+	for i := range storedMetrics {
+		if storedMetrics[i].text != currentMetrics[i].text {
+			diffs = append(diffs, MetricDiff{
+				Name:    storedMetrics[i].name,
+				Stored:  storedMetrics[i].text,
+				Current: currentMetrics[i].text,
+			})
+		}
+	}
+
+	return diffs
+}
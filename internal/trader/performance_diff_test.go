@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffPerformanceRecords(t *testing.T) {
+	var stored = PerformanceRecord{
+		Trades:                 10,
+		TotalPerformanceInPips: 12.5,
+		TotalTimeInMarket:      time.Hour,
+		Duration:               "1s",
+		RunID:                  "1",
+		ChartHTML:              "<html>1</html>",
+	}
+	stored.ID = 1
+	stored.CreatedAt = time.Now()
+	var current = stored
+	current.Duration = "2s"
+	current.BacktestingID = "other"
+	current.RunID = "2"
+	current.ChartHTML = "<html>2</html>"
+	current.ID = 2
+	current.CreatedAt = stored.CreatedAt.Add(time.Minute)
+	current.UpdatedAt = current.CreatedAt
+
+	assert.EqualInt(t, 0, len(DiffPerformanceRecords(stored, current)))
+
+	current.Trades = 11
+	current.MaxConsecutiveTradesLoss = 2
+	current.TotalTimeInMarket = time.Hour * 2
+	current.HaltReason = "3 consecutive losses"
+
+	diffs := DiffPerformanceRecords(stored, current)
+	assert.EqualInt(t.Fatalf, 4, len(diffs))
+	assert.EqualStrings(t, "Trades", diffs[0].Name)
+	assert.EqualStrings(t, "10", diffs[0].Stored)
+	assert.EqualStrings(t, "11", diffs[0].Current)
+	assert.EqualStrings(t, "MaxConsecutiveTradesLoss", diffs[1].Name)
+	assert.EqualStrings(t, "TotalTimeInMarket", diffs[2].Name)
+	assert.EqualStrings(t, "2h0m0s", diffs[2].Current)
+	assert.EqualStrings(t, "HaltReason", diffs[3].Name)
+}
+
+// closedPositionsBroker returns the same closed positions for every run
+type closedPositionsBroker struct {
+	noopBroker
+	positions []broker.Position
+}
+
+func (b closedPositionsBroker) GetClosedPositions() ([]broker.Position, error) {
+	return b.positions, nil
+}
+
+func TestDiffPerformanceRecords_rerun(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "performance.db")), &gorm.Config{})
+	assert.NoError(t.Fatalf, err)
+
+	var loc = time.FixedZone("CET", 3600)
+	var from = time.Date(2021, 1, 4, 9, 0, 0, 0, loc)
+	var b = closedPositionsBroker{}
+	for i, sellPrice := range []float64{1.1010, 1.0990, 1.1020} {
+		b.positions = append(b.positions, broker.Position{
+			Instrument:   "EURUSD",
+			BuyDirection: broker.BuyDirectionLong,
+			BuyPrice:     decimal.NewFromFloat(1.1),
+			SellPrice:    decimal.NewFromFloat(sellPrice),
+			BuyTime:      from.Add(time.Hour * time.Duration(i)),
+			SellTime:     from.Add(time.Hour*time.Duration(i) + time.Minute*30),
+			Size:         1,
+			Tag:          "breakout",
+		})
+	}
+
+	newRecord := func(runID string) *PerformanceRecord {
+		tr := New(context.Background(), "EURUSD", "rev", db, WithBroker(b), WithStrategy(newTimeframeStrategy(0)), WithRunID(runID))
+		record, err := tr.GetPerformanceRecord("<html>" + runID + "</html>")
+		assert.NoError(t.Fatalf, err)
+		return record
+	}
+
+	assert.NoError(t.Fatalf, db.Create(newRecord("stored")).Error)
+	stored, err := GetPerformanceRecordsByRunID(db, "stored")
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(stored))
+
+	diffs := DiffPerformanceRecords(stored[0], *newRecord("rerun"))
+	for _, diff := range diffs {
+		t.Errorf("%s differs: %s -> %s", diff.Name, diff.Stored, diff.Current)
+	}
+}
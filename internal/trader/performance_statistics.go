@@ -0,0 +1,47 @@
+package trader
+
+import "math"
+
+// MetricStatistic summarizes a metric over several performance records, e.g. the folds of a cross-validation
+type MetricStatistic struct {
+	Name   string
+	Mean   float64
+	StdDev float64 // Sample standard deviation, zero for less than two records
+	Min    float64
+	Max    float64
+}
+
+// PerformanceStatistics returns mean, standard deviation, min and max of all numeric metrics
+func PerformanceStatistics(records []PerformanceRecord) []MetricStatistic {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var values [][]metric
+	for _, record := range records {
+		values = append(values, numericMetrics(record))
+	}
+
+	var statistics []MetricStatistic
+	for i, m := range values[0] {
+		var stat = MetricStatistic{Name: m.name, Min: m.value, Max: m.value}
+		for _, recordMetrics := range values {
+			v := recordMetrics[i].value
+			stat.Mean += v
+			stat.Min = math.Min(stat.Min, v)
+			stat.Max = math.Max(stat.Max, v)
+		}
+		stat.Mean /= float64(len(values))
+
+		if len(values) > 1 {
+			var sum float64
+			for _, recordMetrics := range values {
+				sum += math.Pow(recordMetrics[i].value-stat.Mean, 2)
+			}
+			stat.StdDev = math.Sqrt(sum / float64(len(values)-1))
+		}
+		statistics = append(statistics, stat)
+	}
+
+	return statistics
+}
@@ -0,0 +1,36 @@
+package trader
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+)
+
+func TestPerformanceStatistics(t *testing.T) {
+	assert.EqualInt(t, 0, len(PerformanceStatistics(nil)))
+
+	var records = []PerformanceRecord{
+		{Trades: 2, TotalPerformanceInPips: 10},
+		{Trades: 4, TotalPerformanceInPips: -10},
+		{Trades: 6, TotalPerformanceInPips: 30},
+	}
+
+	var byName = map[string]MetricStatistic{}
+	for _, stat := range PerformanceStatistics(records) {
+		byName[stat.Name] = stat
+	}
+
+	trades := byName["Trades"]
+	assert.EqualFloat64(t, 4, trades.Mean)
+	assert.EqualFloat64(t, 2, trades.StdDev)
+	assert.EqualFloat64(t, 2, trades.Min)
+	assert.EqualFloat64(t, 6, trades.Max)
+
+	perf := byName["TotalPerformanceInPips"]
+	assert.EqualFloat64(t, 10, perf.Mean)
+	assert.EqualFloat64(t, 20, perf.StdDev)
+
+	single := PerformanceStatistics(records[:1])
+	for _, stat := range single {
+		assert.EqualFloat64(t, 0, stat.StdDev)
+	}
+}
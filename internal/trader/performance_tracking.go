@@ -8,15 +8,20 @@ import (
 	"github.com/sklinkert/at/internal/broker"
 	"github.com/sklinkert/at/pkg/helper"
 	"gorm.io/gorm"
+	"sort"
 	"time"
 )
 
 type PerformanceRecord struct {
 	gorm.Model
 	BacktestingID              string
+	RunID                      string `gorm:"index"`
+	ParentRunID                string `gorm:"index"`
+	Segment                    string
 	StrategyName               string
 	Strategy                   string
 	Instrument                 string
+	Owner                      string
 	CandleDuration             time.Duration
 	TargetInPips               float64
 	StopLossInPips             float64
@@ -44,11 +49,32 @@ type PerformanceRecord struct {
 	LastTrade                  time.Time
 	AVGTradeDurationInSeconds  float64
 	TotalExposureInPercent     float64
-	ChartHTML                  string
+	ChartHTML                  string `json:"-"`
 	BacktestingConfigJSON      string
-	ClosedPositions            []broker.Position `gorm:"-"`
+	ClosedPositions            []broker.Position `gorm:"-" json:"-"`
 	TotalTimeInMarket          time.Duration
 	AVGTimeInMarket            time.Duration
+	BenchmarkInstrument        string
+	BenchmarkReturnInPercent   float64
+	ExcessReturnInPercent      float64
+	RejectedOrders             map[string]int `gorm:"serializer:json"` // risk rule -> rejected orders
+	HaltReason                 string         // set if trading has been halted
+	BenchmarkBeta              float64
+	BenchmarkAlpha             float64
+	BenchmarkCorrelation       float64
+	InformationRatio           float64
+	Tags                       []TagPerformance `gorm:"serializer:json"`
+}
+
+// TagPerformance is the performance of all closed positions with the same tag
+type TagPerformance struct {
+	Tag                       string
+	Trades                    int
+	TradesWin                 int
+	TradesLoss                int
+	TotalPerformanceInPips    float64
+	AVGPerformanceInPips      float64
+	TotalPerformanceInPercent float64
 }
 
 const pipsFactor = 10000.0
@@ -69,6 +95,31 @@ func (tr *Trader) GetPerformanceRecordByID(backtestingID string) (PerformanceRec
 	return record, nil
 }
 
+// GetPerformanceRecordsByRunID returns all performance records of a run. A run has several
+// records when it covers a portfolio.
+func GetPerformanceRecordsByRunID(db *gorm.DB, runID string) ([]PerformanceRecord, error) {
+	var records []PerformanceRecord
+	if err := db.Model(&PerformanceRecord{}).Where("run_id = ? OR backtesting_id = ?", runID, runID).Order("instrument").Find(&records).Error; err != nil {
+		return records, err
+	}
+	if len(records) == 0 {
+		return records, fmt.Errorf("no performance records for run %q", runID)
+	}
+	return records, nil
+}
+
+// GetPerformanceRecordsByParentRunID returns the performance records of all segments of a run
+func GetPerformanceRecordsByParentRunID(db *gorm.DB, parentRunID string) ([]PerformanceRecord, error) {
+	var records []PerformanceRecord
+	if err := db.Model(&PerformanceRecord{}).Where("parent_run_id = ?", parentRunID).Order("run_id, instrument").Find(&records).Error; err != nil {
+		return records, err
+	}
+	if len(records) == 0 {
+		return records, fmt.Errorf("no performance records for parent run %q", parentRunID)
+	}
+	return records, nil
+}
+
 func (tr *Trader) totalTimeInMarket(closedPositions []broker.Position) (timeInMarket time.Duration) {
 	for _, position := range closedPositions {
 		timeInMarket += position.Duration()
@@ -86,8 +137,10 @@ func (tr *Trader) avgTimeInMarket(closedPositions []broker.Position) (avgTimeInM
 }
 
 func (tr *Trader) candleDuration() (duration time.Duration) {
-	if len(tr.closedCandles) > 0 {
-		return tr.closedCandles[0].Duration
+	for key := range tr.closedCandles {
+		if key.duration == tr.strategy.GetCandleDuration() {
+			return key.duration
+		}
 	}
 	return
 }
@@ -201,6 +254,41 @@ func (tr *Trader) totalPerfInPips(closedPositions []broker.Position) decimal.Dec
 	return totalPerfInPips
 }
 
+// tagPerformances groups the closed positions by tag. Untagged positions are grouped under
+// an empty tag.
+func tagPerformances(closedPositions []broker.Position) []TagPerformance {
+	var byTag = map[string]*TagPerformance{}
+	var tags []string
+	for _, position := range closedPositions {
+		perf, exists := byTag[position.Tag]
+		if !exists {
+			perf = &TagPerformance{Tag: position.Tag}
+			byTag[position.Tag] = perf
+			tags = append(tags, position.Tag)
+		}
+
+		perfInPips, _ := helper.Cent2Pips(decimal.NewFromFloat(position.PerformanceAbsolute(decimal.Zero, decimal.Zero))).Float64()
+		perfInPercent := position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+		perf.Trades++
+		if perfInPercent < 0 {
+			perf.TradesLoss++
+		} else {
+			perf.TradesWin++
+		}
+		perf.TotalPerformanceInPips += perfInPips
+		perf.TotalPerformanceInPercent += perfInPercent
+	}
+	sort.Strings(tags)
+
+	var performances []TagPerformance
+	for _, tag := range tags {
+		perf := byTag[tag]
+		perf.AVGPerformanceInPips = perf.TotalPerformanceInPips / float64(perf.Trades)
+		performances = append(performances, *perf)
+	}
+	return performances
+}
+
 func (tr *Trader) GetPerformanceRecord(chartHTML string) (*PerformanceRecord, error) {
 	closedPositions, err := tr.GetClosedPositions()
 	if err != nil {
@@ -220,7 +308,14 @@ func (tr *Trader) GetPerformanceRecord(chartHTML string) (*PerformanceRecord, er
 
 	perf := &PerformanceRecord{
 		BacktestingID:              tr.ID(),
+		RunID:                      tr.runID,
+		ParentRunID:                tr.parentRunID,
+		Segment:                    tr.segment,
+		BacktestingConfigJSON:      tr.backtestingConfigJSON,
 		Instrument:                 tr.Instrument,
+		Owner:                      tr.owner,
+		RejectedOrders:             tr.rejectedOrders,
+		HaltReason:                 tr.Halted(),
 		StrategyName:               tr.strategy.Name(),
 		Strategy:                   tr.strategy.String(),
 		CandleDuration:             tr.candleDuration(),
@@ -249,10 +344,22 @@ func (tr *Trader) GetPerformanceRecord(chartHTML string) (*PerformanceRecord, er
 		TotalTimeInMarket:          tr.totalTimeInMarket(closedPositions),
 		AVGTimeInMarket:            tr.avgTimeInMarket(closedPositions),
 		AVGTradeDurationInSeconds:  tr.totalTimeInMarket(closedPositions).Seconds() / float64(len(closedPositions)),
+		Tags:                       tagPerformances(closedPositions),
 	}
 	perf.TradesWinRationInPercent = float64(perf.TradesWin) * 100 / float64(perf.Trades)
 	perf.TotalExposureInPercent = tr.totalExposureInPercent(perf.TotalTimeInMarket, perf.FirstTrade, perf.LastTrade)
 
+	if tr.benchmark != nil {
+		result := tr.benchmark.Compare(dailyReturnsInPercent(closedPositions, tr.tradingDay.Key))
+		perf.BenchmarkInstrument = result.Instrument
+		perf.BenchmarkReturnInPercent = result.BenchmarkReturn
+		perf.ExcessReturnInPercent = result.ExcessReturn
+		perf.BenchmarkBeta = result.Beta
+		perf.BenchmarkAlpha = result.Alpha
+		perf.BenchmarkCorrelation = result.Correlation
+		perf.InformationRatio = result.InformationRatio
+	}
+
 	if (perf.TradesWin + perf.TradesLoss) != perf.Trades {
 		return nil, fmt.Errorf("TradesWin(%d) + TradesLoss(%d) != Trades(%d)", perf.TradesWin, perf.TradesLoss, perf.Trades)
 	}
@@ -263,6 +370,15 @@ func (tr *Trader) GetPerformanceRecord(chartHTML string) (*PerformanceRecord, er
 	return perf, nil
 }
 
+// dailyReturnsInPercent sums up the performance of the positions closed on each day
+func dailyReturnsInPercent(closedPositions []broker.Position, dayOf func(time.Time) string) map[string]float64 {
+	var returns = map[string]float64{}
+	for _, position := range closedPositions {
+		returns[dayOf(position.SellTime)] += position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+	}
+	return returns
+}
+
 func (tr *Trader) totalExposureInPercent(totalTimeInMarket time.Duration, firstPrice, lastPrice time.Time) float64 {
 	var totalTime = lastPrice.Sub(firstPrice)
 	return float64(totalTimeInMarket) * 100 / float64(totalTime)
@@ -290,6 +406,21 @@ func (tr *Trader) Summary() {
 	log.Infof("%25s: %.2f%% %.2f (%.2f pips)", "Max loss", pr.MaxLossInPercent, pr.MaxLossInPips/pipsFactor, pr.MaxLossInPips)
 	log.Infof("%25s: %.2f (%.2f pips)", "Total performance", pr.TotalPerformanceInPips/pipsFactor, pr.TotalPerformanceInPips)
 	log.Infof("%25s: %.2f (%.2f pips)", "AVG Performance", pr.AVGPerformanceInPips/pipsFactor, pr.AVGPerformanceInPips)
+
+	if pr.BenchmarkInstrument != "" {
+		log.Infof("%25s: %s %.2f%%", "Benchmark", pr.BenchmarkInstrument, pr.BenchmarkReturnInPercent)
+		log.Infof("%25s: %.2f%%", "Excess return", pr.ExcessReturnInPercent)
+		log.Infof("%25s: %.4f", "Beta", pr.BenchmarkBeta)
+		log.Infof("%25s: %.4f%%", "Alpha (daily)", pr.BenchmarkAlpha)
+		log.Infof("%25s: %.4f", "Correlation", pr.BenchmarkCorrelation)
+		log.Infof("%25s: %.4f", "Information ratio", pr.InformationRatio)
+	}
+
+	printTagPerformances(pr.Tags)
+	printRejectedOrders(pr.RejectedOrders)
+	if pr.HaltReason != "" {
+		log.Infof("%25s: %s", "Trading halted", pr.HaltReason)
+	}
 }
 
 func (tr *Trader) SavePerformanceRecord(chartHTML string) error {
@@ -4,7 +4,10 @@ import (
 	"github.com/AMekss/assert"
 	"github.com/shopspring/decimal"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/benchmark"
+	"github.com/sklinkert/at/pkg/ohlc"
 	"testing"
+	"time"
 )
 
 var lossPosition = broker.Position{
@@ -31,3 +34,45 @@ func TestTrader_getMaxConsecutiveLossTrades(t *testing.T) {
 	}
 	assert.EqualInt(t, 2, int(tr.getMaxConsecutiveLossTrades(closedPositions)))
 }
+
+func Test_dailyReturnsInPercent(t *testing.T) {
+	var day = time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	var first, second, third = winPosition, lossPosition, winPosition
+	first.SellTime = day
+	second.SellTime = day.Add(time.Hour)
+	third.SellTime = day.AddDate(0, 0, 1)
+
+	returns := dailyReturnsInPercent([]broker.Position{first, second, third}, benchmark.DayOf)
+	assert.EqualInt(t.Fatalf, 2, len(returns))
+	assert.EqualFloat64(t, 50, returns["2021-01-04"])
+	assert.EqualFloat64(t, 100, returns["2021-01-05"])
+
+	// Forex days begin at 17:00 in New York, 22:00 UTC in winter
+	newYork, err := ohlc.NewTradingDay("America/New_York", "17:00")
+	assert.NoError(t.Fatalf, err)
+	third.SellTime = day.Add(time.Hour * 10)
+	returns = dailyReturnsInPercent([]broker.Position{first, second, third}, newYork.Key)
+	assert.EqualFloat64(t, 50, returns["2021-01-04"])
+	assert.EqualFloat64(t, 100, returns["2021-01-05"])
+}
+
+func Test_tagPerformances(t *testing.T) {
+	var long, short, untagged = winPosition, lossPosition, winPosition
+	long.Tag = "long"
+	short.Tag = "short"
+
+	performances := tagPerformances([]broker.Position{short, long, untagged, long})
+	assert.EqualInt(t.Fatalf, 3, len(performances))
+
+	assert.EqualStrings(t, "", performances[0].Tag)
+	assert.EqualInt(t, 1, performances[0].Trades)
+
+	assert.EqualStrings(t, "long", performances[1].Tag)
+	assert.EqualInt(t, 2, performances[1].Trades)
+	assert.EqualInt(t, 2, performances[1].TradesWin)
+	assert.EqualFloat64(t, 200, performances[1].TotalPerformanceInPercent)
+
+	assert.EqualStrings(t, "short", performances[2].Tag)
+	assert.EqualInt(t, 1, performances[2].TradesLoss)
+	assert.EqualFloat64(t, -50, performances[2].TotalPerformanceInPercent)
+}
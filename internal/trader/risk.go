@@ -3,151 +3,345 @@ package trader
 import (
 	"fmt"
 	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/pkg/ohlc"
 	"github.com/sklinkert/at/pkg/tick"
+	"strings"
+	"time"
 )
 
 const (
-//minDayPerformancePercentageLong  = 0.05
-//minDayPerformancePercentageShort = -0.05
+	RuleMaxOpenPositions = "max_open_positions"
+	RuleMaxExposure      = "max_exposure"
+	RuleMaxDailyLoss     = "max_daily_loss"
+	RuleMaxOrderSize     = "max_order_size"
+	RulePriceSanity      = "price_sanity"
+	RuleTradingWindow    = "trading_window"
+	RuleMarketHours      = "market_hours"
 )
 
-//var locEST *time.Location
-//var noTradingDays []date.Date
-
-func init() {
-	//var err error
-	//locEST, err = time.LoadLocation("EST")
-	//if err != nil {
-	//	log.WithError(err).Fatal("risk: unable to load EST timezone")
-	//}
-	//
-	//noTradingDays = []date.Date{
-	//	// US election days
-	//	date.New(2016, 11, 9),
-	//	date.New(2020, 11, 3),
-	//	date.New(2020, 11, 4),
-	//
-	//	// ECB meetings
-	//	date.New(2016, 1, 21),
-	//	date.New(2016, 3, 10),
-	//	date.New(2016, 4, 21),
-	//	date.New(2016, 6, 2),
-	//	date.New(2016, 7, 21),
-	//	date.New(2016, 9, 8),
-	//	date.New(2016, 10, 20),
-	//	date.New(2016, 12, 8),
-	//	date.New(2017, 1, 19),
-	//	date.New(2017, 3, 9),
-	//	date.New(2017, 4, 27),
-	//	date.New(2017, 6, 8),
-	//	date.New(2017, 7, 20),
-	//	date.New(2017, 9, 7),
-	//	date.New(2017, 10, 26),
-	//	date.New(2017, 12, 14),
-	//	date.New(2018, 1, 25),
-	//	date.New(2018, 3, 8),
-	//	date.New(2018, 4, 26),
-	//	date.New(2018, 6, 14),
-	//	date.New(2018, 7, 26),
-	//	date.New(2018, 9, 13),
-	//	date.New(2018, 10, 25),
-	//	date.New(2018, 12, 13),
-	//	date.New(2019, 1, 24),
-	//	date.New(2019, 3, 7),
-	//	date.New(2019, 4, 10),
-	//	date.New(2019, 6, 6),
-	//	date.New(2019, 7, 25),
-	//	date.New(2019, 9, 12),
-	//	date.New(2019, 10, 24),
-	//	date.New(2019, 12, 12),
-	//	date.New(2020, 1, 23),
-	//	date.New(2020, 3, 12),
-	//	date.New(2020, 4, 30),
-	//	date.New(2020, 5, 27), // ECB press release
-	//	date.New(2020, 6, 4),
-	//	date.New(2020, 7, 16),
-	//	date.New(2020, 9, 10),
-	//	date.New(2020, 10, 29),
-	//	date.New(2020, 12, 10),
-	//}
-}
-
-// RiskLevelOK check if current risk level is low enough.
-// Approves trading with return value 'true', 'false' otherwise.
-//func (tr *Trader) riskLevelOK(currentTick tick.Tick, direction broker.BuyDirection, openPositions []broker.Position) (bool, string) {
-//	const maxOpenPositions = 20
-//
-//	if len(openPositions)+1 > maxOpenPositions {
-//		tr.clog.Debug("Too many open positions")
-//		return false, "too many open positions"
-//	}
-//
-//	var estTime = currentTick.Datetime.In(locEST)
-//	if estTime.Hour() == 9 || estTime.Hour() == 16 { // 09:30 - 16:00 EST
-//		// Avoid trading during US stocks markets (NYSE + NASDAQ) opening and closing
-//		tr.clog.Debug("No trading during US stocks market opening and closing")
-//		return false, "US stocks market is opening/closing"
-//	}
-//
-//	// Avoid trading in Monday night
-//	if currentTick.Datetime.Weekday() == time.Monday && currentTick.Datetime.Hour() < 8 {
-//		return false, "no trading during Monday night"
-//	}
-//
-//	if currentTick.Datetime.Hour() > 8 && currentTick.Datetime.Hour() < 10 {
-//		return false, "Europe stocks market opening"
-//	}
-//
-//	// Check if try to buyAfterRiskCheck against the price trend of the current day candle
-//	perfPercentage := tr.today.PerformanceInPercentage()
-//	tr.clog.WithFields(log.Fields{"TODAY_PERF_PERCENT": perfPercentage}).Debug("Today's performance")
-//	switch direction {
-//	case broker.BuyDirectionLong:
-//		// Concur with daily trend
-//		if perfPercentage.LessThan(decimal.NewFromFloat(minDayPerformancePercentageLong)) {
-//			return false, fmt.Sprintf("long: daily perf < %.2f%%", minDayPerformancePercentageLong)
-//		}
-//
-//	// Don't trade when daily performance is too high, likelihood of turnaround is too big
-//	//dailyHighPerf, err := tr.dayTracker.PerformanceInPercentageQuantile(1.00 - maxDayPerformanceQuantile)
-//	//if err != nil {
-//	//	tr.clog.WithError(err).Debugf("tr.dayTracker.PerformanceInPercentageQuantile() failed")
-//	//	return false, "long: cannot determine recent biggest high"
-//	//}
-//	//tr.clog.Debugf("dailyHighPerf is %.2f%%", dailyHighPerf)
-//	//if perfPercentage.GreaterThanOrEqual(decimal.NewFromFloat(dailyHighPerf)) {
-//	//	return false, "long: daily perf > recent biggest high"
-//	//}
-//	case broker.BuyDirectionShort:
-//		// Concur with daily trend
-//		if perfPercentage.GreaterThan(decimal.NewFromFloat(minDayPerformancePercentageShort)) {
-//			return false, fmt.Sprintf("short: daily perf > %.2f%%", minDayPerformancePercentageShort)
-//		}
-//
-//		// Don't trade when daily performance is too low, likelihood of turnaround is too big
-//		//dailyLowPerf, err := tr.dayTracker.PerformanceInPercentageQuantile(maxDayPerformanceQuantile)
-//		//if err != nil {
-//		//	tr.clog.WithError(err).Debugf("tr.dayTracker.PerformanceInPercentageQuantile() failed")
-//		//	return false, "short: cannot determine recent biggest low"
-//		//}
-//		//tr.clog.Debugf("dailyLowPerf is %.2f%%", dailyLowPerf)
-//		//if dailyLowPerf < 0 && perfPercentage.LessThanOrEqual(decimal.NewFromFloat(dailyLowPerf)) {
-//		//	return false, "short: daily perf < recent biggest low"
-//		//}
-//	}
-//
-//	for _, noTradingDay := range noTradingDays {
-//		if date.FromTime(currentTick.Datetime).Equals(noTradingDay) {
-//			return false, "no trading day"
-//		}
-//	}
-//
-//	//if tr.distanceSMAOK(currentTick, direction) != nil {
-//	//	return false, "distance to SMA is too big"
-//	//}
-//
-//	return true, ""
-//}
+// RiskRule approves an order before it is sent to the broker. A returned error rejects the order,
+// its message is the reason.
+type RiskRule interface {
+	Name() string
+	Check(order broker.Order, state RiskState) error
+}
+
+// RiskState is the trader's view when an order is checked
+type RiskState struct {
+	Tick            tick.Tick
+	PreviousTick    *tick.Tick // nil if the order's instrument had only one tick yet
+	OpenPositions   []broker.Position
+	ClosedPositions []broker.Position
+	Accepted        []broker.Order  // orders approved before within the same candle
+	TradingDay      ohlc.TradingDay // boundaries of the day, midnight in the tick's timezone if unset
+}
+
+// OrderRejection is an order rejected by a risk rule
+type OrderRejection struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	TraderID   string `gorm:"index"`
+	RunID      string `gorm:"index"`
+	Instrument string
+	Owner      string
+	Tag        string
+	Direction  broker.BuyDirection
+	Size       float64
+	Rule       string
+	Reason     string
+	Time       time.Time // time of the tick which triggered the order
+}
+
+type maxOpenPositions struct {
+	max int
+}
+
+// MaxOpenPositions rejects orders if the trader has already max open positions
+func MaxOpenPositions(max int) RiskRule {
+	return &maxOpenPositions{max: max}
+}
+
+func (r *maxOpenPositions) Name() string { return RuleMaxOpenPositions }
+
+func (r *maxOpenPositions) Check(_ broker.Order, state RiskState) error {
+	if open := len(state.OpenPositions) + len(state.Accepted); open >= r.max {
+		return fmt.Errorf("%d open positions, max %d", open, r.max)
+	}
+	return nil
+}
+
+type maxExposure struct {
+	max float64
+}
+
+// MaxExposure limits the summed up size of open positions and the order per instrument and direction
+func MaxExposure(max float64) RiskRule {
+	return &maxExposure{max: max}
+}
+
+func (r *maxExposure) Name() string { return RuleMaxExposure }
+
+func (r *maxExposure) Check(order broker.Order, state RiskState) error {
+	var exposure = order.Size
+	for _, position := range state.OpenPositions {
+		if position.Instrument == order.Instrument && position.BuyDirection == order.Direction {
+			exposure += position.Size
+		}
+	}
+	for _, accepted := range state.Accepted {
+		if accepted.Instrument == order.Instrument && accepted.Direction == order.Direction {
+			exposure += accepted.Size
+		}
+	}
+	if exposure > r.max {
+		return fmt.Errorf("%s %s exposure %.2f exceeds %.2f", order.Instrument, order.Direction, exposure, r.max)
+	}
+	return nil
+}
+
+type maxDailyLoss struct {
+	maxLossInPercent float64
+}
+
+// MaxDailyLoss stops opening positions for the rest of the trading day once the summed up performance
+// of the positions closed on the tick's trading day falls to -maxLossInPercent
+func MaxDailyLoss(maxLossInPercent float64) RiskRule {
+	return &maxDailyLoss{maxLossInPercent: maxLossInPercent}
+}
+
+func (r *maxDailyLoss) Name() string { return RuleMaxDailyLoss }
+
+func (r *maxDailyLoss) Check(_ broker.Order, state RiskState) error {
+	var tradingDay = state.TradingDay
+	if tradingDay.Location == nil {
+		tradingDay = ohlc.TradingDay{Location: state.Tick.Datetime.Location()}
+	}
+	var today = tradingDay.Key(state.Tick.Datetime)
+	var performance float64
+	for _, position := range state.ClosedPositions {
+		if tradingDay.Key(position.SellTime) == today {
+			performance += position.PerformanceInPercentage(decimal.Zero, decimal.Zero)
+		}
+	}
+	if performance <= -r.maxLossInPercent {
+		return fmt.Errorf("daily performance %.2f%% reached max loss of %.2f%%", performance, r.maxLossInPercent)
+	}
+	return nil
+}
+
+type maxOrderSize struct {
+	max float64
+}
+
+// MaxOrderSize rejects orders bigger than max
+func MaxOrderSize(max float64) RiskRule {
+	return &maxOrderSize{max: max}
+}
+
+func (r *maxOrderSize) Name() string { return RuleMaxOrderSize }
+
+func (r *maxOrderSize) Check(order broker.Order, _ RiskState) error {
+	if order.Size > r.max {
+		return fmt.Errorf("order size %.2f exceeds %.2f", order.Size, r.max)
+	}
+	return nil
+}
+
+type priceSanity struct{}
+
+// PriceSanity rejects orders if the price jumped by more than 0.5% since the previous tick, e.g.
+// during a flash crash or because of bad price data
+func PriceSanity() RiskRule {
+	return &priceSanity{}
+}
+
+func (r *priceSanity) Name() string { return RulePriceSanity }
+
+func (r *priceSanity) Check(_ broker.Order, state RiskState) error {
+	if state.PreviousTick == nil {
+		return nil
+	}
+	return flashCrashCheck(*state.PreviousTick, state.Tick)
+}
+
+type tradingWindow struct {
+	location *time.Location
+	from, to time.Duration
+	weekdays map[time.Weekday]bool
+}
+
+// TradingWindow only allows orders between from and to, given as time of day in the location. The
+// window spans midnight if from is after to. No weekdays allow trading on every day.
+func TradingWindow(location *time.Location, from, to time.Duration, weekdays ...time.Weekday) RiskRule {
+	var days = map[time.Weekday]bool{}
+	for _, weekday := range weekdays {
+		days[weekday] = true
+	}
+	return &tradingWindow{location: location, from: from, to: to, weekdays: days}
+}
+
+func (r *tradingWindow) Name() string { return RuleTradingWindow }
+
+func (r *tradingWindow) Check(_ broker.Order, state RiskState) error {
+	var now = state.Tick.Datetime.In(r.location)
+	if len(r.weekdays) > 0 && !r.weekdays[now.Weekday()] {
+		return fmt.Errorf("no trading on %s", now.Weekday())
+	}
+
+	var timeOfDay = time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+	var inWindow = timeOfDay >= r.from && timeOfDay < r.to
+	if r.from > r.to {
+		inWindow = timeOfDay >= r.from || timeOfDay < r.to
+	}
+	if !inWindow {
+		return fmt.Errorf("%s is outside of trading window %s - %s", now.Format("15:04"),
+			clockTime(r.from), clockTime(r.to))
+	}
+	return nil
+}
+
+func clockTime(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+type marketHours struct {
+	calendar *calendar.Calendar
+}
+
+// MarketHours rejects orders while the market of the calendar is closed or during blackouts
+func MarketHours(calendar *calendar.Calendar) RiskRule {
+	return &marketHours{calendar: calendar}
+}
+
+func (r *marketHours) Name() string { return RuleMarketHours }
+
+func (r *marketHours) Check(_ broker.Order, state RiskState) error {
+	return r.calendar.Check(state.Tick.Datetime)
+}
+
+// RiskLimits configures the standard risk rules. Zero values disable a rule.
+type RiskLimits struct {
+	MaxOpenPositions      int
+	MaxExposure           float64
+	MaxDailyLossInPercent float64
+	MaxOrderSize          float64
+	PriceSanity           bool
+	TradingWindow         string   // e.g. "08:00-20:00"
+	TradingDays           []string // e.g. "Mon", "Tue", empty: every day
+	Timezone              string   // of the trading window, UTC if empty
+}
+
+// Rules returns the rules of all enabled limits
+func (l RiskLimits) Rules() ([]RiskRule, error) {
+	var rules []RiskRule
+	if l.PriceSanity {
+		rules = append(rules, PriceSanity())
+	}
+	if l.TradingWindow != "" || len(l.TradingDays) > 0 {
+		rule, err := l.tradingWindow()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	if l.MaxOrderSize > 0 {
+		rules = append(rules, MaxOrderSize(l.MaxOrderSize))
+	}
+	if l.MaxOpenPositions > 0 {
+		rules = append(rules, MaxOpenPositions(l.MaxOpenPositions))
+	}
+	if l.MaxExposure > 0 {
+		rules = append(rules, MaxExposure(l.MaxExposure))
+	}
+	if l.MaxDailyLossInPercent > 0 {
+		rules = append(rules, MaxDailyLoss(l.MaxDailyLossInPercent))
+	}
+	return rules, nil
+}
+
+func (l RiskLimits) tradingWindow() (RiskRule, error) {
+	loc, err := time.LoadLocation(l.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	var from, to = time.Duration(0), time.Hour * 24
+	if l.TradingWindow != "" {
+		start, end, found := strings.Cut(l.TradingWindow, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid trading window %q", l.TradingWindow)
+		}
+		if from, err = calendar.ParseClockTime(start); err != nil {
+			return nil, err
+		}
+		if to, err = calendar.ParseClockTime(end); err != nil {
+			return nil, err
+		}
+	}
+
+	var weekdays []time.Weekday
+	for _, day := range l.TradingDays {
+		weekday, err := calendar.ParseWeekday(day)
+		if err != nil {
+			return nil, err
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	return TradingWindow(loc, from, to, weekdays...), nil
+}
+
+// checkRisk passes the order through all risk rules and returns the first rejection. Every order
+// is rejected while trading is halted.
+func (tr *Trader) checkRisk(order broker.Order, state RiskState) *OrderRejection {
+	if tr.halt != nil {
+		return tr.newRejection(order, state, RuleHalted, "trading halted: "+tr.halt.Reason)
+	}
+	for _, rule := range tr.riskRules {
+		if err := rule.Check(order, state); err != nil {
+			return tr.newRejection(order, state, rule.Name(), err.Error())
+		}
+	}
+	return nil
+}
+
+func (tr *Trader) newRejection(order broker.Order, state RiskState, rule, reason string) *OrderRejection {
+	return &OrderRejection{
+		TraderID:   tr.ID(),
+		RunID:      tr.runID,
+		Instrument: order.Instrument,
+		Owner:      order.Owner,
+		Tag:        order.Tag,
+		Direction:  order.Direction,
+		Size:       order.Size,
+		Rule:       rule,
+		Reason:     reason,
+		Time:       state.Tick.Datetime,
+	}
+}
+
+// rejectOrder logs, counts and persists the rejection and reports it back to the strategy
+func (tr *Trader) rejectOrder(order broker.Order, rejection OrderRejection) {
+	tr.clog.WithFields(log.Fields{"Rule": rejection.Rule}).Infof("Order rejected: %s: %s", rejection.Reason, order.String())
+	tr.rejectedOrders[rejection.Rule]++
+
+	if tr.gormDB != nil {
+		if err := tr.gormDB.Create(&rejection).Error; err != nil {
+			tr.clog.WithError(err).Error("Cannot persist order rejection")
+		}
+	}
+	tr.events.Publish(event.Rejection{Header: tr.eventHeader(order.Instrument, rejection.Time), Order: order,
+		Rule: rejection.Rule, Reason: rejection.Reason})
+
+	if handler, ok := tr.strategy.(strategy.RejectionHandler); ok {
+		handler.OnOrderRejected(order, rejection.Reason)
+	}
+}
 
 var maxAllowedDistanceInPercent = decimal.NewFromFloat(0.5)
 
@@ -162,22 +356,3 @@ func flashCrashCheck(previousTick, currentTick tick.Tick) error {
 	}
 	return nil
 }
-
-//func (tr *Trader) distanceSMAOK(currentTick tick.Tick, direction broker.BuyDirection) error {
-//	gapToSMAPercent, err := tr.gapToSMAInPercent(currentTick)
-//	if err != nil {
-//		return err
-//	}
-//
-//	switch direction {
-//	case broker.BuyDirectionLong:
-//		if gapToSMAPercent.GreaterThan(decimal.NewFromFloat(maxGapToSMAInPercent)) {
-//			return fmt.Errorf("gap to SMA is too big: %s%%", gapToSMAPercent)
-//		}
-//	case broker.BuyDirectionShort:
-//		if gapToSMAPercent.LessThan(decimal.NewFromFloat(maxGapToSMAInPercent).Neg()) {
-//			return fmt.Errorf("gap to SMA is too big: %s%%", gapToSMAPercent)
-//		}
-//	}
-//	return nil
-//}
@@ -1,24 +1,161 @@
 package trader
 
-//func Test_distanceSMAOK(t *testing.T) {
-//	tr := New("", false, false, &backtest.Backtest{}, "", getDBHandle(t))
-//	bid := decimal.NewFromFloat(10)
-//	ask := decimal.NewFromFloat(10.01)
-//	currentTick := tick.New("", time.Now(), bid, ask)
-//	assert.ErrorIncludesMessage(t, "not enough data", tr.distanceSMAOK(currentTick, broker.BuyDirectionLong))
-//
-//	for i := 0; i < 200; i++ {
-//		tr.sma.Insert(10)
-//	}
-//	assert.NoError(t, tr.distanceSMAOK(currentTick, broker.BuyDirectionLong))
-//
-//	bid = decimal.NewFromFloat(15.1)
-//	ask = decimal.NewFromFloat(15.1)
-//	currentTick = tick.New("", time.Now(), bid, ask)
-//	assert.ErrorIncludesMessage(t, "gap to SMA is too big", tr.distanceSMAOK(currentTick, broker.BuyDirectionLong))
-//
-//	bid = decimal.NewFromFloat(8)
-//	ask = decimal.NewFromFloat(8.01)
-//	currentTick = tick.New("", time.Now(), bid, ask)
-//	assert.ErrorIncludesMessage(t, "gap to SMA is too big", tr.distanceSMAOK(currentTick, broker.BuyDirectionShort))
-//}
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"testing"
+	"time"
+)
+
+func newRiskTick(datetime time.Time, price float64) tick.Tick {
+	return tick.New("EURUSD", datetime, decimal.NewFromFloat(price), decimal.NewFromFloat(price))
+}
+
+func TestMaxOpenPositions(t *testing.T) {
+	var rule = MaxOpenPositions(2)
+	var state = RiskState{OpenPositions: []broker.Position{{}}}
+	assert.NoError(t, rule.Check(broker.Order{}, state))
+
+	state.Accepted = []broker.Order{{}}
+	assert.ErrorIncludesMessage(t, "2 open positions", rule.Check(broker.Order{}, state))
+}
+
+func TestMaxExposure(t *testing.T) {
+	var rule = MaxExposure(3)
+	var state = RiskState{
+		OpenPositions: []broker.Position{
+			{Instrument: "EURUSD", BuyDirection: broker.BuyDirectionLong, Size: 2},
+			{Instrument: "EURUSD", BuyDirection: broker.BuyDirectionShort, Size: 5},
+			{Instrument: "GBPUSD", BuyDirection: broker.BuyDirectionLong, Size: 5},
+		},
+	}
+	var order = broker.Order{Instrument: "EURUSD", Direction: broker.BuyDirectionLong, Size: 1}
+	assert.NoError(t, rule.Check(order, state))
+
+	state.Accepted = []broker.Order{order}
+	assert.ErrorIncludesMessage(t, "EURUSD Long exposure 4.00 exceeds 3.00", rule.Check(order, state))
+}
+
+func TestMaxDailyLoss(t *testing.T) {
+	var now = time.Date(2021, 1, 5, 12, 0, 0, 0, time.UTC)
+	var rule = MaxDailyLoss(1)
+	var state = RiskState{
+		Tick: newRiskTick(now, 1),
+		ClosedPositions: []broker.Position{
+			// -2% yesterday
+			{BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(100), SellPrice: decimal.NewFromFloat(98), SellTime: now.AddDate(0, 0, -1), Size: 1},
+			// -0.5% today
+			{BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(100), SellPrice: decimal.NewFromFloat(99.5), SellTime: now.Add(-time.Hour), Size: 1},
+		},
+	}
+	assert.NoError(t, rule.Check(broker.Order{}, state))
+
+	state.ClosedPositions = append(state.ClosedPositions, broker.Position{BuyDirection: broker.BuyDirectionShort,
+		BuyPrice: decimal.NewFromFloat(100), SellPrice: decimal.NewFromFloat(101), SellTime: now.Add(-time.Minute), Size: 1})
+	assert.ErrorIncludesMessage(t, "reached max loss", rule.Check(broker.Order{}, state))
+}
+
+func TestMaxDailyLoss_tradingDay(t *testing.T) {
+	tradingDay, err := ohlc.NewTradingDay("America/New_York", "17:00")
+	assert.NoError(t.Fatalf, err)
+	var now = time.Date(2021, 1, 5, 18, 0, 0, 0, tradingDay.Location)
+	var rule = MaxDailyLoss(1)
+	var state = RiskState{
+		Tick:       newRiskTick(now, 1),
+		TradingDay: tradingDay,
+		ClosedPositions: []broker.Position{
+			// -2% on the same date, but before the trading day began at 17:00
+			{BuyDirection: broker.BuyDirectionLong, BuyPrice: decimal.NewFromFloat(100), SellPrice: decimal.NewFromFloat(98), SellTime: now.Add(-time.Hour * 2), Size: 1},
+		},
+	}
+	assert.NoError(t, rule.Check(broker.Order{}, state))
+
+	// -2% after 17:00
+	state.ClosedPositions = append(state.ClosedPositions, broker.Position{BuyDirection: broker.BuyDirectionLong,
+		BuyPrice: decimal.NewFromFloat(100), SellPrice: decimal.NewFromFloat(98), SellTime: now.Add(-time.Minute * 30), Size: 1})
+	assert.ErrorIncludesMessage(t, "reached max loss", rule.Check(broker.Order{}, state))
+}
+
+func TestMaxOrderSize(t *testing.T) {
+	assert.NoError(t, MaxOrderSize(2).Check(broker.Order{Size: 2}, RiskState{}))
+	assert.ErrorIncludesMessage(t, "exceeds", MaxOrderSize(2).Check(broker.Order{Size: 2.5}, RiskState{}))
+}
+
+func TestPriceSanity(t *testing.T) {
+	var now = time.Now()
+	var previous = newRiskTick(now, 100)
+	assert.NoError(t, PriceSanity().Check(broker.Order{}, RiskState{Tick: newRiskTick(now, 101)}))
+	assert.NoError(t, PriceSanity().Check(broker.Order{}, RiskState{Tick: newRiskTick(now, 100.1), PreviousTick: &previous}))
+	assert.True(t, PriceSanity().Check(broker.Order{}, RiskState{Tick: newRiskTick(now, 101), PreviousTick: &previous}) != nil)
+}
+
+func TestTradingWindow(t *testing.T) {
+	var monday = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var check = func(rule RiskRule, datetime time.Time) error {
+		return rule.Check(broker.Order{}, RiskState{Tick: newRiskTick(datetime, 1)})
+	}
+
+	var rule = TradingWindow(time.UTC, time.Hour*8, time.Hour*20, time.Monday, time.Tuesday)
+	assert.NoError(t, check(rule, monday.Add(time.Hour*8)))
+	assert.ErrorIncludesMessage(t, "07:59 is outside of trading window 08:00 - 20:00", check(rule, monday.Add(time.Hour*8-time.Minute)))
+	assert.True(t, check(rule, monday.Add(time.Hour*20)) != nil)
+	assert.ErrorIncludesMessage(t, "no trading on Wednesday", check(rule, monday.AddDate(0, 0, 2).Add(time.Hour*12)))
+
+	rule = TradingWindow(time.UTC, time.Hour*22, time.Hour*2)
+	assert.NoError(t, check(rule, monday.Add(time.Hour*23)))
+	assert.NoError(t, check(rule, monday.Add(time.Hour)))
+	assert.True(t, check(rule, monday.Add(time.Hour*12)) != nil)
+}
+
+func TestRiskLimits_Rules(t *testing.T) {
+	rules, err := RiskLimits{}.Rules()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t, 0, len(rules))
+
+	rules, err = RiskLimits{MaxOpenPositions: 1, PriceSanity: true, TradingWindow: "08:00-20:00", TradingDays: []string{"mon", "Fri"}}.Rules()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 3, len(rules))
+	assert.EqualStrings(t, RulePriceSanity, rules[0].Name())
+	assert.EqualStrings(t, RuleTradingWindow, rules[1].Name())
+	assert.EqualStrings(t, RuleMaxOpenPositions, rules[2].Name())
+
+	_, err = RiskLimits{TradingWindow: "08:00"}.Rules()
+	assert.ErrorIncludesMessage(t, "invalid trading window", err)
+	_, err = RiskLimits{TradingDays: []string{"Someday"}}.Rules()
+	assert.ErrorIncludesMessage(t, "invalid weekday", err)
+}
+
+// rejectionStrategy records the orders rejected by risk rules
+type rejectionStrategy struct {
+	timeframeStrategy
+	rejected []string
+}
+
+func (s *rejectionStrategy) OnOrderRejected(_ broker.Order, reason string) {
+	s.rejected = append(s.rejected, reason)
+}
+
+func TestTrader_riskRules(t *testing.T) {
+	var b = &orderBroker{}
+	var s = &rejectionStrategy{}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b), WithStrategy(s),
+		WithRiskRules(MaxOrderSize(2), MaxOpenPositions(1)))
+
+	var orders = []broker.Order{
+		{Instrument: "EURUSD", Size: 3},
+		{Instrument: "EURUSD", Size: 1},
+		{Instrument: "EURUSD", Size: 1},
+	}
+	tr.processOrders("EURUSD", newRiskTick(time.Now(), 1), nil, nil, orders)
+
+	assert.EqualInt(t, 1, len(b.orders))
+	assert.EqualInt(t.Fatalf, 2, len(s.rejected))
+	assert.EqualStrings(t, "order size 3.00 exceeds 2.00", s.rejected[0])
+	assert.EqualStrings(t, "1 open positions, max 1", s.rejected[1])
+	assert.EqualInt(t, 1, tr.rejectedOrders[RuleMaxOrderSize])
+	assert.EqualInt(t, 1, tr.rejectedOrders[RuleMaxOpenPositions])
+}
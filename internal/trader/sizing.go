@@ -0,0 +1,53 @@
+package trader
+
+import (
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/sizing"
+	"github.com/sklinkert/at/pkg/indicator/atr"
+	"github.com/sklinkert/at/pkg/tick"
+)
+
+// orderSize computes the size of the order with the trader's sizer and scales it by the trader's
+// size factor
+func (tr *Trader) orderSize(instrument string, order broker.Order, currentTick tick.Tick, closedPositions []broker.Position) (float64, error) {
+	equity, err := tr.equity()
+	if err != nil {
+		return 0, err
+	}
+
+	var price = currentTick.Ask
+	if order.Direction == broker.BuyDirectionShort {
+		price = currentTick.Bid
+	}
+
+	size, err := tr.sizer.Size(sizing.Input{
+		Equity:          equity,
+		Price:           price.InexactFloat64(),
+		StopLoss:        order.StopLossPrice.InexactFloat64(),
+		ATR:             tr.atr(instrument, tr.sizer.ATRPeriod()),
+		ClosedPositions: closedPositions,
+	})
+	if err != nil || tr.sizeFactor == 1 {
+		return size, err
+	}
+	return tr.sizer.Round(size * tr.sizeFactor)
+}
+
+// atr returns the average true range of the strategy's candles or zero if there are not enough
+// candles yet
+func (tr *Trader) atr(instrument string, period int) float64 {
+	var candles = tr.history(instrument, tr.strategy.GetCandleDuration())
+	if len(candles) > period+1 {
+		candles = candles[len(candles)-period-1:]
+	}
+
+	var indicator = atr.New(period)
+	for _, candle := range candles {
+		indicator.Insert(candle)
+	}
+	value, err := indicator.Value()
+	if err != nil {
+		return 0
+	}
+	return value[atr.Value]
+}
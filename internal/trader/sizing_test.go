@@ -0,0 +1,46 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/sizing"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+func TestTrader_orderSize(t *testing.T) {
+	var b = &orderBroker{}
+	var equity = func() (float64, error) { return 10000, nil }
+	var sizer = sizing.New(sizing.FixedFractional(1), sizing.WithLotStep(10))
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b), WithStrategy(newTimeframeStrategy(0)),
+		WithSizing(sizer, equity), WithSizeFactor(0.5))
+
+	var order = broker.Order{Instrument: "EURUSD", Direction: broker.BuyDirectionLong, Size: 1,
+		StopLossPrice: decimal.NewFromFloat(98)}
+	tr.processOrders("EURUSD", newRiskTick(time.Now(), 100), nil, nil, []broker.Order{order, {Instrument: "EURUSD", Size: 1}})
+
+	// 1% of 10000 risked on 2 => 50, halved by the size factor and rounded down to 20
+	assert.EqualInt(t.Fatalf, 1, len(b.orders))
+	assert.EqualFloat64(t, 20, b.orders[0].Size)
+}
+
+func TestTrader_atr(t *testing.T) {
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(newTimeframeStrategy(0)))
+	assert.EqualFloat64(t, 0, tr.atr("EURUSD", 2))
+
+	var start = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	for i, prices := range [][]float64{{100, 110}, {10, 12}, {11, 12}, {12, 15}} {
+		candle := ohlc.New("EURUSD", start.Add(time.Minute*5*time.Duration(i)), time.Minute*5, false)
+		for _, price := range prices {
+			candle.NewPrice(decimal.NewFromFloat(price), candle.Start)
+		}
+		candle.ForceClose()
+		tr.addClosedCandle("EURUSD", candle)
+	}
+
+	// True ranges of the last 2 candles: 1 and 3
+	assert.EqualFloat64(t, 2, tr.atr("EURUSD", 2))
+}
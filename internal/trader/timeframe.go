@@ -0,0 +1,160 @@
+package trader
+
+import (
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"sort"
+	"time"
+)
+
+// candlesToKeep is the number of closed candles kept for each timeframe unless the strategy
+// implements strategy.History
+const candlesToKeep = 100
+
+const persistedCandleDuration = time.Hour * 24
+
+// candleKey identifies the candle history of an instrument's timeframe
+type candleKey struct {
+	instrument string
+	duration   time.Duration
+}
+
+// timeframes returns the candle durations of the strategy, longest first
+func timeframes(s strategy.Strategy) []time.Duration {
+	var durations = []time.Duration{s.GetCandleDuration()}
+	if multi, ok := s.(strategy.MultiTimeframe); ok {
+		for _, duration := range multi.GetCandleDurations() {
+			if !containsDuration(durations, duration) {
+				durations = append(durations, duration)
+			}
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] > durations[j]
+	})
+	return durations
+}
+
+func containsDuration(durations []time.Duration, duration time.Duration) bool {
+	for _, d := range durations {
+		if d == duration {
+			return true
+		}
+	}
+	return false
+}
+
+// barType returns the bar type of the strategy's timeframe
+func barType(s strategy.Strategy, timeframe time.Duration) ohlc.BarType {
+	if bars, ok := s.(strategy.Bars); ok {
+		return bars.GetBarType(timeframe)
+	}
+	return ohlc.BarType{}
+}
+
+// isBar checks if the strategy processes bars instead of time candles in the timeframe
+func (tr *Trader) isBar(timeframe time.Duration) bool {
+	return tr.strategy != nil && tr.isTimeframe(timeframe) && barType(tr.strategy, timeframe).Kind != ohlc.BarTime
+}
+
+// candleDurations returns the durations of all candles built by the trader. Daily candles are
+// built for persistence even if the strategy doesn't use them.
+func (tr *Trader) candleDurations() []time.Duration {
+	var durations = timeframes(tr.strategy)
+	if tr.persistCandleData && !containsDuration(durations, persistedCandleDuration) {
+		durations = append(durations, persistedCandleDuration)
+	}
+	return durations
+}
+
+// isTimeframe checks if the strategy processes candles of the given duration
+func (tr *Trader) isTimeframe(duration time.Duration) bool {
+	return containsDuration(timeframes(tr.strategy), duration)
+}
+
+// candleHistory keeps the most recent closed candles of a timeframe in a ring buffer. Every candle
+// is stored twice in a buffer of double size, so the history is a slice without copying.
+type candleHistory struct {
+	buffer []*ohlc.OHLC
+	next   int // index of the next candle
+	length int
+}
+
+func newCandleHistory(size int) *candleHistory {
+	return &candleHistory{buffer: make([]*ohlc.OHLC, size*2)}
+}
+
+func (h *candleHistory) add(candle *ohlc.OHLC) {
+	var size = len(h.buffer) / 2
+	h.buffer[h.next] = candle
+	h.buffer[h.next+size] = candle
+	h.next = (h.next + 1) % size
+	if h.length < size {
+		h.length++
+	}
+}
+
+// candles returns the history, oldest first. The slice changes when the next candle is added.
+func (h *candleHistory) candles() []*ohlc.OHLC {
+	if h.length < len(h.buffer)/2 {
+		return h.buffer[:h.length]
+	}
+	return h.buffer[h.next : h.next+h.length]
+}
+
+// historySize returns the number of closed candles kept for the timeframe
+func (tr *Trader) historySize(duration time.Duration) int {
+	if history, ok := tr.strategy.(strategy.History); ok && tr.isTimeframe(duration) {
+		if size := history.GetCandleHistory(duration); size > 0 {
+			return int(size)
+		}
+	}
+	return candlesToKeep
+}
+
+// addClosedCandle appends the candle to the history of its timeframe
+func (tr *Trader) addClosedCandle(instrument string, candle *ohlc.OHLC) {
+	var key = candleKey{instrument: instrument, duration: candle.Duration}
+	history, exists := tr.closedCandles[key]
+	if !exists {
+		history = newCandleHistory(tr.historySize(candle.Duration))
+		tr.closedCandles[key] = history
+	}
+	history.add(candle)
+}
+
+// history returns the closed candles of the instrument's timeframe, oldest first
+func (tr *Trader) history(instrument string, duration time.Duration) []*ohlc.OHLC {
+	history, exists := tr.closedCandles[candleKey{instrument: instrument, duration: duration}]
+	if !exists {
+		return nil
+	}
+	return history.candles()
+}
+
+// warmingUp feeds the candle to a multi timeframe strategy as warm-up candle if its timeframe
+// hasn't received enough warm-up candles yet. Returns false if the timeframe is warmed up.
+func (tr *Trader) warmingUp(instrument string, candle *ohlc.OHLC) bool {
+	multi, ok := tr.strategy.(strategy.MultiTimeframe)
+	if !ok {
+		return false
+	}
+
+	var key = candleKey{instrument: instrument, duration: candle.Duration}
+	if tr.warmUpCandles[key] >= multi.GetTimeframeWarmUpCandleAmount(candle.Duration) {
+		return false
+	}
+	tr.warmUpCandles[key]++
+	multi.OnWarmUpTimeframeCandle(candle.Duration, candle)
+	return true
+}
+
+// onCandle passes the closed candles of the candle's timeframe to the strategy
+func (tr *Trader) onCandle(instrument string, candle *ohlc.OHLC) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
+	var closedCandles = tr.history(instrument, candle.Duration)
+	if multi, ok := tr.strategy.(strategy.MultiTimeframe); ok {
+		return multi.OnTimeframeCandle(candle.Duration, closedCandles)
+	}
+	return tr.strategy.OnCandle(closedCandles)
+}
@@ -0,0 +1,231 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"github.com/sklinkert/at/pkg/tick"
+	"testing"
+	"time"
+)
+
+type noopBroker struct{}
+
+func (noopBroker) Buy(broker.Order) (string, error)       { return "", nil }
+func (noopBroker) CancelOrder(string) error               { return nil }
+func (noopBroker) Sell(broker.Position) error             { return nil }
+func (noopBroker) GetOpenOrders() ([]broker.Order, error) { return nil, nil }
+func (noopBroker) GetOpenPosition(string) (broker.Position, error) {
+	return broker.Position{}, broker.ErrPositionNotFound
+}
+func (noopBroker) GetOpenPositions() ([]broker.Position, error) { return nil, nil }
+func (noopBroker) GetOpenPositionsByInstrument(string) ([]broker.Position, error) {
+	return nil, nil
+}
+func (noopBroker) GetClosedPositions() ([]broker.Position, error) { return nil, nil }
+func (noopBroker) ListenToPriceFeed(c chan tick.Tick)             { close(c) }
+
+// timeframeStrategy records the candles it receives per timeframe
+type timeframeStrategy struct {
+	candles        map[time.Duration][]int // number of closed candles passed on each call
+	warmUpCandles  map[time.Duration]int
+	warmUpRequired uint
+	order          []time.Duration
+}
+
+func newTimeframeStrategy(warmUpRequired uint) *timeframeStrategy {
+	return &timeframeStrategy{
+		candles:        map[time.Duration][]int{},
+		warmUpCandles:  map[time.Duration]int{},
+		warmUpRequired: warmUpRequired,
+	}
+}
+
+func (s *timeframeStrategy) Name() string { return "timeframes" }
+func (s *timeframeStrategy) OnCandle([]*ohlc.OHLC) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
+	panic("OnCandle must not be called for multi timeframe strategies")
+}
+func (s *timeframeStrategy) OnTick(tick.Tick) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
+	return
+}
+func (s *timeframeStrategy) OnPosition([]broker.Position, []broker.Position) {}
+func (s *timeframeStrategy) OnOrder([]broker.Order)                          {}
+func (s *timeframeStrategy) OnWarmUpCandle(*ohlc.OHLC)                       {}
+func (s *timeframeStrategy) GetWarmUpCandleAmount() uint                     { return 0 }
+func (s *timeframeStrategy) GetCandleDuration() time.Duration                { return time.Minute * 5 }
+func (s *timeframeStrategy) String() string                                  { return s.Name() }
+
+func (s *timeframeStrategy) GetCandleDurations() []time.Duration {
+	return []time.Duration{time.Minute * 5, time.Hour}
+}
+
+func (s *timeframeStrategy) OnTimeframeCandle(timeframe time.Duration, closedCandles []*ohlc.OHLC) (toOpen, toClose []broker.Order, toClosePositions []broker.Position) {
+	for _, candle := range closedCandles {
+		if candle.Duration != timeframe {
+			panic("candle of foreign timeframe")
+		}
+	}
+	s.candles[timeframe] = append(s.candles[timeframe], len(closedCandles))
+	s.order = append(s.order, timeframe)
+	return
+}
+
+func (s *timeframeStrategy) OnWarmUpTimeframeCandle(timeframe time.Duration, _ *ohlc.OHLC) {
+	s.warmUpCandles[timeframe]++
+}
+
+func (s *timeframeStrategy) GetTimeframeWarmUpCandleAmount(timeframe time.Duration) uint {
+	if timeframe == time.Hour {
+		return s.warmUpRequired
+	}
+	return 0
+}
+
+func feedMinuteTicks(tr *Trader, from time.Time, minutes int) {
+	for i := 0; i <= minutes; i++ {
+		price := decimal.NewFromFloat(1.0 + float64(i)/1000)
+		tr.processTick(tr.Instrument, tick.New(tr.Instrument, from.Add(time.Minute*time.Duration(i)), price, price))
+	}
+}
+
+func TestTrader_multipleTimeframes(t *testing.T) {
+	var s = newTimeframeStrategy(0)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s))
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	feedMinuteTicks(tr, from, 180)
+
+	assert.EqualInt(t.Fatalf, 36, len(s.candles[time.Minute*5]))
+	assert.EqualInt(t.Fatalf, 3, len(s.candles[time.Hour]))
+	assert.EqualInt(t, 36, s.candles[time.Minute*5][35])
+	assert.EqualInt(t, 3, s.candles[time.Hour][2])
+
+	// The hourly candle is processed before the 5m candle closing at the same time
+	assert.True(t, s.order[11] == time.Hour)
+	assert.True(t, s.order[12] == time.Minute*5)
+	assert.True(t, tr.candleDuration() == time.Minute*5)
+}
+
+func TestTrader_multipleTimeframesWarmUp(t *testing.T) {
+	var s = newTimeframeStrategy(2)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s))
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	feedMinuteTicks(tr, from, 180)
+
+	assert.EqualInt(t, 2, s.warmUpCandles[time.Hour])
+	assert.EqualInt(t, 0, s.warmUpCandles[time.Minute*5])
+	assert.EqualInt(t.Fatalf, 1, len(s.candles[time.Hour]))
+	assert.EqualInt(t, 3, s.candles[time.Hour][0])
+	assert.EqualInt(t, 36, len(s.candles[time.Minute*5]))
+}
+
+func Test_timeframes(t *testing.T) {
+	durations := timeframes(newTimeframeStrategy(0))
+	assert.EqualInt(t.Fatalf, 2, len(durations))
+	assert.True(t, durations[0] == time.Hour)
+	assert.True(t, durations[1] == time.Minute*5)
+}
+
+func TestTrader_candleTimer(t *testing.T) {
+	var s = newTimeframeStrategy(0)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s),
+		WithFlatCandles())
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	feedMinuteTicks(tr, from, 3)
+	tr.advanceCandles(from.Add(time.Minute * 4))
+	assert.EqualInt(t, 0, len(s.candles[time.Minute*5]))
+
+	// The first candle closes on time, three flat candles follow until 00:20
+	tr.advanceCandles(from.Add(time.Minute * 20))
+	assert.EqualInt(t.Fatalf, 4, len(s.candles[time.Minute*5]))
+	assert.EqualInt(t, 0, len(s.candles[time.Hour]))
+	var candles = tr.history("EURUSD", time.Minute*5)
+	assert.True(t, !candles[0].Flat)
+	assert.True(t, candles[3].Flat)
+	assert.EqualTime(t, from.Add(time.Minute*20), candles[3].End)
+}
+
+// renkoStrategy processes Renko bricks in the 5m timeframe and hourly candles
+type renkoStrategy struct {
+	*timeframeStrategy
+}
+
+func (s renkoStrategy) GetBarType(timeframe time.Duration) ohlc.BarType {
+	if timeframe == time.Minute*5 {
+		return ohlc.BarType{Kind: ohlc.BarRenko, Size: decimal.NewFromFloat(0.005)}
+	}
+	return ohlc.BarType{}
+}
+
+func TestTrader_bars(t *testing.T) {
+	var s = renkoStrategy{newTimeframeStrategy(0)}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s))
+
+	// Prices rise by 0.18 within 3 hours
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	feedMinuteTicks(tr, from, 180)
+
+	assert.EqualInt(t.Fatalf, 36, len(s.candles[time.Minute*5]))
+	assert.EqualInt(t, 3, len(s.candles[time.Hour]))
+	assert.True(t, tr.isBar(time.Minute*5))
+	assert.True(t, !tr.isBar(time.Hour))
+
+	var bricks = tr.history("EURUSD", time.Minute*5)
+	assert.EqualStrings(t, "1.175", bricks[35].Open.String())
+	assert.EqualStrings(t, "1.18", bricks[35].Close.String())
+	assert.EqualTime(t, from.Add(time.Minute*180), bricks[35].End)
+}
+
+// historyStrategy keeps 150 candles of the 5m timeframe and 2 of the 1h timeframe
+type historyStrategy struct {
+	*timeframeStrategy
+}
+
+func (s historyStrategy) GetCandleHistory(timeframe time.Duration) uint {
+	if timeframe == time.Hour {
+		return 2
+	}
+	return 150
+}
+
+func TestTrader_candleHistory(t *testing.T) {
+	var s = historyStrategy{newTimeframeStrategy(0)}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s))
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	feedMinuteTicks(tr, from, 1000)
+
+	var fiveMinutes = s.candles[time.Minute*5]
+	assert.EqualInt(t.Fatalf, 200, len(fiveMinutes))
+	assert.EqualInt(t, 150, fiveMinutes[149])
+	assert.EqualInt(t, 150, fiveMinutes[199])
+	assert.EqualInt(t, 2, s.candles[time.Hour][15])
+
+	var candles = tr.history("EURUSD", time.Minute*5)
+	assert.EqualInt(t.Fatalf, 150, len(candles))
+	assert.EqualTime(t, from.Add(time.Minute*250), candles[0].Start)
+	assert.EqualTime(t, from.Add(time.Minute*995), candles[149].Start)
+}
+
+func Test_candleHistory(t *testing.T) {
+	var history = newCandleHistory(3)
+	assert.EqualInt(t, 0, len(history.candles()))
+
+	var start = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		history.add(ohlc.New("EURUSD", start.Add(time.Minute*time.Duration(i)), time.Minute, false))
+		if i == 1 {
+			assert.EqualInt(t, 2, len(history.candles()))
+		}
+	}
+
+	var candles = history.candles()
+	assert.EqualInt(t.Fatalf, 3, len(candles))
+	for i, candle := range candles {
+		assert.EqualTime(t, start.Add(time.Minute*time.Duration(i+2)), candle.Start)
+	}
+}
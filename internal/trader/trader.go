@@ -7,11 +7,15 @@ import (
 	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/internal/sizing"
 	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/benchmark"
 	"github.com/sklinkert/at/pkg/ohlc"
 	"github.com/sklinkert/at/pkg/tick"
 	"gorm.io/gorm"
-	"sort"
 	"sync"
 	"time"
 )
@@ -20,74 +24,212 @@ type Trader struct {
 	ctx                         context.Context
 	StartTime                   time.Time
 	Instrument                  string
+	instruments                 map[string]bool // empty: every tick belongs to Instrument
 	TickChan                    chan tick.Tick
+	done                        chan struct{} // closed when all ticks of the closed TickChan are processed
 	running                     bool
 	clog                        *log.Entry
 	broker                      broker.Broker
 	strategy                    strategy.Strategy
 	persistTickData             bool
 	persistCandleData           bool
-	today                       *ohlc.OHLC
+	today                       map[string]*ohlc.OHLC
 	maxConcurrentPositions      int
 	MaxAggregatedDrawdownInPips decimal.Decimal
 	reversedPerformanceInPips   map[ohlc.OHLC]float64
 	gormDB                      *gorm.DB
 	positionBuyTime             map[string]time.Time
-	openCandles                 []*ohlc.OHLC // strategy's candle + today's candle
-	closedCandles               []*ohlc.OHLC
-	lastReceivedTick            *tick.Tick
+	aggregators                 map[string][]ohlc.Builder // instrument -> open candles or bars of each timeframe
+	aggregatorOptions           []ohlc.AggregatorOption
+	candleTimer                 time.Duration // 0: candles are closed by ticks only
+	closedCandles               map[candleKey]*candleHistory
+	warmUpCandles               map[candleKey]uint // warm-up candles sent to multi timeframe strategies
+	warmUpStrategy              strategy.Strategy  // warmed up by New(), the trader's strategy by default
+	warmUpProviders             []warmup.Provider
+	warmUpReports               []warmup.Report
+	checkpoints                 bool
+	checkpointInterval          time.Duration // 0: checkpoints are saved on Stop() and Checkpoint() only
+	restored                    bool          // the strategy was restored from a checkpoint
+	restoredUntil               time.Time     // last tick seen by the restored strategy
+	lastReceivedTick            map[string]*tick.Tick
+	previousTick                map[string]*tick.Tick // tick received before lastReceivedTick
 	gitRev                      string
-	candleSubscribers           []CandleSubscriber
-	positionSubscribers         []PositionSubscriber
-	orderSubscribers            []OrderSubscriber
+	runID                       string
+	parentRunID                 string
+	segment                     string
+	backtestingConfigJSON       string
+	benchmark                   *benchmark.Benchmark
+	events                      *event.Bus
+	ownEvents                   bool // events is closed on Stop
 	closedPositionReferences    map[string]bool
 	currencyCode                string
+	owner                       string  // set when several traders share a broker
+	sizeFactor                  float64 // order sizes of the strategy are multiplied by it
+	riskRules                   []RiskRule
+	sizer                       *sizing.Sizer
+	haltConditions              HaltConditions
+	halt                        *TradingHalt // active halt, nil while trading
+	haltResetAt                 time.Time    // losses before the last reset of a halt are ignored
+	equityTracker               equityTracker
+	equity                      func() (float64, error)
+	rejectedOrders              map[string]int       // risk rule -> rejected orders
+	calendar                    *calendar.Calendar   // nil: always open
+	tradingDay                  ohlc.TradingDay      // timezone and beginning of days of candles and reports
+	sessionEnd                  map[string]time.Time // instrument -> end of the session of the last tick
+	feed                        *feedSupervisor      // nil: the price feed isn't supervised
+	journal                     bool
+	candle                      *ohlc.OHLC // closed candle being processed, set in the headers of events
 	sync.Mutex
 }
 
-type PositionSubscriber interface {
-	OnPosition(position broker.Position)
+type Option func(*Trader)
+
+func WithBroker(broker broker.Broker) Option {
+	return func(trader *Trader) {
+		trader.broker = broker
+	}
 }
 
-type CandleSubscriber interface {
-	OnCandle(candle ohlc.OHLC)
+// WithEventBus publishes the events of the trader on the given bus, e.g. one bus shared by the
+// traders of a portfolio. The bus is closed by its owner, not by the trader.
+func WithEventBus(bus *event.Bus) Option {
+	return func(trader *Trader) {
+		trader.events = bus
+		trader.ownEvents = false
+	}
 }
 
-type OrderSubscriber interface {
-	OnOrder(order broker.Order)
+//func WithGatherPerformanceData() Option {
+//	return func(trader *Trader) {
+//		trader.gatherPerformanceData = true
+//	}
+//}
+
+// WithInstruments restricts the trader to the given instruments. Ticks, orders and positions of
+// other instruments are ignored, so several traders can share one broker. A strategy handling
+// more than one instrument receives the closed candles of each instrument separately.
+func WithInstruments(instruments ...string) Option {
+	return func(trader *Trader) {
+		if trader.instruments == nil {
+			trader.instruments = map[string]bool{}
+		}
+		for _, instrument := range instruments {
+			trader.instruments[instrument] = true
+		}
+	}
 }
 
-type Option func(*Trader)
+// WithRunID sets a unique ID for the run. It's used for the performance record instead of git rev
+// and strategy name which are the same for repeated runs.
+func WithRunID(runID string) Option {
+	return func(trader *Trader) {
+		trader.runID = runID
+	}
+}
 
-func WithBroker(broker broker.Broker) Option {
+// WithOwner marks orders of the trader with the given owner and only considers positions and
+// orders of this owner. Required when several traders trade the same instrument on one broker.
+func WithOwner(owner string) Option {
 	return func(trader *Trader) {
-		trader.broker = broker
+		trader.owner = owner
 	}
 }
 
-func WithCandleSubscription(subscriber CandleSubscriber) Option {
+// WithRiskRules checks every order of the strategy with the given rules before it's sent to the
+// broker. Orders are rejected by the first failing rule.
+func WithRiskRules(rules ...RiskRule) Option {
 	return func(trader *Trader) {
-		trader.candleSubscribers = append(trader.candleSubscribers, subscriber)
+		trader.riskRules = append(trader.riskRules, rules...)
 	}
 }
 
-func WithPositionSubscription(subscriber PositionSubscriber) Option {
+// WithCalendar ignores ticks while the market is closed, closes candles at the end of a session and
+// rejects orders outside of sessions and during blackouts
+func WithCalendar(cal *calendar.Calendar) Option {
 	return func(trader *Trader) {
-		trader.positionSubscribers = append(trader.positionSubscribers, subscriber)
+		trader.calendar = cal
+		trader.riskRules = append(trader.riskRules, MarketHours(cal))
 	}
 }
 
-//func WithOrderSubscription(subscriber OrderSubscriber) Option {
-//	return func(trader *Trader) {
-//		trader.orderSubscribers = append(trader.orderSubscribers, subscriber)
-//	}
-//}
-//
-//func WithGatherPerformanceData() Option {
-//	return func(trader *Trader) {
-//		trader.gatherPerformanceData = true
-//	}
-//}
+// WithTradingDay sets the timezone of ticks and candles and the beginning of daily candles and
+// daily returns, midnight in Berlin by default
+func WithTradingDay(day ohlc.TradingDay) Option {
+	return func(trader *Trader) {
+		trader.tradingDay = day
+	}
+}
+
+// WithCandleAnchor aligns candles to anchor instead of the beginning of the trading day, e.g. the
+// opening of an exchange
+func WithCandleAnchor(anchor time.Time) Option {
+	return func(trader *Trader) {
+		trader.aggregatorOptions = append(trader.aggregatorOptions, ohlc.WithAnchor(anchor))
+	}
+}
+
+// WithFlatCandles passes candles to the strategy for intervals without ticks, all prices are the
+// previous close
+func WithFlatCandles() Option {
+	return func(trader *Trader) {
+		trader.aggregatorOptions = append(trader.aggregatorOptions, ohlc.WithFlatCandles())
+	}
+}
+
+// WithCandleTimer closes candles at their end even if no tick arrives. The clock is checked every
+// interval. Only for live trading, ticks are the clock of backtests.
+func WithCandleTimer(interval time.Duration) Option {
+	return func(trader *Trader) {
+		trader.candleTimer = interval
+	}
+}
+
+// WithHaltConditions stops opening positions once a condition is met until the halt is reset
+func WithHaltConditions(conditions HaltConditions) Option {
+	return func(trader *Trader) {
+		trader.haltConditions = conditions
+	}
+}
+
+// WithSizing computes order sizes from the account's equity with the given sizer. The computed
+// size replaces the strategy's order size.
+func WithSizing(sizer *sizing.Sizer, equity func() (float64, error)) Option {
+	return func(trader *Trader) {
+		trader.sizer = sizer
+		trader.equity = equity
+	}
+}
+
+// WithSizeFactor multiplies the order sizes of the strategy by the given factor
+func WithSizeFactor(factor float64) Option {
+	return func(trader *Trader) {
+		trader.sizeFactor = factor
+	}
+}
+
+// WithSegment links the run to a parent run which is split into segments, e.g. the folds of a
+// cross-validation
+func WithSegment(parentRunID, segment string) Option {
+	return func(trader *Trader) {
+		trader.parentRunID = parentRunID
+		trader.segment = segment
+	}
+}
+
+// WithBacktestingConfig stores the run's configuration with its performance record
+func WithBacktestingConfig(configJSON string) Option {
+	return func(trader *Trader) {
+		trader.backtestingConfigJSON = configJSON
+	}
+}
+
+// WithBenchmark compares the performance with buy and hold of the given instrument. An empty
+// instrument is the traded instrument. Other instruments have to be part of the broker's price feed.
+func WithBenchmark(instrument string) Option {
+	return func(trader *Trader) {
+		trader.benchmark = benchmark.New(instrument)
+	}
+}
 
 func WithPersistTickData(persist bool) Option {
 	return func(trader *Trader) {
@@ -113,27 +255,6 @@ func WithCurrencyCode(currencyCode string) Option {
 	}
 }
 
-func WithFeedStoredCandles(strategy strategy.Strategy) Option {
-	return func(trader *Trader) {
-		var limit = int(strategy.GetWarmUpCandleAmount())
-		var candlePeriod = strategy.GetCandleDuration()
-
-		log.Infof("Searching for warmup candles with period %s", candlePeriod)
-
-		var candles ohlc.OHLCList
-		if err := trader.gormDB.Limit(limit).Order("\"end\" DESC").Where("instrument = ? AND duration = ?", trader.Instrument, candlePeriod).Find(&candles).Error; err != nil {
-			log.WithError(err).Fatal("fetching stored candles failed")
-		}
-		sort.Sort(candles)
-
-		log.Infof("WithFeedStoredCandles: Sending %d candles to strategy for warming up", len(candles))
-		for _, candle := range candles {
-			candle.ForceClose()
-			strategy.OnWarmUpCandle(&candle)
-		}
-	}
-}
-
 func New(ctx context.Context, instrument, gitRev string, db *gorm.DB, options ...Option) *Trader {
 	var clog = log.WithFields(log.Fields{
 		"INSTRUMENT": instrument,
@@ -145,85 +266,285 @@ func New(ctx context.Context, instrument, gitRev string, db *gorm.DB, options ..
 		StartTime:                 time.Now(),
 		clog:                      clog,
 		TickChan:                  make(chan tick.Tick),
+		done:                      make(chan struct{}),
+		today:                     make(map[string]*ohlc.OHLC),
+		aggregators:               make(map[string][]ohlc.Builder),
+		closedCandles:             make(map[candleKey]*candleHistory),
+		warmUpCandles:             make(map[candleKey]uint),
+		lastReceivedTick:          make(map[string]*tick.Tick),
+		previousTick:              make(map[string]*tick.Tick),
+		rejectedOrders:            make(map[string]int),
+		sessionEnd:                make(map[string]time.Time),
 		reversedPerformanceInPips: make(map[ohlc.OHLC]float64),
 		positionBuyTime:           make(map[string]time.Time),
 		closedPositionReferences:  make(map[string]bool),
 		gitRev:                    gitRev,
 		gormDB:                    db,
 		currencyCode:              "USD", // default
+		sizeFactor:                1,
+		tradingDay:                ohlc.DefaultTradingDay(),
+		events:                    event.NewBus(),
+		ownEvents:                 true,
 	}
 
 	for _, option := range options {
 		option(tr)
 	}
 
+	if tr.benchmark != nil {
+		tr.benchmark.SetDayOf(tr.tradingDay.Key)
+		if tr.benchmark.Instrument == "" {
+			tr.benchmark.Instrument = tr.Instrument
+		}
+		if tr.benchmark.Instrument != tr.Instrument && len(tr.instruments) == 0 {
+			// Ticks of the benchmark must not be traded
+			tr.instruments = map[string]bool{tr.Instrument: true}
+		}
+	}
+
+	if tr.strategy != nil {
+		for _, timeframe := range timeframes(tr.strategy) {
+			if err := barType(tr.strategy, timeframe).Validate(); err != nil {
+				log.WithError(err).Fatalf("Invalid bars of timeframe %s", timeframe)
+			}
+		}
+	}
+
+	if registry, ok := tr.broker.(broker.OwnerRegistry); ok && tr.owner != "" {
+		registry.RegisterOwner(tr.owner)
+	}
+
+	if tr.checkpoints {
+		if _, ok := tr.strategy.(strategy.Snapshotter); !ok {
+			tr.clog.Warnf("Strategy %s cannot be snapshotted, checkpoints are disabled", tr.strategy.Name())
+			tr.checkpoints = false
+		}
+	}
+
 	if tr.gormDB == nil {
 		if tr.persistTickData || tr.persistCandleData {
 			log.Fatalf("Persistence of ticks or/and candles requested but no DB given!")
 		}
+		if tr.checkpoints {
+			log.Fatalf("Checkpoints requested but no DB given!")
+		}
+		if tr.journal {
+			log.Fatalf("Journal requested but no DB given!")
+		}
 	} else {
-		if err := tr.gormDB.AutoMigrate(&ohlc.OHLC{}, &PerformanceRecord{}, &tick.Tick{}, &broker.Position{}); err != nil {
+		if err := tr.gormDB.AutoMigrate(&ohlc.OHLC{}, &PerformanceRecord{}, &tick.Tick{}, &broker.Position{}, &OrderRejection{}, &TradingHalt{}, &StrategySnapshot{}, &JournalEntry{}); err != nil {
 			log.WithError(err).Fatal("db.AutoMigrate() failed")
 		}
+		if err := tr.loadHalt(); err != nil {
+			log.WithError(err).Fatal("Cannot load trading halt")
+		}
+		if tr.checkpoints {
+			restored, err := tr.restoreCheckpoint()
+			if err != nil {
+				tr.clog.WithError(err).Warn("Cannot restore strategy from checkpoint, warming up instead")
+			}
+			tr.restored = restored
+		}
+		if tr.journal {
+			tr.subscribeJournal()
+		}
+	}
+
+	if len(tr.warmUpProviders) > 0 {
+		tr.warmUp(time.Now())
 	}
 
 	return tr
 }
 
 func (tr *Trader) ID() string {
-	return fmt.Sprintf("rev_%s_strategy_%s", tr.gitRev, tr.strategy.Name())
+	if tr.runID == "" {
+		return fmt.Sprintf("rev_%s_strategy_%s", tr.gitRev, tr.strategy.Name())
+	}
+	if tr.owner != "" {
+		// Several traders share the run ID in portfolios
+		return fmt.Sprintf("%s_%s", tr.runID, tr.owner)
+	}
+	if len(tr.instruments) > 0 {
+		return fmt.Sprintf("%s_%s", tr.runID, tr.Instrument)
+	}
+	return tr.runID
+}
+
+// Owner returns the owner of the trader's orders or an empty string
+func (tr *Trader) Owner() string {
+	return tr.owner
+}
+
+// Strategy returns the strategy of the trader
+func (tr *Trader) Strategy() strategy.Strategy {
+	return tr.strategy
+}
+
+// SetSizeFactor changes the factor for order sizes of the strategy, e.g. when the capital
+// allocation changes. A factor of zero stops opening positions.
+func (tr *Trader) SetSizeFactor(factor float64) {
+	tr.Lock()
+	defer tr.Unlock()
+	tr.sizeFactor = factor
+}
+
+// SizeFactor returns the factor for order sizes of the strategy
+func (tr *Trader) SizeFactor() float64 {
+	tr.Lock()
+	defer tr.Unlock()
+	return tr.sizeFactor
+}
+
+// owns checks if a position or order belongs to the trader
+func (tr *Trader) owns(instrument, owner string) bool {
+	return tr.HandlesInstrument(instrument) && (tr.owner == "" || tr.owner == owner)
 }
 
+// Start processes the ticks of the broker's price feed and blocks until the feed ended and the
+// trader is stopped
 func (tr *Trader) Start() error {
+	if err := tr.StartWithExternalFeed(); err != nil {
+		return err
+	}
+	tr.broker.ListenToPriceFeed(tr.TickChan)
+
+	return tr.Stop()
+}
+
+// StartWithExternalFeed starts processing ticks sent to TickChan without subscribing to the
+// broker's price feed. Used when one price feed is shared by several traders. The sender closes
+// TickChan when the feed ends.
+func (tr *Trader) StartWithExternalFeed() error {
+	tr.Lock()
 	if tr.running {
+		tr.Unlock()
 		return errors.New("already running")
 	}
 	tr.running = true
+	tr.Unlock()
 	tr.clog.Info("Starting trader")
 
 	go tr.receiveTicks()
-	tr.broker.ListenToPriceFeed(tr.TickChan)
+	if tr.candleTimer > 0 {
+		go tr.closeCandlesOnTime()
+	}
+	if tr.checkpoints && tr.checkpointInterval > 0 {
+		go tr.checkpointPeriodically()
+	}
+	if tr.feed != nil {
+		go tr.superviseFeed()
+	}
 
 	return nil
 }
 
+// HandlesInstrument checks if the trader is responsible for the given instrument
+func (tr *Trader) HandlesInstrument(instrument string) bool {
+	return len(tr.instruments) == 0 || tr.instruments[instrument] || tr.isBenchmark(instrument)
+}
+
+func (tr *Trader) isBenchmark(instrument string) bool {
+	return tr.benchmark != nil && tr.benchmark.Instrument == instrument
+}
+
+// Benchmark returns the benchmark series or nil if no benchmark is configured
+func (tr *Trader) Benchmark() *benchmark.Benchmark {
+	return tr.benchmark
+}
+
+// instrumentOf returns the instrument the given tick is accounted to. Returns false if the
+// trader is not responsible for the tick.
+func (tr *Trader) instrumentOf(currentTick tick.Tick) (string, bool) {
+	if len(tr.instruments) == 0 {
+		return tr.Instrument, true
+	}
+	return currentTick.Instrument, tr.instruments[currentTick.Instrument]
+}
+
+// Stop waits until the ticks of the closed TickChan are processed, saves a last checkpoint and
+// closes the event bus of the trader
 func (tr *Trader) Stop() error {
+	tr.Lock()
 	if !tr.running {
+		tr.Unlock()
 		return errors.New("already stopped")
 	}
+	tr.running = false
+	tr.Unlock()
 	tr.clog.Info("Stopping trader")
 
+	<-tr.done
+
 	tr.Lock()
 	defer tr.Unlock()
-
-	close(tr.TickChan)
-	tr.running = false
-	tr.printPositionPerformanceByNotes()
+	if tr.checkpoints {
+		if err := tr.checkpoint(); err != nil {
+			tr.clog.WithError(err).Error("Cannot save checkpoint")
+		}
+	}
+	if tr.ownEvents {
+		tr.events.Close()
+	}
 
 	return nil
 }
 
+// GetClosedPositions returns the closed positions of the trader. It must not be called while the
+// trader's lock is held, e.g. from a strategy callback.
 func (tr *Trader) GetClosedPositions() ([]broker.Position, error) {
+	tr.Lock()
+	defer tr.Unlock()
+	return tr.getClosedPositions()
+}
+
+func (tr *Trader) getClosedPositions() ([]broker.Position, error) {
 	positions, err := tr.broker.GetClosedPositions()
 	if err != nil {
 		return []broker.Position{}, err
 	}
-	for i := range positions {
-		positions[i].CandleBuyTime = tr.positionBuyTime[positions[i].Reference]
+	return tr.ownPositions(positions), nil
+}
+
+// ownPositions filters positions of foreign instruments and adds the trader's bookkeeping data
+func (tr *Trader) ownPositions(positions []broker.Position) []broker.Position {
+	var own []broker.Position
+	for _, position := range positions {
+		if !tr.owns(position.Instrument, position.Owner) {
+			continue
+		}
+		position.CandleBuyTime = tr.positionBuyTime[position.Reference]
+		own = append(own, position)
+	}
+	return own
+}
+
+func (tr *Trader) getOpenOrders() ([]broker.Order, error) {
+	orders, err := tr.broker.GetOpenOrders()
+	if err != nil {
+		return []broker.Order{}, err
+	}
+	var own []broker.Order
+	for _, order := range orders {
+		if tr.owns(order.Instrument, order.Owner) {
+			own = append(own, order)
+		}
 	}
-	return positions, nil
+	return own, nil
 }
 
-func (tr *Trader) processTodayCandle(currentTick tick.Tick) {
+func (tr *Trader) processTodayCandle(instrument string, currentTick tick.Tick) {
 	const eodPeriod = time.Hour * 24 * 1 // 1d
 
-	if tr.today == nil || tr.today.Start.Day() != currentTick.Datetime.Day() {
-		if tr.today != nil {
-			tr.today.ForceClose()
+	today := tr.today[instrument]
+	if today == nil || !tr.tradingDay.SameDay(today.Start, currentTick.Datetime) {
+		if today != nil {
+			today.ForceClose()
 		}
-		tr.today = ohlc.New(tr.Instrument, currentTick.Datetime, eodPeriod, false)
+		today = ohlc.New(instrument, tr.tradingDay.Begin(currentTick.Datetime), eodPeriod, false)
+		tr.today[instrument] = today
 	}
-	tr.today.NewPrice(currentTick.Bid, currentTick.Datetime)
+	today.NewPrice(currentTick.Bid, currentTick.Datetime)
 }
 
 func (tr *Trader) getOpenPositions() ([]broker.Position, error) {
@@ -231,10 +552,7 @@ func (tr *Trader) getOpenPositions() ([]broker.Position, error) {
 	if err != nil {
 		return []broker.Position{}, err
 	}
-	for i := range positions {
-		positions[i].CandleBuyTime = tr.positionBuyTime[positions[i].Reference]
-	}
-	return positions, nil
+	return tr.ownPositions(positions), nil
 }
 
 func (tr *Trader) persistTick(t tick.Tick) {
@@ -244,41 +562,54 @@ func (tr *Trader) persistTick(t tick.Tick) {
 }
 
 func (tr *Trader) receiveTicks() {
-	var locBerlin, err = time.LoadLocation("Europe/Berlin")
-	if err != nil {
-		log.WithError(err).Fatal("Unable to load Europe/Berlin timezone")
-	}
-
+	defer close(tr.done)
 	for currentTick := range tr.TickChan {
 		if tr.persistTickData {
 			go tr.persistTick(currentTick)
 		}
-		currentTick.Datetime = currentTick.Datetime.In(locBerlin)
+		currentTick.Datetime = currentTick.Datetime.In(tr.tradingDay.Location)
 
 		if err := currentTick.Validate(); err != nil {
 			tr.clog.WithError(err).Debugf("Invalid tick data received: %+v", currentTick)
 			continue
 		}
 
+		instrument, ok := tr.instrumentOf(currentTick)
+		if tr.isBenchmark(currentTick.Instrument) || (ok && tr.isBenchmark(instrument)) {
+			tr.benchmark.Add(currentTick.Datetime, currentTick.Price())
+		}
+		if !ok {
+			continue
+		}
+		if tr.calendar != nil && !tr.calendar.IsOpen(currentTick.Datetime) {
+			tr.clog.Debugf("Market is closed, skipping tick: %s", currentTick.String())
+			continue
+		}
+
 		//tr.clog.Debugf("New tick received %s", currentTick.String())
 		tr.Lock()
-		tr.processTodayCandle(currentTick)
-		tr.processTick(currentTick)
+		tr.processTodayCandle(instrument, currentTick)
+		tr.processTick(instrument, currentTick)
 		tr.Unlock()
 	}
 }
 
-func (tr *Trader) processTick(currentTick tick.Tick) {
-	var closedCandles = tr.processTickByOpenCandles(currentTick)
+func (tr *Trader) processTick(instrument string, currentTick tick.Tick) {
+	if tr.feed != nil {
+		tr.feedTick(instrument, currentTick)
+	}
+	tr.events.Publish(event.Tick{Header: tr.eventHeader(instrument, currentTick.Datetime), Tick: currentTick})
+
+	var closedCandles = tr.processTickByOpenCandles(instrument, currentTick)
 
 	tr.strategy.OnTick(currentTick)
 
 	for _, closedCandle := range closedCandles {
-		tr.processClosedCandle(closedCandle, currentTick)
+		tr.processClosedCandle(instrument, closedCandle, currentTick)
 	}
 }
 
-func (tr *Trader) processClosedCandle(closedCandle *ohlc.OHLC, currentTick tick.Tick) {
+func (tr *Trader) processClosedCandle(instrument string, closedCandle *ohlc.OHLC, currentTick tick.Tick) {
 	tr.clog.Debugf("Processing closed candle: %s", closedCandle)
 
 	if !closedCandle.HasPriceData() {
@@ -286,14 +617,16 @@ func (tr *Trader) processClosedCandle(closedCandle *ohlc.OHLC, currentTick tick.
 		return
 	}
 
-	if tr.strategy.GetCandleDuration() != closedCandle.Duration {
+	if !tr.isTimeframe(closedCandle.Duration) || tr.warmingUp(instrument, closedCandle) {
 		return
 	}
+	tr.candle = closedCandle
+	defer func() { tr.candle = nil }()
 
 	// Orders
-	openOrders, err := tr.broker.GetOpenOrders()
+	openOrders, err := tr.getOpenOrders()
 	if err != nil {
-		tr.clog.WithError(err).Error("Cannot get open orders")
+		tr.reportError(instrument, currentTick.Datetime, err, "Cannot get open orders")
 		return
 	}
 	tr.strategy.OnOrder(openOrders)
@@ -301,33 +634,33 @@ func (tr *Trader) processClosedCandle(closedCandle *ohlc.OHLC, currentTick tick.
 	// Positions
 	openPositions, err := tr.getOpenPositions()
 	if err != nil {
-		tr.clog.WithError(err).Error("Cannot get open positions")
+		tr.reportError(instrument, currentTick.Datetime, err, "Cannot get open positions")
 		return
 	}
-	closedPositions, err := tr.GetClosedPositions()
+	closedPositions, err := tr.getClosedPositions()
 	if err != nil {
-		tr.clog.WithError(err).Error("Cannot get closed positions")
+		tr.reportError(instrument, currentTick.Datetime, err, "Cannot get closed positions")
 		return
 	}
 	tr.detectClosedPositions(closedPositions)
 	tr.processOpenPositions(closedCandle, openPositions)
+	tr.updateEquity(currentTick, openPositions, closedPositions)
 	tr.strategy.OnPosition(openPositions, closedPositions)
 
 	// Candle
-	toOpen, toClose, toClosePositions := tr.strategy.OnCandle(tr.closedCandles)
-	tr.processClosableOrders(toClose)
-	tr.processClosablePositions(toClosePositions)
-	tr.processOrders(closedCandle, currentTick, toOpen)
+	toOpen, toClose, toClosePositions := tr.onCandle(instrument, closedCandle)
+	tr.processClosableOrders(currentTick.Datetime, toClose)
+	tr.processClosablePositions(currentTick.Datetime, toClosePositions)
+	tr.processOrders(instrument, currentTick, openPositions, closedPositions, toOpen)
 
-	for _, subscriber := range tr.candleSubscribers {
-		subscriber.OnCandle(*closedCandle)
-	}
+	tr.events.Publish(event.Candle{Header: tr.eventHeader(instrument, currentTick.Datetime), Candle: *closedCandle})
 }
 
-func (tr *Trader) processClosableOrders(orders []broker.Order) {
+func (tr *Trader) processClosableOrders(now time.Time, orders []broker.Order) {
 	for _, order := range orders {
 		if err := tr.broker.CancelOrder(order.ID); err != nil {
-			tr.clog.WithError(err).WithFields(log.Fields{"OrderID": order.ID}).Error("Unable to cancel order")
+			tr.reportError(order.Instrument, now, err, "Unable to cancel order "+order.ID)
+			continue
 		}
 	}
 }
@@ -337,101 +670,186 @@ func (tr *Trader) processOpenPositions(candle *ohlc.OHLC, openPositions []broker
 		_, exists := tr.positionBuyTime[openPosition.Reference]
 		if !exists {
 			tr.positionBuyTime[openPosition.Reference] = candle.Start
+			tr.openPosition(openPosition)
 		}
 	}
 }
 
-func (tr *Trader) processClosablePositions(toClose []broker.Position) {
+func (tr *Trader) processClosablePositions(now time.Time, toClose []broker.Position) {
 	for _, position := range toClose {
 		if err := tr.broker.Sell(position); err != nil {
-			tr.clog.WithError(err).WithFields(log.Fields{"Reference": position.Reference}).Error("Unable to sell position")
+			tr.reportError(position.Instrument, now, err, "Unable to sell position "+position.Reference)
+			continue
 		}
 	}
 }
 
 // processOrders - Execute order and open new positions
-func (tr *Trader) processOrders(candle *ohlc.OHLC, currentTick tick.Tick, toOpen []broker.Order) {
+func (tr *Trader) processOrders(instrument string, currentTick tick.Tick, openPositions, closedPositions []broker.Position, toOpen []broker.Order) {
+	var state = RiskState{
+		Tick:            currentTick,
+		PreviousTick:    tr.previousTick[instrument],
+		OpenPositions:   openPositions,
+		ClosedPositions: closedPositions,
+		TradingDay:      tr.tradingDay,
+	}
+
 	for _, order := range toOpen {
 		order.CurrencyCode = tr.currencyCode
+		order.Owner = tr.owner
+		if tr.sizer != nil {
+			size, err := tr.orderSize(instrument, order, currentTick, closedPositions)
+			if err != nil {
+				tr.clog.WithError(err).Infof("Cannot size order: %s", order.String())
+				continue
+			}
+			order.Size = size
+		} else {
+			order.Size *= tr.sizeFactor
+		}
+		if order.Size <= 0 {
+			tr.clog.Debugf("Skipping order without capital allocation: %s", order.String())
+			continue
+		}
+		if rejection := tr.checkRisk(order, state); rejection != nil {
+			tr.rejectOrder(order, *rejection)
+			continue
+		}
 
-		_, err := tr.broker.Buy(order)
+		orderID, err := tr.broker.Buy(order)
 		if err != nil {
-			tr.clog.WithError(err).Errorf("Unable to open position: %+v", order)
+			tr.reportError(instrument, currentTick.Datetime, err, fmt.Sprintf("Unable to open position: %+v", order))
 			continue
 		}
+		state.Accepted = append(state.Accepted, order)
 
 		tr.clog.Infof("Got new order: %s", order.String())
 
-		for _, subscriber := range tr.orderSubscribers {
-			subscriber.OnOrder(order)
-		}
+		order.ID = orderID
+		tr.events.Publish(event.Order{Header: tr.eventHeader(instrument, currentTick.Datetime), Order: order})
 	}
 }
 
-func (tr *Trader) processTickByOpenCandles(currentTick tick.Tick) (closedCandles []*ohlc.OHLC) {
-	var stillOpenCandles []*ohlc.OHLC
-
-	defer func() {
-		lastReceivedTick := currentTick
-		tr.lastReceivedTick = &lastReceivedTick
-		tr.openCandles = stillOpenCandles
-	}()
-
-	if len(tr.openCandles) == 0 {
-		candle := ohlc.New(tr.Instrument, currentTick.Datetime, tr.strategy.GetCandleDuration(), true)
-		tr.openCandles = append(tr.openCandles, candle)
-
-		if tr.persistCandleData && tr.strategy.GetCandleDuration() != time.Hour*24 {
-			candle := ohlc.New(tr.Instrument, currentTick.Datetime, time.Hour*24, true)
-			tr.openCandles = append(tr.openCandles, candle)
+func (tr *Trader) processTickByOpenCandles(instrument string, currentTick tick.Tick) (closedCandles []*ohlc.OHLC) {
+	var aggregators = tr.aggregators[instrument]
+	if len(aggregators) == 0 {
+		for _, duration := range tr.candleDurations() {
+			aggregators = append(aggregators, tr.newBuilder(instrument, duration))
 		}
+		tr.aggregators[instrument] = aggregators
 	}
 
-	for _, candle := range tr.openCandles {
-		switch candle.Duration {
-		case time.Hour * 24:
-			if tr.lastReceivedTick != nil && tr.lastReceivedTick.Datetime.Day() != currentTick.Datetime.Day() {
-				candle.ForceClose()
+	var sessionChanged = tr.sessionChanged(instrument, currentTick.Datetime)
+	for _, aggregator := range aggregators {
+		if sessionChanged {
+			if candle := aggregator.ForceClose(); candle != nil {
+				closedCandles = append(closedCandles, candle)
 			}
-		case time.Hour:
-			if tr.lastReceivedTick != nil && tr.lastReceivedTick.Datetime.Hour() != currentTick.Datetime.Hour() {
-				candle.ForceClose()
+		}
+		closedCandles = append(closedCandles, aggregator.AddTick(currentTick)...)
+	}
+	for _, candle := range closedCandles {
+		tr.closeCandle(instrument, candle)
+	}
+
+	tr.previousTick[instrument] = tr.lastReceivedTick[instrument]
+	tr.lastReceivedTick[instrument] = &currentTick
+	return
+}
+
+// closeCandlesOnTime closes candles at their end even if no tick arrives, e.g. in a quiet market
+func (tr *Trader) closeCandlesOnTime() {
+	ticker := time.NewTicker(tr.candleTimer)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-tr.ctx.Done():
+			return
+		case now := <-ticker.C:
+			tr.Lock()
+			if !tr.running {
+				tr.Unlock()
+				return
 			}
+			tr.advanceCandles(now)
+			tr.Unlock()
 		}
+	}
+}
 
-		isOpen := candle.NewPrice(currentTick.Price(), currentTick.Datetime)
-		if isOpen {
-			stillOpenCandles = append(stillOpenCandles, candle)
+// advanceCandles closes the candles which ended before now and processes them with the last tick
+// of their instrument. Candles are closed early when the market closed.
+func (tr *Trader) advanceCandles(now time.Time) {
+	now = now.In(tr.tradingDay.Location)
+	for instrument, aggregators := range tr.aggregators {
+		var lastTick = tr.lastReceivedTick[instrument]
+		if lastTick == nil || now.Before(lastTick.Datetime) {
 			continue
 		}
+		var marketClosed = tr.calendar != nil && !tr.calendar.IsOpen(now)
+
+		var closedCandles []*ohlc.OHLC
+		for _, aggregator := range aggregators {
+			if marketClosed {
+				if candle := aggregator.ForceClose(); candle != nil {
+					closedCandles = append(closedCandles, candle)
+				}
+				continue
+			}
+			closedCandles = append(closedCandles, aggregator.Advance(now)...)
+		}
+		for _, candle := range closedCandles {
+			tr.closeCandle(instrument, candle)
+		}
+		for _, candle := range closedCandles {
+			tr.processClosedCandle(instrument, candle, *lastTick)
+		}
+	}
+}
 
-		newCandle := tr.closeCandle(currentTick, candle)
-		stillOpenCandles = append(stillOpenCandles, newCandle)
-		closedCandles = append(closedCandles, candle)
+// sessionChanged tells if the tick's time is in another session than the previous tick of the
+// instrument. Candles must not span several sessions.
+func (tr *Trader) sessionChanged(instrument string, now time.Time) bool {
+	if tr.calendar == nil {
+		return false
 	}
-	return
+	end, known := tr.sessionEnd[instrument]
+	if known && now.Before(end) {
+		return false
+	}
+	_, tr.sessionEnd[instrument], _ = tr.calendar.Session(now)
+	return known
 }
 
-func (tr *Trader) closeCandle(tick tick.Tick, candle *ohlc.OHLC) (newCandle *ohlc.OHLC) {
-	tr.closedCandles = append(tr.closedCandles, candle)
+// newAggregator builds the candles of the timeframe aligned to the trading day or the anchor
+func (tr *Trader) newAggregator(instrument string, duration time.Duration) *ohlc.Aggregator {
+	return ohlc.NewAggregator(instrument, duration, tr.tradingDay, tr.aggregatorOptions...)
+}
 
-	var candlesToKeep = 100
-	if len(tr.closedCandles) > candlesToKeep {
-		tr.closedCandles = tr.closedCandles[len(tr.closedCandles)-candlesToKeep:]
+// newBuilder builds the bars of the timeframe if the strategy selected a bar type, otherwise candles
+func (tr *Trader) newBuilder(instrument string, duration time.Duration) ohlc.Builder {
+	if !tr.isBar(duration) {
+		return tr.newAggregator(instrument, duration)
+	}
+	builder, err := ohlc.NewBuilder(instrument, duration, tr.tradingDay, barType(tr.strategy, duration))
+	if err != nil {
+		tr.clog.WithError(err).Errorf("Cannot build bars of timeframe %s, building candles", duration)
+		return tr.newAggregator(instrument, duration)
 	}
+	return builder
+}
+
+func (tr *Trader) closeCandle(instrument string, candle *ohlc.OHLC) {
+	tr.addClosedCandle(instrument, candle)
 
-	if tr.gormDB != nil && tr.persistCandleData {
+	if tr.gormDB != nil && tr.persistCandleData && !tr.isBar(candle.Duration) {
 		go func() {
 			if err := candle.Store(tr.gormDB); err != nil {
 				tr.clog.WithError(err).Errorf("Failed to store OHLC: %+v", candle)
 			}
 		}()
 	}
-
-	// Replace closed OHLC from openOHLCs list
-	openCandle := ohlc.New(candle.Instrument, tick.Datetime, candle.Duration, true)
-	openCandle.NewPrice(tick.Price(), tick.Datetime)
-	return openCandle
 }
 
 func (tr *Trader) detectClosedPositions(brokerClosedPositions []broker.Position) {
@@ -445,7 +863,41 @@ func (tr *Trader) detectClosedPositions(brokerClosedPositions []broker.Position)
 }
 
 func (tr *Trader) closePosition(position broker.Position) {
-	for _, subscriber := range tr.positionSubscribers {
-		subscriber.OnPosition(position)
+	if _, seen := tr.positionBuyTime[position.Reference]; !seen {
+		// Opened and closed between two candles
+		tr.openPosition(position)
 	}
+
+	var header = tr.eventHeader(position.Instrument, position.SellTime)
+	tr.events.Publish(event.Fill{Header: header, Reference: position.Reference, Direction: position.BuyDirection,
+		Price: position.SellPrice, Size: position.Size})
+	tr.events.Publish(event.PositionClosed{Header: header, Position: position})
+}
+
+func (tr *Trader) openPosition(position broker.Position) {
+	var header = tr.eventHeader(position.Instrument, position.BuyTime)
+	tr.events.Publish(event.Fill{Header: header, Reference: position.Reference, Direction: position.BuyDirection,
+		Price: position.BuyPrice, Size: position.Size, Entry: true})
+	tr.events.Publish(event.PositionOpened{Header: header, Position: position})
+}
+
+// Events returns the bus on which the trader publishes ticks, candles, orders, fills, positions,
+// rejections and errors. Subscribe before Start.
+func (tr *Trader) Events() *event.Bus {
+	return tr.events
+}
+
+func (tr *Trader) eventHeader(instrument string, t time.Time) event.Header {
+	var header = event.Header{Time: t, Instrument: instrument, Owner: tr.owner}
+	if tr.candle != nil {
+		header.CandleStart = tr.candle.Start
+		header.CandleDuration = tr.candle.Duration
+	}
+	return header
+}
+
+// reportError logs and publishes an error which doesn't stop the trader
+func (tr *Trader) reportError(instrument string, t time.Time, err error, message string) {
+	tr.clog.WithError(err).Error(message)
+	tr.events.Publish(event.Error{Header: tr.eventHeader(instrument, t), Message: message, Err: err})
 }
@@ -1,9 +1,16 @@
 package trader
 
 import (
+	"context"
+	"fmt"
 	"github.com/AMekss/assert"
 	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/internal/calendar"
+	"github.com/sklinkert/at/internal/event"
+	"github.com/sklinkert/at/pkg/ohlc"
 	"github.com/sklinkert/at/pkg/tick"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -31,3 +38,161 @@ func Test__distanceInPercentage(t *testing.T) {
 	price2 = decimal.NewFromFloat(2)
 	assert.EqualStrings(t, "100", distanceInPercentage(price1, price2).String())
 }
+
+// orderBroker records the orders and sold positions it receives and returns the given closed positions
+type orderBroker struct {
+	noopBroker
+	orders          []broker.Order
+	sold            []broker.Position
+	closedPositions []broker.Position
+}
+
+func (b *orderBroker) Sell(position broker.Position) error {
+	b.sold = append(b.sold, position)
+	return nil
+}
+
+func (b *orderBroker) Buy(order broker.Order) (string, error) {
+	b.orders = append(b.orders, order)
+	return strconv.Itoa(len(b.orders)), nil
+}
+
+func (b *orderBroker) GetClosedPositions() ([]broker.Position, error) {
+	return b.closedPositions, nil
+}
+
+func TestTrader_owner(t *testing.T) {
+	var b = &orderBroker{closedPositions: []broker.Position{
+		{Instrument: "EURUSD", Owner: "sma10/EURUSD"},
+		{Instrument: "EURUSD", Owner: "rsi/EURUSD"},
+		{Instrument: "GBPUSD", Owner: "sma10/EURUSD"},
+	}}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b),
+		WithInstruments("EURUSD"), WithOwner("sma10/EURUSD"), WithSizeFactor(0.5))
+
+	positions, err := tr.GetClosedPositions()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 1, len(positions))
+	assert.EqualStrings(t, "sma10/EURUSD", positions[0].Owner)
+
+	tr.processOrders("EURUSD", tick.Tick{}, nil, nil, []broker.Order{{Instrument: "EURUSD", Size: 2}})
+	tr.SetSizeFactor(0)
+	tr.processOrders("EURUSD", tick.Tick{}, nil, nil, []broker.Order{{Instrument: "EURUSD", Size: 2}})
+	assert.EqualInt(t.Fatalf, 1, len(b.orders))
+	assert.EqualFloat64(t, 1, b.orders[0].Size)
+	assert.EqualStrings(t, "sma10/EURUSD", b.orders[0].Owner)
+}
+
+func TestTrader_calendar(t *testing.T) {
+	var day = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var cal = calendar.New("test", time.UTC,
+		calendar.WithSessions(time.Hour*9, time.Hour*9+time.Minute*7, time.Monday),
+		calendar.WithSessions(time.Hour*9+time.Minute*10, time.Hour*10, time.Monday))
+	var s = newTimeframeStrategy(0)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s), WithCalendar(cal))
+
+	go func() {
+		for i := 0; i <= 20; i++ {
+			price := decimal.NewFromFloat(1.0 + float64(i)/1000)
+			tr.TickChan <- tick.New("EURUSD", day.Add(time.Hour*9+time.Minute*time.Duration(i)), price, price)
+		}
+		close(tr.TickChan)
+	}()
+	tr.receiveTicks()
+
+	// The ticks at 09:07 - 09:09 are skipped and the session end at 09:07 closes all candles
+	var fiveMinutes = tr.history("EURUSD", time.Minute*5)
+	var hours = tr.history("EURUSD", time.Hour)
+	assert.EqualInt(t.Fatalf, 4, len(fiveMinutes))
+	assert.EqualInt(t.Fatalf, 1, len(hours))
+	assert.True(t, fiveMinutes[1].End.Equal(day.Add(time.Hour*9+time.Minute*6)))
+	assert.True(t, hours[0].End.Equal(day.Add(time.Hour*9+time.Minute*6)))
+	assert.True(t, fiveMinutes[2].Start.Equal(day.Add(time.Hour*9+time.Minute*10)))
+
+	var state = RiskState{Tick: tick.New("EURUSD", day.Add(time.Hour*12), decimal.NewFromFloat(1), decimal.NewFromFloat(1))}
+	var rejection = tr.checkRisk(broker.Order{Instrument: "EURUSD", Size: 1}, state)
+	assert.True(t.Fatalf, rejection != nil)
+	assert.EqualStrings(t, RuleMarketHours, rejection.Rule)
+	assert.EqualStrings(t, "test is closed at Mon 12:00 UTC", rejection.Reason)
+}
+
+func TestTrader_tradingDay(t *testing.T) {
+	day, err := ohlc.NewTradingDay("America/New_York", "17:00")
+	assert.NoError(t.Fatalf, err)
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithTradingDay(day))
+
+	var newYorkClose = time.Date(2021, 1, 4, 22, 0, 0, 0, time.UTC)
+	var price = decimal.NewFromFloat(1)
+	tr.processTodayCandle("EURUSD", tick.New("EURUSD", newYorkClose.Add(-time.Minute), price, price))
+	var yesterday = tr.today["EURUSD"]
+	assert.EqualTime(t, newYorkClose.AddDate(0, 0, -1), yesterday.Start.UTC())
+
+	tr.processTodayCandle("EURUSD", tick.New("EURUSD", newYorkClose, price, price))
+	assert.True(t, yesterday.Closed())
+	assert.EqualTime(t, newYorkClose, tr.today["EURUSD"].Start.UTC())
+	assert.EqualTime(t, newYorkClose, tr.newAggregator("EURUSD", time.Hour*24).Start(newYorkClose.Add(time.Hour*3)).UTC())
+}
+
+func TestTrader_events(t *testing.T) {
+	var now = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var b = &orderBroker{closedPositions: []broker.Position{
+		{Reference: "1", Instrument: "EURUSD", BuyTime: now, SellTime: now.Add(time.Minute)},
+	}}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b), WithStrategy(newTimeframeStrategy(0)),
+		WithRiskRules(MaxOrderSize(1)))
+
+	var events = map[string]int{}
+	event.Subscribe(tr.Events(), "test", func(e event.Event) {
+		events[fmt.Sprintf("%T", e)]++
+	})
+	var fills []event.Fill
+	event.Subscribe(tr.Events(), "fills", func(e event.Fill) {
+		fills = append(fills, e)
+	})
+
+	feedMinuteTicks(tr, now, 60)
+	tr.processOrders("EURUSD", newRiskTick(now, 1), nil, nil, []broker.Order{
+		{Instrument: "EURUSD", Size: 1},
+		{Instrument: "EURUSD", Size: 2},
+	})
+
+	assert.EqualInt(t, 61, events["event.Tick"])
+	assert.EqualInt(t, 13, events["event.Candle"])
+	assert.EqualInt(t, 1, events["event.PositionOpened"])
+	assert.EqualInt(t, 1, events["event.PositionClosed"])
+	assert.EqualInt(t, 1, events["event.Order"])
+	assert.EqualInt(t, 1, events["event.Rejection"])
+	assert.EqualInt(t.Fatalf, 2, len(fills))
+	assert.True(t, fills[0].Entry)
+	assert.True(t, !fills[1].Entry)
+}
+
+// feedBroker sends minute ticks to the trader and ends its feed afterwards
+type feedBroker struct {
+	noopBroker
+	from  time.Time
+	ticks int
+}
+
+func (b feedBroker) ListenToPriceFeed(c chan tick.Tick) {
+	for i := 0; i < b.ticks; i++ {
+		price := decimal.NewFromFloat(1.0 + float64(i)/1000)
+		c <- tick.New("EURUSD", b.from.Add(time.Minute*time.Duration(i)), price, price)
+	}
+	close(c)
+}
+
+func TestTrader_Start(t *testing.T) {
+	var b = feedBroker{from: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), ticks: 100}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(b), WithStrategy(newTimeframeStrategy(0)))
+	var received int
+	event.Subscribe(tr.Events(), "test", func(event.Tick) {
+		time.Sleep(time.Millisecond)
+		received++
+	}, event.Async(1))
+
+	// Start returns after all ticks are processed and the subscribers received their events
+	assert.NoError(t.Fatalf, tr.Start())
+	assert.EqualInt(t, 100, received)
+	assert.ErrorIncludesMessage(t, "already stopped", tr.Stop())
+}
@@ -0,0 +1,105 @@
+package trader
+
+import (
+	"github.com/sklinkert/at/internal/strategy"
+	"github.com/sklinkert/at/internal/warmup"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"time"
+)
+
+// WithFeedStoredCandles warms up the strategy with the candles stored in the DB and loads them into
+// the candle history
+func WithFeedStoredCandles(s strategy.Strategy) Option {
+	return func(trader *Trader) {
+		trader.warmUpStrategy = s
+		if trader.gormDB != nil {
+			trader.warmUpProviders = append(trader.warmUpProviders, warmup.DB(trader.gormDB))
+		}
+	}
+}
+
+// WithWarmUp adds providers of warm-up candles, e.g. the broker's price history. They are asked in
+// the order of the options, so later providers fill the gap between the stored candles and now.
+func WithWarmUp(providers ...warmup.Provider) Option {
+	return func(trader *Trader) {
+		trader.warmUpProviders = append(trader.warmUpProviders, providers...)
+	}
+}
+
+// WarmUpReports returns how complete the warm-up of each timeframe was
+func (tr *Trader) WarmUpReports() []warmup.Report {
+	return tr.warmUpReports
+}
+
+// warmUp loads the candles of each timeframe until now into the history and sends the most recent
+// ones to the strategy for warming up. A strategy restored from a checkpoint only gets the candles
+// which ended after its snapshot and candles restored with the snapshot aren't loaded again. Bars
+// aren't stored and can't be loaded.
+func (tr *Trader) warmUp(now time.Time) {
+	var s = tr.warmUpStrategy
+	if s == nil {
+		s = tr.strategy
+	}
+	if s == nil {
+		return
+	}
+	multi, isMulti := s.(strategy.MultiTimeframe)
+	var durations = []time.Duration{s.GetCandleDuration()}
+	if isMulti {
+		durations = timeframes(s)
+	}
+
+	for _, timeframe := range durations {
+		if barType(s, timeframe).Kind != ohlc.BarTime {
+			continue
+		}
+		var warmUp = s.GetWarmUpCandleAmount()
+		if isMulti {
+			warmUp = multi.GetTimeframeWarmUpCandleAmount(timeframe)
+		}
+		var amount = warmUp
+		if size := uint(tr.historySize(timeframe)); size > amount {
+			amount = size
+		}
+
+		candles, report := warmup.Load(tr.warmUpProviders, tr.Instrument, timeframe, now, tr.tradingDay, int(amount))
+		tr.warmUpReports = append(tr.warmUpReports, report)
+		for _, err := range report.Errors {
+			tr.clog.WithError(err).Warn("Warm-up provider failed")
+		}
+		if report.Complete() {
+			tr.clog.Infof("Warm-up complete: %s", report)
+		} else {
+			tr.clog.Warnf("Warm-up incomplete: %s", report)
+		}
+
+		var restoredHistory = tr.history(tr.Instrument, timeframe)
+		var loaded bool
+		for i := range candles {
+			candle := &candles[i]
+			if len(restoredHistory) > 0 && !candle.End.After(restoredHistory[len(restoredHistory)-1].End) {
+				continue
+			}
+			loaded = true
+			tr.addClosedCandle(tr.Instrument, candle)
+			if tr.restored {
+				// The restored strategy only misses the candles after its snapshot
+				if !candle.End.After(tr.restoredUntil) {
+					continue
+				}
+			} else if uint(len(candles)-i) > warmUp {
+				continue
+			}
+			if isMulti {
+				multi.OnWarmUpTimeframeCandle(timeframe, candle)
+				tr.warmUpCandles[candleKey{instrument: tr.Instrument, duration: timeframe}]++
+			} else {
+				s.OnWarmUpCandle(candle)
+			}
+		}
+		if loaded && tr.restored {
+			// The open candle of the snapshot ended while the trader was down
+			tr.resetBuilder(tr.Instrument, timeframe)
+		}
+	}
+}
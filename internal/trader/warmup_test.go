@@ -0,0 +1,57 @@
+package trader
+
+import (
+	"context"
+	"github.com/AMekss/assert"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+// candleProvider provides candles of any duration beginning at from
+type candleProvider struct {
+	from time.Time
+}
+
+func (p candleProvider) Name() string {
+	return "test"
+}
+
+func (p candleProvider) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	var candles []ohlc.OHLC
+	for start := p.from; !start.Add(duration).After(to); start = start.Add(duration) {
+		var candle = ohlc.New(instrument, start, duration, false)
+		candle.End = start.Add(duration)
+		candles = append(candles, *candle)
+	}
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+	return candles, nil
+}
+
+func TestTrader_warmUp(t *testing.T) {
+	var s = historyStrategy{newTimeframeStrategy(2)}
+	var tr = New(context.Background(), "EURUSD", "", nil, WithBroker(noopBroker{}), WithStrategy(s))
+
+	var from = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var now = from.Add(time.Hour*3 + time.Minute*30)
+	tr.warmUpProviders = append(tr.warmUpProviders, candleProvider{from: from})
+	tr.warmUp(now)
+
+	assert.EqualInt(t, 2, s.warmUpCandles[time.Hour])
+	assert.EqualInt(t, 0, s.warmUpCandles[time.Minute*5])
+	assert.EqualInt(t, 2, len(tr.history("EURUSD", time.Hour)))
+	assert.EqualInt(t, 42, len(tr.history("EURUSD", time.Minute*5)))
+
+	var reports = tr.WarmUpReports()
+	assert.EqualInt(t.Fatalf, 2, len(reports))
+	assert.True(t, reports[0].Duration == time.Hour && reports[0].Complete())
+	assert.True(t, reports[1].Duration == time.Minute*5 && !reports[1].Complete())
+	assert.EqualInt(t, 150, reports[1].Requested)
+
+	// The strategy is warmed up and receives the next hourly candle with the loaded ones
+	feedMinuteTicks(tr, now, 30)
+	assert.EqualInt(t.Fatalf, 1, len(s.candles[time.Hour]))
+	assert.EqualInt(t, 2, s.candles[time.Hour][0])
+}
@@ -0,0 +1,92 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MethodHoldOut = "holdout" // in-sample and out-of-sample segment
+	MethodKFold   = "kfold"   // k consecutive, purged folds
+)
+
+const (
+	SegmentInSample    = "in-sample"
+	SegmentOutOfSample = "out-of-sample"
+)
+
+// Segment is a part of the backtesting period which is run on its own
+type Segment struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+// Split splits the period with the given method
+func Split(method string, from, to time.Time, outOfSamplePercent float64, folds int, purge time.Duration) ([]Segment, error) {
+	switch method {
+	case MethodHoldOut:
+		return HoldOut(from, to, outOfSamplePercent, purge)
+	case MethodKFold:
+		return KFold(from, to, folds, purge)
+	default:
+		return nil, fmt.Errorf("unknown validation method %q", method)
+	}
+}
+
+// HoldOut splits the period into an in-sample segment and an out-of-sample segment at the end of
+// the period. The out-of-sample segment starts after the purge gap.
+func HoldOut(from, to time.Time, outOfSamplePercent float64, purge time.Duration) ([]Segment, error) {
+	if outOfSamplePercent <= 0 || outOfSamplePercent >= 100 {
+		return nil, fmt.Errorf("out-of-sample percent must be between 0 and 100, got %.2f", outOfSamplePercent)
+	}
+	if !to.After(from) {
+		return nil, errors.New("period end must be after its start")
+	}
+
+	var period = to.Sub(from)
+	var split = from.Add(time.Duration(float64(period) * (100 - outOfSamplePercent) / 100))
+	if !split.Add(purge).Before(to) {
+		return nil, fmt.Errorf("purge %s exceeds out-of-sample segment", purge)
+	}
+
+	return []Segment{
+		{Name: SegmentInSample, From: from, To: split},
+		{Name: SegmentOutOfSample, From: split.Add(purge), To: to},
+	}, nil
+}
+
+// KFold splits the period into k consecutive folds of the same length. Every fold but the first
+// starts after the purge gap so no position or indicator state leaks from the previous fold.
+func KFold(from, to time.Time, k int, purge time.Duration) ([]Segment, error) {
+	if k < 2 {
+		return nil, fmt.Errorf("at least 2 folds required, got %d", k)
+	}
+	if !to.After(from) {
+		return nil, errors.New("period end must be after its start")
+	}
+
+	var foldLength = to.Sub(from) / time.Duration(k)
+	if purge >= foldLength {
+		return nil, fmt.Errorf("purge %s exceeds fold length %s", purge, foldLength)
+	}
+
+	var segments []Segment
+	for i := 0; i < k; i++ {
+		var segment = Segment{
+			Name: fmt.Sprintf("fold-%d", i+1),
+			From: from.Add(foldLength * time.Duration(i)),
+			To:   from.Add(foldLength * time.Duration(i+1)),
+		}
+		if i > 0 {
+			segment.From = segment.From.Add(purge)
+		}
+		if i == k-1 {
+			segment.To = to
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+	"time"
+)
+
+var (
+	periodFrom = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	periodTo   = time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)
+)
+
+func TestHoldOut(t *testing.T) {
+	segments, err := HoldOut(periodFrom, periodTo, 30, time.Hour)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 2, len(segments))
+
+	assert.EqualStrings(t, SegmentInSample, segments[0].Name)
+	assert.EqualTime(t, periodFrom, segments[0].From)
+	assert.EqualTime(t, time.Date(2021, 1, 8, 0, 0, 0, 0, time.UTC), segments[0].To)
+
+	assert.EqualStrings(t, SegmentOutOfSample, segments[1].Name)
+	assert.EqualTime(t, time.Date(2021, 1, 8, 1, 0, 0, 0, time.UTC), segments[1].From)
+	assert.EqualTime(t, periodTo, segments[1].To)
+
+	_, err = HoldOut(periodFrom, periodTo, 100, 0)
+	assert.ErrorIncludesMessage(t, "between 0 and 100", err)
+
+	_, err = HoldOut(periodFrom, periodTo, 10, time.Hour*24)
+	assert.ErrorIncludesMessage(t, "exceeds out-of-sample segment", err)
+}
+
+func TestKFold(t *testing.T) {
+	segments, err := KFold(periodFrom, periodTo, 5, time.Hour)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 5, len(segments))
+
+	assert.EqualStrings(t, "fold-1", segments[0].Name)
+	assert.EqualTime(t, periodFrom, segments[0].From)
+	assert.EqualTime(t, time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC), segments[0].To)
+
+	assert.EqualStrings(t, "fold-2", segments[1].Name)
+	assert.EqualTime(t, time.Date(2021, 1, 3, 1, 0, 0, 0, time.UTC), segments[1].From)
+	assert.EqualTime(t, time.Date(2021, 1, 5, 0, 0, 0, 0, time.UTC), segments[1].To)
+
+	assert.EqualTime(t, periodTo, segments[4].To)
+
+	_, err = KFold(periodFrom, periodTo, 1, 0)
+	assert.ErrorIncludesMessage(t, "at least 2 folds", err)
+
+	_, err = KFold(periodFrom, periodTo, 5, time.Hour*48)
+	assert.ErrorIncludesMessage(t, "exceeds fold length", err)
+}
+
+func TestSplit(t *testing.T) {
+	_, err := Split("walkforward", periodFrom, periodTo, 30, 5, 0)
+	assert.ErrorIncludesMessage(t, "unknown validation method", err)
+
+	segments, err := Split(MethodKFold, periodFrom, periodTo, 30, 3, 0)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t, 3, len(segments))
+}
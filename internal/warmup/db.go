@@ -0,0 +1,32 @@
+package warmup
+
+import (
+	"github.com/sklinkert/at/pkg/ohlc"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+type dbProvider struct {
+	db *gorm.DB
+}
+
+// DB provides the candles stored in the price DB, e.g. by traders persisting their candles
+func DB(db *gorm.DB) Provider {
+	return dbProvider{db: db}
+}
+
+func (p dbProvider) Name() string {
+	return "db"
+}
+
+func (p dbProvider) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	var candles ohlc.OHLCList
+	if err := p.db.Limit(limit).Order("\"end\" DESC").
+		Where("instrument = ? AND duration = ? AND \"end\" <= ?", instrument, duration, to.UTC()).
+		Find(&candles).Error; err != nil {
+		return nil, err
+	}
+	sort.Sort(candles)
+	return candles, nil
+}
@@ -0,0 +1,118 @@
+package warmup
+
+import (
+	"fmt"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provider returns closed candles for warming up strategies, e.g. from the price DB or the broker
+type Provider interface {
+	// Name identifies the provider in reports
+	Name() string
+
+	// Candles returns up to limit candles of the duration which ended before or at to, oldest first
+	Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error)
+}
+
+// Report tells how complete the warm-up of a timeframe was
+type Report struct {
+	Instrument string
+	Duration   time.Duration
+	Requested  int
+	Loaded     int
+	Sources    map[string]int // provider name -> number of loaded candles
+	LastEnd    time.Time      // end of the most recent candle
+	Gap        time.Duration  // from the most recent candle to the start of the current candle
+	Errors     []error
+}
+
+// Complete is true if the requested number of candles was loaded without a gap to now
+func (r Report) Complete() bool {
+	return r.Loaded >= r.Requested && r.Gap == 0
+}
+
+func (r Report) String() string {
+	var sources []string
+	for name, loaded := range r.Sources {
+		sources = append(sources, fmt.Sprintf("%s=%d", name, loaded))
+	}
+	sort.Strings(sources)
+	return fmt.Sprintf("%s %s: %d/%d candles (%s), gap %s, %d errors",
+		r.Instrument, r.Duration, r.Loaded, r.Requested, strings.Join(sources, " "), r.Gap, len(r.Errors))
+}
+
+type sourcedCandle struct {
+	candle ohlc.OHLC
+	source string
+}
+
+// Load asks the providers in the given order until the requested number of candles is loaded
+// without a gap to now. Later providers fill the gaps of earlier ones, e.g. the broker the time
+// since the last stored candle. Candles with the same start are taken from the first provider,
+// candles which haven't ended at now are ignored. Failing providers are skipped and reported.
+func Load(providers []Provider, instrument string, duration time.Duration, now time.Time, day ohlc.TradingDay, limit int) ([]ohlc.OHLC, Report) {
+	var report = Report{
+		Instrument: instrument,
+		Duration:   duration,
+		Requested:  limit,
+		Sources:    map[string]int{},
+	}
+	var currentStart = day.CandleStart(now, duration)
+
+	var candles []sourcedCandle
+	var starts = map[int64]bool{}
+	for _, provider := range providers {
+		if len(candles) >= limit && gap(candles, currentStart) == 0 {
+			break
+		}
+
+		loaded, err := provider.Candles(instrument, duration, now, limit)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
+		for _, candle := range loaded {
+			if candle.End.After(now) || starts[candle.Start.UnixNano()] {
+				continue
+			}
+			starts[candle.Start.UnixNano()] = true
+			var end = candle.End
+			candle.ForceClose()
+			candle.End = end
+			candles = append(candles, sourcedCandle{candle: candle, source: provider.Name()})
+		}
+		sort.Slice(candles, func(i, j int) bool {
+			return candles[i].candle.Start.Before(candles[j].candle.Start)
+		})
+		if len(candles) > limit {
+			candles = candles[len(candles)-limit:]
+		}
+	}
+
+	var result = make([]ohlc.OHLC, 0, len(candles))
+	for _, c := range candles {
+		result = append(result, c.candle)
+		report.Sources[c.source]++
+	}
+	report.Loaded = len(result)
+	report.Gap = gap(candles, currentStart)
+	if len(result) > 0 {
+		report.LastEnd = result[len(result)-1].End
+	}
+	return result, report
+}
+
+// gap returns the time between the end of the most recent candle and the start of the current candle
+func gap(candles []sourcedCandle, currentStart time.Time) time.Duration {
+	if len(candles) == 0 {
+		return 0
+	}
+	var end = candles[len(candles)-1].candle.End
+	if !end.Before(currentStart) {
+		return 0
+	}
+	return currentStart.Sub(end)
+}
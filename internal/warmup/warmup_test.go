@@ -0,0 +1,78 @@
+package warmup
+
+import (
+	"errors"
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+var monday = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+
+// fakeProvider returns hourly candles from the given hours after monday
+type fakeProvider struct {
+	name  string
+	hours []int
+	price float64
+	err   error
+}
+
+func (p fakeProvider) Name() string {
+	return p.name
+}
+
+func (p fakeProvider) Candles(instrument string, duration time.Duration, to time.Time, limit int) ([]ohlc.OHLC, error) {
+	var candles []ohlc.OHLC
+	for _, hour := range p.hours {
+		var start = monday.Add(time.Hour * time.Duration(hour))
+		var price = decimal.NewFromFloat(p.price)
+		candles = append(candles, ohlc.OHLC{Instrument: instrument, Open: price, High: price, Low: price, Close: price,
+			Start: start, End: start.Add(duration), Duration: duration})
+	}
+	return candles, p.err
+}
+
+func TestLoad(t *testing.T) {
+	var day = ohlc.TradingDay{Location: time.UTC}
+	var now = monday.Add(time.Hour*10 + time.Minute*30)
+	var stored = fakeProvider{name: "db", hours: []int{2, 3, 4, 5}, price: 1}
+	var failing = fakeProvider{name: "failing", err: errors.New("offline")}
+	var broker = fakeProvider{name: "broker", hours: []int{5, 6, 7, 8, 9, 10}, price: 2}
+
+	candles, report := Load([]Provider{stored, failing, broker}, "EURUSD", time.Hour, now, day, 6)
+	assert.EqualInt(t.Fatalf, 6, len(candles))
+	assert.EqualTime(t, monday.Add(time.Hour*4), candles[0].Start)
+	assert.EqualTime(t, monday.Add(time.Hour*10), candles[5].End)
+	assert.True(t, candles[0].Closed())
+
+	// The candle at 05:00 is taken from the first provider, the unfinished candle at 10:00 is ignored
+	assert.EqualStrings(t, "1", candles[1].Close.String())
+	assert.EqualStrings(t, "2", candles[2].Close.String())
+	assert.True(t, report.Complete())
+	assert.EqualInt(t, 2, report.Sources["db"])
+	assert.EqualInt(t, 4, report.Sources["broker"])
+	assert.EqualInt(t.Fatalf, 1, len(report.Errors))
+	assert.ErrorIncludesMessage(t, "failing: offline", report.Errors[0])
+}
+
+func TestLoad_incomplete(t *testing.T) {
+	var day = ohlc.TradingDay{Location: time.UTC}
+	var now = monday.Add(time.Hour*10 + time.Minute*30)
+	var stored = fakeProvider{name: "db", hours: []int{2, 3, 4, 5}}
+	var broker = fakeProvider{name: "broker", hours: []int{7}}
+
+	candles, report := Load([]Provider{stored, broker}, "EURUSD", time.Hour, now, day, 10)
+	assert.EqualInt(t, 5, len(candles))
+	assert.True(t, !report.Complete())
+	assert.EqualInt(t, 10, report.Requested)
+	assert.EqualInt(t, 5, report.Loaded)
+	assert.True(t, report.Gap == time.Hour*2)
+	assert.EqualTime(t, monday.Add(time.Hour*8), report.LastEnd)
+
+	// Providers aren't asked once the candles are complete
+	_, report = Load([]Provider{stored, broker}, "EURUSD", time.Hour, monday.Add(time.Hour*6), day, 3)
+	assert.True(t, report.Complete())
+	assert.EqualInt(t, 0, report.Sources["broker"])
+}
@@ -0,0 +1,173 @@
+package benchmark
+
+import (
+	"github.com/shopspring/decimal"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const dayFormat = "2006-01-02"
+
+// Price is the closing price of a benchmark day
+type Price struct {
+	Date  time.Time
+	Price decimal.Decimal
+}
+
+// Benchmark collects the daily closing prices of a benchmark series, e.g. buy and hold of the
+// traded instrument.
+type Benchmark struct {
+	Instrument string
+	closes     []Price
+	dayOf      func(time.Time) string
+	sync.RWMutex
+}
+
+// Result compares the daily returns of a strategy with its benchmark. Returns are in percent.
+type Result struct {
+	Instrument       string
+	Days             int
+	StrategyReturn   float64
+	BenchmarkReturn  float64
+	ExcessReturn     float64
+	Beta             float64
+	Alpha            float64 // Daily return not explained by the benchmark
+	Correlation      float64
+	InformationRatio float64 // Mean daily excess return divided by its standard deviation
+}
+
+func New(instrument string) *Benchmark {
+	return &Benchmark{Instrument: instrument, dayOf: DayOf}
+}
+
+// SetDayOf sets the day of prices and returns, UTC days by default
+func (b *Benchmark) SetDayOf(dayOf func(time.Time) string) {
+	b.Lock()
+	defer b.Unlock()
+	b.dayOf = dayOf
+}
+
+// Add records a price. The last price of each day is its closing price.
+func (b *Benchmark) Add(datetime time.Time, price decimal.Decimal) {
+	b.Lock()
+	defer b.Unlock()
+
+	var day = b.dayOf(datetime)
+	if n := len(b.closes); n > 0 && b.dayOf(b.closes[n-1].Date) == day {
+		b.closes[n-1] = Price{Date: datetime, Price: price}
+		return
+	}
+	b.closes = append(b.closes, Price{Date: datetime, Price: price})
+}
+
+// Closes returns the closing price of each day
+func (b *Benchmark) Closes() []Price {
+	b.RLock()
+	defer b.RUnlock()
+
+	var closes = make([]Price, len(b.closes))
+	copy(closes, b.closes)
+	return closes
+}
+
+// DailyReturns returns the performance in percent of each day compared to the previous close
+func (b *Benchmark) DailyReturns() map[string]float64 {
+	var closes = b.Closes()
+	b.RLock()
+	var dayOf = b.dayOf
+	b.RUnlock()
+
+	var returns = map[string]float64{}
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1].Price.IsZero() {
+			continue
+		}
+		perf, _ := closes[i].Price.Sub(closes[i-1].Price).Div(closes[i-1].Price).Float64()
+		returns[dayOf(closes[i].Date)] = perf * 100
+	}
+	return returns
+}
+
+// DayOf returns the key of the day used for daily returns
+func DayOf(datetime time.Time) string {
+	return datetime.UTC().Format(dayFormat)
+}
+
+// Compare compares the daily strategy returns with the benchmark. Days without strategy returns
+// count as zero performance.
+func (b *Benchmark) Compare(strategyReturns map[string]float64) Result {
+	var benchmarkReturns = b.DailyReturns()
+	var result = Result{Instrument: b.Instrument}
+
+	var days []string
+	for day := range benchmarkReturns {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	var s, m []float64
+	for _, day := range days {
+		s = append(s, strategyReturns[day])
+		m = append(m, benchmarkReturns[day])
+	}
+	for _, perf := range strategyReturns {
+		result.StrategyReturn += perf
+	}
+
+	if closes := b.Closes(); len(closes) > 1 && !closes[0].Price.IsZero() {
+		perf, _ := closes[len(closes)-1].Price.Sub(closes[0].Price).Div(closes[0].Price).Float64()
+		result.BenchmarkReturn = perf * 100
+	}
+	result.ExcessReturn = result.StrategyReturn - result.BenchmarkReturn
+	result.Days = len(days)
+
+	if len(days) < 2 {
+		return result
+	}
+
+	meanS, meanM := mean(s), mean(m)
+	varM := variance(m, meanM)
+	varS := variance(s, meanS)
+	cov := covariance(s, meanS, m, meanM)
+
+	if varM > 0 {
+		result.Beta = cov / varM
+	}
+	result.Alpha = meanS - result.Beta*meanM
+	if varM > 0 && varS > 0 {
+		result.Correlation = cov / math.Sqrt(varM*varS)
+	}
+
+	var excess = make([]float64, len(s))
+	for i := range s {
+		excess[i] = s[i] - m[i]
+	}
+	meanExcess := mean(excess)
+	if trackingError := math.Sqrt(variance(excess, meanExcess)); trackingError > 0 {
+		result.InformationRatio = meanExcess / trackingError
+	}
+
+	return result
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func variance(values []float64, mean float64) float64 {
+	return covariance(values, mean, values, mean)
+}
+
+func covariance(a []float64, meanA float64, b []float64, meanB float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += (a[i] - meanA) * (b[i] - meanB)
+	}
+	return sum / float64(len(a)-1)
+}
@@ -0,0 +1,56 @@
+package benchmark
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+func newBenchmark(prices ...float64) *Benchmark {
+	var b = New("EURUSD")
+	var day = time.Date(2021, 1, 1, 22, 0, 0, 0, time.UTC)
+	for _, price := range prices {
+		b.Add(day.Add(-time.Hour), decimal.NewFromFloat(price/2))
+		b.Add(day, decimal.NewFromFloat(price))
+		day = day.AddDate(0, 0, 1)
+	}
+	return b
+}
+
+func TestBenchmark_Add(t *testing.T) {
+	b := newBenchmark(100, 110)
+	closes := b.Closes()
+	assert.EqualInt(t.Fatalf, 2, len(closes))
+	assert.EqualStrings(t, "110", closes[1].Price.String())
+
+	returns := b.DailyReturns()
+	assert.EqualInt(t.Fatalf, 1, len(returns))
+	assert.EqualFloat64Tol(t, 10, returns["2021-01-02"], 0.0001)
+}
+
+func TestBenchmark_Compare(t *testing.T) {
+	b := newBenchmark(100, 110, 99, 108.9)
+
+	// Strategy with twice the benchmark's daily moves
+	result := b.Compare(map[string]float64{
+		"2021-01-02": 20,
+		"2021-01-03": -20,
+		"2021-01-04": 20,
+	})
+	assert.EqualInt(t, 3, result.Days)
+	assert.EqualFloat64Tol(t, 20, result.StrategyReturn, 0.0001)
+	assert.EqualFloat64Tol(t, 8.9, result.BenchmarkReturn, 0.0001)
+	assert.EqualFloat64Tol(t, 11.1, result.ExcessReturn, 0.0001)
+	assert.EqualFloat64Tol(t, 2, result.Beta, 0.0001)
+	assert.EqualFloat64Tol(t, 1, result.Correlation, 0.0001)
+	assert.True(t, result.Alpha < 0.0001 && result.Alpha > -0.0001)
+	assert.True(t, result.InformationRatio > 0)
+}
+
+func TestBenchmark_Compare_not_enough_data(t *testing.T) {
+	result := newBenchmark(100).Compare(map[string]float64{"2021-01-01": 5})
+	assert.EqualInt(t, 0, result.Days)
+	assert.EqualFloat64(t, 5, result.ExcessReturn)
+	assert.EqualFloat64(t, 0, result.Beta)
+}
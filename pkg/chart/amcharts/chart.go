@@ -8,6 +8,7 @@ import (
 	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/benchmark"
 	"github.com/sklinkert/at/pkg/ohlc"
 	"html/template"
 	"io"
@@ -17,14 +18,15 @@ import (
 )
 
 type dataPoint struct {
-	Start     string            `json:"date"`
-	End       string            `json:"-"`
-	Open      string            `json:"open"`
-	High      string            `json:"high"`
-	Low       string            `json:"low"`
-	Close     string            `json:"close"`
-	Volume    int               `json:"volume"`
-	Positions []broker.Position `json:"-"`
+	Start         string            `json:"date"`
+	End           string            `json:"-"`
+	Open          string            `json:"open"`
+	High          string            `json:"high"`
+	Low           string            `json:"low"`
+	Close         string            `json:"close"`
+	Volume        float64           `json:"volume"`
+	PositionCount int               `json:"positions"` // positions opened in the candle
+	Positions     []broker.Position `json:"-"`
 }
 
 //go:embed assets
@@ -35,6 +37,13 @@ type Chart struct {
 	candles    []ohlc.OHLC
 	positions  []broker.Position
 	instrument string
+	benchmark  *benchmark.Benchmark
+	location   *time.Location
+}
+
+// SetBenchmark plots buy and hold of one unit of the benchmark on the equity curve
+func (c *Chart) SetBenchmark(b *benchmark.Benchmark) {
+	c.benchmark = b
 }
 
 func (c *Chart) OnPosition(position broker.Position) {
@@ -78,60 +87,57 @@ func (c *Chart) RenderChartToHTML() (string, error) {
 }
 
 func (c *Chart) RenderChart(w io.Writer) error {
-	var dataPoints []dataPoint
-
 	t, err := template.ParseFS(staticFiles, "assets/amcharts.html")
 	if err != nil {
 		return err
 	}
 
-	locBerlin, err := time.LoadLocation("Europe/Berlin")
+	var dataPoints = c.dataPoints()
+	dataPointsJSON, err := json.Marshal(&dataPoints)
 	if err != nil {
 		return err
 	}
 
+	chartData := struct {
+		DataPoints     []dataPoint
+		DataPointsJSON string
+	}{
+		dataPoints,
+		string(dataPointsJSON[:]),
+	}
+
+	return t.Execute(w, chartData)
+}
+
+// dataPoints returns the candles with the positions opened in them
+func (c *Chart) dataPoints() (dataPoints []dataPoint) {
 	for _, candle := range c.candles {
 		var openPositions positionList
-		var volume = 0
 
 		for _, position := range c.positions {
-			position.BuyTime = position.BuyTime.In(locBerlin)
-			position.SellTime = position.SellTime.In(locBerlin)
+			position.BuyTime = position.BuyTime.In(c.location)
+			position.SellTime = position.SellTime.In(c.location)
 
 			// Sum up all open closedPositions
 			if position.BuyTime.Equal(candle.Start) || (position.BuyTime.After(candle.Start) && position.BuyTime.Before(candle.End)) {
-				volume++
 				openPositions = append(openPositions, position)
 			}
 		}
 		sort.Sort(openPositions)
 
 		dataPoints = append(dataPoints, dataPoint{
-			Start:     candle.Start.In(locBerlin).Format("2006-01-02 15:04"),
-			End:       candle.End.In(locBerlin).Format("2006-01-02 15:04"),
-			Open:      fmt.Sprintf("%.5f", dec2Float(candle.Open)),
-			High:      fmt.Sprintf("%.5f", dec2Float(candle.High)),
-			Low:       fmt.Sprintf("%.5f", dec2Float(candle.Low)),
-			Close:     fmt.Sprintf("%.5f", dec2Float(candle.Close)),
-			Volume:    volume,
-			Positions: openPositions,
+			Start:         candle.Start.In(c.location).Format("2006-01-02 15:04"),
+			End:           candle.End.In(c.location).Format("2006-01-02 15:04"),
+			Open:          fmt.Sprintf("%.5f", dec2Float(candle.Open)),
+			High:          fmt.Sprintf("%.5f", dec2Float(candle.High)),
+			Low:           fmt.Sprintf("%.5f", dec2Float(candle.Low)),
+			Close:         fmt.Sprintf("%.5f", dec2Float(candle.Close)),
+			Volume:        candle.Volume,
+			PositionCount: len(openPositions),
+			Positions:     openPositions,
 		})
 	}
-
-	dataPointsJSON, err := json.Marshal(&dataPoints)
-	if err != nil {
-		return err
-	}
-
-	chartData := struct {
-		DataPoints     []dataPoint
-		DataPointsJSON string
-	}{
-		dataPoints,
-		string(dataPointsJSON[:]),
-	}
-
-	return t.Execute(w, chartData)
+	return dataPoints
 }
 
 func (c *Chart) RenderEquityCurve(w io.Writer) error {
@@ -159,11 +165,37 @@ func (c *Chart) RenderEquityCurve(w io.Writer) error {
 		return err
 	}
 
+	type BenchmarkPoint struct {
+		Date      time.Time
+		Benchmark float64
+	}
+	var benchmarkPoints = []BenchmarkPoint{}
+	var benchmarkInstrument string
+	if c.benchmark != nil {
+		benchmarkInstrument = c.benchmark.Instrument
+		closes := c.benchmark.Closes()
+		for _, price := range closes {
+			benchmarkPoints = append(benchmarkPoints, BenchmarkPoint{
+				Date:      price.Date,
+				Benchmark: dec2Float(price.Price.Sub(closes[0].Price)),
+			})
+		}
+	}
+
+	benchmarkJSON, err := json.Marshal(&benchmarkPoints)
+	if err != nil {
+		return err
+	}
+
 	chartData := struct {
-		DataPointsJSON string
-		Instrument     string
+		DataPointsJSON      string
+		BenchmarkJSON       string
+		BenchmarkInstrument string
+		Instrument          string
 	}{
 		string(dataPointsJSON[:]),
+		string(benchmarkJSON[:]),
+		benchmarkInstrument,
 		c.instrument,
 	}
 	return t.Execute(w, chartData)
@@ -200,10 +232,18 @@ func WithPort(port int) Option {
 	}
 }
 
+// WithLocation sets the timezone of the candles, Europe/Berlin by default
+func WithLocation(location *time.Location) Option {
+	return func(chart *Chart) {
+		chart.location = location
+	}
+}
+
 func NewChart(instrument string, options ...Option) *Chart {
 	chart := &Chart{
 		port:       8080,
 		instrument: instrument,
+		location:   ohlc.DefaultTradingDay().Location,
 	}
 	for _, option := range options {
 		option(chart)
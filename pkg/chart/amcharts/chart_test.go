@@ -5,6 +5,7 @@ import (
 	"github.com/AMekss/assert"
 	"github.com/shopspring/decimal"
 	"github.com/sklinkert/at/internal/broker"
+	"github.com/sklinkert/at/pkg/benchmark"
 	"github.com/sklinkert/at/pkg/chart"
 	"github.com/sklinkert/at/pkg/ohlc"
 	"testing"
@@ -49,3 +50,48 @@ func TestChart_RenderChart(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, html != "")
 }
+
+func TestChart_dataPoints(t *testing.T) {
+	var candles = getCandlesLong(2)
+	candles[1].Volume = 42.5
+
+	c := NewChart("EURUSD", WithLocation(time.UTC))
+	for _, candle := range candles {
+		c.OnCandle(*candle)
+	}
+	c.OnPosition(broker.Position{BuyTime: candles[1].Start, SellTime: candles[1].End})
+
+	var dataPoints = c.dataPoints()
+	assert.EqualInt(t.Fatalf, 2, len(dataPoints))
+	assert.EqualFloat64(t, 2, dataPoints[0].Volume)
+	assert.EqualInt(t, 0, dataPoints[0].PositionCount)
+	assert.EqualFloat64(t, 42.5, dataPoints[1].Volume)
+	assert.EqualInt(t, 1, dataPoints[1].PositionCount)
+}
+
+func TestChart_RenderEquityCurve(t *testing.T) {
+	var b = benchmark.New("SPX")
+	var now = time.Date(2021, 1, 4, 12, 0, 0, 0, time.UTC)
+	b.Add(now, decimal.NewFromFloat(100))
+	b.Add(now.AddDate(0, 0, 1), decimal.NewFromFloat(105))
+
+	c := NewChart("EURUSD")
+	c.SetBenchmark(b)
+	c.OnPosition(winningPosition(now))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t.Fatalf, c.RenderEquityCurve(buf))
+	assert.IncludesString(t, "Buy and hold SPX", buf.String())
+	assert.IncludesString(t, `\"Benchmark\":5`, buf.String())
+}
+
+func winningPosition(sellTime time.Time) broker.Position {
+	return broker.Position{
+		BuyDirection: broker.BuyDirectionLong,
+		BuyPrice:     decimal.NewFromFloat(1),
+		SellPrice:    decimal.NewFromFloat(2),
+		BuyTime:      sellTime.Add(-time.Hour),
+		SellTime:     sellTime,
+		Size:         1,
+	}
+}
@@ -1,9 +1,11 @@
 package eo
 
 import (
+	"encoding/json"
 	ring "github.com/falzm/golang-ring"
 	log "github.com/sirupsen/logrus"
 	"github.com/sklinkert/at/pkg/helper"
+	"github.com/sklinkert/at/pkg/indicator"
 	"github.com/sklinkert/at/pkg/ohlc"
 	"sort"
 )
@@ -16,6 +18,11 @@ type EnvironmentOverlay struct {
 	priceChangesPercent ring.Ring
 }
 
+type snapshot struct {
+	Candles             []float64 `json:"candles"`
+	PriceChangesPercent []float64 `json:"price_changes_percent"`
+}
+
 type RiskLevel int
 
 const (
@@ -50,6 +57,31 @@ func (eo *EnvironmentOverlay) AddCandle(candle *ohlc.OHLC) {
 	eo.priceChangesPercent.Enqueue(perfPercent)
 }
 
+// Snapshot returns the close prices and price changes of the last candles
+func (eo *EnvironmentOverlay) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{
+		Candles:             eo.candles.Values(),
+		PriceChangesPercent: eo.priceChangesPercent.Values(),
+	})
+}
+
+func (eo *EnvironmentOverlay) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	candles, err := indicator.RestoreRing(minCandles, s.Candles)
+	if err != nil {
+		return err
+	}
+	priceChangesPercent, err := indicator.RestoreRing(minCandles, s.PriceChangesPercent)
+	if err != nil {
+		return err
+	}
+	eo.candles, eo.priceChangesPercent = candles, priceChangesPercent
+	return nil
+}
+
 func (eo *EnvironmentOverlay) riskLevel() RiskLevel {
 	var prices = eo.candles.Values()
 	var priceChangesPercent = eo.priceChangesPercent.Values()
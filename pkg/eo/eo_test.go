@@ -38,3 +38,22 @@ func generateCandle(diff float64) *ohlc.OHLC {
 	candle.ForceClose()
 	return candle
 }
+
+func TestEnvironmentOverlay_Snapshot(t *testing.T) {
+	overlay := New()
+	for i := 0; i < 60; i++ {
+		overlay.AddCandle(generateCandle(float64(i)))
+	}
+
+	data, err := overlay.Snapshot()
+	assert.NoError(t.Fatalf, err)
+
+	restored := New()
+	assert.NoError(t.Fatalf, restored.Restore(data))
+	assert.EqualInt(t, int(overlay.riskLevel()), int(restored.riskLevel()))
+
+	overlay.AddCandle(generateCandle(-100))
+	restored.AddCandle(generateCandle(-100))
+	assert.EqualInt(t, int(overlay.riskLevel()), int(restored.riskLevel()))
+	assert.EqualInt(t, int(RLow), int(restored.riskLevel()))
+}
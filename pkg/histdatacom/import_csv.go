@@ -7,6 +7,7 @@ import (
 	"github.com/sklinkert/at/pkg/tick"
 	"io"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -40,7 +41,7 @@ func importFromCSV(instrument, file string, tickChan chan tick.Tick) {
 		clog.WithError(err).Fatal("cannot load timezone")
 	}
 
-	// DateTime Stamp;Bar OPEN Bid Quote;Bar HIGH Bid Quote;Bar LOW Bid Quote;Bar CLOSE Bid Quote;Volume
+	// DateTime Stamp,Bid Quote,Ask Quote,Volume
 	r := csv.NewReader(csvFile)
 	var tmp decimal.Decimal
 	for {
@@ -82,6 +83,10 @@ func importFromCSV(instrument, file string, tickChan chan tick.Tick) {
 		}
 
 		tickData := tick.New(instrument, datetime, bid, ask)
+		if tickData.Volume, err = strconv.ParseFloat(record[3], 64); err != nil {
+			clog.WithError(err).Warn("cannot parse volume", record[3])
+			continue
+		}
 		tickChan <- tickData
 
 		//log.Infof("IMPORT: %s %s %s", datetime, bid, ask)
@@ -1,6 +1,7 @@
 package adx
 
 import (
+	"encoding/json"
 	"github.com/falzm/golang-ring"
 	"github.com/markcheno/go-talib"
 	"github.com/sklinkert/at/pkg/indicator"
@@ -16,6 +17,12 @@ type ADX struct {
 	size        int
 }
 
+type snapshot struct {
+	ClosePrices []float64 `json:"close_prices"`
+	HighPrices  []float64 `json:"high_prices"`
+	LowPrices   []float64 `json:"low_prices"`
+}
+
 // New creates a new instance.
 // size is usually 14
 func New(size int) *ADX {
@@ -70,3 +77,32 @@ func (v *ADX) Value() (map[string]float64, error) {
 func (v *ADX) ValueResultKeys() []string {
 	return []string{Value}
 }
+
+func (v *ADX) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{
+		ClosePrices: v.closePrices.Values(),
+		HighPrices:  v.highPrices.Values(),
+		LowPrices:   v.lowPrices.Values(),
+	})
+}
+
+func (v *ADX) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	closePrices, err := indicator.RestoreRing(v.closePrices.Capacity(), s.ClosePrices)
+	if err != nil {
+		return err
+	}
+	highPrices, err := indicator.RestoreRing(v.highPrices.Capacity(), s.HighPrices)
+	if err != nil {
+		return err
+	}
+	lowPrices, err := indicator.RestoreRing(v.lowPrices.Capacity(), s.LowPrices)
+	if err != nil {
+		return err
+	}
+	v.closePrices, v.highPrices, v.lowPrices = closePrices, highPrices, lowPrices
+	return nil
+}
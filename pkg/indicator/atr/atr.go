@@ -0,0 +1,72 @@
+package atr
+
+import (
+	"encoding/json"
+	"github.com/sklinkert/at/pkg/indicator"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"math"
+)
+
+const Value = "ATR_VALUE"
+
+// ATR is the average true range over the given number of candles
+type ATR struct {
+	cb        *indicator.Buffer
+	prevClose *float64
+}
+
+type snapshot struct {
+	TrueRanges indicator.BufferSnapshot `json:"true_ranges"`
+	PrevClose  *float64                 `json:"prev_close"`
+}
+
+func New(period int) *ATR {
+	return &ATR{
+		cb: indicator.NewBuffer(period, period),
+	}
+}
+
+func (a *ATR) Insert(o *ohlc.OHLC) {
+	if !o.Closed() {
+		return
+	}
+
+	high, _ := o.High.Float64()
+	low, _ := o.Low.Float64()
+	closePrice, _ := o.Close.Float64()
+
+	var trueRange = high - low
+	if a.prevClose != nil {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(high-*a.prevClose), math.Abs(low-*a.prevClose)))
+	}
+	a.prevClose = &closePrice
+	a.cb.Insert(trueRange)
+}
+
+func (a *ATR) Value() (map[string]float64, error) {
+	var err error
+	var m = map[string]float64{}
+
+	m[Value], err = a.cb.Average()
+	return m, err
+}
+
+func (a *ATR) ValueResultKeys() []string {
+	return []string{Value}
+}
+
+func (a *ATR) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{TrueRanges: a.cb.Snapshot(), PrevClose: a.prevClose})
+}
+
+func (a *ATR) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if err := a.cb.Restore(s.TrueRanges); err != nil {
+		return err
+	}
+	a.prevClose = s.PrevClose
+	return nil
+}
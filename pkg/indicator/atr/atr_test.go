@@ -0,0 +1,37 @@
+package atr
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+func newCandle(prices ...float64) *ohlc.OHLC {
+	o := ohlc.New("test", time.Now(), time.Minute, false)
+	for _, price := range prices {
+		o.NewPrice(decimal.NewFromFloat(price), o.Start)
+	}
+	o.ForceClose()
+	return o
+}
+
+func TestATR_Value(t *testing.T) {
+	var atr = New(3)
+
+	atr.Insert(newCandle(10, 12, 9, 11)) // 3
+	_, err := atr.Value()
+	assert.ErrorIncludesMessage(t, "not enough", err)
+
+	atr.Insert(newCandle(11, 12, 11, 12)) // 1
+	atr.Insert(newCandle(15, 16, 14, 15)) // gap: 16 - 12 = 4
+	value, err := atr.Value()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 8.0/3, value[Value])
+
+	atr.Insert(newCandle(15, 15, 9, 10)) // gap: 15 - 9 = 6
+	value, err = atr.Value()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 11.0/3, value[Value])
+}
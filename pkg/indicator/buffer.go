@@ -0,0 +1,70 @@
+package indicator
+
+import (
+	"fmt"
+	"github.com/sklinkert/circularbuffer"
+)
+
+// Buffer is a circularbuffer.CircularBuffer which can be snapshotted. It remembers the most recent
+// values in the order of insertion, so a restored buffer equals the original one.
+type Buffer struct {
+	*circularbuffer.CircularBuffer
+	minSize, maxSize int
+	values           []float64 // most recent values, oldest first
+	inserted         int
+}
+
+// BufferSnapshot is the state of a Buffer
+type BufferSnapshot struct {
+	Values   []float64 `json:"values"`
+	Inserted int       `json:"inserted"`
+}
+
+func NewBuffer(minSize, maxSize int) *Buffer {
+	return &Buffer{
+		CircularBuffer: circularbuffer.New(minSize, maxSize),
+		minSize:        minSize,
+		maxSize:        maxSize,
+	}
+}
+
+// Insert adds the value and overrides the oldest one if the buffer is full
+func (b *Buffer) Insert(value float64) {
+	b.CircularBuffer.Insert(value)
+	b.values = append(b.values, value)
+	if len(b.values) >= b.maxSize*2 {
+		b.values = append([]float64(nil), b.values[len(b.values)-b.maxSize:]...)
+	}
+	b.inserted++
+}
+
+func (b *Buffer) Snapshot() BufferSnapshot {
+	var values = b.values
+	if len(values) > b.maxSize {
+		values = values[len(values)-b.maxSize:]
+	}
+	return BufferSnapshot{Values: append([]float64(nil), values...), Inserted: b.inserted}
+}
+
+// Restore replaces the values with the snapshot of a buffer of the same size
+func (b *Buffer) Restore(snapshot BufferSnapshot) error {
+	var full = snapshot.Inserted > b.maxSize
+	if len(snapshot.Values) > b.maxSize || snapshot.Inserted < len(snapshot.Values) ||
+		(full && len(snapshot.Values) != b.maxSize) {
+		return fmt.Errorf("snapshot of %d values doesn't fit into a buffer of %d values", len(snapshot.Values), b.maxSize)
+	}
+
+	var restored = NewBuffer(b.minSize, b.maxSize)
+	if full {
+		// The values override placeholders, so they are stored at the same positions as in the original buffer
+		for i := 0; i < (snapshot.Inserted-b.maxSize)%b.maxSize; i++ {
+			restored.CircularBuffer.Insert(0)
+		}
+	}
+	for _, value := range snapshot.Values {
+		restored.Insert(value)
+	}
+	restored.inserted = snapshot.Inserted
+	*b = *restored
+	return nil
+}
@@ -0,0 +1,33 @@
+package indicator
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+)
+
+func TestBuffer_Restore(t *testing.T) {
+	for _, inserted := range []int{2, 5, 13, 27} {
+		var buffer = NewBuffer(3, 5)
+		for i := 0; i < inserted; i++ {
+			buffer.Insert(float64(i))
+		}
+
+		var restored = NewBuffer(3, 5)
+		assert.NoError(t.Fatalf, restored.Restore(buffer.Snapshot()))
+		buffer.Insert(100)
+		restored.Insert(100)
+
+		want, err := buffer.GetAll()
+		assert.NoError(t.Fatalf, err)
+		got, err := restored.GetAll()
+		assert.NoError(t.Fatalf, err)
+		assert.EqualInt(t.Fatalf, len(want), len(got))
+		for i := range want {
+			assert.EqualFloat64(t, want[i], got[i])
+		}
+		assert.EqualInt(t, buffer.Snapshot().Inserted, restored.Snapshot().Inserted)
+	}
+
+	var small = NewBuffer(1, 2)
+	assert.ErrorIncludesMessage(t, "doesn't fit", small.Restore(BufferSnapshot{Values: []float64{1, 2, 3}, Inserted: 3}))
+}
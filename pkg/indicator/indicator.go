@@ -11,4 +11,15 @@ type Indicator interface {
 	Value() (map[string]float64, error)
 }
 
+// Snapshotter is implemented by indicators whose state can be saved and restored, e.g. to resume after a
+// restart without warming up again
+type Snapshotter interface {
+	// Snapshot returns the state of the indicator
+	Snapshot() ([]byte, error)
+
+	// Restore replaces the state with a snapshot of an indicator with the same settings. The state is
+	// unchanged if the snapshot can't be restored.
+	Restore(snapshot []byte) error
+}
+
 var ErrNotEnoughData = errors.New("not enough data to calculate indicator")
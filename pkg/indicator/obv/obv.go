@@ -0,0 +1,69 @@
+package obv
+
+import (
+	"encoding/json"
+	"github.com/sklinkert/at/pkg/indicator"
+	"github.com/sklinkert/at/pkg/ohlc"
+)
+
+const Value = "OBV_VALUE"
+
+// OBV is the on-balance volume: the volume of candles closing higher is added, the volume of
+// candles closing lower is subtracted
+type OBV struct {
+	value     float64
+	prevClose *float64
+	candles   int
+}
+
+type snapshot struct {
+	Value     float64  `json:"value"`
+	PrevClose *float64 `json:"prev_close"`
+	Candles   int      `json:"candles"`
+}
+
+func New() *OBV {
+	return &OBV{}
+}
+
+func (v *OBV) Insert(o *ohlc.OHLC) {
+	if !o.Closed() {
+		return
+	}
+
+	closePrice, _ := o.Close.Float64()
+	if v.prevClose != nil {
+		switch {
+		case closePrice > *v.prevClose:
+			v.value += o.Volume
+		case closePrice < *v.prevClose:
+			v.value -= o.Volume
+		}
+	}
+	v.prevClose = &closePrice
+	v.candles++
+}
+
+func (v *OBV) Value() (map[string]float64, error) {
+	if v.candles < 2 {
+		return nil, indicator.ErrNotEnoughData
+	}
+	return map[string]float64{Value: v.value}, nil
+}
+
+func (v *OBV) ValueResultKeys() []string {
+	return []string{Value}
+}
+
+func (v *OBV) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{Value: v.value, PrevClose: v.prevClose, Candles: v.candles})
+}
+
+func (v *OBV) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v.value, v.prevClose, v.candles = s.Value, s.PrevClose, s.Candles
+	return nil
+}
@@ -0,0 +1,32 @@
+package obv
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+func newCandle(closePrice, volume float64) *ohlc.OHLC {
+	o := ohlc.New("test", time.Now(), time.Minute, false)
+	o.NewPrice(decimal.NewFromFloat(closePrice), o.Start)
+	o.ForceClose()
+	o.Volume = volume
+	return o
+}
+
+func TestOBV_Value(t *testing.T) {
+	var obv = New()
+
+	obv.Insert(newCandle(10, 100))
+	_, err := obv.Value()
+	assert.ErrorIncludesMessage(t, "not enough", err)
+
+	obv.Insert(newCandle(11, 50))
+	obv.Insert(newCandle(9, 20))
+	obv.Insert(newCandle(9, 70))
+	value, err := obv.Value()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 30, value[Value])
+}
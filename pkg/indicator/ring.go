@@ -0,0 +1,20 @@
+package indicator
+
+import (
+	"fmt"
+	"github.com/falzm/golang-ring"
+)
+
+// RestoreRing returns a ring.Ring of the capacity holding the values of a snapshot, oldest first.
+// Snapshots of rings are their Values().
+func RestoreRing(capacity int, values []float64) (ring.Ring, error) {
+	var r = ring.Ring{}
+	if len(values) > capacity {
+		return r, fmt.Errorf("snapshot of %d values doesn't fit into a ring of %d values", len(values), capacity)
+	}
+	r.SetCapacity(capacity)
+	for _, value := range values {
+		r.Enqueue(value)
+	}
+	return r, nil
+}
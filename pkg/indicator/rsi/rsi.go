@@ -1,6 +1,7 @@
 package rsi
 
 import (
+	"encoding/json"
 	"github.com/falzm/golang-ring"
 	"github.com/markcheno/go-talib"
 	"github.com/sklinkert/at/pkg/indicator"
@@ -66,3 +67,20 @@ func (v *RSI) Value() (map[string]float64, error) {
 func (v *RSI) ValueResultKeys() []string {
 	return []string{Value}
 }
+
+func (v *RSI) Snapshot() ([]byte, error) {
+	return json.Marshal(v.cb.Values())
+}
+
+func (v *RSI) Restore(snapshot []byte) error {
+	var values []float64
+	if err := json.Unmarshal(snapshot, &values); err != nil {
+		return err
+	}
+	cb, err := indicator.RestoreRing(v.cb.Capacity(), values)
+	if err != nil {
+		return err
+	}
+	v.cb = cb
+	return nil
+}
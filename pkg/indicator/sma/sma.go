@@ -1,19 +1,20 @@
 package sma
 
 import (
+	"encoding/json"
+	"github.com/sklinkert/at/pkg/indicator"
 	"github.com/sklinkert/at/pkg/ohlc"
-	"github.com/sklinkert/circularbuffer"
 )
 
 const Value = "SMA_VALUE"
 
 type SMA struct {
-	cb *circularbuffer.CircularBuffer
+	cb *indicator.Buffer
 }
 
 func New(size int) *SMA {
 	return &SMA{
-		cb: circularbuffer.New(size, size),
+		cb: indicator.NewBuffer(size, size),
 	}
 }
 
@@ -37,3 +38,15 @@ func (v *SMA) Value() (map[string]float64, error) {
 func (v *SMA) ValueResultKeys() []string {
 	return []string{Value}
 }
+
+func (v *SMA) Snapshot() ([]byte, error) {
+	return json.Marshal(v.cb.Snapshot())
+}
+
+func (v *SMA) Restore(snapshot []byte) error {
+	var s indicator.BufferSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	return v.cb.Restore(s)
+}
@@ -0,0 +1,85 @@
+package indicator_test
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/indicator"
+	"github.com/sklinkert/at/pkg/indicator/adx"
+	"github.com/sklinkert/at/pkg/indicator/atr"
+	"github.com/sklinkert/at/pkg/indicator/obv"
+	"github.com/sklinkert/at/pkg/indicator/rsi"
+	"github.com/sklinkert/at/pkg/indicator/sma"
+	"github.com/sklinkert/at/pkg/indicator/stoch"
+	"github.com/sklinkert/at/pkg/indicator/stochrsi"
+	"github.com/sklinkert/at/pkg/indicator/vwap"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"math"
+	"testing"
+	"time"
+)
+
+type snapshottable interface {
+	indicator.Indicator
+	indicator.Snapshotter
+}
+
+// TestSnapshot restores each indicator from a snapshot and checks that it continues exactly like the
+// original, before it has enough data and after its buffers wrapped around
+func TestSnapshot(t *testing.T) {
+	var tests = []struct {
+		name string
+		new  func() snapshottable
+	}{
+		{"adx", func() snapshottable { return adx.New(14) }},
+		{"atr", func() snapshottable { return atr.New(14) }},
+		{"obv", func() snapshottable { return obv.New() }},
+		{"rsi", func() snapshottable { return rsi.New(14) }},
+		{"sma", func() snapshottable { return sma.New(20) }},
+		{"stoch", func() snapshottable { return stoch.New(14, 3) }},
+		{"stochrsi", func() snapshottable { return stochrsi.New(14, 3, 14) }},
+		{"vwap", func() snapshottable { return vwap.New(ohlc.TradingDay{Location: time.UTC}) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testSnapshot(t, test.new, 5)
+			testSnapshot(t, test.new, 100)
+		})
+	}
+}
+
+func testSnapshot(t *testing.T, newIndicator func() snapshottable, candles int) {
+	var original, restored = newIndicator(), newIndicator()
+	var start = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var insert = func(v snapshottable, from, to int) {
+		for i := from; i < to; i++ {
+			o := ohlc.New("test", start.Add(time.Hour*time.Duration(i)), time.Hour, false)
+			o.NewPrice(decimal.NewFromFloat(100+10*math.Sin(float64(i)/3)), o.Start)
+			o.NewPrice(decimal.NewFromFloat(100+10*math.Sin(float64(i)/3+1)), o.Start)
+			o.ForceClose()
+			o.Volume = float64(i)
+			v.Insert(o)
+		}
+	}
+
+	insert(original, 0, candles)
+	snapshot, err := original.Snapshot()
+	assert.NoError(t.Fatalf, err)
+	assert.NoError(t.Fatalf, restored.Restore(snapshot))
+
+	insert(original, candles, candles+10)
+	insert(restored, candles, candles+10)
+	want, wantErr := original.Value()
+	got, gotErr := restored.Value()
+	if candles < 100 && (wantErr != nil || gotErr != nil) {
+		assert.True(t, (wantErr == nil) == (gotErr == nil))
+		return
+	}
+	assert.NoError(t.Fatalf, wantErr)
+	assert.NoError(t.Fatalf, gotErr)
+	for _, key := range original.ValueResultKeys() {
+		if want[key] != got[key] && !(math.IsNaN(want[key]) && math.IsNaN(got[key])) {
+			t.Errorf("after %d candles: %s is %f, want %f", candles, key, got[key], want[key])
+		}
+	}
+}
@@ -1,27 +1,34 @@
 package stoch
 
 import (
+	"encoding/json"
 	"github.com/markcheno/go-talib"
+	"github.com/sklinkert/at/pkg/indicator"
 	"github.com/sklinkert/at/pkg/ohlc"
-	"github.com/sklinkert/circularbuffer"
 )
 
 const ValueK = "Stoch_VALUE_K"
 const ValueD = "Stoch_VALUE_D"
 
 type Stoch struct {
-	closePrices *circularbuffer.CircularBuffer
-	highPrices  *circularbuffer.CircularBuffer
-	lowPrices   *circularbuffer.CircularBuffer
+	closePrices *indicator.Buffer
+	highPrices  *indicator.Buffer
+	lowPrices   *indicator.Buffer
 	fastKPeriod int
 	fastDPeriod int
 }
 
+type snapshot struct {
+	ClosePrices indicator.BufferSnapshot `json:"close_prices"`
+	HighPrices  indicator.BufferSnapshot `json:"high_prices"`
+	LowPrices   indicator.BufferSnapshot `json:"low_prices"`
+}
+
 func New(fastKPeriod, fastDPeriod int) *Stoch {
 	return &Stoch{
-		closePrices: circularbuffer.New(1, fastKPeriod*fastDPeriod),
-		highPrices:  circularbuffer.New(1, fastKPeriod*fastDPeriod),
-		lowPrices:   circularbuffer.New(1, fastKPeriod*fastDPeriod),
+		closePrices: indicator.NewBuffer(1, fastKPeriod*fastDPeriod),
+		highPrices:  indicator.NewBuffer(1, fastKPeriod*fastDPeriod),
+		lowPrices:   indicator.NewBuffer(1, fastKPeriod*fastDPeriod),
 		fastKPeriod: fastKPeriod,
 		fastDPeriod: fastDPeriod,
 	}
@@ -72,3 +79,29 @@ func (v *Stoch) Value() (map[string]float64, error) {
 func (v *Stoch) ValueResultKeys() []string {
 	return []string{ValueK, ValueD}
 }
+
+func (v *Stoch) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{
+		ClosePrices: v.closePrices.Snapshot(),
+		HighPrices:  v.highPrices.Snapshot(),
+		LowPrices:   v.lowPrices.Snapshot(),
+	})
+}
+
+func (v *Stoch) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	var closePrices, highPrices, lowPrices = *v.closePrices, *v.highPrices, *v.lowPrices
+	for _, restore := range []struct {
+		buffer   *indicator.Buffer
+		snapshot indicator.BufferSnapshot
+	}{{&closePrices, s.ClosePrices}, {&highPrices, s.HighPrices}, {&lowPrices, s.LowPrices}} {
+		if err := restore.buffer.Restore(restore.snapshot); err != nil {
+			return err
+		}
+	}
+	v.closePrices, v.highPrices, v.lowPrices = &closePrices, &highPrices, &lowPrices
+	return nil
+}
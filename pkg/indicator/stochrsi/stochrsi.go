@@ -1,16 +1,17 @@
 package stochrsi
 
 import (
+	"encoding/json"
 	"github.com/markcheno/go-talib"
+	"github.com/sklinkert/at/pkg/indicator"
 	"github.com/sklinkert/at/pkg/ohlc"
-	"github.com/sklinkert/circularbuffer"
 )
 
 const ValueK = "StochRSI_VALUE_K"
 const ValueD = "StochRSI_VALUE_D"
 
 type StochRSI struct {
-	cb           *circularbuffer.CircularBuffer
+	cb           *indicator.Buffer
 	fastKPeriod  int
 	fastDPeriod  int
 	inTimePeriod int
@@ -18,7 +19,7 @@ type StochRSI struct {
 
 func New(fastKPeriod, fastDPeriod, size int) *StochRSI {
 	return &StochRSI{
-		cb:           circularbuffer.New(size*3, size*3),
+		cb:           indicator.NewBuffer(size*3, size*3),
 		inTimePeriod: size,
 		fastKPeriod:  fastKPeriod,
 		fastDPeriod:  fastDPeriod,
@@ -56,3 +57,15 @@ func (v *StochRSI) Value() (map[string]float64, error) {
 func (v *StochRSI) ValueResultKeys() []string {
 	return []string{ValueK, ValueD}
 }
+
+func (v *StochRSI) Snapshot() ([]byte, error) {
+	return json.Marshal(v.cb.Snapshot())
+}
+
+func (v *StochRSI) Restore(snapshot []byte) error {
+	var s indicator.BufferSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	return v.cb.Restore(s)
+}
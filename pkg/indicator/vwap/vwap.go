@@ -0,0 +1,68 @@
+package vwap
+
+import (
+	"encoding/json"
+	"github.com/sklinkert/at/pkg/indicator"
+	"github.com/sklinkert/at/pkg/ohlc"
+)
+
+const Value = "VWAP_VALUE"
+
+// VWAP is the volume weighted average of the typical price, (high+low+close)/3, of the trading
+// day's candles. It starts again with the first candle of each trading day.
+type VWAP struct {
+	day         ohlc.TradingDay
+	key         string
+	priceVolume float64
+	volume      float64
+}
+
+type snapshot struct {
+	Key         string  `json:"key"`
+	PriceVolume float64 `json:"price_volume"`
+	Volume      float64 `json:"volume"`
+}
+
+func New(day ohlc.TradingDay) *VWAP {
+	return &VWAP{day: day}
+}
+
+func (v *VWAP) Insert(o *ohlc.OHLC) {
+	if !o.Closed() {
+		return
+	}
+
+	if key := v.day.Key(o.Start); key != v.key {
+		v.key, v.priceVolume, v.volume = key, 0, 0
+	}
+
+	high, _ := o.High.Float64()
+	low, _ := o.Low.Float64()
+	closePrice, _ := o.Close.Float64()
+	v.priceVolume += (high + low + closePrice) / 3 * o.Volume
+	v.volume += o.Volume
+}
+
+func (v *VWAP) Value() (map[string]float64, error) {
+	if v.volume == 0 {
+		return nil, indicator.ErrNotEnoughData
+	}
+	return map[string]float64{Value: v.priceVolume / v.volume}, nil
+}
+
+func (v *VWAP) ValueResultKeys() []string {
+	return []string{Value}
+}
+
+func (v *VWAP) Snapshot() ([]byte, error) {
+	return json.Marshal(snapshot{Key: v.key, PriceVolume: v.priceVolume, Volume: v.volume})
+}
+
+func (v *VWAP) Restore(data []byte) error {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v.key, v.priceVolume, v.volume = s.Key, s.PriceVolume, s.Volume
+	return nil
+}
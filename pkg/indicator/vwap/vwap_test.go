@@ -0,0 +1,39 @@
+package vwap
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/ohlc"
+	"testing"
+	"time"
+)
+
+func newCandle(start time.Time, volume float64, prices ...float64) *ohlc.OHLC {
+	o := ohlc.New("test", start, time.Hour, false)
+	for _, price := range prices {
+		o.NewPrice(decimal.NewFromFloat(price), o.Start)
+	}
+	o.ForceClose()
+	o.Volume = volume
+	return o
+}
+
+func TestVWAP_Value(t *testing.T) {
+	var vwap = New(ohlc.TradingDay{Location: time.UTC})
+	var monday = time.Date(2021, 1, 4, 10, 0, 0, 0, time.UTC)
+
+	_, err := vwap.Value()
+	assert.ErrorIncludesMessage(t, "not enough", err)
+
+	vwap.Insert(newCandle(monday, 100, 10, 12, 8, 10))                 // typical price 10
+	vwap.Insert(newCandle(monday.Add(time.Hour), 300, 12, 13, 11, 12)) // typical price 12
+	value, err := vwap.Value()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 11.5, value[Value])
+
+	// Starts again on the next day
+	vwap.Insert(newCandle(monday.AddDate(0, 0, 1), 50, 20, 21, 19, 20))
+	value, err = vwap.Value()
+	assert.NoError(t.Fatalf, err)
+	assert.EqualFloat64(t, 20, value[Value])
+}
@@ -0,0 +1,171 @@
+package ohlc
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"time"
+)
+
+// Aggregator builds candles of one duration from prices. Candles close when the time passes their
+// end, either by a later price or by Advance, e.g. called by a timer in live trading. Backtests
+// and live trading build the same candles from the same ticks.
+type Aggregator struct {
+	instrument string
+	duration   time.Duration
+	day        TradingDay
+	anchor     time.Time // zero: aligned to the trading day
+	flat       bool
+	candle     *OHLC           // open candle, nil until the next price
+	next       time.Time       // start of the candle following the last closed one
+	lastClose  decimal.Decimal // close of the last closed candle
+	fillable   bool            // next and lastClose can be used for flat candles
+}
+
+type AggregatorOption func(*Aggregator)
+
+// WithAnchor aligns the candles to anchor instead of the beginning of the trading day, e.g. 90m
+// candles beginning at the opening of an exchange at 09:30
+func WithAnchor(anchor time.Time) AggregatorOption {
+	return func(a *Aggregator) {
+		a.anchor = anchor
+	}
+}
+
+// WithFlatCandles builds candles without ticks for intervals without prices. All prices of a flat
+// candle are the close of the previous candle.
+func WithFlatCandles() AggregatorOption {
+	return func(a *Aggregator) {
+		a.flat = true
+	}
+}
+
+func NewAggregator(instrument string, duration time.Duration, day TradingDay, options ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		instrument: instrument,
+		duration:   duration,
+		day:        day,
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a
+}
+
+func (a *Aggregator) Duration() time.Duration {
+	return a.duration
+}
+
+// Start returns the start of the candle which contains t
+func (a *Aggregator) Start(t time.Time) time.Time {
+	if a.anchor.IsZero() {
+		return a.day.CandleStart(t, a.duration)
+	}
+	return AnchoredCandleStart(t.In(a.day.Location), a.anchor, a.duration)
+}
+
+func (a *Aggregator) end(start time.Time) time.Time {
+	if a.anchor.IsZero() {
+		return a.day.CandleEnd(start, a.duration)
+	}
+	return start.Add(a.duration)
+}
+
+// Add adds the price to the candle which contains t and returns the candles closed before t.
+// Prices of candles which are already closed are ignored.
+func (a *Aggregator) Add(price decimal.Decimal, t time.Time) []*OHLC {
+	return a.add(price, t, 1)
+}
+
+// AddTick adds the tick's price and volume like Add
+func (a *Aggregator) AddTick(t tick.Tick) []*OHLC {
+	return a.add(t.Price(), t.Datetime, tickVolume(t))
+}
+
+func (a *Aggregator) add(price decimal.Decimal, t time.Time, volume float64) []*OHLC {
+	var closed = a.Advance(t)
+	if a.open(t) {
+		a.candle.update(price, t, volume)
+	}
+	return closed
+}
+
+// AddCandle adds a candle of a shorter duration, e.g. a stored 1m candle, to the candle which
+// contains its start and returns the candles closed before it
+func (a *Aggregator) AddCandle(candle *OHLC) []*OHLC {
+	var closed = a.Advance(candle.Start)
+	if a.open(candle.Start) {
+		a.candle.merge(candle)
+	}
+	return closed
+}
+
+// open opens the candle which contains t unless a candle is open. Returns false if the candle
+// containing t is already closed.
+func (a *Aggregator) open(t time.Time) bool {
+	if a.candle != nil {
+		return true
+	}
+	var start = a.Start(t)
+	if start.Before(a.next) {
+		return false
+	}
+	a.candle = New(a.instrument, start, a.duration, false)
+	a.candle.End = a.end(start)
+	return true
+}
+
+// Advance closes the open candle if it ended before or at now and returns the closed candles,
+// including flat candles for the intervals without prices until now
+func (a *Aggregator) Advance(now time.Time) (closed []*OHLC) {
+	if a.candle != nil {
+		if now.Before(a.candle.End) {
+			return nil
+		}
+		a.candle.closed = true
+		closed = append(closed, a.candle)
+		a.next, a.lastClose, a.fillable = a.candle.End, a.candle.Close, true
+		a.candle = nil
+	}
+	if !a.flat || !a.fillable {
+		return closed
+	}
+	for end := a.end(a.next); !now.Before(end); end = a.end(a.next) {
+		closed = append(closed, a.flatCandle(a.next, end))
+		a.next = end
+	}
+	return closed
+}
+
+// ForceClose closes the open candle before its end, e.g. at the end of a trading session, and
+// returns it. Flat candles aren't built until the next price.
+func (a *Aggregator) ForceClose() *OHLC {
+	a.next, a.fillable = time.Time{}, false
+	if a.candle == nil {
+		return nil
+	}
+	var candle = a.candle
+	a.candle = nil
+	candle.ForceClose()
+	return candle
+}
+
+func (a *Aggregator) flatCandle(start, end time.Time) *OHLC {
+	return &OHLC{
+		Instrument:        a.instrument,
+		Open:              a.lastClose,
+		High:              a.lastClose,
+		HighTime:          start,
+		Low:               a.lastClose,
+		LowTime:           start,
+		Close:             a.lastClose,
+		Start:             start,
+		End:               end,
+		Duration:          a.duration,
+		Flat:              true,
+		priceDataSeen:     true,
+		closed:            true,
+		lastReceivedPrice: start,
+	}
+}
@@ -0,0 +1,60 @@
+package ohlc
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	var day = TradingDay{Location: time.UTC}
+	var a = NewAggregator("EURUSD", time.Hour*2, day)
+	var monday = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var price = decimal.NewFromFloat(1)
+
+	assert.EqualInt(t, 0, len(a.Add(price, monday.Add(time.Hour*3))))
+	assert.EqualInt(t, 0, len(a.Advance(monday.Add(time.Hour*4-time.Second))))
+
+	// Closed on time without a tick
+	var closed = a.Advance(monday.Add(time.Hour * 4))
+	assert.EqualInt(t.Fatalf, 1, len(closed))
+	assert.EqualTime(t, monday.Add(time.Hour*2), closed[0].Start)
+	assert.EqualTime(t, monday.Add(time.Hour*4), closed[0].End)
+	assert.True(t, closed[0].Closed())
+
+	// Late ticks of the closed candle are ignored
+	assert.EqualInt(t, 0, len(a.Add(price, monday.Add(time.Hour*4-time.Second))))
+	assert.EqualInt(t, 0, len(a.Advance(monday.Add(time.Hour*6))))
+
+	// No candles for gaps without flat candles
+	assert.EqualInt(t, 0, len(a.Add(price, monday.Add(time.Hour*9))))
+	closed = a.Add(price, monday.Add(time.Hour*10))
+	assert.EqualInt(t.Fatalf, 1, len(closed))
+	assert.EqualTime(t, monday.Add(time.Hour*8), closed[0].Start)
+}
+
+func TestAggregator_flatCandles(t *testing.T) {
+	var day = TradingDay{Location: time.UTC}
+	var monday = time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	var a = NewAggregator("EURUSD", time.Minute*90, day, WithFlatCandles(),
+		WithAnchor(time.Date(2021, 1, 4, 9, 30, 0, 0, time.UTC)))
+
+	a.Add(decimal.NewFromFloat(1), monday.Add(time.Hour*10))
+	a.Add(decimal.NewFromFloat(2), monday.Add(time.Hour*10+time.Minute))
+	var closed = a.Add(decimal.NewFromFloat(3), monday.Add(time.Hour*15))
+	assert.EqualInt(t.Fatalf, 3, len(closed))
+	assert.EqualTime(t, monday.Add(time.Hour*9+time.Minute*30), closed[0].Start)
+	assert.True(t, !closed[0].Flat)
+	assert.EqualTime(t, monday.Add(time.Hour*11), closed[1].Start)
+	assert.EqualTime(t, monday.Add(time.Hour*12+time.Minute*30), closed[2].Start)
+	assert.True(t, closed[2].Flat)
+	assertDecimal(t, decimal.NewFromFloat(2), closed[2].Open)
+	assertDecimal(t, decimal.NewFromFloat(2), closed[2].Low)
+
+	// No flat candles after a forced close, e.g. over a weekend
+	assert.True(t.Fatalf, a.ForceClose() != nil)
+	assert.EqualInt(t, 0, len(a.Add(decimal.NewFromFloat(3), monday.Add(time.Hour*72))))
+	assert.True(t, a.ForceClose() != nil)
+	assert.True(t, a.ForceClose() == nil)
+}
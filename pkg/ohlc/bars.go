@@ -0,0 +1,239 @@
+package ohlc
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"time"
+)
+
+// BarKind selects how bars are built from ticks
+type BarKind int
+
+const (
+	BarTime   BarKind = iota // candles of the timeframe's duration
+	BarRenko                 // bricks of Size price movement
+	BarRange                 // bars closing when the range from low to high reaches Size
+	BarTick                  // bars of Size ticks
+	BarVolume                // bars of Size volume
+	BarDollar                // bars of Size traded value, price times volume
+)
+
+var barKindNames = map[BarKind]string{
+	BarTime:   "time",
+	BarRenko:  "renko",
+	BarRange:  "range",
+	BarTick:   "tick",
+	BarVolume: "volume",
+	BarDollar: "dollar",
+}
+
+func (k BarKind) String() string {
+	if name, ok := barKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("BarKind(%d)", int(k))
+}
+
+// BarType selects the bars of a timeframe, e.g. BarType{Kind: BarRenko, Size: decimal.NewFromFloat(0.001)} for
+// Renko bricks of 10 pips. The zero value selects time candles.
+type BarType struct {
+	Kind BarKind
+	Size decimal.Decimal
+}
+
+func (b BarType) String() string {
+	if b.Kind == BarTime {
+		return b.Kind.String()
+	}
+	return fmt.Sprintf("%s(%s)", b.Kind, b.Size)
+}
+
+// Validate checks that the bar type is known and has a positive size
+func (b BarType) Validate() error {
+	if _, ok := barKindNames[b.Kind]; !ok {
+		return fmt.Errorf("unknown bar type %s", b.Kind)
+	}
+	if b.Kind != BarTime && !b.Size.IsPositive() {
+		return fmt.Errorf("size of %s bars must be positive", b.Kind)
+	}
+	return nil
+}
+
+// Builder builds the candles or bars of a timeframe from ticks
+type Builder interface {
+	// Duration returns the timeframe
+	Duration() time.Duration
+
+	// AddTick adds the tick and returns the candles or bars closed by it
+	AddTick(t tick.Tick) []*OHLC
+
+	// Advance returns the candles which ended before or at now
+	Advance(now time.Time) []*OHLC
+
+	// ForceClose closes the open candle, e.g. at the end of a trading session, and returns it
+	ForceClose() *OHLC
+
+	// Snapshot returns the open candle or bar, e.g. for a checkpoint
+	Snapshot() ([]byte, error)
+
+	// Restore continues with the open candle or bar of a snapshot of a builder with the same settings
+	Restore(snapshot []byte) error
+}
+
+// NewBuilder returns an Aggregator for time candles and a bar builder for the other bar types. Bars
+// aren't aligned to time and span trading sessions; their Duration is the timeframe they replace.
+func NewBuilder(instrument string, duration time.Duration, day TradingDay, barType BarType, options ...AggregatorOption) (Builder, error) {
+	if err := barType.Validate(); err != nil {
+		return nil, err
+	}
+	switch barType.Kind {
+	case BarTime:
+		return NewAggregator(instrument, duration, day, options...), nil
+	case BarRenko:
+		return &renkoBuilder{instrument: instrument, duration: duration, size: barType.Size}, nil
+	default:
+		return &barBuilder{instrument: instrument, duration: duration, barType: barType}, nil
+	}
+}
+
+// barBuilder builds range, tick, volume and dollar bars. A bar closes with the tick which makes it
+// reach the size, so gaps and large ticks can exceed it.
+type barBuilder struct {
+	instrument string
+	duration   time.Duration
+	barType    BarType
+	bar        *OHLC
+	ticks      int64
+	value      decimal.Decimal // traded value of the open bar
+}
+
+func (b *barBuilder) Duration() time.Duration {
+	return b.duration
+}
+
+func (b *barBuilder) AddTick(t tick.Tick) []*OHLC {
+	var price, volume = t.Price(), tickVolume(t)
+	if b.bar == nil {
+		b.bar = &OHLC{Instrument: b.instrument, Start: t.Datetime, Duration: b.duration}
+	}
+	b.bar.update(price, t.Datetime, volume)
+	b.bar.End = t.Datetime
+	b.ticks++
+	b.value = b.value.Add(price.Mul(decimal.NewFromFloat(volume)))
+
+	if !b.full() {
+		return nil
+	}
+	var bar = b.bar
+	bar.closed = true
+	b.bar, b.ticks, b.value = nil, 0, decimal.Zero
+	return []*OHLC{bar}
+}
+
+func (b *barBuilder) full() bool {
+	var size = b.barType.Size
+	switch b.barType.Kind {
+	case BarRange:
+		return b.bar.High.Sub(b.bar.Low).GreaterThanOrEqual(size)
+	case BarTick:
+		return decimal.NewFromInt(b.ticks).GreaterThanOrEqual(size)
+	case BarVolume:
+		return decimal.NewFromFloat(b.bar.Volume).GreaterThanOrEqual(size)
+	case BarDollar:
+		return b.value.GreaterThanOrEqual(size)
+	}
+	return false
+}
+
+// Advance returns nothing, bars don't close by time
+func (b *barBuilder) Advance(time.Time) []*OHLC {
+	return nil
+}
+
+// ForceClose returns nothing, bars span trading sessions
+func (b *barBuilder) ForceClose() *OHLC {
+	return nil
+}
+
+// renkoBuilder builds Renko bricks. A brick in the direction of the last brick needs a move of the
+// size from its close, a reversal a move of the size from its open.
+type renkoBuilder struct {
+	instrument  string
+	duration    time.Duration
+	size        decimal.Decimal
+	started     bool
+	open, close decimal.Decimal // of the last brick, the first price until the first brick
+	direction   int             // of the last brick: 1 up, -1 down, 0 none yet
+	start       time.Time       // of the next brick
+	volume      float64         // of the next brick
+}
+
+func (r *renkoBuilder) Duration() time.Duration {
+	return r.duration
+}
+
+// AddTick returns the bricks completed by the tick, several if the price moved by several sizes
+func (r *renkoBuilder) AddTick(t tick.Tick) (bricks []*OHLC) {
+	var price = t.Price()
+	if !r.started {
+		r.open, r.close, r.start, r.started = price, price, t.Datetime, true
+	}
+	r.volume += tickVolume(t)
+
+	for {
+		var upFrom, downFrom = r.close, r.close
+		if r.direction > 0 {
+			downFrom = r.open
+		} else if r.direction < 0 {
+			upFrom = r.open
+		}
+
+		switch {
+		case price.GreaterThanOrEqual(upFrom.Add(r.size)):
+			bricks = append(bricks, r.brick(upFrom, upFrom.Add(r.size), t.Datetime))
+			r.direction = 1
+		case price.LessThanOrEqual(downFrom.Sub(r.size)):
+			bricks = append(bricks, r.brick(downFrom, downFrom.Sub(r.size), t.Datetime))
+			r.direction = -1
+		default:
+			return bricks
+		}
+	}
+}
+
+func (r *renkoBuilder) brick(open, close decimal.Decimal, end time.Time) *OHLC {
+	var brick = &OHLC{
+		Instrument:        r.instrument,
+		Open:              open,
+		High:              decimal.Max(open, close),
+		HighTime:          end,
+		Low:               decimal.Min(open, close),
+		LowTime:           end,
+		Close:             close,
+		Start:             r.start,
+		End:               end,
+		Duration:          r.duration,
+		Volume:            r.volume,
+		priceDataSeen:     true,
+		closed:            true,
+		lastReceivedPrice: end,
+	}
+	if close.GreaterThan(open) {
+		brick.LowTime = r.start
+	} else {
+		brick.HighTime = r.start
+	}
+	r.open, r.close, r.start, r.volume = open, close, end, 0
+	return brick
+}
+
+// Advance returns nothing, bricks don't close by time
+func (r *renkoBuilder) Advance(time.Time) []*OHLC {
+	return nil
+}
+
+// ForceClose returns nothing, bricks span trading sessions
+func (r *renkoBuilder) ForceClose() *OHLC {
+	return nil
+}
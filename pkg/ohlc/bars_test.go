@@ -0,0 +1,129 @@
+package ohlc
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"testing"
+	"time"
+)
+
+var barsStart = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+
+// addPrices adds ticks one second apart and returns the closed bars
+func addPrices(b Builder, volume float64, prices ...float64) (closed []*OHLC) {
+	for i, price := range prices {
+		var p = decimal.NewFromFloat(price)
+		var t = tick.New("EURUSD", barsStart.Add(time.Second*time.Duration(i)), p, p)
+		t.Volume = volume
+		closed = append(closed, b.AddTick(t)...)
+	}
+	return
+}
+
+func newBuilder(t *testing.T, kind BarKind, size float64) Builder {
+	b, err := NewBuilder("EURUSD", time.Minute, TradingDay{Location: time.UTC}, BarType{Kind: kind, Size: decimal.NewFromFloat(size)})
+	assert.NoError(t.Fatalf, err)
+	return b
+}
+
+func TestNewBuilder(t *testing.T) {
+	_, err := NewBuilder("EURUSD", time.Minute, TradingDay{Location: time.UTC}, BarType{Kind: BarRenko})
+	assert.ErrorIncludesMessage(t, "size of renko bars must be positive", err)
+
+	_, err = NewBuilder("EURUSD", time.Minute, TradingDay{Location: time.UTC}, BarType{Kind: BarKind(42), Size: decimal.NewFromInt(1)})
+	assert.ErrorIncludesMessage(t, "unknown bar type", err)
+
+	b, err := NewBuilder("EURUSD", time.Minute, TradingDay{Location: time.UTC}, BarType{})
+	assert.NoError(t, err)
+	_, isAggregator := b.(*Aggregator)
+	assert.True(t, isAggregator)
+}
+
+func TestBuilder_renko(t *testing.T) {
+	var b = newBuilder(t, BarRenko, 1)
+
+	// Two bricks up at once, a move of one brick down isn't a reversal
+	var bricks = addPrices(b, 0, 10, 10.5, 12.2, 11.5, 11)
+	assert.EqualInt(t.Fatalf, 2, len(bricks))
+	assert.EqualStrings(t, "10", bricks[0].Open.String())
+	assert.EqualStrings(t, "11", bricks[0].Close.String())
+	assert.EqualStrings(t, "11", bricks[1].Open.String())
+	assert.EqualStrings(t, "12", bricks[1].Close.String())
+	assert.EqualFloat64(t, 3, bricks[0].Volume)
+	assert.EqualFloat64(t, 0, bricks[1].Volume)
+	assert.EqualTime(t, barsStart, bricks[0].Start)
+	assert.EqualTime(t, barsStart.Add(time.Second*2), bricks[1].End)
+	assert.True(t, bricks[1].Closed())
+	assert.True(t, bricks[1].Duration == time.Minute)
+
+	// The reversal needs a move of two bricks from the close
+	bricks = addPrices(b, 0, 10)
+	assert.EqualInt(t.Fatalf, 1, len(bricks))
+	assert.EqualStrings(t, "11", bricks[0].Open.String())
+	assert.EqualStrings(t, "10", bricks[0].Close.String())
+	assert.EqualStrings(t, "11", bricks[0].High.String())
+	assert.EqualStrings(t, "10", bricks[0].Low.String())
+}
+
+func TestBuilder_range(t *testing.T) {
+	var b = newBuilder(t, BarRange, 1)
+
+	var bars = addPrices(b, 0, 10, 10.6, 9.8, 10.8, 10.2)
+	assert.EqualInt(t.Fatalf, 1, len(bars))
+	assert.EqualStrings(t, "10", bars[0].Open.String())
+	assert.EqualStrings(t, "10.8", bars[0].High.String())
+	assert.EqualStrings(t, "9.8", bars[0].Low.String())
+	assert.EqualStrings(t, "10.8", bars[0].Close.String())
+	assert.EqualFloat64(t, 4, bars[0].Volume)
+	assert.EqualTime(t, barsStart.Add(time.Second*3), bars[0].End)
+
+	// Range bars don't close by time or session
+	assert.EqualInt(t, 0, len(b.Advance(barsStart.Add(time.Hour))))
+	assert.True(t, b.ForceClose() == nil)
+}
+
+func TestBuilder_ticks(t *testing.T) {
+	var b = newBuilder(t, BarTick, 3)
+
+	var bars = addPrices(b, 0, 1, 2, 3, 4, 5, 6, 7)
+	assert.EqualInt(t.Fatalf, 2, len(bars))
+	assert.EqualStrings(t, "1", bars[0].Open.String())
+	assert.EqualStrings(t, "3", bars[0].Close.String())
+	assert.EqualStrings(t, "4", bars[1].Open.String())
+	assert.EqualStrings(t, "6", bars[1].Close.String())
+	assert.EqualTime(t, barsStart.Add(time.Second*3), bars[1].Start)
+}
+
+func TestBuilder_volume(t *testing.T) {
+	var b = newBuilder(t, BarVolume, 100)
+
+	var bars = addPrices(b, 40, 1, 2, 3, 4, 5)
+	assert.EqualInt(t.Fatalf, 1, len(bars))
+	assert.EqualFloat64(t, 120, bars[0].Volume)
+	assert.EqualStrings(t, "3", bars[0].Close.String())
+
+	// Ticks without volume count as one
+	b = newBuilder(t, BarVolume, 2)
+	assert.EqualInt(t, 2, len(addPrices(b, 0, 1, 2, 3, 4, 5)))
+}
+
+func TestBuilder_dollar(t *testing.T) {
+	var b = newBuilder(t, BarDollar, 1000)
+
+	var bars = addPrices(b, 100, 2, 3, 6, 1)
+	assert.EqualInt(t.Fatalf, 1, len(bars))
+	assert.EqualStrings(t, "6", bars[0].Close.String())
+	assert.EqualFloat64(t, 300, bars[0].Volume)
+}
+
+func TestAggregator_addTick(t *testing.T) {
+	var a = NewAggregator("EURUSD", time.Minute, TradingDay{Location: time.UTC})
+
+	addPrices(a, 2.5, 1, 2, 3)
+	var closed = addPrices(a, 0, 4)
+	assert.EqualInt(t.Fatalf, 0, len(closed))
+	closed = a.Advance(barsStart.Add(time.Minute))
+	assert.EqualInt(t.Fatalf, 1, len(closed))
+	assert.EqualFloat64(t, 8.5, closed[0].Volume)
+}
@@ -25,7 +25,9 @@ type OHLC struct {
 	Start             time.Time       `gorm:"index"`
 	End               time.Time
 	Duration          time.Duration `gorm:"index"`
+	Volume            float64       `gorm:"default:0"` // number of ticks unless given by the source
 	Gaps              bool
+	Flat              bool // no prices in the candle's period, all prices are the previous close
 	priceDataSeen     bool
 	closed            bool
 	lastReceivedPrice time.Time
@@ -63,6 +65,23 @@ func (o *OHLC) Closed() bool {
 // Returns true if data was considered
 // Returns false if the candle is already closed.
 func (o *OHLC) NewPrice(price decimal.Decimal, now time.Time) bool {
+	return o.newPrice(price, now, 1)
+}
+
+// NewTick handles the tick's price like NewPrice and adds its volume
+func (o *OHLC) NewTick(t tick.Tick) bool {
+	return o.newPrice(t.Price(), t.Datetime, tickVolume(t))
+}
+
+// tickVolume returns the volume of the tick. Ticks without volume count as one.
+func tickVolume(t tick.Tick) float64 {
+	if t.Volume > 0 {
+		return t.Volume
+	}
+	return 1
+}
+
+func (o *OHLC) newPrice(price decimal.Decimal, now time.Time, volume float64) bool {
 	if o.Closed() {
 		return false
 	}
@@ -72,6 +91,12 @@ func (o *OHLC) NewPrice(price decimal.Decimal, now time.Time) bool {
 		return false
 	}
 
+	o.update(price, now, volume)
+	return true
+}
+
+// update adds the price and its volume without checking the candle's end
+func (o *OHLC) update(price decimal.Decimal, now time.Time, volume float64) {
 	if price.GreaterThan(o.High) {
 		o.High = price
 		o.HighTime = now
@@ -97,9 +122,41 @@ func (o *OHLC) NewPrice(price decimal.Decimal, now time.Time) bool {
 
 	o.lastReceivedPrice = now
 	o.Close = price
+	o.Volume += volume
 	o.priceDataSeen = true
+}
 
-	return true
+// merge adds the prices and volume of a shorter, later candle
+func (o *OHLC) merge(c *OHLC) {
+	var highTime, lowTime = c.HighTime, c.LowTime
+	if highTime.IsZero() {
+		highTime = c.Start
+	}
+	if lowTime.IsZero() {
+		lowTime = c.Start
+	}
+
+	if !o.priceDataSeen {
+		o.Open = c.Open
+		o.High, o.HighTime = c.High, highTime
+		o.Low, o.LowTime = c.Low, lowTime
+	} else {
+		if c.High.GreaterThan(o.High) {
+			o.High, o.HighTime = c.High, highTime
+		}
+		if c.Low.LessThan(o.Low) {
+			o.Low, o.LowTime = c.Low, lowTime
+		}
+		if c.Start.Sub(o.lastReceivedPrice).Seconds() > maxGapBetweenTicksInSeconds {
+			o.Gaps = true
+		}
+	}
+
+	o.Gaps = o.Gaps || c.Gaps
+	o.lastReceivedPrice = c.End
+	o.Close = c.Close
+	o.Volume += c.Volume
+	o.priceDataSeen = true
 }
 
 func (o *OHLC) HasGaps() bool {
@@ -220,12 +277,15 @@ func (o *OHLC) ToTicks() []tick.Tick {
 	}
 	ticks = append(ticks, o.CloseTick())
 
+	for i := range ticks {
+		ticks[i].Volume = o.Volume / float64(len(ticks))
+	}
 	return ticks
 }
 
-// round ts to the closest period
+// round ts to the start of the period, aligned to midnight in the location of ts
 func smoothCandleStart(ts time.Time, period time.Duration) time.Time {
-	return time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute()/int(period.Minutes())*int(period.Minutes()), 0, 0, ts.Location())
+	return TradingDay{Location: ts.Location()}.CandleStart(ts, period)
 }
 
 // ToHeikinAshi calculates a Heikin Ashi candle from two OHLC candles
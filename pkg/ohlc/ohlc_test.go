@@ -3,6 +3,7 @@ package ohlc
 import (
 	"github.com/AMekss/assert"
 	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
 	"sort"
 	"testing"
 	"time"
@@ -193,6 +194,22 @@ func TestOHLC_NewPrice(t *testing.T) {
 	assert.False(t, considered)
 }
 
+func TestOHLC_NewTick(t *testing.T) {
+	var now = time.Now()
+	var o = New("abc", now, time.Hour, false)
+	var price = decimal.NewFromFloat(1)
+
+	var withVolume = tick.New("abc", now, price, price)
+	withVolume.Volume = 2.5
+	assert.True(t, o.NewTick(withVolume))
+	assert.True(t, o.NewTick(tick.New("abc", now.Add(time.Second), price, price)))
+	assert.True(t, o.NewPrice(price, now.Add(time.Second*2)))
+
+	// Ticks and prices without volume count as one
+	assert.EqualFloat64(t, 4.5, o.Volume)
+	assert.False(t, o.NewTick(tick.New("abc", o.End, price, price)))
+}
+
 func TestOHLC_NewPrice_with_Gaps(t *testing.T) {
 	var o = New("abc", time.Now(), time.Hour, false)
 	now := time.Now()
@@ -318,6 +335,7 @@ func Test__ToTicks(t *testing.T) {
 	assert.EqualTime(t, ticks[1].Datetime, lowTime)
 	assert.EqualTime(t, ticks[2].Datetime, highTime)
 	assert.EqualTime(t, ticks[3].Datetime, closeTime)
+	assert.EqualFloat64(t, 1, ticks[3].Volume) // 4 prices, split across 4 ticks
 }
 
 func TestOHLC__Sort(t *testing.T) {
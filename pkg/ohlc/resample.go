@@ -0,0 +1,38 @@
+package ohlc
+
+import (
+	"fmt"
+	"time"
+)
+
+// Resample aggregates time-ordered candles of a shorter duration, e.g. 1m, to candles of the given
+// duration aligned like the candles of the trader. Open and close are taken from the first and the
+// last candle, high and low with their times from the extremes, volumes are summed up. A last
+// candle which isn't complete yet is left out.
+func Resample(candles OHLCList, duration time.Duration, day TradingDay, options ...AggregatorOption) (OHLCList, error) {
+	if len(candles) == 0 {
+		return nil, nil
+	}
+
+	var aggregator = NewAggregator(candles[0].Instrument, duration, day, options...)
+	var resampled OHLCList
+	var add = func(closed []*OHLC) {
+		for _, candle := range closed {
+			resampled = append(resampled, *candle)
+		}
+	}
+
+	for i := range candles {
+		var candle = &candles[i]
+		if candle.Duration > duration {
+			return nil, fmt.Errorf("cannot resample %s candles to %s", candle.Duration, duration)
+		}
+		if i > 0 && candle.Start.Before(candles[i-1].Start) {
+			return nil, fmt.Errorf("candles aren't ordered by time at %s", candle.Start)
+		}
+		add(aggregator.AddCandle(candle))
+	}
+	add(aggregator.Advance(candles[len(candles)-1].End))
+
+	return resampled, nil
+}
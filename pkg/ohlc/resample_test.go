@@ -0,0 +1,67 @@
+package ohlc
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+func newMinuteCandle(start time.Time, open, high, low, close float64) OHLC {
+	return OHLC{
+		Instrument: "EURUSD",
+		Open:       decimal.NewFromFloat(open),
+		High:       decimal.NewFromFloat(high),
+		HighTime:   start.Add(time.Second * 10),
+		Low:        decimal.NewFromFloat(low),
+		LowTime:    start.Add(time.Second * 20),
+		Close:      decimal.NewFromFloat(close),
+		Start:      start,
+		End:        start.Add(time.Minute),
+		Duration:   time.Minute,
+		Volume:     10,
+	}
+}
+
+func TestResample(t *testing.T) {
+	var day = TradingDay{Location: time.UTC}
+	var start = time.Date(2021, 1, 4, 9, 0, 0, 0, time.UTC)
+	var candles = OHLCList{
+		newMinuteCandle(start.Add(time.Minute*13), 1.0, 1.2, 0.9, 1.1),
+		newMinuteCandle(start.Add(time.Minute*14), 1.1, 1.3, 1.0, 1.2),
+		newMinuteCandle(start.Add(time.Minute*15), 1.2, 1.5, 1.1, 1.4),
+		newMinuteCandle(start.Add(time.Minute*16), 1.4, 1.4, 0.8, 1.3),
+		newMinuteCandle(start.Add(time.Minute*29), 1.3, 1.3, 1.2, 1.2),
+		newMinuteCandle(start.Add(time.Minute*30), 1.2, 1.3, 1.1, 1.2),
+	}
+
+	resampled, err := Resample(candles, time.Minute*15, day)
+	assert.NoError(t.Fatalf, err)
+	assert.EqualInt(t.Fatalf, 2, len(resampled))
+
+	var first = resampled[0]
+	assert.EqualTime(t, start, first.Start)
+	assert.EqualTime(t, start.Add(time.Minute*15), first.End)
+	assertDecimal(t, decimal.NewFromFloat(1.0), first.Open)
+	assertDecimal(t, decimal.NewFromFloat(1.3), first.High)
+	assert.EqualTime(t, start.Add(time.Minute*14+time.Second*10), first.HighTime)
+	assertDecimal(t, decimal.NewFromFloat(0.9), first.Low)
+	assertDecimal(t, decimal.NewFromFloat(1.2), first.Close)
+	assert.EqualFloat64(t, 20, first.Volume)
+	assert.True(t, first.Closed())
+	assert.True(t, !first.HasGaps())
+
+	var second = resampled[1]
+	assertDecimal(t, decimal.NewFromFloat(1.2), second.Open)
+	assertDecimal(t, decimal.NewFromFloat(1.5), second.High)
+	assertDecimal(t, decimal.NewFromFloat(0.8), second.Low)
+	assert.EqualTime(t, start.Add(time.Minute*16+time.Second*20), second.LowTime)
+	assertDecimal(t, decimal.NewFromFloat(1.2), second.Close)
+	assert.EqualFloat64(t, 30, second.Volume)
+	assert.True(t, second.HasGaps())
+
+	_, err = Resample(candles, time.Second*30, day)
+	assert.ErrorIncludesMessage(t, "cannot resample 1m0s candles to 30s", err)
+	_, err = Resample(OHLCList{candles[1], candles[0]}, time.Hour, day)
+	assert.ErrorIncludesMessage(t, "aren't ordered", err)
+}
@@ -0,0 +1,96 @@
+package ohlc
+
+import (
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// CandleState is a candle including its unexported state, e.g. to save it in a checkpoint
+type CandleState struct {
+	OHLC
+	PriceDataSeen     bool
+	Closed            bool
+	LastReceivedPrice time.Time
+}
+
+// StateOf returns the state of the candle, nil for nil
+func StateOf(o *OHLC) *CandleState {
+	if o == nil {
+		return nil
+	}
+	return &CandleState{OHLC: *o, PriceDataSeen: o.priceDataSeen, Closed: o.closed, LastReceivedPrice: o.lastReceivedPrice}
+}
+
+// Candle returns the candle of the state, nil for nil
+func (s *CandleState) Candle() *OHLC {
+	if s == nil {
+		return nil
+	}
+	var o = s.OHLC
+	o.priceDataSeen, o.closed, o.lastReceivedPrice = s.PriceDataSeen, s.Closed, s.LastReceivedPrice
+	return &o
+}
+
+type aggregatorSnapshot struct {
+	Candle    *CandleState
+	Next      time.Time
+	LastClose decimal.Decimal
+	Fillable  bool
+}
+
+// Snapshot returns the open candle and the state needed for the following candles
+func (a *Aggregator) Snapshot() ([]byte, error) {
+	return json.Marshal(aggregatorSnapshot{Candle: StateOf(a.candle), Next: a.next, LastClose: a.lastClose, Fillable: a.fillable})
+}
+
+// Restore continues with the open candle of a snapshot of an aggregator with the same settings
+func (a *Aggregator) Restore(snapshot []byte) error {
+	var s aggregatorSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	a.candle, a.next, a.lastClose, a.fillable = s.Candle.Candle(), s.Next, s.LastClose, s.Fillable
+	return nil
+}
+
+type barBuilderSnapshot struct {
+	Bar   *CandleState
+	Ticks int64
+	Value decimal.Decimal
+}
+
+func (b *barBuilder) Snapshot() ([]byte, error) {
+	return json.Marshal(barBuilderSnapshot{Bar: StateOf(b.bar), Ticks: b.ticks, Value: b.value})
+}
+
+func (b *barBuilder) Restore(snapshot []byte) error {
+	var s barBuilderSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	b.bar, b.ticks, b.value = s.Bar.Candle(), s.Ticks, s.Value
+	return nil
+}
+
+type renkoBuilderSnapshot struct {
+	Started     bool
+	Open, Close decimal.Decimal
+	Direction   int
+	Start       time.Time
+	Volume      float64
+}
+
+func (r *renkoBuilder) Snapshot() ([]byte, error) {
+	return json.Marshal(renkoBuilderSnapshot{Started: r.started, Open: r.open, Close: r.close,
+		Direction: r.direction, Start: r.start, Volume: r.volume})
+}
+
+func (r *renkoBuilder) Restore(snapshot []byte) error {
+	var s renkoBuilderSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	r.started, r.open, r.close, r.direction, r.start, r.volume = s.Started, s.Open, s.Close, s.Direction, s.Start, s.Volume
+	return nil
+}
@@ -0,0 +1,55 @@
+package ohlc
+
+import (
+	"github.com/AMekss/assert"
+	"github.com/shopspring/decimal"
+	"github.com/sklinkert/at/pkg/tick"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestBuilder_Snapshot restores each builder from a snapshot with an open candle or bar and checks
+// that it closes the same candles as the original
+func TestBuilder_Snapshot(t *testing.T) {
+	var tests = []struct {
+		kind BarKind
+		size float64
+	}{
+		{BarTime, 0},
+		{BarRenko, 0.002},
+		{BarRange, 0.003},
+		{BarTick, 7},
+		{BarVolume, 20},
+		{BarDollar, 25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.kind.String(), func(t *testing.T) {
+			var original, restored = newBuilder(t, test.kind, test.size), newBuilder(t, test.kind, test.size)
+			var add = func(b Builder, from, to int) (closed []*OHLC) {
+				for i := from; i < to; i++ {
+					var price = decimal.NewFromFloat(1 + math.Sin(float64(i)/5)/100).Round(5)
+					var currentTick = tick.New("EURUSD", barsStart.Add(time.Second*20*time.Duration(i)), price, price)
+					currentTick.Volume = 3
+					closed = append(closed, b.AddTick(currentTick)...)
+				}
+				return closed
+			}
+
+			add(original, 0, 50)
+			snapshot, err := original.Snapshot()
+			assert.NoError(t.Fatalf, err)
+			assert.NoError(t.Fatalf, restored.Restore(snapshot))
+
+			var want, got = add(original, 50, 100), add(restored, 50, 100)
+			assert.True(t.Fatalf, len(want) > 0)
+			assert.EqualInt(t.Fatalf, len(want), len(got))
+			for i := range want {
+				assert.EqualStrings(t, want[i].String(), got[i].String())
+				assert.EqualFloat64(t, want[i].Volume, got[i].Volume)
+				assert.True(t, got[i].Closed())
+			}
+		})
+	}
+}
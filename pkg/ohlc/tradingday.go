@@ -0,0 +1,120 @@
+package ohlc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimezone = "Europe/Berlin"
+	dayFormat       = "2006-01-02"
+	day             = time.Hour * 24
+)
+
+// TradingDay is the timezone of candles, reports and charts and the time of day at which a trading
+// day begins, e.g. 17:00 in New York for forex or midnight UTC for crypto currencies.
+type TradingDay struct {
+	Location *time.Location
+	Start    time.Duration // time of day, a day beginning in the evening is named after the next date
+}
+
+// NewTradingDay returns the trading day in the timezone beginning at start, e.g. "17:00". An empty
+// start is midnight.
+func NewTradingDay(timezone, start string) (TradingDay, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return TradingDay{}, err
+	}
+	var day = TradingDay{Location: loc}
+	if start = strings.TrimSpace(start); start != "" {
+		t, err := time.Parse("15:04", start)
+		if err != nil {
+			return TradingDay{}, fmt.Errorf("invalid start of day %q", start)
+		}
+		day.Start = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return day, nil
+}
+
+// DefaultTradingDay begins at midnight in Berlin
+func DefaultTradingDay() TradingDay {
+	day, err := NewTradingDay(DefaultTimezone, "")
+	if err != nil {
+		return TradingDay{Location: time.UTC}
+	}
+	return day
+}
+
+// Begin returns the beginning of the trading day which contains t
+func (d TradingDay) Begin(t time.Time) time.Time {
+	var local = t.In(d.Location)
+	var y, m, day = local.Date()
+	var begin = time.Date(y, m, day, int(d.Start.Hours()), int(d.Start.Minutes())%60, 0, 0, d.Location)
+	if begin.After(local) {
+		begin = time.Date(y, m, day-1, int(d.Start.Hours()), int(d.Start.Minutes())%60, 0, 0, d.Location)
+	}
+	return begin
+}
+
+// Key returns the date of the trading day which contains t, e.g. "2021-01-05"
+func (d TradingDay) Key(t time.Time) string {
+	var begin = d.Begin(t)
+	if d.Start > 0 {
+		begin = begin.AddDate(0, 0, 1)
+	}
+	return begin.Format(dayFormat)
+}
+
+// SameDay tells if a and b are in the same trading day
+func (d TradingDay) SameDay(a, b time.Time) bool {
+	return d.Begin(a).Equal(d.Begin(b))
+}
+
+// CandleStart returns the start of the candle which contains t. Candles of durations which divide
+// a day, e.g. 15m, 90m or 4h, are aligned to the beginning of the trading day. Longer or odd
+// durations are aligned to the beginning of the trading week.
+func (d TradingDay) CandleStart(t time.Time, duration time.Duration) time.Time {
+	t = t.In(d.Location)
+	if !dividesDay(duration) {
+		return AnchoredCandleStart(t, d.weekBegin(), duration)
+	}
+	var begin = d.Begin(t)
+	if duration == day {
+		return begin
+	}
+	return begin.Add(t.Sub(begin).Truncate(duration))
+}
+
+// CandleEnd returns the end of the candle beginning at start. Candles aligned to the trading day
+// end with the day, also on days which are shorter or longer due to daylight saving time.
+func (d TradingDay) CandleEnd(start time.Time, duration time.Duration) time.Time {
+	var end = start.Add(duration)
+	if !dividesDay(duration) {
+		return end
+	}
+	var nextDay = d.Begin(d.Begin(start).Add(day + time.Hour*2))
+	if duration == day || end.After(nextDay) {
+		return nextDay
+	}
+	return end
+}
+
+// weekBegin returns the beginning of the trading day Monday, 1st of January 2001
+func (d TradingDay) weekBegin() time.Time {
+	return d.Begin(time.Date(2001, 1, 1, 0, 0, 0, 0, d.Location))
+}
+
+func dividesDay(duration time.Duration) bool {
+	return duration > 0 && duration <= day && day%duration == 0
+}
+
+// AnchoredCandleStart returns the start of the candle which contains t for candles beginning at
+// anchor, e.g. the opening of an exchange, and every duration before and after it
+func AnchoredCandleStart(t, anchor time.Time, duration time.Duration) time.Time {
+	var offset = t.Sub(anchor) % duration
+	if offset < 0 {
+		offset += duration
+	}
+	return t.Add(-offset).In(t.Location())
+}
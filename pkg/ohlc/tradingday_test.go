@@ -0,0 +1,48 @@
+package ohlc
+
+import (
+	"github.com/AMekss/assert"
+	"testing"
+	"time"
+)
+
+func TestTradingDay(t *testing.T) {
+	day, err := NewTradingDay("America/New_York", "17:00")
+	assert.NoError(t.Fatalf, err)
+
+	// 16:59 and 17:00 in New York
+	var before = time.Date(2021, 1, 4, 21, 59, 0, 0, time.UTC)
+	var after = before.Add(time.Minute)
+	assert.EqualStrings(t, "2021-01-04", day.Key(before))
+	assert.EqualStrings(t, "2021-01-05", day.Key(after))
+	assert.True(t, !day.SameDay(before, after))
+	assert.True(t, day.SameDay(after, after.Add(time.Hour*23)))
+	assert.EqualTime(t, time.Date(2021, 1, 3, 22, 0, 0, 0, time.UTC), day.Begin(before).UTC())
+
+	// Daily and 4h candles begin at 17:00, short candles at full minutes
+	assert.EqualTime(t, after, day.CandleStart(after.Add(time.Hour*5), time.Hour*24).UTC())
+	assert.EqualTime(t, after.Add(time.Hour*4), day.CandleStart(after.Add(time.Hour*5), time.Hour*4).UTC())
+	assert.EqualTime(t, after.Add(time.Minute*15), day.CandleStart(after.Add(time.Minute*20), time.Minute*15).UTC())
+
+	utc, err := NewTradingDay("UTC", "")
+	assert.NoError(t.Fatalf, err)
+	assert.EqualStrings(t, "2021-01-04", utc.Key(before))
+	assert.EqualTime(t, time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), utc.CandleStart(before, time.Hour*24))
+
+	// 90m candles divide the day, 7h and weekly candles are aligned to Monday
+	assert.EqualTime(t, time.Date(2021, 1, 4, 3, 0, 0, 0, time.UTC), utc.CandleStart(before.Add(-time.Hour*18), time.Minute*90))
+	assert.EqualTime(t, time.Date(2021, 1, 4, 21, 0, 0, 0, time.UTC), utc.CandleStart(before, time.Hour*7))
+	assert.EqualTime(t, time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC), utc.CandleStart(before.AddDate(0, 0, 3), time.Hour*24*7))
+	assert.EqualTime(t, time.Date(2021, 1, 3, 22, 0, 0, 0, time.UTC), day.CandleStart(before.AddDate(0, 0, 3), time.Hour*24*7).UTC())
+
+	// Daylight saving time ends on 31st of October 2021 in Berlin, the day has 25 hours
+	berlin := DefaultTradingDay()
+	var lastDay = time.Date(2021, 10, 31, 0, 0, 0, 0, berlin.Location)
+	assert.EqualTime(t, lastDay, berlin.CandleStart(lastDay.Add(time.Hour*24+time.Minute), time.Hour*24))
+	assert.EqualTime(t, lastDay.AddDate(0, 0, 1), berlin.CandleEnd(lastDay, time.Hour*24))
+	assert.EqualTime(t, lastDay.AddDate(0, 0, 1), berlin.CandleEnd(lastDay.Add(time.Hour*24), time.Hour*4))
+
+	_, err = NewTradingDay("UTC", "5pm")
+	assert.ErrorIncludesMessage(t, "invalid start of day", err)
+	assert.EqualStrings(t, DefaultTimezone, DefaultTradingDay().Location.String())
+}
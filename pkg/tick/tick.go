@@ -15,6 +15,7 @@ type Tick struct {
 	Instrument string          `gorm:"index"`
 	Bid        decimal.Decimal `gorm:"type:decimal(13,6);"`
 	Ask        decimal.Decimal `gorm:"type:decimal(13,6);"`
+	Volume     float64         `gorm:"default:0"` // traded volume, zero if unknown
 	price      decimal.Decimal `gorm:"-"`
 }
 
@@ -28,6 +29,7 @@ func New(instrument string, datetime time.Time, bid, ask decimal.Decimal) Tick {
 		instrument,
 		bid,
 		ask,
+		0,
 		bid.Add(ask).Div(dec2),
 	}
 }
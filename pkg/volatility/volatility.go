@@ -1,17 +1,18 @@
 package volatility
 
 import (
+	"encoding/json"
+	"github.com/sklinkert/at/pkg/indicator"
 	"github.com/sklinkert/at/pkg/ohlc"
-	"github.com/sklinkert/circularbuffer"
 )
 
 type Volatility struct {
-	cb *circularbuffer.CircularBuffer
+	cb *indicator.Buffer
 }
 
 func New(minSize, maxSize int) *Volatility {
 	return &Volatility{
-		cb: circularbuffer.New(minSize, maxSize),
+		cb: indicator.NewBuffer(minSize, maxSize),
 	}
 }
 
@@ -31,3 +32,15 @@ func (v *Volatility) MedianVolatilityInPercentage() (float64, error) {
 func (v *Volatility) VolatilityInPercentageQuantile(quantile float64) (float64, error) {
 	return v.cb.Quantile(quantile)
 }
+
+func (v *Volatility) Snapshot() ([]byte, error) {
+	return json.Marshal(v.cb.Snapshot())
+}
+
+func (v *Volatility) Restore(snapshot []byte) error {
+	var s indicator.BufferSnapshot
+	if err := json.Unmarshal(snapshot, &s); err != nil {
+		return err
+	}
+	return v.cb.Restore(s)
+}